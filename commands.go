@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/metrics"
+	"ipssl-client/internal/notify"
+	"ipssl-client/internal/report"
+	"ipssl-client/internal/state"
+	"ipssl-client/internal/support"
+)
+
+// runReport implements the report command: it prints the inventory report or,
+// with -send, emails it to the configured recipients
+func runReport(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	format := flags.String("format", report.FormatText, "output format: text, json, html or ics")
+	send := flags.Bool("send", false, "email the report to IPSSL_REPORT_TO instead of printing it")
+	certificate := flags.String("certificate", "", certificateUsage)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := forCertificate(cfg, *certificate)
+	if err != nil {
+		return err
+	}
+
+	st, err := state.Load(filepath.Join(cfg.StateDir, state.FileName))
+	if err != nil {
+		return err
+	}
+	r := report.Build(cfg, st, time.Now())
+
+	if !*send {
+		return report.Render(os.Stdout, r, *format)
+	}
+
+	var body bytes.Buffer
+	if err := report.Render(&body, r, *format); err != nil {
+		return err
+	}
+	email, err := notify.NewEmail(cfg.SMTP)
+	if err != nil {
+		return err
+	}
+	return email.Send(cfg.Report.Recipients, r.Subject(), report.ContentType(*format), body.Bytes())
+}
+
+// certificateUsage describes the -certificate flag of the commands acting on the state of a certificate
+const certificateUsage = "certificate set to act on (required with IPSSL_CERTIFICATES or IPSSL_CERTIFICATE_PER_IP)"
+
+// forCertificate returns the configuration of the named certificate set, whose
+// client keeps its state in a directory of its own, or cfg itself for a single
+// certificate
+func forCertificate(cfg *config.Config, name string) (*config.Config, error) {
+	if len(cfg.Certificates) == 0 {
+		if name != "" {
+			return nil, errors.New("-certificate requires IPSSL_CERTIFICATES or IPSSL_CERTIFICATE_PER_IP")
+		}
+		return cfg, nil
+	}
+	names := make([]string, 0, len(cfg.Certificates))
+	for _, set := range cfg.Certificates {
+		if set.Name == name {
+			return cfg.ForCertificate(set), nil
+		}
+		names = append(names, set.Name)
+	}
+	if name == "" {
+		return nil, fmt.Errorf("-certificate is required with several certificates (one of %s)", strings.Join(names, ", "))
+	}
+	return nil, fmt.Errorf("unknown certificate %q (expected one of %s)", name, strings.Join(names, ", "))
+}
+
+// runDashboard implements the dashboard command: it prints a Grafana dashboard
+// for the exported Prometheus metrics
+func runDashboard(args []string) error {
+	flags := flag.NewFlagSet("dashboard", flag.ContinueOnError)
+	title := flags.String("title", "IPSSL certificates", "dashboard title")
+	labels := flags.String("labels", "job,instance", "comma-separated labels exposed as dashboard variables")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var labelList []string
+	for _, label := range strings.Split(*labels, ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			labelList = append(labelList, label)
+		}
+	}
+
+	dashboard, err := metrics.Dashboard(metrics.DashboardOptions{Title: *title, Labels: labelList})
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(append(dashboard, '\n'))
+	return err
+}
+
+// runFreeze implements the freeze command: it suspends renewal and deployment
+// of the certificate for -ttl and records the reason in the audit log
+func runFreeze(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("freeze", flag.ContinueOnError)
+	ttl := flags.Duration("ttl", 24*time.Hour, "how long the freeze lasts")
+	reason := flags.String("reason", "", "why renewals are frozen (required)")
+	certificate := flags.String("certificate", "", certificateUsage)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := forCertificate(cfg, *certificate)
+	if err != nil {
+		return err
+	}
+	if *reason == "" {
+		return errors.New("-reason is required")
+	}
+	if *ttl <= 0 {
+		return fmt.Errorf("invalid -ttl %s (expected a positive duration)", *ttl)
+	}
+
+	// The state directory of a certificate set is created by its client, which may not have run yet
+	if err := os.MkdirAll(cfg.StateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	now := time.Now()
+	freeze := &state.Freeze{Since: now, Until: now.Add(*ttl), Reason: *reason}
+	if err := state.Update(filepath.Join(cfg.StateDir, state.FileName), func(st *state.State) {
+		st.Freeze = freeze
+	}); err != nil {
+		return err
+	}
+	if err := state.AppendAudit(filepath.Join(cfg.StateDir, state.AuditFileName), state.AuditEvent{
+		Time:   now,
+		Action: state.AuditFreeze,
+		Reason: *reason,
+		Until:  freeze.Until,
+	}); err != nil {
+		return err
+	}
+	fmt.Printf("Renewal frozen until %s: %s\n", freeze.Until.Format(time.RFC3339), *reason)
+	return nil
+}
+
+// runUnfreeze implements the unfreeze command: it lifts an active freeze early
+func runUnfreeze(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("unfreeze", flag.ContinueOnError)
+	reason := flags.String("reason", "", "why the freeze is lifted")
+	certificate := flags.String("certificate", "", certificateUsage)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := forCertificate(cfg, *certificate)
+	if err != nil {
+		return err
+	}
+
+	frozen := false
+	if err := state.Update(filepath.Join(cfg.StateDir, state.FileName), func(st *state.State) {
+		if frozen = st.ActiveFreeze(time.Now()) != nil; frozen {
+			st.Freeze = nil
+		}
+	}); err != nil {
+		return err
+	}
+	if !frozen {
+		return errors.New("renewal is not frozen")
+	}
+	if err := state.AppendAudit(filepath.Join(cfg.StateDir, state.AuditFileName), state.AuditEvent{
+		Time:   time.Now(),
+		Action: state.AuditUnfreeze,
+		Reason: *reason,
+	}); err != nil {
+		return err
+	}
+	fmt.Println("Renewal unfrozen")
+	return nil
+}
+
+// runHistory implements the history command. Its prune subcommand applies the
+// retention from -keep and -max-age, defaulting to IPSSL_HISTORY_KEEP and
+// IPSSL_HISTORY_MAX_AGE, to the audit log and the state file.
+func runHistory(cfg *config.Config, args []string) error {
+	if len(args) == 0 || args[0] != "prune" {
+		return errors.New("usage: history prune [-keep N] [-max-age DURATION]")
+	}
+
+	flags := flag.NewFlagSet("history prune", flag.ContinueOnError)
+	keep := flags.Int("keep", cfg.HistoryKeep, "number of newest audit log entries to keep (0 for no limit)")
+	maxAge := flags.Duration("max-age", cfg.HistoryMaxAge, "drop entries older than this (0 for no limit)")
+	certificate := flags.String("certificate", "", certificateUsage)
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	cfg, err := forCertificate(cfg, *certificate)
+	if err != nil {
+		return err
+	}
+	if *keep < 0 || *maxAge < 0 {
+		return errors.New("-keep and -max-age must not be negative")
+	}
+
+	retention := state.Retention{Keep: *keep, MaxAge: *maxAge}
+	if !retention.Enabled() {
+		return errors.New("nothing to prune: set -keep or -max-age")
+	}
+	result, err := state.Prune(cfg.StateDir, retention, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Pruned %d audit log entries, %d certificates and %d deployment targets\n",
+		result.AuditEntries, result.Certificates, result.Targets)
+	return nil
+}
+
+// runConfig implements the config command. Its schema subcommand prints the
+// JSON Schema of the configuration variables; validate checks a dotenv file,
+// or the environment when none is given, and prints every problem found.
+func runConfig(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: config schema | config validate [FILE]")
+	}
+
+	switch args[0] {
+	case "schema":
+		schema, err := config.Schema()
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(append(schema, '\n'))
+		return err
+	case "validate":
+		source := "the environment"
+		vars := make(map[string]string)
+		if len(args) > 1 {
+			source = args[1]
+			read, err := godotenv.Read(args[1])
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", args[1], err)
+			}
+			vars = read
+		} else {
+			for _, entry := range os.Environ() {
+				if key, value, ok := strings.Cut(entry, "="); ok {
+					vars[key] = value
+				}
+			}
+		}
+
+		errs := config.Validate(vars)
+		if len(errs) == 0 {
+			fmt.Printf("Configuration in %s is valid\n", source)
+			return nil
+		}
+		for _, err := range errs {
+			fmt.Println(err)
+		}
+		return fmt.Errorf("%d problems found in %s", len(errs), source)
+	default:
+		return fmt.Errorf("unknown config subcommand %q (expected schema or validate)", args[0])
+	}
+}
+
+// runSupportBundle implements the support-bundle command: it writes an archive
+// with the redacted configuration, versions, state, last failure report and
+// recent logs to attach to bug reports. It runs even when the configuration
+// does not load, so that the problems can be reported.
+func runSupportBundle(args []string) error {
+	flags := flag.NewFlagSet("support-bundle", flag.ContinueOnError)
+	now := time.Now()
+	output := flags.String("o", "ipssl-support-"+now.UTC().Format("20060102T150405Z")+".tar.gz", "archive to write")
+	logs := flags.String("logs", "", "log file to include, - for standard input (e.g. docker logs ipssl-client 2>&1 | ipssl-client support-bundle -logs -)")
+	lines := flags.Int("lines", support.DefaultLogLines, "number of trailing log and audit log lines to include")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	vars := make(map[string]string)
+	for _, entry := range os.Environ() {
+		if key, value, ok := strings.Cut(entry, "="); ok {
+			vars[key] = value
+		}
+	}
+	opts := support.Options{Problems: config.Validate(vars), Secrets: config.SecretValues(vars), LogLines: *lines, Now: now}
+	if cfg, err := config.Load(); err == nil {
+		opts.Config = cfg
+	}
+
+	switch *logs {
+	case "":
+	case "-":
+		opts.Logs = os.Stdin
+	default:
+		file, err := os.Open(*logs)
+		if err != nil {
+			return fmt.Errorf("failed to open logs: %w", err)
+		}
+		defer file.Close()
+		opts.Logs = file
+	}
+
+	file, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create support bundle: %w", err)
+	}
+	if err := support.Write(file, opts); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write support bundle: %w", err)
+	}
+	fmt.Printf("Support bundle written to %s; review it before attaching it to an issue\n", *output)
+	return nil
+}
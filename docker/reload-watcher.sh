@@ -0,0 +1,31 @@
+#!/bin/sh
+# Watches the trigger file written by ipssl-client (IPSSL_RELOAD_STRATEGY=file)
+# and runs a reload command inside this container whenever it changes. Use it
+# where the Docker socket cannot be mounted into ipssl-client.
+#
+#   RELOAD_FILE      trigger file on the shared volume (default /ipssl/reload.trigger)
+#   RELOAD_COMMAND   command to run on change (default: caddy reload)
+#   RELOAD_INTERVAL  polling interval in seconds (default 5)
+set -u
+
+RELOAD_FILE="${RELOAD_FILE:-/ipssl/reload.trigger}"
+RELOAD_COMMAND="${RELOAD_COMMAND:-caddy reload --config /etc/caddy/Caddyfile --adapter caddyfile}"
+RELOAD_INTERVAL="${RELOAD_INTERVAL:-5}"
+
+# Fingerprint of the trigger file; empty while it does not exist
+fingerprint() {
+	[ -f "$RELOAD_FILE" ] && cksum "$RELOAD_FILE" 2>/dev/null
+}
+
+last="$(fingerprint)"
+echo "reload-watcher: watching $RELOAD_FILE"
+while sleep "$RELOAD_INTERVAL"; do
+	current="$(fingerprint)"
+	[ -z "$current" ] || [ "$current" = "$last" ] && continue
+	last="$current"
+
+	echo "reload-watcher: trigger changed ($(tr '\n' ' ' < "$RELOAD_FILE")), reloading"
+	if ! sh -c "$RELOAD_COMMAND"; then
+		echo "reload-watcher: reload command failed" >&2
+	fi
+done
@@ -0,0 +1,375 @@
+package acme
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme"
+
+	"ipssl-client/internal/fsys"
+	"ipssl-client/internal/keys"
+	"ipssl-client/internal/logger"
+	"ipssl-client/internal/webroot"
+)
+
+// accountKeyFile is the name of the persisted ACME account key inside the SSL directory
+const accountKeyFile = "acme-account.key"
+
+// EABSource returns External Account Binding credentials: the key ID and the
+// base64url-encoded HMAC key issued by the CA. It is only called when an
+// account is registered.
+type EABSource func(ctx context.Context) (kid, hmacKey string, err error)
+
+// StaticEAB returns an EABSource for fixed credentials
+func StaticEAB(kid, hmacKey string) EABSource {
+	return func(context.Context) (string, string, error) {
+		return kid, hmacKey, nil
+	}
+}
+
+// Client issues certificates from an ACME certificate authority using HTTP-01 validation
+type Client struct {
+	directoryURL   string
+	email          string
+	validationDirs []string
+	accountPath    string
+	httpClient     *http.Client
+	files          fsys.FS
+	logger         *logger.Logger
+	eab            EABSource
+	mustStaple     bool
+	client         *acme.Client
+}
+
+// NewClient creates a new ACME client. Challenge responses are written below
+// every directory in validationDirs and the account key is kept in sslDir.
+// httpClient may be nil to use the default client.
+func NewClient(directoryURL, email string, validationDirs []string, sslDir string, httpClient *http.Client, logger *logger.Logger) (*Client, error) {
+	if directoryURL == "" {
+		return nil, fmt.Errorf("ACME directory URL is required")
+	}
+
+	return &Client{
+		directoryURL:   directoryURL,
+		email:          email,
+		validationDirs: validationDirs,
+		accountPath:    filepath.Join(sslDir, accountKeyFile),
+		httpClient:     httpClient,
+		files:          fsys.OS,
+		logger:         logger,
+	}, nil
+}
+
+// SetFS replaces the filesystem the account key, certificates and challenge
+// files are read from and written to
+func (c *Client) SetFS(files fsys.FS) {
+	c.files = files
+}
+
+// SetExternalAccountBinding binds new accounts to an existing account at the
+// CA, as required by ZeroSSL and other commercial ACME directories
+func (c *Client) SetExternalAccountBinding(source EABSource) {
+	c.eab = source
+}
+
+// SetMustStaple requests the OCSP Must-Staple extension in certificates
+func (c *Client) SetMustStaple(enabled bool) {
+	c.mustStaple = enabled
+}
+
+// SupportedKeyTypes lists the key types accepted by the directory. Public CAs do
+// not issue Ed25519 certificates; private ACME servers are assumed to.
+func (c *Client) SupportedKeyTypes() []string {
+	if u, err := url.Parse(c.directoryURL); err == nil {
+		host := u.Hostname()
+		if strings.HasSuffix(host, "letsencrypt.org") || strings.HasSuffix(host, "zerossl.com") {
+			return []string{keys.RSA2048, keys.RSA4096, keys.ECDSAP256, keys.ECDSAP384}
+		}
+	}
+	return keys.All
+}
+
+// RequestCertificate orders a single certificate covering all given identifiers
+// (IP addresses and hostnames) and returns the PEM chain and private key.
+func (c *Client) RequestCertificate(ctx context.Context, identifiers []string, keyType string) ([]byte, []byte, error) {
+	if len(identifiers) == 0 {
+		return nil, nil, fmt.Errorf("at least one identifier is required")
+	}
+	c.logger.Info("Requesting certificate from ACME", "directory", c.directoryURL, "identifiers", identifiers)
+
+	client, err := c.account(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	order, err := client.AuthorizeOrder(ctx, authzIDs(identifiers))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create order: %w", err)
+	}
+
+	for _, authzURL := range order.AuthzURLs {
+		if err := c.authorize(ctx, client, authzURL); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	order, err = client.WaitOrder(ctx, order.URI)
+	if err != nil {
+		return nil, nil, fmt.Errorf("order did not become ready: %w", err)
+	}
+
+	key, err := keys.Generate(keyType)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+	csr, err := createCSR(identifiers, key, c.mustStaple)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	der, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to finalize order: %w", err)
+	}
+
+	var chain []byte
+	for _, cert := range der {
+		chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})...)
+	}
+	keyPEM, err := keys.EncodePEM(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+
+	c.logger.Info("Certificate issued by ACME", "order", order.URI, "certificates", len(der))
+	return chain, keyPEM, nil
+}
+
+// RevokeCertificate revokes an issued certificate using the account key
+func (c *Client) RevokeCertificate(ctx context.Context, certPEM []byte) error {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return fmt.Errorf("failed to decode PEM block")
+	}
+
+	client, err := c.account(ctx)
+	if err != nil {
+		return err
+	}
+	if err := client.RevokeCert(ctx, nil, block.Bytes, acme.CRLReasonUnspecified); err != nil {
+		return fmt.Errorf("failed to revoke certificate: %w", err)
+	}
+
+	c.logger.Info("Certificate revoked", "directory", c.directoryURL)
+	return nil
+}
+
+// IsCertificateValid checks if a certificate is valid and not expired
+func (c *Client) IsCertificateValid(certPath string, validityDuration time.Duration) (bool, error) {
+	certPEM, err := c.files.ReadFile(certPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read certificate file: %w", err)
+	}
+
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return false, fmt.Errorf("failed to decode PEM block")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	// Renew once the certificate enters the validity window
+	return time.Now().Add(validityDuration).Before(cert.NotAfter), nil
+}
+
+// account returns an ACME client registered with the configured directory,
+// creating the account key on first use
+func (c *Client) account(ctx context.Context) (*acme.Client, error) {
+	if c.client != nil {
+		return c.client, nil
+	}
+
+	key, err := c.loadAccountKey()
+	if err != nil {
+		return nil, err
+	}
+
+	client := &acme.Client{
+		Key:          key,
+		DirectoryURL: c.directoryURL,
+		HTTPClient:   c.httpClient,
+		UserAgent:    "ipssl-client",
+	}
+
+	account := &acme.Account{}
+	if c.email != "" {
+		account.Contact = []string{"mailto:" + c.email}
+	}
+	if c.eab != nil {
+		eab, err := c.externalAccountBinding(ctx)
+		if err != nil {
+			return nil, err
+		}
+		account.ExternalAccountBinding = eab
+	}
+	if _, err := client.Register(ctx, account, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return nil, fmt.Errorf("failed to register ACME account: %w", err)
+	}
+
+	c.client = client
+	return client, nil
+}
+
+// externalAccountBinding resolves and decodes the EAB credentials
+func (c *Client) externalAccountBinding(ctx context.Context) (*acme.ExternalAccountBinding, error) {
+	kid, hmacKey, err := c.eab(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get external account binding: %w", err)
+	}
+	if kid == "" || hmacKey == "" {
+		return nil, fmt.Errorf("external account binding requires both a key ID and an HMAC key")
+	}
+
+	// CAs hand out the key base64url-encoded, usually without padding
+	key, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(hmacKey, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid external account binding HMAC key: %w", err)
+	}
+	return &acme.ExternalAccountBinding{KID: kid, Key: key}, nil
+}
+
+// loadAccountKey reads the persisted account key or generates a new one
+func (c *Client) loadAccountKey() (crypto.Signer, error) {
+	data, err := c.files.ReadFile(c.accountPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read ACME account key %s: %w", c.accountPath, err)
+	}
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("failed to decode ACME account key %s", c.accountPath)
+		}
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ACME account key %s: %w", c.accountPath, err)
+		}
+		return key, nil
+	}
+
+	c.logger.Info("Generating new ACME account key", "path", c.accountPath)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ACME account key: %w", err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode ACME account key: %w", err)
+	}
+	if err := c.files.MkdirAll(filepath.Dir(c.accountPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for ACME account key: %w", err)
+	}
+	if err := fsys.WriteFileAtomic(c.files, c.accountPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return nil, fmt.Errorf("failed to save ACME account key: %w", err)
+	}
+	return key, nil
+}
+
+// authorize completes the HTTP-01 challenge of a pending authorization
+func (c *Client) authorize(ctx context.Context, client *acme.Client, authzURL string) error {
+	authz, err := client.GetAuthorization(ctx, authzURL)
+	if err != nil {
+		return fmt.Errorf("failed to get authorization: %w", err)
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+
+	var challenge *acme.Challenge
+	for _, ch := range authz.Challenges {
+		if ch.Type == "http-01" {
+			challenge = ch
+			break
+		}
+	}
+	if challenge == nil {
+		return fmt.Errorf("no http-01 challenge offered for %s", authz.Identifier.Value)
+	}
+
+	response, err := client.HTTP01ChallengeResponse(challenge.Token)
+	if err != nil {
+		return fmt.Errorf("failed to compute challenge response: %w", err)
+	}
+	challengePaths, err := webroot.Write(c.files, c.validationDirs, client.HTTP01ChallengePath(challenge.Token), []byte(response))
+	if err != nil {
+		return fmt.Errorf("failed to write challenge file: %w", err)
+	}
+	defer webroot.Remove(c.files, challengePaths)
+	c.logger.Info("Challenge file created", "identifier", authz.Identifier.Value, "paths", challengePaths)
+
+	if _, err := client.Accept(ctx, challenge); err != nil {
+		return fmt.Errorf("failed to accept challenge for %s: %w", authz.Identifier.Value, err)
+	}
+	if _, err := client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return fmt.Errorf("validation failed for %s: %w", authz.Identifier.Value, err)
+	}
+
+	c.logger.Info("Identifier validated", "identifier", authz.Identifier.Value)
+	return nil
+}
+
+// authzIDs maps identifiers to ACME "ip" or "dns" identifiers
+func authzIDs(identifiers []string) []acme.AuthzID {
+	ids := make([]acme.AuthzID, 0, len(identifiers))
+	for _, identifier := range identifiers {
+		if net.ParseIP(identifier) != nil {
+			ids = append(ids, acme.AuthzID{Type: "ip", Value: identifier})
+		} else {
+			ids = append(ids, acme.AuthzID{Type: "dns", Value: identifier})
+		}
+	}
+	return ids
+}
+
+// createCSR builds a certificate request listing every identifier as a SAN. IP
+// certificates carry no CommonName since CAs do not accept addresses there.
+func createCSR(identifiers []string, key crypto.Signer, mustStaple bool) ([]byte, error) {
+	template := &x509.CertificateRequest{}
+	if mustStaple {
+		template.ExtraExtensions = []pkix.Extension{keys.MustStaple()}
+	}
+	for _, identifier := range identifiers {
+		if ip := net.ParseIP(identifier); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, identifier)
+		}
+	}
+	if net.ParseIP(identifiers[0]) == nil {
+		template.Subject = pkix.Name{CommonName: identifiers[0]}
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CSR: %w", err)
+	}
+	return csr, nil
+}
@@ -0,0 +1,287 @@
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ipssl-client/internal/fsys"
+	"ipssl-client/internal/keys"
+	"ipssl-client/internal/logger"
+)
+
+func TestCreateCSR(t *testing.T) {
+	key, err := keys.Generate(keys.ECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := createCSR([]string{"192.0.2.1", "example.com"}, key, true)
+	if err != nil {
+		t.Fatalf("createCSR failed: %v", err)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		t.Errorf("Expected a CSR signed by the key: %v", err)
+	}
+	if csr.Subject.CommonName != "" {
+		t.Errorf("Expected no CommonName for a leading IP address, got %q", csr.Subject.CommonName)
+	}
+	if len(csr.IPAddresses) != 1 || csr.IPAddresses[0].String() != "192.0.2.1" || len(csr.DNSNames) != 1 || csr.DNSNames[0] != "example.com" {
+		t.Errorf("Expected every identifier as a SAN, got %v and %v", csr.IPAddresses, csr.DNSNames)
+	}
+	if !hasExtension(csr.Extensions, keys.MustStaple().Id) {
+		t.Error("Expected the Must-Staple extension")
+	}
+
+	der, err = createCSR([]string{"example.com", "192.0.2.1"}, key, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, _ = x509.ParseCertificateRequest(der)
+	if csr.Subject.CommonName != "example.com" {
+		t.Errorf("Expected a leading hostname as CommonName, got %q", csr.Subject.CommonName)
+	}
+	if hasExtension(csr.Extensions, keys.MustStaple().Id) {
+		t.Error("Expected no Must-Staple extension unless enabled")
+	}
+}
+
+// hasExtension reports whether extensions contain id
+func hasExtension(extensions []pkix.Extension, id []int) bool {
+	for _, ext := range extensions {
+		if ext.Id.Equal(id) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestIsCertificateValid(t *testing.T) {
+	mem := fsys.NewMem()
+	mem.MkdirAll("/ssl", 0755)
+	mem.WriteFile("/ssl/cert.pem", selfSigned(t, time.Now().Add(10*24*time.Hour)), 0644)
+
+	client, err := NewClient("https://acme.test/directory", "", nil, "/ssl", nil, logger.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetFS(mem)
+
+	if valid, err := client.IsCertificateValid("/ssl/cert.pem", 5*24*time.Hour); err != nil || !valid {
+		t.Errorf("Expected a certificate outside the renewal window to be valid, got %v, %v", valid, err)
+	}
+	if valid, err := client.IsCertificateValid("/ssl/cert.pem", 30*24*time.Hour); err != nil || valid {
+		t.Errorf("Expected a certificate inside the renewal window to be invalid, got %v, %v", valid, err)
+	}
+	if _, err := client.IsCertificateValid("/ssl/missing.pem", time.Hour); err == nil {
+		t.Error("Expected an error for a missing certificate")
+	}
+}
+
+// selfSigned returns a PEM self-signed certificate expiring at notAfter
+func selfSigned(t *testing.T, notAfter time.Time) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: time.Now().Add(-time.Hour), NotAfter: notAfter}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// fakeCA is an ACME directory that validates every challenge and issues
+// certificates from a test CA
+type fakeCA struct {
+	t      *testing.T
+	server *httptest.Server
+	files  *fsys.Mem
+
+	mu        sync.Mutex
+	validated bool
+	// served is the challenge file content found when the challenge was accepted
+	served string
+	issued []byte
+}
+
+func newFakeCA(t *testing.T, files *fsys.Mem) *fakeCA {
+	ca := &fakeCA{t: t, files: files}
+	ca.server = httptest.NewServer(http.HandlerFunc(ca.serveHTTP))
+	t.Cleanup(ca.server.Close)
+	return ca
+}
+
+func (ca *fakeCA) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	url := ca.server.URL
+	w.Header().Set("Replay-Nonce", fmt.Sprintf("nonce-%d", time.Now().UnixNano()))
+	authz := func() map[string]any {
+		status := "pending"
+		if ca.validated {
+			status = "valid"
+		}
+		return map[string]any{
+			"status":     status,
+			"identifier": map[string]string{"type": "ip", "value": "192.0.2.1"},
+			"challenges": []map[string]string{
+				{"type": "dns-01", "url": url + "/chall/dns", "token": "dns-token", "status": "pending"},
+				{"type": "http-01", "url": url + "/chall/http", "token": "http-token", "status": status},
+			},
+		}
+	}
+
+	switch r.URL.Path {
+	case "/directory":
+		json.NewEncoder(w).Encode(map[string]string{
+			"newNonce":   url + "/nonce",
+			"newAccount": url + "/account",
+			"newOrder":   url + "/order",
+			"revokeCert": url + "/revoke",
+			"keyChange":  url + "/key-change",
+		})
+	case "/nonce":
+		w.WriteHeader(http.StatusOK)
+	case "/account":
+		w.Header().Set("Location", url+"/account/1")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"status": "valid"})
+	case "/order":
+		w.Header().Set("Location", url+"/order/1")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(ca.order("pending"))
+	case "/order/1":
+		status := "pending"
+		if ca.validated {
+			status = "ready"
+		}
+		if ca.issued != nil {
+			status = "valid"
+		}
+		json.NewEncoder(w).Encode(ca.order(status))
+	case "/authz/1":
+		json.NewEncoder(w).Encode(authz())
+	case "/chall/http":
+		data, _ := ca.files.ReadFile("/www/.well-known/acme-challenge/http-token")
+		ca.served = string(data)
+		ca.validated = true
+		json.NewEncoder(w).Encode(map[string]string{"type": "http-01", "url": url + "/chall/http", "token": "http-token", "status": "valid"})
+	case "/finalize":
+		ca.issue(w, r)
+	case "/cert":
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		w.Write(ca.issued)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// order returns the order with the given status
+func (ca *fakeCA) order(status string) map[string]any {
+	order := map[string]any{
+		"status":         status,
+		"identifiers":    []map[string]string{{"type": "ip", "value": "192.0.2.1"}},
+		"authorizations": []string{ca.server.URL + "/authz/1"},
+		"finalize":       ca.server.URL + "/finalize",
+	}
+	if status == "valid" {
+		order["certificate"] = ca.server.URL + "/cert"
+	}
+	return order
+}
+
+// issue signs the CSR of a finalize request with a throwaway CA
+func (ca *fakeCA) issue(w http.ResponseWriter, r *http.Request) {
+	var jws struct {
+		Payload string `json:"payload"`
+	}
+	var finalize struct {
+		CSR string `json:"csr"`
+	}
+	json.NewDecoder(r.Body).Decode(&jws)
+	payload, _ := base64.RawURLEncoding.DecodeString(jws.Payload)
+	json.Unmarshal(payload, &finalize)
+	der, _ := base64.RawURLEncoding.DecodeString(finalize.CSR)
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "Test CA"}, NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour), IsCA: true, BasicConstraintsValid: true}
+	caDER, _ := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	leaf := &x509.Certificate{SerialNumber: big.NewInt(2), NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour), IPAddresses: csr.IPAddresses, DNSNames: csr.DNSNames}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leaf, caTemplate, csr.PublicKey, caKey)
+	if err != nil {
+		ca.t.Errorf("Failed to issue certificate: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	ca.issued = append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER}), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})...)
+	json.NewEncoder(w).Encode(ca.order("valid"))
+}
+
+func TestRequestCertificate(t *testing.T) {
+	mem := fsys.NewMem()
+	ca := newFakeCA(t, mem)
+
+	client, err := NewClient(ca.server.URL+"/directory", "admin@example.com", []string{"/www"}, "/ssl", ca.server.Client(), logger.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetFS(mem)
+
+	chainPEM, keyPEM, err := client.RequestCertificate(context.Background(), []string{"192.0.2.1"}, keys.ECDSAP256)
+	if err != nil {
+		t.Fatalf("RequestCertificate failed: %v", err)
+	}
+
+	if !strings.HasPrefix(ca.served, "http-token.") {
+		t.Errorf("Expected the key authorization to be served while the challenge was accepted, got %q", ca.served)
+	}
+	if _, err := mem.Stat("/www/.well-known/acme-challenge/http-token"); err == nil {
+		t.Error("Expected the challenge file to be removed after validation")
+	}
+	if _, err := mem.ReadFile("/ssl/" + accountKeyFile); err != nil {
+		t.Errorf("Expected the account key to be saved: %v", err)
+	}
+
+	block, rest := pem.Decode(chainPEM)
+	if block == nil || len(rest) == 0 {
+		t.Fatal("Expected the leaf and its issuer in the chain")
+	}
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leaf.IPAddresses) != 1 || leaf.IPAddresses[0].String() != "192.0.2.1" {
+		t.Errorf("Expected a certificate for 192.0.2.1, got %v", leaf.IPAddresses)
+	}
+	if _, err := tls.X509KeyPair(chainPEM, keyPEM); err != nil {
+		t.Errorf("Expected the private key of the certificate: %v", err)
+	}
+}
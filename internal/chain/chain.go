@@ -0,0 +1,178 @@
+package chain
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"slices"
+)
+
+// pemBegin starts every PEM block
+const pemBegin = "-----BEGIN "
+
+// Assemble builds the PEM chain served to clients: the leaf certificate
+// followed by the certificates of bundle, each one issuing the certificate
+// before it. CAs return bundles in any order, with Windows line endings or
+// without newlines between blocks, and none of this survives into the result.
+// Certificates of bundle that do not extend the chain are kept at the end.
+func Assemble(leaf, bundle []byte) ([]byte, error) {
+	leafCerts, err := decode(leaf)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate: %w", err)
+	}
+	if len(leafCerts) == 0 {
+		return nil, fmt.Errorf("invalid certificate: no certificate found")
+	}
+	caCerts, err := decode(bundle)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CA bundle: %w", err)
+	}
+
+	// Some CAs return the intermediates along with the certificate itself
+	leafCert := leafCerts[0]
+	return Encode(order(leafCert, dedupe(leafCert, append(leafCerts[1:], caCerts...)))), nil
+}
+
+// Parse decodes a PEM bundle into its certificates: the leaf, which issued no
+// other certificate of the bundle, followed by its issuers in order and then
+// any unrelated certificates. Line endings are normalized and duplicate
+// certificates are dropped, so the result holds each certificate once.
+func Parse(data []byte) ([]*x509.Certificate, error) {
+	certs, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found")
+	}
+	certs = dedupe(nil, certs)
+
+	leaf := certs[0]
+	for i, cert := range certs {
+		if !slices.ContainsFunc(certs, func(other *x509.Certificate) bool { return other != cert && issued(cert, other) }) {
+			leaf = certs[i]
+			break
+		}
+	}
+	return order(leaf, slices.DeleteFunc(certs, func(cert *x509.Certificate) bool { return cert == leaf })), nil
+}
+
+// Verify checks that certs, as returned by Parse, lead from the leaf through
+// the other certificates to one of roots, or to a system root if roots is nil
+func Verify(certs []*x509.Certificate, roots *x509.CertPool) error {
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	return err
+}
+
+// Missing returns the identifiers, IP addresses or hostnames, that leaf is not valid for
+func Missing(leaf *x509.Certificate, identifiers []string) []string {
+	var missing []string
+	for _, identifier := range identifiers {
+		if leaf.VerifyHostname(identifier) != nil {
+			missing = append(missing, identifier)
+		}
+	}
+	return missing
+}
+
+// Encode returns the PEM bundle of certs
+func Encode(certs []*x509.Certificate) []byte {
+	var out bytes.Buffer
+	for _, cert := range certs {
+		pem.Encode(&out, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	return out.Bytes()
+}
+
+// decode parses the certificates of a PEM bundle. Text outside of PEM blocks
+// is ignored, as openssl writes such text before blocks, but a block that
+// cannot be decoded fails the whole bundle rather than being dropped.
+func decode(data []byte) ([]*x509.Certificate, error) {
+	data = normalize(data)
+
+	var certs []*x509.Certificate
+	blocks := 0
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		blocks++
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected PEM block %q", block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate %d: %w", blocks, err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if markers := bytes.Count(data, []byte(pemBegin)); markers != blocks {
+		return nil, fmt.Errorf("malformed PEM data: decoded %d of %d blocks", blocks, markers)
+	}
+	return certs, nil
+}
+
+// normalize converts line endings to \n and starts every PEM block on a new
+// line, which pem.Decode requires
+func normalize(data []byte) []byte {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	data = bytes.ReplaceAll(data, []byte("\r"), []byte("\n"))
+
+	var out bytes.Buffer
+	for i, part := range bytes.Split(data, []byte(pemBegin)) {
+		if i > 0 {
+			if out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+				out.WriteByte('\n')
+			}
+			out.WriteString(pemBegin)
+		}
+		out.Write(part)
+	}
+	return out.Bytes()
+}
+
+// order arranges cas after leaf so that every certificate is issued by the
+// one following it. Certificates outside of that path keep their order at the end.
+func order(leaf *x509.Certificate, cas []*x509.Certificate) []*x509.Certificate {
+	certs := []*x509.Certificate{leaf}
+	rest := slices.Clone(cas)
+	for {
+		last := certs[len(certs)-1]
+		i := slices.IndexFunc(rest, func(ca *x509.Certificate) bool { return issued(ca, last) })
+		if i < 0 {
+			break
+		}
+		certs = append(certs, rest[i])
+		rest = slices.Delete(rest, i, i+1)
+	}
+	return append(certs, rest...)
+}
+
+// dedupe drops copies of leaf and repeated certificates from certs
+func dedupe(leaf *x509.Certificate, certs []*x509.Certificate) []*x509.Certificate {
+	var unique []*x509.Certificate
+	for _, cert := range certs {
+		if (leaf != nil && cert.Equal(leaf)) || slices.ContainsFunc(unique, cert.Equal) {
+			continue
+		}
+		unique = append(unique, cert)
+	}
+	return unique
+}
+
+// issued reports whether ca issued cert
+func issued(ca, cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, ca.RawSubject) && cert.CheckSignatureFrom(ca) == nil
+}
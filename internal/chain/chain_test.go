@@ -0,0 +1,324 @@
+package chain
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testChain holds PEM certificates of a root, an intermediate and a leaf
+type testChain struct {
+	root, intermediate, leaf []byte
+	rootCert                 *x509.Certificate
+}
+
+// newTestChain issues a three-certificate chain
+func newTestChain(t testing.TB) testChain {
+	t.Helper()
+	issue := func(serial int64, name string, ca bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(serial),
+			Subject:               pkix.Name{CommonName: name},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(24 * time.Hour),
+			IsCA:                  ca,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		}
+		if !ca {
+			template.IPAddresses = []net.IP{net.ParseIP(name)}
+		}
+		if parent == nil {
+			parent, parentKey = template, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert, key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	}
+
+	root, rootKey, rootPEM := issue(1, "Test Root", true, nil, nil)
+	intermediate, intermediateKey, intermediatePEM := issue(2, "Test Intermediate", true, root, rootKey)
+	_, _, leafPEM := issue(3, "192.0.2.1", false, intermediate, intermediateKey)
+	return testChain{root: rootPEM, intermediate: intermediatePEM, leaf: leafPEM, rootCert: root}
+}
+
+func TestAssemble(t *testing.T) {
+	c := newTestChain(t)
+	want := string(c.leaf) + string(c.intermediate) + string(c.root)
+	trim := func(b []byte) string { return strings.TrimSuffix(string(b), "\n") }
+
+	tests := []struct {
+		name         string
+		leaf, bundle string
+	}{
+		{"ordered", string(c.leaf), string(c.intermediate) + string(c.root)},
+		{"reversed bundle", string(c.leaf), string(c.root) + string(c.intermediate)},
+		{"no trailing newline", trim(c.leaf), trim(c.intermediate) + "\n" + trim(c.root)},
+		{"no newline between blocks", trim(c.leaf), trim(c.root) + trim(c.intermediate)},
+		{"windows line endings", strings.ReplaceAll(string(c.leaf), "\n", "\r\n"), strings.ReplaceAll(string(c.intermediate)+string(c.root), "\n", "\r\n")},
+		{"text between blocks", "subject=CN = 192.0.2.1\n" + string(c.leaf), "issuer\n" + string(c.root) + "\n\n" + string(c.intermediate)},
+		{"chain in certificate", string(c.leaf) + string(c.root), string(c.intermediate)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Assemble([]byte(tt.leaf), []byte(tt.bundle))
+			if err != nil {
+				t.Fatalf("Assemble failed: %v", err)
+			}
+			if string(got) != want {
+				t.Errorf("Expected leaf, intermediate and root in order, got\n%s", got)
+			}
+		})
+	}
+}
+
+func TestAssembleWithoutBundle(t *testing.T) {
+	c := newTestChain(t)
+	got, err := Assemble(c.leaf, nil)
+	if err != nil {
+		t.Fatalf("Assemble failed: %v", err)
+	}
+	if !bytes.Equal(got, c.leaf) {
+		t.Errorf("Expected the leaf alone, got\n%s", got)
+	}
+}
+
+func TestAssembleInvalid(t *testing.T) {
+	c := newTestChain(t)
+	truncated := c.intermediate[:len(c.intermediate)/2]
+	corrupt := bytes.Replace(c.intermediate, []byte("-----END CERTIFICATE-----"), []byte("-----END CERTIFICATE----- trailing"), 1)
+
+	tests := []struct {
+		name         string
+		leaf, bundle []byte
+	}{
+		{"empty certificate", nil, c.intermediate},
+		{"garbage certificate", []byte("not a certificate"), c.intermediate},
+		{"truncated bundle", c.leaf, truncated},
+		{"garbage after end line", c.leaf, corrupt},
+		{"private key", c.leaf, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("key")})},
+		{"invalid DER", c.leaf, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("garbage")})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := Assemble(tt.leaf, tt.bundle); err == nil {
+				t.Errorf("Expected an error, got\n%s", got)
+			}
+		})
+	}
+}
+
+func FuzzAssemble(f *testing.F) {
+	c := newTestChain(f)
+	f.Add(c.leaf, append(c.intermediate, c.root...))
+	f.Add(c.leaf, append(c.root, c.intermediate...))
+	f.Add(bytes.TrimSpace(c.leaf), append(bytes.TrimSpace(c.root), bytes.TrimSpace(c.intermediate)...))
+	f.Add(bytes.ReplaceAll(c.leaf, []byte("\n"), []byte("\r\n")), c.intermediate)
+	f.Add(append(c.leaf, c.intermediate...), []byte{})
+	f.Add(c.leaf, []byte("-----BEGIN CERTIFICATE-----\ngarbage\n-----END CERTIFICATE-----\n"))
+	f.Add([]byte("garbage"), []byte("\x00\xff-----BEGIN -----BEGIN "))
+
+	f.Fuzz(func(t *testing.T, leaf, bundle []byte) {
+		got, err := Assemble(leaf, bundle)
+		if err != nil {
+			return
+		}
+
+		// The result is a clean sequence of certificate blocks
+		var certs []*x509.Certificate
+		rest := got
+		for len(rest) > 0 {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil || block.Type != "CERTIFICATE" {
+				t.Fatalf("Assembled chain is not a sequence of certificates:\n%q", got)
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				t.Fatalf("Assembled chain contains an invalid certificate: %v", err)
+			}
+			certs = append(certs, cert)
+		}
+
+		for i := range certs {
+			for _, other := range certs[i+1:] {
+				if certs[i].Equal(other) {
+					t.Fatal("Assembled chain contains a certificate twice")
+				}
+			}
+		}
+
+		// The leaf stays first and assembling again changes nothing
+		leafCerts, err := decode(leaf)
+		if err != nil || len(leafCerts) == 0 {
+			t.Fatalf("Assemble accepted a certificate that does not decode: %v", err)
+		}
+		if !certs[0].Equal(leafCerts[0]) {
+			t.Error("Expected the leaf certificate first")
+		}
+		again, err := Assemble(got, nil)
+		if err != nil {
+			t.Fatalf("Assembled chain cannot be assembled again: %v", err)
+		}
+		if !bytes.Equal(again, got) {
+			t.Errorf("Assembling the chain again changed it:\n%s\n%s", got, again)
+		}
+	})
+}
+
+func TestParse(t *testing.T) {
+	c := newTestChain(t)
+	want := string(c.leaf) + string(c.intermediate) + string(c.root)
+
+	tests := []struct {
+		name   string
+		bundle string
+	}{
+		{"ordered", want},
+		{"reversed", string(c.root) + string(c.intermediate) + string(c.leaf)},
+		{"duplicates", string(c.intermediate) + string(c.leaf) + string(c.intermediate) + string(c.root) + string(c.leaf)},
+		{"windows line endings", strings.ReplaceAll(string(c.intermediate)+string(c.root)+string(c.leaf), "\n", "\r\n")},
+		{"no newline between blocks", strings.TrimSpace(string(c.root)) + strings.TrimSpace(string(c.leaf)) + string(c.intermediate)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certs, err := Parse([]byte(tt.bundle))
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if len(certs) != 3 {
+				t.Errorf("Expected 3 certificates, got %d", len(certs))
+			}
+			if got := string(Encode(certs)); got != want {
+				t.Errorf("Expected leaf, intermediate and root in order, got\n%s", got)
+			}
+		})
+	}
+
+	if _, err := Parse([]byte("garbage")); err == nil {
+		t.Error("Expected an error for a bundle without certificates")
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	c := newTestChain(f)
+	f.Add(append(append(c.root, c.leaf...), c.intermediate...))
+	f.Add(append(append(c.leaf, c.leaf...), bytes.TrimSpace(c.intermediate)...))
+	f.Add(bytes.ReplaceAll(c.root, []byte("\n"), []byte("\r")))
+	f.Add([]byte("-----BEGIN CERTIFICATE-----\r\n-----END CERTIFICATE-----"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		certs, err := Parse(data)
+		if err != nil {
+			return
+		}
+		if len(certs) == 0 {
+			t.Fatal("Parse returned no certificates without an error")
+		}
+
+		// Parsing the encoded bundle again yields the same certificates
+		again, err := Parse(Encode(certs))
+		if err != nil {
+			t.Fatalf("Encoded bundle cannot be parsed: %v", err)
+		}
+		if len(again) != len(certs) {
+			t.Fatalf("Expected %d certificates after encoding, got %d", len(certs), len(again))
+		}
+		for i := range certs {
+			if !certs[i].Equal(again[i]) {
+				t.Fatalf("Certificate %d changed after encoding", i)
+			}
+			for _, other := range certs[i+1:] {
+				if certs[i].Equal(other) {
+					t.Fatal("Parse returned a certificate twice")
+				}
+			}
+		}
+	})
+}
+
+func TestVerify(t *testing.T) {
+	c := newTestChain(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(c.rootCert)
+
+	certs, err := Parse(append(c.leaf, c.intermediate...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(certs, roots); err != nil {
+		t.Errorf("Expected chain to verify: %v", err)
+	}
+
+	// Without the intermediate the leaf cannot be traced to the root
+	certs, err = Parse(append(c.leaf, c.root...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(certs, roots); err == nil {
+		t.Error("Expected verification without the intermediate to fail")
+	}
+	if err := Verify(certs[:1], x509.NewCertPool()); err == nil {
+		t.Error("Expected verification against an unrelated root to fail")
+	}
+}
+
+func TestMissing(t *testing.T) {
+	c := newTestChain(t)
+	certs, err := Parse(c.leaf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing := Missing(certs[0], []string{"192.0.2.1"}); len(missing) != 0 {
+		t.Errorf("Expected the leaf to cover its IP, missing %v", missing)
+	}
+	missing := Missing(certs[0], []string{"192.0.2.1", "192.0.2.2", "example.com"})
+	if strings.Join(missing, ",") != "192.0.2.2,example.com" {
+		t.Errorf("Expected 192.0.2.2 and example.com to be missing, got %v", missing)
+	}
+}
+
+func TestEncodePKCS7(t *testing.T) {
+	c := newTestChain(t)
+	certs, err := Parse(append(append([]byte{}, c.leaf...), c.intermediate...))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	der, err := EncodePKCS7(certs)
+	if err != nil {
+		t.Fatalf("EncodePKCS7 failed: %v", err)
+	}
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil || !ci.ContentType.Equal(oidSignedData) {
+		t.Fatalf("Expected a SignedData ContentInfo, got %v (%v)", ci.ContentType, err)
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		t.Fatalf("Failed to parse SignedData: %v", err)
+	}
+	decoded, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil || len(decoded) != 2 || !decoded[0].Equal(certs[0]) || !decoded[1].Equal(certs[1]) {
+		t.Errorf("Expected the leaf and intermediate in order, got %d certificates (%v)", len(decoded), err)
+	}
+}
@@ -1,66 +1,1307 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"text/template"
 	"time"
+
+	"ipssl-client/internal/keys"
 )
 
-// Config holds the application configuration
+// Config holds the application configuration. Credentials are tagged
+// json:"-" and listed by Secrets.
 type Config struct {
-	ClientIP        string        `json:"client_ip"`
-	APIKey          string        `json:"api_key"`
-	ValidationDir   string        `json:"validation_dir"`
-	SSLDir          string        `json:"ssl_dir"`
-	ContainerName   string        `json:"container_name"`
-	RenewalInterval time.Duration `json:"renewal_interval"`
-	CertValidity    time.Duration `json:"cert_validity"`
+	ClientIP        string           `json:"client_ip"`
+	ClientIPs       []string         `json:"client_ips"`
+	Domains         []string         `json:"domains"`
+	IPAllow         []string         `json:"ip_allow"`
+	IPDeny          []string         `json:"ip_deny"`
+	OwnershipCheck  bool             `json:"ownership_check"`
+	OwnershipPorts  []int            `json:"ownership_ports"`
+	Provider        string           `json:"provider"`
+	APIKey          string           `json:"-"`
+	APIURL          string           `json:"api_url"`
+	ScrubEnv        bool             `json:"scrub_env"`
+	CleanupStale    bool             `json:"cleanup_stale"`
+	CertQuota       int              `json:"cert_quota"`
+	VerifyLimit     int              `json:"verify_limit"`
+	VerifyQueueDir  string           `json:"verify_queue_dir"`
+	PollInterval    time.Duration    `json:"poll_interval"`
+	IssuanceTimeout time.Duration    `json:"issuance_timeout"`
+	APIRetry        APIRetryConfig   `json:"api_retry"`
+	Sandbox         bool             `json:"sandbox"`
+	ACME            ACMEConfig       `json:"acme"`
+	ValidationDir   string           `json:"validation_dir"`
+	ValidationDirs  []string         `json:"validation_dirs"`
+	SelfCheck       bool             `json:"self_check"`
+	ValidationEOL   string           `json:"validation_eol"`
+	ValidationOrder string           `json:"validation_order"`
+	SSLDir          string           `json:"ssl_dir"`
+	OutputFormats   []string         `json:"output_formats"`
+	LiveName        string           `json:"live_name"`
+	CaddyBind       []string         `json:"caddy_bind"`
+	CertTemplate    string           `json:"cert_path_template"`
+	KeyTemplate     string           `json:"key_path_template"`
+	Files           FilesConfig      `json:"files"`
+	StateDir        string           `json:"state_dir"`
+	OverrideDir     string           `json:"override_dir"`
+	HistoryKeep     int              `json:"history_keep"`
+	HistoryMaxAge   time.Duration    `json:"history_max_age"`
+	ContainerName   string           `json:"container_name"`
+	Timezone        string           `json:"timezone"`
+	Location        *time.Location   `json:"-"`
+	RenewalInterval time.Duration    `json:"renewal_interval"`
+	StartupRetry    StartupRetry     `json:"startup_retry"`
+	CertValidity    time.Duration    `json:"cert_validity"`
+	RenewalFraction float64          `json:"renewal_fraction"`
+	KeyType         string           `json:"key_type"`
+	KeyTypeFallback bool             `json:"key_type_fallback"`
+	KeyFormat       string           `json:"key_format"`
+	MustStaple      bool             `json:"must_staple"`
+	OCSPStaple      bool             `json:"ocsp_staple"`
+	RevocationCheck time.Duration    `json:"revocation_check"`
+	RevokedReissue  bool             `json:"revoked_reissue"`
+	VerifyChain     bool             `json:"verify_chain"`
+	ChainRoots      string           `json:"chain_roots"`
+	VerifyCT        bool             `json:"verify_ct"`
+	CTMinSCTs       int              `json:"ct_min_scts"`
+	CTLogList       string           `json:"ct_log_list"`
+	RenewalBudget   time.Duration    `json:"renewal_budget"`
+	RenewalTimeout  time.Duration    `json:"renewal_timeout"`
+	RenewalRetry    time.Duration    `json:"renewal_retry"`
+	DockerTimeout   time.Duration    `json:"docker_timeout"`
+	VerifyWrites    bool             `json:"verify_writes"`
+	ContainerSSLDir string           `json:"container_ssl_dir"`
+	ReloadStrategy  string           `json:"reload_strategy"`
+	ReloadFile      string           `json:"reload_file"`
+	ReloadFallback  string           `json:"reload_fallback"`
+	TLSProbeAddr    string           `json:"tls_probe_addr"`
+	TLSProbeTimeout time.Duration    `json:"tls_probe_timeout"`
+	HealthTimeout   time.Duration    `json:"health_timeout"`
+	ExpiredAction   string           `json:"expired_action"`
+	TSAURL          string           `json:"tsa_url"`
+	MetricsAddr     string           `json:"metrics_addr"`
+	HealthIntervals int              `json:"health_intervals"`
+	Deployers       []string         `json:"deployers"`
+	HookEnv         []string         `json:"hook_env"`
+	DeployWorkers   int              `json:"deploy_workers"`
+	DeployRetries   int              `json:"deploy_retries"`
+	DeployBackoff   time.Duration    `json:"deploy_backoff"`
+	Report          ReportConfig     `json:"report"`
+	SMTP            SMTPConfig       `json:"smtp"`
+	SIEM            SIEMConfig       `json:"siem"`
+	VRRP            VRRPConfig       `json:"vrrp"`
+	Compose         ComposeConfig    `json:"compose"`
+	IIS             IISConfig        `json:"iis"`
+	HTTP            HTTPDeployConfig `json:"http"`
+	PKCS12          PKCS12Config     `json:"pkcs12"`
+	Vault           VaultConfig      `json:"vault"`
+	AWS             AWSConfig        `json:"aws"`
+	Azure           AzureConfig      `json:"azure"`
+	Kubernetes      KubeConfig       `json:"kubernetes"`
+	Certificates    []CertificateSet `json:"certificates"`
+	CertPerIP       bool             `json:"cert_per_ip"`
+	Upload          []UploadTarget   `json:"upload"`
+	SSH             []SSHTarget      `json:"ssh"`
+
+	// ContainerMap names the container serving each IP address, for certificate sets
+	ContainerMap map[string]string `json:"container_map"`
+
+	// Labels annotate the certificate, e.g. with its team, service and environment
+	Labels map[string]string `json:"labels"`
+
+	// Presets holds per-deployer overrides for built-in server presets, keyed by deployer name
+	Presets map[string]PresetConfig `json:"presets"`
+
+	// Appliances holds API connection settings for appliance deployers, keyed by deployer name
+	Appliances map[string]ApplianceConfig `json:"appliances"`
+}
+
+// ComposeConfig configures the docker-compose deployer
+type ComposeConfig struct {
+	File     string   `json:"file"`
+	Project  string   `json:"project"`
+	EnvFile  string   `json:"env_file"`
+	EnvKey   string   `json:"env_key"`
+	Services []string `json:"services"`
+}
+
+// Identifiers returns all identifiers the certificate must cover: IP addresses first, then domains
+func (c *Config) Identifiers() []string {
+	identifiers := append([]string{}, c.ClientIPs...)
+	return append(identifiers, c.Domains...)
+}
+
+// Primary returns the primary identifier, the certificate's CommonName: the
+// first IP address, or the first hostname for hostname-only certificates
+func (c *Config) Primary() string {
+	if identifiers := c.Identifiers(); len(identifiers) > 0 {
+		return identifiers[0]
+	}
+	return ""
+}
+
+// In converts t to the configured time zone for display, leaving zero times
+// and configurations without a time zone unchanged
+func (c *Config) In(t time.Time) time.Time {
+	if t.IsZero() || c.Location == nil {
+		return t
+	}
+	return t.In(c.Location)
+}
+
+// ForCertificate returns the configuration of a single client managing the
+// certificate set. Metrics are left to the caller, which serves them for all sets.
+func (c *Config) ForCertificate(set CertificateSet) *Config {
+	sub := *c
+	sub.Certificates = nil
+	sub.ClientIPs = set.ClientIPs
+	sub.Domains = set.Domains
+	sub.ClientIP = ""
+	if len(set.ClientIPs) > 0 {
+		sub.ClientIP = set.ClientIPs[0]
+	}
+	sub.SSLDir = set.SSLDir
+	sub.StateDir = set.StateDir
+	sub.OverrideDir = set.OverrideDir
+	sub.ContainerName = set.ContainerName
+	sub.ReloadFile = set.ReloadFile
+	sub.TLSProbeAddr = set.TLSProbeAddr
+	sub.ContainerSSLDir = set.ContainerDir
+	sub.MetricsAddr = ""
+	sub.Labels = make(map[string]string)
+	for _, labels := range []map[string]string{c.Labels, set.Labels} {
+		for name, value := range labels {
+			sub.Labels[name] = value
+		}
+	}
+	if path := c.Report.CalendarFile; path != "" {
+		sub.Report.CalendarFile = filepath.Join(filepath.Dir(path), set.Name+"-"+filepath.Base(path))
+	}
+	return &sub
+}
+
+// CheckIP returns an error if ip may not be issued for: it lies outside every
+// range of IPSSL_IP_ALLOW, when set, or inside a range of IPSSL_IP_DENY
+func (c *Config) CheckIP(ip string) error {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return fmt.Errorf("invalid IP address %s", ip)
+	}
+	addr = addr.Unmap()
+
+	for _, r := range c.IPDeny {
+		if prefix, err := parsePrefix(r); err == nil && prefix.Contains(addr) {
+			return fmt.Errorf("%s is in the denied range %s (IPSSL_IP_DENY)", ip, r)
+		}
+	}
+	if len(c.IPAllow) == 0 {
+		return nil
+	}
+	for _, r := range c.IPAllow {
+		if prefix, err := parsePrefix(r); err == nil && prefix.Contains(addr) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not in an allowed range (IPSSL_IP_ALLOW %s)", ip, strings.Join(c.IPAllow, ","))
+}
+
+// parsePrefix parses a CIDR range or a single IP address
+func parsePrefix(s string) (netip.Prefix, error) {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()).Masked(), nil
+}
+
+// APIRetryConfig configures retries of failed certificate authority API calls
+type APIRetryConfig struct {
+	MaxAttempts int           `json:"max_attempts"`
+	BaseDelay   time.Duration `json:"base_delay"`
+	MaxDelay    time.Duration `json:"max_delay"`
+}
+
+// StartupRetry configures how often issuance is retried when the client starts
+// without any certificate, instead of waiting for the renewal interval
+type StartupRetry struct {
+	Delay    time.Duration `json:"delay"`
+	MaxDelay time.Duration `json:"max_delay"`
 }
 
+// ACMEConfig configures the ACME issuance backend
+type ACMEConfig struct {
+	DirectoryURL string `json:"directory_url"`
+	Email        string `json:"email"`
+	EABKID       string `json:"eab_kid"`
+	EABHMACKey   string `json:"-"`
+}
+
+// ReportConfig configures the scheduled inventory report
+type ReportConfig struct {
+	Interval   time.Duration `json:"interval"`
+	Format     string        `json:"format"`
+	Recipients []string      `json:"recipients"`
+
+	// CalendarFile, when set, is rewritten with an iCalendar feed of expiry dates after every check
+	CalendarFile string `json:"calendar_file"`
+}
+
+// SMTPConfig configures the mail server used for notifications
+type SMTPConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"-"`
+	From     string `json:"from"`
+}
+
+// SIEMConfig configures the export of certificate lifecycle events to a SIEM
+type SIEMConfig struct {
+	// Addr is the host:port of the TLS listener; empty disables the export
+	Addr   string `json:"addr"`
+	Format string `json:"format"`
+
+	// CAFile verifies the listener with a private CA instead of the system roots
+	CAFile string `json:"ca_file"`
+
+	// SigningKey, when set, signs every event with HMAC-SHA256
+	SigningKey string        `json:"-"`
+	Timeout    time.Duration `json:"timeout"`
+}
+
+// Line endings and part orders of ZeroSSL validation files
+const (
+	ValidationEOLLF          = "lf"
+	ValidationEOLCRLF        = "crlf"
+	ValidationOrderAPI       = "api"
+	ValidationOrderCanonical = "canonical"
+)
+
+// Certificate file formats written to IPSSL_SSL_DIR
+const (
+	OutputPEM     = "pem"
+	OutputDER     = "der"
+	OutputHAProxy = "haproxy"
+	OutputSplit   = "split"
+	OutputLive    = "live"
+	OutputPins    = "pins"
+	OutputP7B     = "p7b"
+	OutputJSON    = "json"
+	OutputCaddy   = "caddy"
+)
+
+// SIEM event formats
+const (
+	SIEMFormatJSON = "json"
+	SIEMFormatCEF  = "cef"
+)
+
+// VRRPConfig configures active/standby operation behind a virtual IP managed
+// by keepalived or another VRRP daemon
+type VRRPConfig struct {
+	// VIP is the virtual IP; the node with it on a local interface is active
+	VIP string `json:"vip"`
+
+	// CheckCommand, when set, decides instead: exit status 0 means active
+	CheckCommand string `json:"check_command"`
+}
+
+// Enabled reports whether issuance is restricted to the active node
+func (v VRRPConfig) Enabled() bool {
+	return v.VIP != "" || v.CheckCommand != ""
+}
+
+// Certificate issuance backends
+const (
+	ProviderZeroSSL = "zerossl"
+	ProviderACME    = "acme"
+)
+
+// Let's Encrypt ACME directories used unless IPSSL_ACME_DIRECTORY is set
+const (
+	LetsEncryptDirectory        = "https://acme-v02.api.letsencrypt.org/directory"
+	LetsEncryptStagingDirectory = "https://acme-staging-v02.api.letsencrypt.org/directory"
+)
+
+// ZeroSSLDirectory is the ZeroSSL ACME directory, which requires External Account Binding
+const ZeroSSLDirectory = "https://acme.zerossl.com/v2/DV90"
+
+// Container reload strategies
+const (
+	ReloadSignal    = "signal"
+	ReloadRestart   = "restart"
+	ReloadBlueGreen = "blue-green"
+	ReloadTrigger   = "file"
+)
+
+// Fallback policies when a signal reload does not take effect
+const (
+	FallbackNone    = "none"
+	FallbackRestart = "restart"
+)
+
+// Actions when the installed certificate has expired and renewal keeps failing
+const (
+	ExpiredKeep       = "keep"
+	ExpiredSelfSigned = "self-signed"
+	ExpiredStop       = "stop"
+)
+
 // Load loads configuration from environment variables
 func Load() (*Config, error) {
+	return newLoader(os.LookupEnv).load()
+}
+
+// load reads the configuration and validates it, reporting every invalid
+// setting rather than only the first
+func (l *loader) load() (*Config, error) {
+	var errs []error
 	cfg := &Config{
-		ClientIP:        getEnv("CLIENT_IP", "127.0.0.1"),
-		APIKey:          getEnv("IPSSL_API_KEY", ""),
-		ValidationDir:   getEnv("IPSSL_VALIDATION_DIR", "/usr/share/caddy/"),
-		SSLDir:          getEnv("IPSSL_SSL_DIR", "/ipssl/"),
-		ContainerName:   getEnv("IPSSL_CONTAINER_NAME", "caddy-1"),
-		RenewalInterval: getDurationEnv("RENEWAL_INTERVAL", 24*time.Hour),
-		CertValidity:    getDurationEnv("CERT_VALIDITY", 30*24*time.Hour),
+		ClientIP:       l.getEnv("CLIENT_IP", "127.0.0.1"),
+		Provider:       l.getEnv("IPSSL_PROVIDER", ProviderZeroSSL),
+		APIKey:         l.getEnv("IPSSL_API_KEY", ""),
+		APIURL:         l.getEnv("IPSSL_API_URL", ""),
+		ScrubEnv:       l.getBoolEnv("IPSSL_SCRUB_ENV", false),
+		CleanupStale:   l.getBoolEnv("IPSSL_CLEANUP_STALE", false),
+		CertQuota:      l.getIntEnv("IPSSL_CERT_QUOTA", 0),
+		VerifyLimit:    l.getIntEnv("IPSSL_VERIFY_CONCURRENCY", 0),
+		VerifyQueueDir: l.getEnv("IPSSL_VERIFY_QUEUE_DIR", ""),
+		Sandbox:        l.getBoolEnv("IPSSL_SANDBOX", false),
+		ACME: ACMEConfig{
+			DirectoryURL: l.getEnv("IPSSL_ACME_DIRECTORY", ""),
+			Email:        l.getEnv("IPSSL_ACME_EMAIL", ""),
+			EABKID:       l.getEnv("IPSSL_ACME_EAB_KID", ""),
+			EABHMACKey:   l.getEnv("IPSSL_ACME_EAB_HMAC_KEY", ""),
+		},
+		APIRetry: APIRetryConfig{
+			MaxAttempts: l.getIntEnv("IPSSL_API_MAX_ATTEMPTS", 3),
+			BaseDelay:   l.getDurationEnv("IPSSL_API_RETRY_DELAY", 2*time.Second),
+			MaxDelay:    l.getDurationEnv("IPSSL_API_RETRY_MAX_DELAY", 30*time.Second),
+		},
+		StartupRetry: StartupRetry{
+			Delay:    l.getDurationEnv("IPSSL_STARTUP_RETRY_DELAY", 30*time.Second),
+			MaxDelay: l.getDurationEnv("IPSSL_STARTUP_RETRY_MAX_DELAY", 10*time.Minute),
+		},
+		PollInterval:    l.getDurationEnv("IPSSL_POLL_INTERVAL", 10*time.Second),
+		IssuanceTimeout: l.getDurationEnv("IPSSL_ISSUANCE_TIMEOUT", 30*time.Minute),
+		ValidationDir:   l.getEnv("IPSSL_VALIDATION_DIR", "/usr/share/caddy/"),
+		SelfCheck:       l.getBoolEnv("IPSSL_VALIDATION_SELF_CHECK", true),
+		ValidationEOL:   l.getEnv("IPSSL_VALIDATION_EOL", ValidationEOLLF),
+		ValidationOrder: l.getEnv("IPSSL_VALIDATION_ORDER", ValidationOrderAPI),
+		SSLDir:          l.getEnv("IPSSL_SSL_DIR", "/ipssl/"),
+		StateDir:        l.getEnv("IPSSL_STATE_DIR", ""),
+		OverrideDir:     l.getEnv("IPSSL_OVERRIDE_DIR", ""),
+		HistoryKeep:     l.getIntEnv("IPSSL_HISTORY_KEEP", 0),
+		HistoryMaxAge:   l.getDurationEnv("IPSSL_HISTORY_MAX_AGE", 0),
+		ContainerName:   l.getEnv("IPSSL_CONTAINER_NAME", "caddy-1"),
+		Timezone:        l.getEnv("IPSSL_TIMEZONE", ""),
+		Location:        time.Local,
+		RenewalInterval: l.getDurationEnv("RENEWAL_INTERVAL", 24*time.Hour),
+		CertValidity:    l.getDurationEnv("CERT_VALIDITY", 30*24*time.Hour),
+		RenewalFraction: l.getFractionEnv("IPSSL_RENEWAL_FRACTION", 2.0/3),
+		KeyType:         l.getEnv("IPSSL_KEY_TYPE", keys.RSA2048),
+		KeyTypeFallback: l.getBoolEnv("IPSSL_KEY_TYPE_FALLBACK", true),
+		KeyFormat:       l.getEnv("IPSSL_KEY_FORMAT", keys.FormatTraditional),
+		MustStaple:      l.getBoolEnv("IPSSL_MUST_STAPLE", false),
+		OCSPStaple:      l.getBoolEnv("IPSSL_OCSP_STAPLE", false),
+		RevocationCheck: l.getDurationEnv("IPSSL_REVOCATION_CHECK_INTERVAL", 0),
+		RevokedReissue:  l.getBoolEnv("IPSSL_REVOKED_REISSUE", false),
+		VerifyChain:     l.getBoolEnv("IPSSL_VERIFY_CHAIN", true),
+		ChainRoots:      l.getEnv("IPSSL_CHAIN_ROOTS", ""),
+		VerifyCT:        l.getBoolEnv("IPSSL_VERIFY_CT", false),
+		CTMinSCTs:       l.getIntEnv("IPSSL_CT_MIN_SCTS", 2),
+		CTLogList:       l.getEnv("IPSSL_CT_LOG_LIST", ""),
+		RenewalBudget:   l.getDurationEnv("IPSSL_RENEWAL_BUDGET", 0),
+		RenewalTimeout:  l.getDurationEnv("IPSSL_RENEWAL_TIMEOUT", time.Hour),
+		RenewalRetry:    l.getDurationEnv("IPSSL_RENEWAL_RETRY_DELAY", 5*time.Minute),
+		DockerTimeout:   l.getDurationEnv("IPSSL_DOCKER_TIMEOUT", 30*time.Second),
+		VerifyWrites:    l.getBoolEnv("IPSSL_VERIFY_WRITES", false),
+		ContainerSSLDir: l.getEnv("IPSSL_CONTAINER_SSL_DIR", ""),
+		ReloadStrategy:  l.getEnv("IPSSL_RELOAD_STRATEGY", ReloadSignal),
+		ReloadFile:      l.getEnv("IPSSL_RELOAD_FILE", ""),
+		ReloadFallback:  l.getEnv("IPSSL_RELOAD_FALLBACK", FallbackNone),
+		TLSProbeAddr:    l.getEnv("IPSSL_TLS_PROBE_ADDR", ""),
+		TLSProbeTimeout: l.getDurationEnv("IPSSL_TLS_PROBE_TIMEOUT", 30*time.Second),
+		HealthTimeout:   l.getDurationEnv("IPSSL_HEALTH_TIMEOUT", 60*time.Second),
+		ExpiredAction:   l.getEnv("IPSSL_EXPIRED_ACTION", ExpiredKeep),
+		TSAURL:          l.getEnv("IPSSL_TSA_URL", ""),
+		MetricsAddr:     l.getEnv("IPSSL_METRICS_ADDR", ""),
+		HealthIntervals: l.getIntEnv("IPSSL_HEALTHZ_INTERVALS", 3),
+		Deployers:       l.getListEnv("IPSSL_DEPLOYERS"),
+		HookEnv:         l.getListEnv("IPSSL_HOOK_ENV"),
+		DeployWorkers:   l.getIntEnv("IPSSL_DEPLOY_CONCURRENCY", 4),
+		DeployRetries:   l.getIntEnv("IPSSL_DEPLOY_RETRIES", 0),
+		DeployBackoff:   l.getDurationEnv("IPSSL_DEPLOY_RETRY_DELAY", 30*time.Second),
+		Report: ReportConfig{
+			Interval:     l.getDurationEnv("IPSSL_REPORT_INTERVAL", 0),
+			Format:       l.getEnv("IPSSL_REPORT_FORMAT", "html"),
+			Recipients:   l.getListEnv("IPSSL_REPORT_TO"),
+			CalendarFile: l.getEnv("IPSSL_CALENDAR_FILE", ""),
+		},
+		SMTP: SMTPConfig{
+			Host:     l.getEnv("IPSSL_SMTP_HOST", ""),
+			Port:     l.getIntEnv("IPSSL_SMTP_PORT", 587),
+			Username: l.getEnv("IPSSL_SMTP_USERNAME", ""),
+			Password: l.getEnv("IPSSL_SMTP_PASSWORD", ""),
+			From:     l.getEnv("IPSSL_SMTP_FROM", ""),
+		},
+		SIEM: SIEMConfig{
+			Addr:       l.getEnv("IPSSL_SIEM_ADDR", ""),
+			Format:     l.getEnv("IPSSL_SIEM_FORMAT", SIEMFormatJSON),
+			CAFile:     l.getEnv("IPSSL_SIEM_CA_FILE", ""),
+			SigningKey: l.getEnv("IPSSL_SIEM_SIGNING_KEY", ""),
+			Timeout:    l.getDurationEnv("IPSSL_SIEM_TIMEOUT", 10*time.Second),
+		},
+		VRRP: VRRPConfig{
+			VIP:          l.getEnv("IPSSL_VRRP_VIP", ""),
+			CheckCommand: l.getEnv("IPSSL_VRRP_CHECK", ""),
+		},
+		Compose: ComposeConfig{
+			File:     l.getEnv("IPSSL_COMPOSE_FILE", ""),
+			Project:  l.getEnv("IPSSL_COMPOSE_PROJECT", ""),
+			EnvFile:  l.getEnv("IPSSL_COMPOSE_ENV_FILE", ""),
+			EnvKey:   l.getEnv("IPSSL_COMPOSE_ENV_KEY", "CERT_VERSION"),
+			Services: l.getListEnv("IPSSL_COMPOSE_SERVICES"),
+		},
+		IIS: IISConfig{
+			Site:   l.getEnv("IPSSL_IIS_SITE", "Default Web Site"),
+			Port:   l.getIntEnv("IPSSL_IIS_PORT", 443),
+			BindIP: l.getEnv("IPSSL_IIS_BIND_IP", "*"),
+		},
+		HTTP: HTTPDeployConfig{
+			Method:             l.getEnv("IPSSL_HTTP_METHOD", "POST"),
+			URL:                l.getEnv("IPSSL_HTTP_URL", ""),
+			Headers:            l.getSeparatedEnv("IPSSL_HTTP_HEADERS", ";"),
+			Body:               l.getEnv("IPSSL_HTTP_BODY", ""),
+			BodyFile:           l.getEnv("IPSSL_HTTP_BODY_FILE", ""),
+			ExpectedStatus:     l.getIntListEnv("IPSSL_HTTP_EXPECTED_STATUS"),
+			Timeout:            l.getDurationEnv("IPSSL_HTTP_TIMEOUT", 30*time.Second),
+			InsecureSkipVerify: l.getBoolEnv("IPSSL_HTTP_INSECURE", false),
+		},
+		PKCS12: PKCS12Config{
+			Path:         l.getEnv("IPSSL_PKCS12_PATH", ""),
+			Password:     l.getEnv("IPSSL_PKCS12_PASSWORD", ""),
+			PasswordFile: l.getEnv("IPSSL_PKCS12_PASSWORD_FILE", ""),
+			Legacy:       l.getBoolEnv("IPSSL_PKCS12_LEGACY", false),
+		},
+		Vault: VaultConfig{
+			URL:                l.getEnv("IPSSL_VAULT_URL", ""),
+			Namespace:          l.getEnv("IPSSL_VAULT_NAMESPACE", ""),
+			Mount:              l.getEnv("IPSSL_VAULT_MOUNT", "secret"),
+			Path:               l.getEnv("IPSSL_VAULT_PATH", "ipssl/{{.IP}}"),
+			KVVersion:          l.getIntEnv("IPSSL_VAULT_KV_VERSION", 2),
+			Token:              l.getEnv("IPSSL_VAULT_TOKEN", ""),
+			TokenFile:          l.getEnv("IPSSL_VAULT_TOKEN_FILE", ""),
+			AppRoleMount:       l.getEnv("IPSSL_VAULT_APPROLE_MOUNT", "approle"),
+			RoleID:             l.getEnv("IPSSL_VAULT_ROLE_ID", ""),
+			SecretID:           l.getEnv("IPSSL_VAULT_SECRET_ID", ""),
+			SecretIDFile:       l.getEnv("IPSSL_VAULT_SECRET_ID_FILE", ""),
+			InsecureSkipVerify: l.getBoolEnv("IPSSL_VAULT_INSECURE", false),
+		},
+		AWS: AWSConfig{
+			Region:          l.getEnv("AWS_REGION", ""),
+			AccessKeyID:     l.getEnv("AWS_ACCESS_KEY_ID", ""),
+			SecretAccessKey: l.getEnv("AWS_SECRET_ACCESS_KEY", ""),
+			SessionToken:    l.getEnv("AWS_SESSION_TOKEN", ""),
+			ContainerCreds:  l.getEnv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", ""),
+			ACMARNs:         l.getListEnv("IPSSL_AWS_ACM_CERTIFICATE_ARN"),
+			SecretID:        l.getEnv("IPSSL_AWS_SECRET_ID", ""),
+		},
+		Azure: AzureConfig{
+			VaultURL:     l.getEnv("IPSSL_AZURE_VAULT_URL", ""),
+			CertName:     l.getEnv("IPSSL_AZURE_CERT_NAME", "ipssl"),
+			TenantID:     l.getEnv("AZURE_TENANT_ID", ""),
+			ClientID:     l.getEnv("AZURE_CLIENT_ID", ""),
+			ClientSecret: l.getEnv("AZURE_CLIENT_SECRET", ""),
+		},
+		Kubernetes: KubeConfig{
+			URL:       l.getEnv("IPSSL_KUBERNETES_URL", ""),
+			Namespace: l.getEnv("IPSSL_KUBERNETES_NAMESPACE", ""),
+			Secret:    l.getEnv("IPSSL_KUBERNETES_SECRET", "ipssl-tls"),
+			TokenFile: l.getEnv("IPSSL_KUBERNETES_TOKEN_FILE", "/var/run/secrets/kubernetes.io/serviceaccount/token"),
+			CAFile:    l.getEnv("IPSSL_KUBERNETES_CA_FILE", "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"),
+		},
+	}
+	// In a pod the API server is announced through the service environment
+	if cfg.Kubernetes.URL == "" {
+		if host := l.getEnv("KUBERNETES_SERVICE_HOST", ""); host != "" {
+			cfg.Kubernetes.URL = "https://" + net.JoinHostPort(host, l.getEnv("KUBERNETES_SERVICE_PORT", "443"))
+		}
+	}
+
+	// CLIENT_IP may list several addresses; the first one is the primary
+	// identifier. Without CLIENT_IP, IPSSL_DOMAINS alone requests a certificate
+	// for hostnames only.
+	cfg.ClientIPs = l.getListEnv("CLIENT_IP")
+	cfg.Domains = l.getListEnv("IPSSL_DOMAINS")
+	cfg.IPAllow = l.getListEnv("IPSSL_IP_ALLOW")
+	cfg.IPDeny = l.getListEnv("IPSSL_IP_DENY")
+	cfg.OwnershipCheck = l.getBoolEnv("IPSSL_OWNERSHIP_CHECK", false)
+	cfg.OwnershipPorts = l.getIntListEnv("IPSSL_OWNERSHIP_PORTS")
+	cfg.OutputFormats = l.getListEnv("IPSSL_OUTPUT_FORMATS")
+	if len(cfg.OutputFormats) == 0 {
+		cfg.OutputFormats = []string{OutputPEM}
+	}
+	cfg.LiveName = l.getEnv("IPSSL_LIVE_NAME", "")
+	cfg.CaddyBind = l.getListEnv("IPSSL_CADDY_BIND")
+	if name := cfg.LiveName; name != "" && (filepath.Base(name) != name || name == "." || name == "..") {
+		errs = append(errs, fmt.Errorf("invalid IPSSL_LIVE_NAME %q (expected a name usable as a directory)", cfg.LiveName))
+	}
+	cfg.CertTemplate = l.getEnv("IPSSL_CERT_PATH_TEMPLATE", "")
+	cfg.KeyTemplate = l.getEnv("IPSSL_KEY_PATH_TEMPLATE", "")
+	cfg.Files = FilesConfig{
+		CertMode: l.getModeEnv("IPSSL_CERT_MODE"),
+		KeyMode:  l.getModeEnv("IPSSL_KEY_MODE"),
+		DirMode:  l.getModeEnv("IPSSL_SSL_DIR_MODE"),
+		Owner:    l.getEnv("IPSSL_FILE_OWNER", ""),
+		Group:    l.getEnv("IPSSL_FILE_GROUP", ""),
+	}
+	if len(cfg.ClientIPs) == 0 && len(cfg.Domains) == 0 {
+		cfg.ClientIPs = []string{cfg.ClientIP}
+	}
+	cfg.ClientIP = ""
+	if len(cfg.ClientIPs) > 0 {
+		cfg.ClientIP = cfg.ClientIPs[0]
+	}
+
+	// IPSSL_VALIDATION_DIR may list the document roots of several web nodes
+	// sharing the address; validation files are written to all of them
+	cfg.ValidationDirs = l.getListEnv("IPSSL_VALIDATION_DIR")
+	if len(cfg.ValidationDirs) == 0 {
+		cfg.ValidationDirs = []string{cfg.ValidationDir}
 	}
+	cfg.ValidationDir = cfg.ValidationDirs[0]
 
-	if cfg.APIKey == "" {
-		return nil, fmt.Errorf("IPSSL_API_KEY environment variable is required")
+	// Upload targets are read as IPSSL_UPLOAD_<NAME>_URL etc. for each listed target
+	for _, name := range l.getListEnv("IPSSL_UPLOAD_TARGETS") {
+		prefix := "IPSSL_UPLOAD_" + strings.ToUpper(name) + "_"
+		cfg.Upload = append(cfg.Upload, UploadTarget{
+			Name:               name,
+			URL:                l.getEnv(prefix+"URL", ""),
+			Username:           l.getEnv(prefix+"USERNAME", ""),
+			Password:           l.getEnv(prefix+"PASSWORD", ""),
+			CertPath:           l.getEnv(prefix+"CERT_PATH", "cert.pem"),
+			KeyPath:            l.getEnv(prefix+"KEY_PATH", "key.pem"),
+			PFXPassword:        l.getEnv(prefix+"PFX_PASSWORD", ""),
+			InsecureSkipVerify: l.getBoolEnv(prefix+"INSECURE", false),
+		})
+		// IPSSL_UPLOAD_<NAME>_FILES lists path=content entries replacing CERT_PATH and KEY_PATH
+		for _, entry := range l.getListEnv(prefix + "FILES") {
+			i := strings.LastIndex(entry, "=")
+			if i <= 0 || i == len(entry)-1 {
+				errs = append(errs, fmt.Errorf("invalid %sFILES entry %q (expected path=content)", prefix, entry))
+				continue
+			}
+			target := &cfg.Upload[len(cfg.Upload)-1]
+			target.Files = append(target.Files, UploadFile{Path: entry[:i], Content: entry[i+1:]})
+		}
+	}
+
+	// SSH targets are read as IPSSL_SSH_<NAME>_HOST etc. for each listed target
+	for _, name := range l.getListEnv("IPSSL_SSH_TARGETS") {
+		prefix := "IPSSL_SSH_" + strings.ToUpper(name) + "_"
+		cfg.SSH = append(cfg.SSH, SSHTarget{
+			Name:                  name,
+			Host:                  l.getEnv(prefix+"HOST", ""),
+			User:                  l.getEnv(prefix+"USER", "root"),
+			Password:              l.getEnv(prefix+"PASSWORD", ""),
+			KeyFile:               l.getEnv(prefix+"KEY_FILE", ""),
+			Recipe:                l.getEnv(prefix+"RECIPE", ""),
+			KnownHosts:            l.getEnv(prefix+"KNOWN_HOSTS", ""),
+			InsecureIgnoreHostKey: l.getBoolEnv(prefix+"INSECURE_IGNORE_HOST_KEY", false),
+			HostKeyFingerprints:   l.getListEnv(prefix + "HOST_KEY_FINGERPRINT"),
+		})
+	}
+
+	// Certificate sets are read as IPSSL_CERTIFICATE_<NAME>_IPS etc. for each listed set
+	for _, name := range l.getListEnv("IPSSL_CERTIFICATES") {
+		prefix := "IPSSL_CERTIFICATE_" + strings.ToUpper(name) + "_"
+		cfg.Certificates = append(cfg.Certificates, CertificateSet{
+			Name:          name,
+			ClientIPs:     l.getListEnv(prefix + "IPS"),
+			Domains:       l.getListEnv(prefix + "DOMAINS"),
+			SSLDir:        l.getEnv(prefix+"SSL_DIR", ""),
+			ContainerName: l.getEnv(prefix+"CONTAINER", ""),
+		})
+		set := &cfg.Certificates[len(cfg.Certificates)-1]
+		var labelErrs []error
+		set.Labels, labelErrs = parseLabels(prefix+"LABELS", l.getListEnv(prefix+"LABELS"))
+		errs = append(errs, labelErrs...)
+	}
+
+	// Labels are read as <name>=<value> entries
+	var labelErrs []error
+	cfg.Labels, labelErrs = parseLabels("IPSSL_LABELS", l.getListEnv("IPSSL_LABELS"))
+	errs = append(errs, labelErrs...)
+
+	// With IPSSL_CERTIFICATE_PER_IP every address in CLIENT_IP gets a
+	// certificate of its own, kept in SSL_DIR/<ip>
+	cfg.CertPerIP = l.getBoolEnv("IPSSL_CERTIFICATE_PER_IP", false)
+	if cfg.CertPerIP {
+		switch {
+		case len(cfg.Certificates) > 0:
+			errs = append(errs, fmt.Errorf("IPSSL_CERTIFICATE_PER_IP and IPSSL_CERTIFICATES cannot be used together"))
+		case len(cfg.Domains) > 0:
+			errs = append(errs, fmt.Errorf("IPSSL_CERTIFICATE_PER_IP does not support IPSSL_DOMAINS (list hostnames per certificate in IPSSL_CERTIFICATES)"))
+		default:
+			for _, ip := range cfg.ClientIPs {
+				cfg.Certificates = append(cfg.Certificates, CertificateSet{Name: ip, ClientIPs: []string{ip}})
+			}
+		}
+	}
+
+	// IPSSL_CONTAINER_MAP names the container serving each address, as
+	// <ip>=<container> entries, for certificates without their own container
+	cfg.ContainerMap = make(map[string]string)
+	for _, entry := range l.getListEnv("IPSSL_CONTAINER_MAP") {
+		ip, container, ok := strings.Cut(entry, "=")
+		ip, container = strings.TrimSpace(ip), strings.TrimSpace(container)
+		if !ok || net.ParseIP(ip) == nil || container == "" {
+			errs = append(errs, fmt.Errorf("invalid IPSSL_CONTAINER_MAP entry %q (expected <ip>=<container>)", entry))
+			continue
+		}
+		cfg.ContainerMap[ip] = container
+	}
+
+	// Per-deployer settings are read as IPSSL_<DEPLOYER>_CERT_PATH etc. for each configured deployer
+	cfg.Presets = make(map[string]PresetConfig)
+	cfg.Appliances = make(map[string]ApplianceConfig)
+	for _, name := range cfg.Deployers {
+		prefix := "IPSSL_" + strings.ToUpper(name) + "_"
+		cfg.Appliances[name] = ApplianceConfig{
+			URL:                l.getEnv(prefix+"URL", ""),
+			Username:           l.getEnv(prefix+"USERNAME", ""),
+			Password:           l.getEnv(prefix+"PASSWORD", ""),
+			APIKey:             l.getEnv(prefix+"API_KEY", ""),
+			APISecret:          l.getEnv(prefix+"API_SECRET", ""),
+			CertName:           l.getEnv(prefix+"CERT_NAME", "ipssl"),
+			Node:               l.getEnv(prefix+"NODE", ""),
+			InsecureSkipVerify: l.getBoolEnv(prefix+"INSECURE", false),
+		}
+		cfg.Presets[name] = PresetConfig{
+			CertPath:      l.getEnv(prefix+"CERT_PATH", ""),
+			KeyPath:       l.getEnv(prefix+"KEY_PATH", ""),
+			Owner:         l.getEnv(prefix+"OWNER", ""),
+			Group:         l.getEnv(prefix+"GROUP", ""),
+			ReloadCommand: l.getEnv(prefix+"RELOAD_COMMAND", ""),
+		}
 	}
 
+	switch cfg.Provider {
+	case ProviderZeroSSL:
+		if cfg.APIKey == "" {
+			errs = append(errs, fmt.Errorf("IPSSL_API_KEY environment variable is required"))
+		}
+		// ZeroSSL has no fixed sandbox host, so sandbox mode must point at one
+		// explicitly rather than silently using the production API
+		if cfg.Sandbox && cfg.APIURL == "" {
+			errs = append(errs, fmt.Errorf("IPSSL_SANDBOX requires IPSSL_API_URL for the %s provider", ProviderZeroSSL))
+		}
+	case ProviderACME:
+		if cfg.ACME.DirectoryURL == "" {
+			cfg.ACME.DirectoryURL = LetsEncryptDirectory
+			if cfg.Sandbox {
+				cfg.ACME.DirectoryURL = LetsEncryptStagingDirectory
+			}
+		}
+		if (cfg.ACME.EABKID == "") != (cfg.ACME.EABHMACKey == "") {
+			errs = append(errs, fmt.Errorf("IPSSL_ACME_EAB_KID and IPSSL_ACME_EAB_HMAC_KEY must be set together"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("invalid IPSSL_PROVIDER %q (expected %s or %s)", cfg.Provider, ProviderZeroSSL, ProviderACME))
+	}
+
+	if cfg.CertQuota < 0 {
+		errs = append(errs, fmt.Errorf("invalid IPSSL_CERT_QUOTA %d (expected 0 or a positive number)", cfg.CertQuota))
+	}
+	if cfg.VerifyLimit < 0 {
+		errs = append(errs, fmt.Errorf("invalid IPSSL_VERIFY_CONCURRENCY %d (expected 0 or a positive number)", cfg.VerifyLimit))
+	}
+
+	if cfg.PollInterval <= 0 {
+		errs = append(errs, fmt.Errorf("invalid IPSSL_POLL_INTERVAL %s (expected a positive duration)", cfg.PollInterval))
+	}
+	if cfg.IssuanceTimeout < 0 {
+		errs = append(errs, fmt.Errorf("invalid IPSSL_ISSUANCE_TIMEOUT %s (expected 0 or a positive duration)", cfg.IssuanceTimeout))
+	}
+	if cfg.RenewalTimeout < 0 {
+		errs = append(errs, fmt.Errorf("invalid IPSSL_RENEWAL_TIMEOUT %s (expected 0 or a positive duration)", cfg.RenewalTimeout))
+	}
+	// The watchdog must leave issuance polling enough time to give up on its own
+	if cfg.RenewalTimeout > 0 && cfg.IssuanceTimeout > 0 && cfg.RenewalTimeout <= cfg.IssuanceTimeout {
+		errs = append(errs, fmt.Errorf("invalid IPSSL_RENEWAL_TIMEOUT %s (expected longer than IPSSL_ISSUANCE_TIMEOUT %s)", cfg.RenewalTimeout, cfg.IssuanceTimeout))
+	}
+	if cfg.CTMinSCTs < 1 {
+		errs = append(errs, fmt.Errorf("invalid IPSSL_CT_MIN_SCTS %d (expected a positive number)", cfg.CTMinSCTs))
+	}
+	if cfg.HistoryKeep < 0 {
+		errs = append(errs, fmt.Errorf("invalid IPSSL_HISTORY_KEEP %d (expected 0 or a positive number)", cfg.HistoryKeep))
+	}
+	if cfg.HistoryMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("invalid IPSSL_HISTORY_MAX_AGE %s (expected 0 or a positive duration)", cfg.HistoryMaxAge))
+	}
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid IPSSL_TIMEZONE %q (expected an IANA time zone such as Asia/Shanghai)", cfg.Timezone))
+		} else {
+			cfg.Location = loc
+		}
+	}
+	if cfg.HealthIntervals < 0 {
+		errs = append(errs, fmt.Errorf("invalid IPSSL_HEALTHZ_INTERVALS %d (expected 0 or a positive number)", cfg.HealthIntervals))
+	}
+	if cfg.RenewalRetry <= 0 {
+		errs = append(errs, fmt.Errorf("invalid IPSSL_RENEWAL_RETRY_DELAY %s (expected a positive duration)", cfg.RenewalRetry))
+	}
+
+	if cfg.RevocationCheck < 0 {
+		errs = append(errs, fmt.Errorf("invalid IPSSL_REVOCATION_CHECK_INTERVAL %s (expected a positive duration, or 0 to disable)", cfg.RevocationCheck))
+	}
+	if cfg.RevokedReissue && cfg.RevocationCheck == 0 {
+		errs = append(errs, fmt.Errorf("IPSSL_REVOKED_REISSUE requires IPSSL_REVOCATION_CHECK_INTERVAL"))
+	}
+
+	if cfg.RenewalFraction < 0 || cfg.RenewalFraction >= 1 {
+		errs = append(errs, fmt.Errorf("invalid IPSSL_RENEWAL_FRACTION %g (expected a fraction below 1, e.g. 2/3, or 0 to disable)", cfg.RenewalFraction))
+	}
+
+	if cfg.APIRetry.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("invalid IPSSL_API_MAX_ATTEMPTS %d (expected at least 1)", cfg.APIRetry.MaxAttempts))
+	}
+
+	if cfg.StartupRetry.Delay < 0 {
+		errs = append(errs, fmt.Errorf("invalid IPSSL_STARTUP_RETRY_DELAY %s (expected 0 or a positive duration)", cfg.StartupRetry.Delay))
+	}
+	if cfg.StartupRetry.MaxDelay < cfg.StartupRetry.Delay {
+		errs = append(errs, fmt.Errorf("invalid IPSSL_STARTUP_RETRY_MAX_DELAY %s (expected at least IPSSL_STARTUP_RETRY_DELAY %s)", cfg.StartupRetry.MaxDelay, cfg.StartupRetry.Delay))
+	}
+
+	if cfg.APIURL != "" {
+		if u, err := url.Parse(cfg.APIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("invalid IPSSL_API_URL %q (expected an http or https URL)", cfg.APIURL))
+		}
+	}
+
+	for _, ip := range cfg.ClientIPs {
+		if net.ParseIP(ip) == nil {
+			errs = append(errs, fmt.Errorf("invalid IP address in CLIENT_IP: %s", ip))
+		}
+	}
+	for _, domain := range cfg.Domains {
+		if net.ParseIP(domain) != nil || strings.ContainsAny(domain, "/: ") {
+			errs = append(errs, fmt.Errorf("invalid hostname in IPSSL_DOMAINS: %s (IP addresses belong in CLIENT_IP)", domain))
+		}
+	}
+	for _, r := range cfg.IPAllow {
+		if _, err := parsePrefix(r); err != nil {
+			errs = append(errs, fmt.Errorf("invalid IPSSL_IP_ALLOW entry %q (expected an IP address or CIDR range)", r))
+		}
+	}
+	for _, r := range cfg.IPDeny {
+		if _, err := parsePrefix(r); err != nil {
+			errs = append(errs, fmt.Errorf("invalid IPSSL_IP_DENY entry %q (expected an IP address or CIDR range)", r))
+		}
+	}
+	for _, port := range cfg.OwnershipPorts {
+		if port < 1 || port > 65535 {
+			errs = append(errs, fmt.Errorf("invalid IPSSL_OWNERSHIP_PORTS entry %d (expected a port between 1 and 65535)", port))
+		}
+	}
+
+	// Each certificate set keeps its files in its own SSL directory, and its
+	// state and overrides in a subdirectory of the shared directories if set
+	sets := make(map[string]bool)
+	for i := range cfg.Certificates {
+		set := &cfg.Certificates[i]
+		if sets[set.Name] {
+			errs = append(errs, fmt.Errorf("duplicate certificate set %q in IPSSL_CERTIFICATES", set.Name))
+		}
+		sets[set.Name] = true
+		if filepath.Base(set.Name) != set.Name || set.Name == "." || set.Name == ".." {
+			errs = append(errs, fmt.Errorf("invalid certificate set name %q in IPSSL_CERTIFICATES (expected a name usable as a directory)", set.Name))
+		}
+		if len(set.ClientIPs) == 0 && len(set.Domains) == 0 {
+			errs = append(errs, fmt.Errorf("certificate set %q requires IPSSL_CERTIFICATE_%s_IPS or IPSSL_CERTIFICATE_%s_DOMAINS", set.Name, strings.ToUpper(set.Name), strings.ToUpper(set.Name)))
+		}
+		for _, ip := range set.ClientIPs {
+			if net.ParseIP(ip) == nil {
+				errs = append(errs, fmt.Errorf("invalid IP address in IPSSL_CERTIFICATE_%s_IPS: %s", strings.ToUpper(set.Name), ip))
+			}
+		}
+		if set.SSLDir == "" {
+			set.SSLDir = filepath.Join(cfg.SSLDir, set.Name)
+			// Files are only verified inside the container where the
+			// directory of the set is known to be mounted
+			if cfg.ContainerSSLDir != "" {
+				set.ContainerDir = path.Join(cfg.ContainerSSLDir, set.Name)
+			}
+		}
+		if set.ContainerName == "" && len(set.ClientIPs) > 0 {
+			set.ContainerName = cfg.ContainerMap[set.ClientIPs[0]]
+		}
+		if set.ContainerName == "" {
+			set.ContainerName = cfg.ContainerName
+		}
+		set.StateDir = set.SSLDir
+		if cfg.StateDir != "" {
+			set.StateDir = filepath.Join(cfg.StateDir, set.Name)
+		}
+		set.OverrideDir = filepath.Join(set.SSLDir, "override")
+		if cfg.OverrideDir != "" {
+			set.OverrideDir = filepath.Join(cfg.OverrideDir, set.Name)
+		}
+		set.ReloadFile = cfg.ReloadFile
+		if set.ReloadFile == "" && cfg.ReloadStrategy == ReloadTrigger {
+			set.ReloadFile = filepath.Join(set.SSLDir, "reload.trigger")
+		}
+		set.TLSProbeAddr = cfg.TLSProbeAddr
+		if identifiers := append(append([]string{}, set.ClientIPs...), set.Domains...); set.TLSProbeAddr == "" && len(identifiers) > 0 {
+			set.TLSProbeAddr = net.JoinHostPort(identifiers[0], "443")
+		}
+	}
+
+	switch cfg.ReloadStrategy {
+	case ReloadSignal, ReloadRestart, ReloadBlueGreen:
+	case ReloadTrigger:
+		if cfg.ReloadFile == "" {
+			cfg.ReloadFile = filepath.Join(cfg.SSLDir, "reload.trigger")
+		}
+	default:
+		errs = append(errs, fmt.Errorf("invalid IPSSL_RELOAD_STRATEGY %q (expected %s, %s, %s or %s)", cfg.ReloadStrategy, ReloadSignal, ReloadRestart, ReloadBlueGreen, ReloadTrigger))
+	}
+
+	switch cfg.ReloadFallback {
+	case FallbackNone, FallbackRestart:
+	default:
+		errs = append(errs, fmt.Errorf("invalid IPSSL_RELOAD_FALLBACK %q (expected %s or %s)", cfg.ReloadFallback, FallbackNone, FallbackRestart))
+	}
+	switch cfg.ExpiredAction {
+	case ExpiredKeep, ExpiredSelfSigned:
+	case ExpiredStop:
+		if cfg.ContainerName == "" || cfg.ReloadStrategy == ReloadTrigger {
+			errs = append(errs, fmt.Errorf("IPSSL_EXPIRED_ACTION=%s requires IPSSL_CONTAINER_NAME and Docker access (not IPSSL_RELOAD_STRATEGY=%s)", ExpiredStop, ReloadTrigger))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("invalid IPSSL_EXPIRED_ACTION %q (expected %s, %s or %s)", cfg.ExpiredAction, ExpiredKeep, ExpiredSelfSigned, ExpiredStop))
+	}
+
+	if cfg.ContainerSSLDir != "" && !path.IsAbs(cfg.ContainerSSLDir) {
+		errs = append(errs, fmt.Errorf("invalid IPSSL_CONTAINER_SSL_DIR %q (expected an absolute path inside the container)", cfg.ContainerSSLDir))
+	}
+
+	if cfg.VRRP.VIP != "" && net.ParseIP(cfg.VRRP.VIP) == nil {
+		errs = append(errs, fmt.Errorf("invalid IPSSL_VRRP_VIP %q (expected an IP address)", cfg.VRRP.VIP))
+	}
+
+	if cfg.OverrideDir == "" {
+		cfg.OverrideDir = filepath.Join(cfg.SSLDir, "override")
+	}
+	if cfg.StateDir == "" {
+		cfg.StateDir = cfg.SSLDir
+	}
+	if cfg.VerifyQueueDir == "" {
+		cfg.VerifyQueueDir = filepath.Join(cfg.StateDir, "verify-queue")
+	}
+	if cfg.TLSProbeAddr == "" {
+		cfg.TLSProbeAddr = net.JoinHostPort(cfg.Primary(), "443")
+	}
+
+	if !keys.Valid(cfg.KeyType) {
+		errs = append(errs, fmt.Errorf("invalid IPSSL_KEY_TYPE %q (expected one of %s)", cfg.KeyType, strings.Join(keys.All, ", ")))
+	}
+	switch cfg.KeyFormat {
+	case keys.FormatTraditional, keys.FormatPKCS8:
+	default:
+		errs = append(errs, fmt.Errorf("invalid IPSSL_KEY_FORMAT %q (expected %s or %s)", cfg.KeyFormat, keys.FormatTraditional, keys.FormatPKCS8))
+	}
+
+	switch cfg.Report.Format {
+	case "text", "json", "html":
+	default:
+		errs = append(errs, fmt.Errorf("invalid IPSSL_REPORT_FORMAT %q (expected text, json or html)", cfg.Report.Format))
+	}
+	switch cfg.ValidationEOL {
+	case ValidationEOLLF, ValidationEOLCRLF:
+	default:
+		errs = append(errs, fmt.Errorf("invalid IPSSL_VALIDATION_EOL %q (expected %s or %s)", cfg.ValidationEOL, ValidationEOLLF, ValidationEOLCRLF))
+	}
+	switch cfg.ValidationOrder {
+	case ValidationOrderAPI, ValidationOrderCanonical:
+	default:
+		errs = append(errs, fmt.Errorf("invalid IPSSL_VALIDATION_ORDER %q (expected %s or %s)", cfg.ValidationOrder, ValidationOrderAPI, ValidationOrderCanonical))
+	}
+	for _, format := range cfg.OutputFormats {
+		switch format {
+		case OutputPEM, OutputDER, OutputHAProxy, OutputSplit, OutputLive, OutputPins, OutputP7B, OutputJSON, OutputCaddy:
+		default:
+			errs = append(errs, fmt.Errorf("invalid IPSSL_OUTPUT_FORMATS entry %q (expected %s, %s, %s, %s, %s, %s, %s, %s or %s)", format, OutputPEM, OutputDER, OutputHAProxy, OutputSplit, OutputLive, OutputPins, OutputP7B, OutputJSON, OutputCaddy))
+		}
+	}
+	for _, tmpl := range []struct{ name, text string }{
+		{"IPSSL_CERT_PATH_TEMPLATE", cfg.CertTemplate},
+		{"IPSSL_KEY_PATH_TEMPLATE", cfg.KeyTemplate},
+	} {
+		if _, err := template.New(tmpl.name).Parse(tmpl.text); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s %q: %w", tmpl.name, tmpl.text, err))
+		}
+	}
+	switch cfg.SIEM.Format {
+	case SIEMFormatJSON, SIEMFormatCEF:
+	default:
+		errs = append(errs, fmt.Errorf("invalid IPSSL_SIEM_FORMAT %q (expected %s or %s)", cfg.SIEM.Format, SIEMFormatJSON, SIEMFormatCEF))
+	}
+	if cfg.SIEM.Addr != "" {
+		if _, _, err := net.SplitHostPort(cfg.SIEM.Addr); err != nil {
+			errs = append(errs, fmt.Errorf("invalid IPSSL_SIEM_ADDR %q (expected host:port)", cfg.SIEM.Addr))
+		}
+		if cfg.SIEM.Timeout <= 0 {
+			errs = append(errs, fmt.Errorf("invalid IPSSL_SIEM_TIMEOUT %s (expected a positive duration)", cfg.SIEM.Timeout))
+		}
+	}
+
+	if cfg.Report.Interval > 0 && (len(cfg.Report.Recipients) == 0 || cfg.SMTP.Host == "") {
+		errs = append(errs, fmt.Errorf("IPSSL_REPORT_INTERVAL requires IPSSL_REPORT_TO and IPSSL_SMTP_HOST"))
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
 	return cfg, nil
 }
 
+// labelName matches label names, which must be valid Prometheus label names
+var labelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// parseLabels parses the <name>=<value> entries of the variable key
+func parseLabels(key string, entries []string) (map[string]string, []error) {
+	labels := make(map[string]string)
+	var errs []error
+	for _, entry := range entries {
+		name, value, ok := strings.Cut(entry, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || !labelName.MatchString(name) {
+			errs = append(errs, fmt.Errorf("invalid %s entry %q (expected <name>=<value> with a name of letters, digits and underscores)", key, entry))
+			continue
+		}
+		labels[name] = value
+	}
+	return labels, errs
+}
+
+// FormatLabels renders labels as name=value pairs sorted by name
+func FormatLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + labels[name]
+	}
+	return strings.Join(pairs, ",")
+}
+
 // getEnv gets an environment variable with a default value
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
+func (l *loader) getEnv(key, defaultValue string) string {
+	if value := l.value(key, kindString, defaultValue); value != "" {
 		return value
 	}
 	return defaultValue
 }
 
+// IISConfig configures the Windows IIS binding deployer
+type IISConfig struct {
+	Site   string `json:"site"`
+	Port   int    `json:"port"`
+	BindIP string `json:"bind_ip"`
+}
+
+// FilesConfig sets the permissions and ownership of the certificate and key
+// files written to IPSSL_SSL_DIR and of the directory itself, e.g. for a web
+// server running as a non-root user. Zero modes and empty names keep the
+// defaults.
+type FilesConfig struct {
+	CertMode os.FileMode `json:"cert_mode"`
+	KeyMode  os.FileMode `json:"key_mode"`
+	DirMode  os.FileMode `json:"dir_mode"`
+
+	// Owner and Group are names or numeric IDs, which need not exist in this container
+	Owner string `json:"owner"`
+	Group string `json:"group"`
+}
+
+// PKCS12Config configures the deployer that writes a PKCS#12 (.pfx) bundle
+// next to the PEM files for consumers such as IIS or Java keystores
+type PKCS12Config struct {
+	Path         string `json:"path"`
+	Password     string `json:"-"`
+	PasswordFile string `json:"password_file"`
+
+	// Legacy selects the 3DES/SHA-1 encoding understood by older Windows and Java versions
+	Legacy bool `json:"legacy"`
+}
+
+// HTTPDeployConfig configures the generic HTTP request deployer
+type HTTPDeployConfig struct {
+	Method             string        `json:"method"`
+	URL                string        `json:"url"`
+	Headers            []string      `json:"headers"`
+	Body               string        `json:"body"`
+	BodyFile           string        `json:"body_file"`
+	ExpectedStatus     []int         `json:"expected_status"`
+	Timeout            time.Duration `json:"timeout"`
+	InsecureSkipVerify bool          `json:"insecure_skip_verify"`
+}
+
+// VaultConfig configures the HashiCorp Vault KV deployer. It authenticates
+// with a token, or logs in with AppRole when RoleID is set.
+type VaultConfig struct {
+	URL          string `json:"url"`
+	Namespace    string `json:"namespace"`
+	Mount        string `json:"mount"`
+	Path         string `json:"path"`
+	KVVersion    int    `json:"kv_version"`
+	Token        string `json:"-"`
+	TokenFile    string `json:"token_file"`
+	AppRoleMount string `json:"approle_mount"`
+	RoleID       string `json:"role_id"`
+	SecretID     string `json:"-"`
+	SecretIDFile string `json:"secret_id_file"`
+
+	InsecureSkipVerify bool `json:"insecure_skip_verify"`
+}
+
+// AWSConfig configures the AWS deployer, which imports the certificate into
+// ACM and stores it in Secrets Manager. Credentials are read from the standard
+// AWS variables, or from the ECS task role or EC2 instance profile.
+type AWSConfig struct {
+	Region          string   `json:"region"`
+	AccessKeyID     string   `json:"access_key_id"`
+	SecretAccessKey string   `json:"-"`
+	SessionToken    string   `json:"-"`
+	ContainerCreds  string   `json:"container_credentials_uri"`
+	ACMARNs         []string `json:"acm_certificate_arns"`
+	SecretID        string   `json:"secret_id"`
+}
+
+// AzureConfig configures the Azure Key Vault deployer. It authenticates as a
+// service principal when ClientSecret is set, and with the managed identity
+// otherwise (ClientID selecting a user-assigned identity).
+type AzureConfig struct {
+	VaultURL     string `json:"vault_url"`
+	CertName     string `json:"cert_name"`
+	TenantID     string `json:"tenant_id"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"-"`
+}
+
+// KubeConfig configures the Kubernetes TLS Secret deployer. The defaults are
+// those of the pod's service account.
+type KubeConfig struct {
+	URL       string `json:"url"`
+	Namespace string `json:"namespace"`
+	Secret    string `json:"secret"`
+	TokenFile string `json:"token_file"`
+	CAFile    string `json:"ca_file"`
+}
+
+// CertificateSet is one of several certificates managed side by side, each
+// with its own identifiers, directories and renewal schedule
+type CertificateSet struct {
+	Name          string   `json:"name"`
+	ClientIPs     []string `json:"client_ips"`
+	Domains       []string `json:"domains"`
+	SSLDir        string   `json:"ssl_dir"`
+	StateDir      string   `json:"state_dir"`
+	OverrideDir   string   `json:"override_dir"`
+	ContainerName string   `json:"container_name"`
+	ReloadFile    string   `json:"reload_file"`
+	TLSProbeAddr  string   `json:"tls_probe_addr"`
+	ContainerDir  string   `json:"container_ssl_dir"`
+
+	// Labels are added to the global labels, replacing those of the same name
+	Labels map[string]string `json:"labels"`
+}
+
+// UploadTarget is a FTP, FTPS or WebDAV server that receives certificate uploads
+type UploadTarget struct {
+	Name               string `json:"name"`
+	URL                string `json:"url"`
+	Username           string `json:"username"`
+	Password           string `json:"-"`
+	CertPath           string `json:"cert_path"`
+	KeyPath            string `json:"key_path"`
+	PFXPassword        string `json:"-"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+
+	// Files replace CertPath and KeyPath when set, so that each target receives
+	// the formats it expects
+	Files []UploadFile `json:"files"`
+}
+
+// UploadFile is a file written to an upload target: a path template and the
+// content it holds
+type UploadFile struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// SSHTarget is a device that receives certificates over SSH according to a recipe
+type SSHTarget struct {
+	Name                  string `json:"name"`
+	Host                  string `json:"host"`
+	User                  string `json:"user"`
+	Password              string `json:"-"`
+	KeyFile               string `json:"key_file"`
+	Recipe                string `json:"recipe"`
+	KnownHosts            string `json:"known_hosts"`
+	InsecureIgnoreHostKey bool   `json:"insecure_ignore_host_key"`
+
+	// HostKeyFingerprints pins the host key to one of these SHA256
+	// fingerprints, as printed by ssh-keygen -l, instead of known_hosts
+	HostKeyFingerprints []string `json:"host_key_fingerprints"`
+}
+
+// ApplianceConfig holds the API endpoint and credentials of a network appliance or host
+type ApplianceConfig struct {
+	URL                string `json:"url"`
+	Username           string `json:"username"`
+	Password           string `json:"-"`
+	APIKey             string `json:"-"`
+	APISecret          string `json:"-"`
+	CertName           string `json:"cert_name"`
+	Node               string `json:"node"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+}
+
+// PresetConfig overrides the paths and reload command of a built-in preset
+type PresetConfig struct {
+	CertPath      string `json:"cert_path"`
+	KeyPath       string `json:"key_path"`
+	Owner         string `json:"owner"`
+	Group         string `json:"group"`
+	ReloadCommand string `json:"reload_command"`
+}
+
+// getListEnv gets a comma-separated list environment variable, ignoring empty items
+func (l *loader) getListEnv(key string) []string {
+	return l.getSeparatedEnv(key, ",")
+}
+
+// getSeparatedEnv gets a list environment variable split on sep, ignoring empty items
+func (l *loader) getSeparatedEnv(key, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(l.value(key, kindList, ""), sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getIntListEnv gets a comma-separated list of integers, ignoring invalid items
+func (l *loader) getIntListEnv(key string) []int {
+	var values []int
+	for _, item := range strings.Split(l.value(key, kindIntList, ""), ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		value, err := strconv.Atoi(item)
+		if err != nil {
+			l.invalid(key, item, "a comma-separated list of integers")
+			continue
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// getBoolEnv gets a boolean environment variable with a default value
+func (l *loader) getBoolEnv(key string, defaultValue bool) bool {
+	if value := l.value(key, kindBool, strconv.FormatBool(defaultValue)); value != "" {
+		boolValue, err := strconv.ParseBool(value)
+		if err == nil {
+			return boolValue
+		}
+		l.invalid(key, value, "true or false")
+	}
+	return defaultValue
+}
+
 // getDurationEnv gets a duration environment variable with a default value
-func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
-	if value := os.Getenv(key); value != "" {
-		if duration, err := time.ParseDuration(value); err == nil {
+func (l *loader) getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	if value := l.value(key, kindDuration, defaultValue.String()); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err == nil {
 			return duration
 		}
+		l.invalid(key, value, "a duration such as 30s, 10m or 24h")
+	}
+	return defaultValue
+}
+
+// getFractionEnv gets a fraction written as a decimal ("0.66") or a ratio ("2/3")
+func (l *loader) getFractionEnv(key string, defaultValue float64) float64 {
+	value := l.value(key, kindFraction, strconv.FormatFloat(defaultValue, 'g', -1, 64))
+	if value == "" {
+		return defaultValue
+	}
+	if num, den, ok := strings.Cut(value, "/"); ok {
+		n, err1 := strconv.ParseFloat(strings.TrimSpace(num), 64)
+		d, err2 := strconv.ParseFloat(strings.TrimSpace(den), 64)
+		if err1 == nil && err2 == nil && d != 0 {
+			return n / d
+		}
+		l.invalid(key, value, "a decimal or a ratio such as 2/3")
+		return defaultValue
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f
 	}
+	l.invalid(key, value, "a decimal or a ratio such as 2/3")
 	return defaultValue
 }
 
+// getModeEnv gets file permissions written in octal, such as 0640; 0 if unset
+func (l *loader) getModeEnv(key string) os.FileMode {
+	value := l.value(key, kindMode, "")
+	if value == "" {
+		return 0
+	}
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0777 {
+		l.invalid(key, value, "octal permissions such as 0640")
+		return 0
+	}
+	return os.FileMode(mode)
+}
+
 // getIntEnv gets an integer environment variable with a default value
-func getIntEnv(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
+func (l *loader) getIntEnv(key string, defaultValue int) int {
+	if value := l.value(key, kindInt, strconv.Itoa(defaultValue)); value != "" {
+		intValue, err := strconv.Atoi(value)
+		if err == nil {
 			return intValue
 		}
+		l.invalid(key, value, "an integer")
 	}
 	return defaultValue
 }
@@ -1,7 +1,11 @@
 package config
 
 import (
+	"encoding/json"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 )
@@ -46,8 +50,8 @@ func TestLoad(t *testing.T) {
 		t.Errorf("Expected RenewalInterval to be 1h, got %v", cfg.RenewalInterval)
 	}
 
-	if cfg.CertValidity != 30*time.Hour {
-		t.Errorf("Expected CertValidity to be 30h, got %v", cfg.CertValidity)
+	if cfg.CertValidity != 720*time.Hour {
+		t.Errorf("Expected CertValidity to be 720h, got %v", cfg.CertValidity)
 	}
 
 	// Clean up
@@ -112,3 +116,459 @@ func TestLoadDefaults(t *testing.T) {
 	// Clean up
 	os.Unsetenv("IPSSL_API_KEY")
 }
+
+func TestLoadInvalidReloadStrategy(t *testing.T) {
+	os.Setenv("IPSSL_API_KEY", "test-api-key")
+	os.Setenv("IPSSL_RELOAD_STRATEGY", "rolling")
+
+	_, err := Load()
+	if err == nil {
+		t.Error("Expected error for invalid reload strategy, got nil")
+	}
+
+	// Clean up
+	os.Unsetenv("IPSSL_API_KEY")
+	os.Unsetenv("IPSSL_RELOAD_STRATEGY")
+}
+
+func TestLoadInvalidSIEMTimeout(t *testing.T) {
+	t.Setenv("IPSSL_API_KEY", "test-api-key")
+	t.Setenv("IPSSL_SIEM_ADDR", "siem.local:6514")
+	t.Setenv("IPSSL_SIEM_TIMEOUT", "0s")
+
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "invalid IPSSL_SIEM_TIMEOUT") {
+		t.Errorf("Expected error for a zero SIEM timeout, got %v", err)
+	}
+}
+
+func TestLoadTimezone(t *testing.T) {
+	if _, err := time.LoadLocation("Europe/Berlin"); err != nil {
+		t.Skip("time zone database unavailable:", err)
+	}
+	t.Setenv("IPSSL_API_KEY", "test-api-key")
+	t.Setenv("IPSSL_TIMEZONE", "Europe/Berlin")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.Location.String() != "Europe/Berlin" {
+		t.Errorf("Expected location Europe/Berlin, got %s", cfg.Location)
+	}
+
+	t.Setenv("IPSSL_TIMEZONE", "Mars/Olympus")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "IPSSL_TIMEZONE") {
+		t.Errorf("Expected IPSSL_TIMEZONE error, got %v", err)
+	}
+}
+
+func TestLoadCertificateSets(t *testing.T) {
+	t.Setenv("IPSSL_API_KEY", "test-api-key")
+	t.Setenv("IPSSL_SSL_DIR", "/ipssl")
+	t.Setenv("IPSSL_STATE_DIR", "/state")
+	t.Setenv("IPSSL_CONTAINER_NAME", "caddy")
+	t.Setenv("IPSSL_CERTIFICATES", "web,api")
+	t.Setenv("IPSSL_CERTIFICATE_WEB_IPS", "192.0.2.1")
+	t.Setenv("IPSSL_CERTIFICATE_API_IPS", "192.0.2.2")
+	t.Setenv("IPSSL_CERTIFICATE_API_DOMAINS", "api.example.com")
+	t.Setenv("IPSSL_CERTIFICATE_API_SSL_DIR", "/certs/api")
+	t.Setenv("IPSSL_CERTIFICATE_API_CONTAINER", "api-proxy")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if len(cfg.Certificates) != 2 {
+		t.Fatalf("Expected two certificate sets, got %+v", cfg.Certificates)
+	}
+
+	web := cfg.ForCertificate(cfg.Certificates[0])
+	if web.SSLDir != "/ipssl/web" || web.StateDir != "/state/web" || web.ContainerName != "caddy" {
+		t.Errorf("Unexpected web directories: ssl %s, state %s, container %s", web.SSLDir, web.StateDir, web.ContainerName)
+	}
+	if web.Primary() != "192.0.2.1" || web.TLSProbeAddr != "192.0.2.1:443" {
+		t.Errorf("Unexpected web identifiers: %v, probe %s", web.Identifiers(), web.TLSProbeAddr)
+	}
+
+	api := cfg.ForCertificate(cfg.Certificates[1])
+	if api.SSLDir != "/certs/api" || api.OverrideDir != "/certs/api/override" || api.ContainerName != "api-proxy" {
+		t.Errorf("Unexpected api directories: ssl %s, override %s, container %s", api.SSLDir, api.OverrideDir, api.ContainerName)
+	}
+	if got := strings.Join(api.Identifiers(), ","); got != "192.0.2.2,api.example.com" {
+		t.Errorf("Unexpected api identifiers: %s", got)
+	}
+
+	t.Setenv("IPSSL_CERTIFICATE_WEB_IPS", "")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "IPSSL_CERTIFICATE_WEB_IPS") {
+		t.Errorf("Expected an error for a set without identifiers, got %v", err)
+	}
+}
+
+func TestLoadLabels(t *testing.T) {
+	t.Setenv("IPSSL_API_KEY", "test-api-key")
+	t.Setenv("IPSSL_LABELS", "team=platform, env=prod")
+	t.Setenv("IPSSL_CERTIFICATES", "web")
+	t.Setenv("IPSSL_CERTIFICATE_WEB_IPS", "192.0.2.1")
+	t.Setenv("IPSSL_CERTIFICATE_WEB_LABELS", "team=web,service=shop")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if got := FormatLabels(cfg.Labels); got != "env=prod,team=platform" {
+		t.Errorf("Unexpected global labels: %s", got)
+	}
+	if got := FormatLabels(cfg.ForCertificate(cfg.Certificates[0]).Labels); got != "env=prod,service=shop,team=web" {
+		t.Errorf("Expected set labels to extend the global ones, got %s", got)
+	}
+
+	t.Setenv("IPSSL_LABELS", "team-name=web")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "IPSSL_LABELS") {
+		t.Errorf("Expected an error for an invalid label name, got %v", err)
+	}
+}
+
+func TestLoadCertificatePerIP(t *testing.T) {
+	t.Setenv("IPSSL_API_KEY", "test-api-key")
+	t.Setenv("IPSSL_SSL_DIR", "/ipssl")
+	t.Setenv("IPSSL_CONTAINER_NAME", "caddy")
+	t.Setenv("CLIENT_IP", "192.0.2.1,192.0.2.2")
+	t.Setenv("IPSSL_CERTIFICATE_PER_IP", "true")
+	t.Setenv("IPSSL_CONTAINER_MAP", "192.0.2.2=caddy-b")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if len(cfg.Certificates) != 2 {
+		t.Fatalf("Expected a certificate per address, got %+v", cfg.Certificates)
+	}
+	for i, want := range []struct{ ip, dir, container string }{
+		{"192.0.2.1", "/ipssl/192.0.2.1", "caddy"},
+		{"192.0.2.2", "/ipssl/192.0.2.2", "caddy-b"},
+	} {
+		sub := cfg.ForCertificate(cfg.Certificates[i])
+		if sub.Primary() != want.ip || sub.SSLDir != want.dir || sub.StateDir != want.dir || sub.ContainerName != want.container {
+			t.Errorf("Expected %s in %s reloading %s, got %s in %s (state %s) reloading %s", want.ip, want.dir, want.container, sub.Primary(), sub.SSLDir, sub.StateDir, sub.ContainerName)
+		}
+	}
+
+	t.Setenv("IPSSL_CONTAINER_MAP", "caddy-b")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "IPSSL_CONTAINER_MAP") {
+		t.Errorf("Expected an error for a malformed container mapping, got %v", err)
+	}
+}
+
+func TestLoadMultipleIdentifiers(t *testing.T) {
+	os.Setenv("IPSSL_API_KEY", "test-api-key")
+	os.Setenv("CLIENT_IP", "192.0.2.1, 192.0.2.2")
+	os.Setenv("IPSSL_DOMAINS", "example.com")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if cfg.ClientIP != "192.0.2.1" {
+		t.Errorf("Expected primary ClientIP '192.0.2.1', got '%s'", cfg.ClientIP)
+	}
+
+	expected := []string{"192.0.2.1", "192.0.2.2", "example.com"}
+	identifiers := cfg.Identifiers()
+	if len(identifiers) != len(expected) {
+		t.Fatalf("Expected identifiers %v, got %v", expected, identifiers)
+	}
+	for i := range expected {
+		if identifiers[i] != expected[i] {
+			t.Errorf("Expected identifier %d to be '%s', got '%s'", i, expected[i], identifiers[i])
+		}
+	}
+
+	// Clean up
+	os.Unsetenv("IPSSL_API_KEY")
+	os.Unsetenv("CLIENT_IP")
+	os.Unsetenv("IPSSL_DOMAINS")
+}
+
+func TestLoadDomainsOnly(t *testing.T) {
+	os.Setenv("IPSSL_API_KEY", "test-api-key")
+	os.Unsetenv("CLIENT_IP")
+	os.Setenv("IPSSL_DOMAINS", "www.example.com,example.com")
+	defer os.Unsetenv("IPSSL_API_KEY")
+	defer os.Unsetenv("IPSSL_DOMAINS")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if len(cfg.ClientIPs) != 0 || cfg.ClientIP != "" {
+		t.Errorf("Expected no IP addresses, got %v", cfg.ClientIPs)
+	}
+	if cfg.Primary() != "www.example.com" {
+		t.Errorf("Expected the first hostname as primary identifier, got '%s'", cfg.Primary())
+	}
+	if cfg.TLSProbeAddr != "www.example.com:443" {
+		t.Errorf("Expected TLS probe of the primary hostname, got '%s'", cfg.TLSProbeAddr)
+	}
+
+	os.Setenv("IPSSL_DOMAINS", "192.0.2.1")
+	if _, err := Load(); err == nil {
+		t.Error("Expected an error for an IP address in IPSSL_DOMAINS")
+	}
+}
+
+func TestLoadMultipleValidationDirs(t *testing.T) {
+	os.Setenv("IPSSL_API_KEY", "test-api-key")
+	os.Setenv("IPSSL_VALIDATION_DIR", "/srv/node1, /srv/node2")
+	defer os.Unsetenv("IPSSL_API_KEY")
+	defer os.Unsetenv("IPSSL_VALIDATION_DIR")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if cfg.ValidationDir != "/srv/node1" {
+		t.Errorf("Expected primary ValidationDir '/srv/node1', got '%s'", cfg.ValidationDir)
+	}
+	if len(cfg.ValidationDirs) != 2 || cfg.ValidationDirs[1] != "/srv/node2" {
+		t.Errorf("Expected both validation directories, got %v", cfg.ValidationDirs)
+	}
+}
+
+func TestLoadACMEWithoutAPIKey(t *testing.T) {
+	os.Unsetenv("IPSSL_API_KEY")
+	os.Setenv("IPSSL_PROVIDER", "acme")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error for ACME provider without API key, got %v", err)
+	}
+
+	if cfg.ACME.DirectoryURL != "https://acme-v02.api.letsencrypt.org/directory" {
+		t.Errorf("Expected default Let's Encrypt directory, got %s", cfg.ACME.DirectoryURL)
+	}
+
+	// Clean up
+	os.Unsetenv("IPSSL_PROVIDER")
+}
+
+func TestLoadSandbox(t *testing.T) {
+	os.Setenv("IPSSL_API_KEY", "test-key")
+	os.Setenv("IPSSL_SANDBOX", "true")
+	defer os.Unsetenv("IPSSL_API_KEY")
+	defer os.Unsetenv("IPSSL_SANDBOX")
+
+	if _, err := Load(); err == nil {
+		t.Error("Expected error for zerossl sandbox without IPSSL_API_URL, got nil")
+	}
+
+	os.Setenv("IPSSL_PROVIDER", "acme")
+	defer os.Unsetenv("IPSSL_PROVIDER")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.ACME.DirectoryURL != LetsEncryptStagingDirectory {
+		t.Errorf("Expected Let's Encrypt staging directory in sandbox mode, got %s", cfg.ACME.DirectoryURL)
+	}
+}
+
+func TestLoadACMEPartialEAB(t *testing.T) {
+	os.Setenv("IPSSL_PROVIDER", "acme")
+	os.Setenv("IPSSL_ACME_EAB_KID", "kid")
+	defer os.Unsetenv("IPSSL_PROVIDER")
+	defer os.Unsetenv("IPSSL_ACME_EAB_KID")
+
+	if _, err := Load(); err == nil {
+		t.Error("Expected error for EAB key ID without HMAC key, got nil")
+	}
+}
+
+func TestLoadEncryptedEnv(t *testing.T) {
+	dir := t.TempDir()
+	sops := filepath.Join(dir, "sops")
+	script := "#!/bin/sh\nprintf 'IPSSL_API_KEY=decrypted-key\\nIPSSL_SMTP_PASSWORD=secret\\n'\n"
+	if err := os.WriteFile(sops, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("IPSSL_SOPS_BINARY", sops)
+	t.Setenv("IPSSL_SMTP_PASSWORD", "from-environment")
+	os.Unsetenv("IPSSL_API_KEY")
+	defer os.Unsetenv("IPSSL_API_KEY")
+
+	loaded, err := LoadEncryptedEnv(filepath.Join(dir, "secrets.env"))
+	if err != nil {
+		t.Fatalf("LoadEncryptedEnv failed: %v", err)
+	}
+	if len(loaded) != 1 || loaded[0] != "IPSSL_API_KEY" {
+		t.Errorf("Expected only IPSSL_API_KEY to be loaded, got %v", loaded)
+	}
+	if got := os.Getenv("IPSSL_API_KEY"); got != "decrypted-key" {
+		t.Errorf("Expected decrypted API key, got %q", got)
+	}
+	if got := os.Getenv("IPSSL_SMTP_PASSWORD"); got != "from-environment" {
+		t.Errorf("Expected the environment to take precedence, got %q", got)
+	}
+}
+
+func TestGetFractionEnv(t *testing.T) {
+	for value, want := range map[string]float64{"": 0.5, "2/3": 2.0 / 3, "0.75": 0.75, "1/0": 0.5, "x": 0.5} {
+		t.Setenv("IPSSL_TEST_FRACTION", value)
+		if got := newLoader(os.LookupEnv).getFractionEnv("IPSSL_TEST_FRACTION", 0.5); got != want {
+			t.Errorf("getFractionEnv(%q) = %g, want %g", value, got, want)
+		}
+	}
+}
+
+func TestCheckIP(t *testing.T) {
+	cfg := &Config{
+		IPAllow: []string{"192.0.2.0/24", "2001:db8::/32"},
+		IPDeny:  []string{"192.0.2.128/25", "2001:db8::1"},
+	}
+	tests := []struct {
+		ip      string
+		allowed bool
+	}{
+		{"192.0.2.10", true},
+		{"192.0.2.200", false},
+		{"198.51.100.1", false},
+		{"::ffff:192.0.2.10", true},
+		{"2001:db8::2", true},
+		{"2001:db8::1", false},
+		{"10.8.0.2", false},
+	}
+	for _, tt := range tests {
+		if err := cfg.CheckIP(tt.ip); (err == nil) != tt.allowed {
+			t.Errorf("CheckIP(%s) = %v, expected allowed %v", tt.ip, err, tt.allowed)
+		}
+	}
+
+	if err := (&Config{IPDeny: []string{"10.0.0.0/8"}}).CheckIP("192.0.2.1"); err != nil {
+		t.Errorf("Expected addresses outside the denylist to be allowed without an allowlist: %v", err)
+	}
+}
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	errs := Validate(map[string]string{
+		"IPSSL_API_KEY":         "key",
+		"IPSSL_CERT_QUOTA":      "many",
+		"IPSSL_RELOAD_STRATEGY": "bogus",
+		"IPSSL_KEY_TYPE":        "dsa",
+		"IPSSL_RENEWL_INTERVAL": "24h",
+		"IPSSL_UPLOAD_NAS_URL":  "ftp://nas/",
+		"IPSSL_SOPS_FILE":       "secrets.env",
+		"PATH":                  "/usr/bin",
+	})
+
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	joined := strings.Join(messages, "\n")
+	for _, want := range []string{
+		`invalid IPSSL_CERT_QUOTA "many"`,
+		`invalid IPSSL_RELOAD_STRATEGY "bogus"`,
+		`invalid IPSSL_KEY_TYPE "dsa"`,
+		"unknown variable IPSSL_RENEWL_INTERVAL",
+		"unknown variable IPSSL_UPLOAD_NAS_URL",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("Expected error %q, got:\n%s", want, joined)
+		}
+	}
+	if len(errs) != 5 {
+		t.Errorf("Expected 5 errors, got %d:\n%s", len(errs), joined)
+	}
+
+	if errs := Validate(map[string]string{"IPSSL_API_KEY": "key", "IPSSL_UPLOAD_TARGETS": "nas", "IPSSL_UPLOAD_NAS_URL": "ftp://nas/"}); len(errs) != 0 {
+		t.Errorf("Expected a valid configuration, got %v", errs)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	data, err := Schema()
+	if err != nil {
+		t.Fatalf("Schema failed: %v", err)
+	}
+	var schema struct {
+		Properties        map[string]map[string]any `json:"properties"`
+		PatternProperties map[string]map[string]any `json:"patternProperties"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("Schema is not valid JSON: %v", err)
+	}
+
+	if got := schema.Properties["IPSSL_PROVIDER"]["default"]; got != ProviderZeroSSL {
+		t.Errorf("Expected IPSSL_PROVIDER to default to %s, got %v", ProviderZeroSSL, got)
+	}
+	if _, ok := schema.Properties["IPSSL_SOPS_FILE"]; !ok {
+		t.Error("Expected IPSSL_SOPS_FILE in the schema")
+	}
+	pattern, ok := schema.Properties["IPSSL_CERT_QUOTA"]["pattern"].(string)
+	if !ok || regexp.MustCompile(pattern).MatchString("many") || !regexp.MustCompile(pattern).MatchString("10") {
+		t.Errorf("Expected an integer pattern for IPSSL_CERT_QUOTA, got %q", pattern)
+	}
+	if _, ok := schema.PatternProperties[`^IPSSL_UPLOAD_[A-Z0-9_-]+_URL$`]; !ok {
+		t.Errorf("Expected a pattern for upload target URLs, got %v", schema.PatternProperties)
+	}
+}
+
+func TestSecrets(t *testing.T) {
+	cfg := &Config{
+		APIKey:     "api-key-secret",
+		ACME:       ACMEConfig{EABKID: "kid", EABHMACKey: "hmac-secret"},
+		SMTP:       SMTPConfig{Host: "mail.example.com", Password: "smtp-secret"},
+		SIEM:       SIEMConfig{SigningKey: "signing-secret"},
+		PKCS12:     PKCS12Config{Password: "pkcs12-secret"},
+		Vault:      VaultConfig{Token: "vault-token-secret", SecretID: "vault-secret-id"},
+		AWS:        AWSConfig{SecretAccessKey: "aws-key-secret", SessionToken: "aws-session-secret"},
+		Azure:      AzureConfig{ClientSecret: "azure-secret"},
+		Upload:     []UploadTarget{{Password: "upload-secret", PFXPassword: "pfx-secret"}},
+		SSH:        []SSHTarget{{Password: "ssh-secret"}},
+		Appliances: map[string]ApplianceConfig{"pfsense": {Password: "appliance-secret", APIKey: "appliance-key", APISecret: "appliance-api-secret"}},
+	}
+	want := []string{"api-key-secret", "hmac-secret", "smtp-secret", "signing-secret", "pkcs12-secret", "vault-token-secret", "vault-secret-id",
+		"aws-key-secret", "aws-session-secret", "azure-secret", "upload-secret", "pfx-secret", "ssh-secret", "appliance-secret", "appliance-key", "appliance-api-secret"}
+	if got := cfg.Secrets(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Secrets() = %v, want %v", got, want)
+	}
+
+	// Credentials are never encoded
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range want {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("Expected %q not to be encoded", secret)
+		}
+	}
+	if !strings.Contains(string(data), "mail.example.com") {
+		t.Error("Expected the other settings to be encoded")
+	}
+}
+
+func TestScrubEnv(t *testing.T) {
+	t.Setenv("IPSSL_API_KEY", "test-api-key")
+	t.Setenv("IPSSL_UPLOAD_NAS_PFX_PASSWORD", "secret")
+	t.Setenv("IPSSL_PKCS12_PASSWORD_FILE", "/run/secrets/pfx")
+	t.Setenv("IPSSL_SSL_DIR", "/test/ssl")
+
+	scrubbed, err := ScrubEnv()
+	if err != nil {
+		t.Fatalf("ScrubEnv failed: %v", err)
+	}
+	if strings.Join(scrubbed, ",") != "IPSSL_API_KEY,IPSSL_UPLOAD_NAS_PFX_PASSWORD" {
+		t.Errorf("Expected only the secrets to be scrubbed, got %v", scrubbed)
+	}
+	if _, set := os.LookupEnv("IPSSL_API_KEY"); set {
+		t.Error("Expected IPSSL_API_KEY to be unset")
+	}
+	if os.Getenv("IPSSL_PKCS12_PASSWORD_FILE") == "" || os.Getenv("IPSSL_SSL_DIR") == "" {
+		t.Error("Expected file paths and other settings to be kept")
+	}
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Kinds of configuration values, as parsed by the loader helpers
+const (
+	kindString   = "string"
+	kindList     = "list"
+	kindIntList  = "int-list"
+	kindBool     = "bool"
+	kindInt      = "int"
+	kindDuration = "duration"
+	kindFraction = "fraction"
+	kindMode     = "mode"
+)
+
+// bootstrapVars are read before the configuration is loaded
+var bootstrapVars = []string{"IPSSL_SOPS_FILE", "IPSSL_SOPS_BINARY"}
+
+// schemaPlaceholder stands in for the names of deployers and targets when the
+// schema is generated, so that their per-name variables become patterns
+const schemaPlaceholder = "placeholder0"
+
+// variable is a configuration variable read by the loader
+type variable struct {
+	name        string
+	kind        string
+	defaultText string
+}
+
+// loader reads configuration values through lookup. It records every
+// variable it reads and every value it cannot parse, which Load ignores in
+// favour of the default but Validate reports.
+type loader struct {
+	lookup func(string) (string, bool)
+	vars   map[string]variable
+	errs   []error
+}
+
+// newLoader creates a loader reading values through lookup
+func newLoader(lookup func(string) (string, bool)) *loader {
+	return &loader{lookup: lookup, vars: make(map[string]variable)}
+}
+
+// value records the variable key and returns its value, or "" when unset
+func (l *loader) value(key, kind, defaultText string) string {
+	if _, ok := l.vars[key]; !ok {
+		l.vars[key] = variable{name: key, kind: kind, defaultText: defaultText}
+	}
+	value, _ := l.lookup(key)
+	return value
+}
+
+// invalid records a value that cannot be parsed
+func (l *loader) invalid(key, value, expected string) {
+	l.errs = append(l.errs, fmt.Errorf("invalid %s %q (expected %s)", key, value, expected))
+}
+
+// Validate checks a set of configuration variables, e.g. read from a dotenv
+// file, and returns every problem found: values that cannot be parsed,
+// IPSSL_ variables that are not used, and settings that Load would reject.
+// Variables not in vars take their defaults.
+func Validate(vars map[string]string) []error {
+	l := newLoader(func(key string) (string, bool) {
+		value, ok := vars[key]
+		return value, ok
+	})
+	for _, name := range bootstrapVars {
+		l.value(name, kindString, "")
+	}
+	_, err := l.load()
+
+	errs := append([]error(nil), l.errs...)
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, known := l.vars[name]; !known && strings.HasPrefix(name, "IPSSL_") {
+			errs = append(errs, fmt.Errorf("unknown variable %s", name))
+		}
+	}
+
+	var joined interface{ Unwrap() []error }
+	if errors.As(err, &joined) {
+		errs = append(errs, joined.Unwrap()...)
+	} else if err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// Schema returns a JSON Schema describing the configuration variables, for
+// validating dotenv files or deployment manifests with external tools.
+// Variables named after a deployer or target are described by patterns.
+func Schema() ([]byte, error) {
+	l := newLoader(func(key string) (string, bool) {
+		switch key {
+		case "IPSSL_DEPLOYERS", "IPSSL_UPLOAD_TARGETS", "IPSSL_SSH_TARGETS", "IPSSL_CERTIFICATES":
+			return schemaPlaceholder, true
+		}
+		return "", false
+	})
+	for _, name := range bootstrapVars {
+		l.value(name, kindString, "")
+	}
+	// Only the variables read matter, not whether the empty configuration is valid
+	_, _ = l.load()
+
+	properties := make(map[string]any)
+	patterns := make(map[string]any)
+	placeholder := strings.ToUpper(schemaPlaceholder)
+	for _, v := range l.vars {
+		if strings.Contains(v.name, placeholder) {
+			pattern := strings.Replace(regexp.QuoteMeta(v.name), placeholder, "[A-Z0-9_-]+", 1)
+			patterns["^"+pattern+"$"] = v.schema()
+			continue
+		}
+		properties[v.name] = v.schema()
+	}
+
+	return json.MarshalIndent(map[string]any{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                "ipssl-client configuration",
+		"description":          "Environment variables read by ipssl-client. All values are strings; an empty value selects the default.",
+		"type":                 "object",
+		"properties":           properties,
+		"patternProperties":    patterns,
+		"additionalProperties": map[string]any{"type": "string"},
+	}, "", "  ")
+}
+
+// schema describes the values the variable accepts
+func (v variable) schema() map[string]any {
+	s := map[string]any{"type": "string"}
+	if v.defaultText != "" {
+		s["default"] = v.defaultText
+	}
+	switch v.kind {
+	case kindBool:
+		s["enum"] = []string{"", "1", "t", "T", "true", "TRUE", "True", "0", "f", "F", "false", "FALSE", "False"}
+	case kindInt:
+		s["pattern"] = `^([+-]?[0-9]+)?$`
+	case kindIntList:
+		s["pattern"] = `^(\s*[+-]?[0-9]+\s*)?(,(\s*[+-]?[0-9]+\s*)?)*$`
+	case kindDuration:
+		s["pattern"] = `^([+-]?(0|([0-9]*\.?[0-9]+(ns|us|µs|ms|s|m|h))+))?$`
+	case kindFraction:
+		s["pattern"] = `^(\s*[0-9]*\.?[0-9]+\s*(/\s*[0-9]*\.?[0-9]+\s*)?)?$`
+	case kindMode:
+		s["pattern"] = `^(0?[0-7]{3})?$`
+	}
+	return s
+}
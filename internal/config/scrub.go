@@ -0,0 +1,101 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// secretWords mark the IPSSL_ variables that hold credentials
+var secretWords = []string{"PASSWORD", "SECRET", "TOKEN", "API_KEY", "HMAC_KEY", "SIGNING_KEY"}
+
+// secretVars are the credentials read from other variables
+var secretVars = []string{"AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AZURE_CLIENT_SECRET"}
+
+// secretVar reports whether the variable holds a credential rather than the
+// path of a file containing one
+func secretVar(name string) bool {
+	if slices.Contains(secretVars, name) {
+		return true
+	}
+	if !strings.HasPrefix(name, "IPSSL_") || strings.HasSuffix(name, "_FILE") || strings.HasSuffix(name, "_PATH") {
+		return false
+	}
+	for _, word := range secretWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// SecretValues returns the values of the variables in vars that hold
+// credentials, so that they can be scrubbed from output even when the
+// configuration does not load
+func SecretValues(vars map[string]string) []string {
+	var values []string
+	for name, value := range vars {
+		if value != "" && secretVar(name) {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Secrets returns the credentials held in the configuration, which are
+// never encoded, so that they can be scrubbed from logs and support bundles.
+// A credential added to the configuration must be added here.
+func (c *Config) Secrets() []string {
+	candidates := []string{
+		c.APIKey,
+		c.ACME.EABHMACKey,
+		c.SMTP.Password,
+		c.SIEM.SigningKey,
+		c.PKCS12.Password,
+		c.Vault.Token,
+		c.Vault.SecretID,
+		c.AWS.SecretAccessKey,
+		c.AWS.SessionToken,
+		c.Azure.ClientSecret,
+	}
+	for _, target := range c.Upload {
+		candidates = append(candidates, target.Password, target.PFXPassword)
+	}
+	for _, target := range c.SSH {
+		candidates = append(candidates, target.Password)
+	}
+	for _, appliance := range c.Appliances {
+		candidates = append(candidates, appliance.Password, appliance.APIKey, appliance.APISecret)
+	}
+
+	var secrets []string
+	for _, secret := range candidates {
+		if secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
+}
+
+// ScrubEnv unsets the IPSSL_ variables holding credentials, such as
+// IPSSL_API_KEY and the deployer passwords, once the configuration has been
+// loaded, so that hook commands and other child processes do not inherit
+// them. The secrets are then only kept in the Config. It returns the names
+// of the variables removed.
+func ScrubEnv() ([]string, error) {
+	var scrubbed []string
+	for _, entry := range os.Environ() {
+		name, _, _ := strings.Cut(entry, "=")
+		if !secretVar(name) {
+			continue
+		}
+		if err := os.Unsetenv(name); err != nil {
+			return scrubbed, fmt.Errorf("failed to unset %s: %w", name, err)
+		}
+		scrubbed = append(scrubbed, name)
+	}
+	sort.Strings(scrubbed)
+	return scrubbed, nil
+}
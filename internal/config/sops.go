@@ -0,0 +1,55 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// sopsTimeout bounds decryption, which may need to reach a KMS
+const sopsTimeout = 60 * time.Second
+
+// LoadEncryptedEnv decrypts a sops-encrypted dotenv file with the sops binary
+// and sets the variables it contains, keeping the plaintext in memory only.
+// Variables already set in the environment take precedence, like godotenv.Load.
+// The sops binary resolves the decryption key itself (e.g. SOPS_AGE_KEY_FILE),
+// so which secrets a host can read is decided by the recipients in the file.
+func LoadEncryptedEnv(path string) ([]string, error) {
+	binary := os.Getenv("IPSSL_SOPS_BINARY")
+	if binary == "" {
+		binary = "sops"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sopsTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, "--decrypt", "--input-type", "dotenv", "--output-type", "dotenv", path)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s with %s: %w: %s", path, binary, err, strings.TrimSpace(stderr.String()))
+	}
+
+	values, err := godotenv.Unmarshal(stdout.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse decrypted %s: %w", path, err)
+	}
+
+	var loaded []string
+	for key, value := range values {
+		if _, set := os.LookupEnv(key); set {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return nil, fmt.Errorf("failed to set %s: %w", key, err)
+		}
+		loaded = append(loaded, key)
+	}
+	return loaded, nil
+}
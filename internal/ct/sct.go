@@ -0,0 +1,293 @@
+package ct
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// oidSCTList identifies the embedded SCT list extension (RFC 6962, section 3.3)
+var oidSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// Signature algorithms of a digitally-signed struct (RFC 5246, section 7.4.1.4.1)
+const (
+	hashSHA256     = 4
+	signatureRSA   = 1
+	signatureECDSA = 3
+)
+
+// SCT is a signed certificate timestamp: a log's promise to include a
+// certificate
+type SCT struct {
+	LogID      [32]byte
+	Timestamp  time.Time
+	Extensions []byte
+
+	hashAlgorithm      uint8
+	signatureAlgorithm uint8
+	signature          []byte
+	timestampMillis    uint64
+}
+
+// EmbeddedSCTs returns the SCTs embedded in cert, or none if it carries no
+// SCT list extension
+func EmbeddedSCTs(cert *x509.Certificate) ([]SCT, error) {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidSCTList) {
+			return parseSCTList(ext.Value)
+		}
+	}
+	return nil, nil
+}
+
+// parseSCTList parses the OCTET STRING holding a TLS-encoded SignedCertificateTimestampList
+func parseSCTList(value []byte) ([]SCT, error) {
+	var raw []byte
+	if _, err := asn1.Unmarshal(value, &raw); err != nil {
+		return nil, fmt.Errorf("invalid SCT list extension: %w", err)
+	}
+
+	var list cryptobyte.String
+	input := cryptobyte.String(raw)
+	if !input.ReadUint16LengthPrefixed(&list) || !input.Empty() {
+		return nil, errors.New("invalid SCT list")
+	}
+	var scts []SCT
+	for !list.Empty() {
+		var entry cryptobyte.String
+		if !list.ReadUint16LengthPrefixed(&entry) {
+			return nil, errors.New("invalid SCT list entry")
+		}
+		sct, err := parseSCT(entry)
+		if err != nil {
+			return nil, err
+		}
+		scts = append(scts, sct)
+	}
+	return scts, nil
+}
+
+// parseSCT parses a version 1 SignedCertificateTimestamp
+func parseSCT(entry cryptobyte.String) (SCT, error) {
+	var (
+		sct        SCT
+		version    uint8
+		logID      []byte
+		extensions cryptobyte.String
+		signature  cryptobyte.String
+	)
+	if !entry.ReadUint8(&version) || version != 0 {
+		return sct, fmt.Errorf("unsupported SCT version %d", version)
+	}
+	if !entry.ReadBytes(&logID, 32) ||
+		!entry.ReadUint64(&sct.timestampMillis) ||
+		!entry.ReadUint16LengthPrefixed(&extensions) ||
+		!entry.ReadUint8(&sct.hashAlgorithm) ||
+		!entry.ReadUint8(&sct.signatureAlgorithm) ||
+		!entry.ReadUint16LengthPrefixed(&signature) ||
+		!entry.Empty() {
+		return sct, errors.New("invalid SCT")
+	}
+	copy(sct.LogID[:], logID)
+	sct.Timestamp = time.UnixMilli(int64(sct.timestampMillis))
+	sct.Extensions = []byte(extensions)
+	sct.signature = []byte(signature)
+	return sct, nil
+}
+
+// Log is a CT log whose SCTs can be verified
+type Log struct {
+	Description string
+	URL         string
+	Key         crypto.PublicKey
+}
+
+// LogList maps log IDs, the SHA-256 hashes of the log keys, to logs
+type LogList map[[32]byte]*Log
+
+// logListFile is the part of a log list in the v3 JSON format published by
+// Google and Apple that identifies logs and their keys
+type logListFile struct {
+	Operators []struct {
+		Name string `json:"name"`
+		Logs []struct {
+			Description string `json:"description"`
+			Key         []byte `json:"key"`
+			URL         string `json:"url"`
+		} `json:"logs"`
+	} `json:"operators"`
+}
+
+// LoadLogList reads a log list in the v3 JSON format
+func LoadLogList(path string) (LogList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CT log list: %w", err)
+	}
+	var file logListFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse CT log list %s: %w", path, err)
+	}
+
+	logs := make(LogList)
+	for _, operator := range file.Operators {
+		for _, log := range operator.Logs {
+			key, err := x509.ParsePKIXPublicKey(log.Key)
+			if err != nil {
+				return nil, fmt.Errorf("invalid key of CT log %s: %w", log.Description, err)
+			}
+			logs[sha256.Sum256(log.Key)] = &Log{Description: log.Description, URL: log.URL, Key: key}
+		}
+	}
+	if len(logs) == 0 {
+		return nil, fmt.Errorf("no logs found in CT log list %s", path)
+	}
+	return logs, nil
+}
+
+// Verify checks the signature of an SCT embedded in cert, issued by issuer,
+// with the key of log. Embedded SCTs sign the precertificate: the TBS
+// certificate without the SCT list, bound to the issuer's key.
+func (sct SCT) Verify(cert, issuer *x509.Certificate, log *Log) error {
+	tbs, err := precertTBS(cert.RawTBSCertificate)
+	if err != nil {
+		return err
+	}
+	issuerKeyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+
+	var b cryptobyte.Builder
+	b.AddUint8(0) // version v1
+	b.AddUint8(0) // certificate_timestamp
+	b.AddUint64(sct.timestampMillis)
+	b.AddUint16(1) // precert_entry
+	b.AddBytes(issuerKeyHash[:])
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(tbs) })
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(sct.Extensions) })
+	signed, err := b.Bytes()
+	if err != nil {
+		return err
+	}
+
+	if sct.hashAlgorithm != hashSHA256 {
+		return fmt.Errorf("unsupported SCT hash algorithm %d", sct.hashAlgorithm)
+	}
+	digest := sha256.Sum256(signed)
+	switch key := log.Key.(type) {
+	case *ecdsa.PublicKey:
+		if sct.signatureAlgorithm != signatureECDSA || !ecdsa.VerifyASN1(key, digest[:], sct.signature) {
+			return fmt.Errorf("invalid SCT signature of %s", log.Description)
+		}
+	case *rsa.PublicKey:
+		if sct.signatureAlgorithm != signatureRSA || rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sct.signature) != nil {
+			return fmt.Errorf("invalid SCT signature of %s", log.Description)
+		}
+	default:
+		return fmt.Errorf("unsupported key type of %s", log.Description)
+	}
+	return nil
+}
+
+// precertTBS removes the SCT list extension from a DER TBSCertificate
+func precertTBS(raw []byte) ([]byte, error) {
+	var tbs asn1.RawValue
+	if _, err := asn1.Unmarshal(raw, &tbs); err != nil {
+		return nil, fmt.Errorf("invalid TBS certificate: %w", err)
+	}
+
+	var fields []byte
+	for rest := tbs.Bytes; len(rest) > 0; {
+		var field asn1.RawValue
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &field); err != nil {
+			return nil, fmt.Errorf("invalid TBS certificate: %w", err)
+		}
+		// Extensions are the [3] EXPLICIT field
+		if field.Class == asn1.ClassContextSpecific && field.Tag == 3 {
+			extensions, err := withoutSCTList(field.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			if extensions == nil {
+				continue
+			}
+			if field.FullBytes, err = asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 3, IsCompound: true, Bytes: extensions}); err != nil {
+				return nil, err
+			}
+		}
+		fields = append(fields, field.FullBytes...)
+	}
+	return asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: fields})
+}
+
+// withoutSCTList re-encodes a DER SEQUENCE of extensions without the SCT
+// list, returning nil if no other extension remains
+func withoutSCTList(der []byte) ([]byte, error) {
+	var extensions []asn1.RawValue
+	if _, err := asn1.Unmarshal(der, &extensions); err != nil {
+		return nil, fmt.Errorf("invalid certificate extensions: %w", err)
+	}
+
+	var kept []byte
+	for _, raw := range extensions {
+		var ext pkix.Extension
+		if _, err := asn1.Unmarshal(raw.FullBytes, &ext); err != nil {
+			return nil, fmt.Errorf("invalid certificate extension: %w", err)
+		}
+		if !ext.Id.Equal(oidSCTList) {
+			kept = append(kept, raw.FullBytes...)
+		}
+	}
+	if kept == nil {
+		return nil, nil
+	}
+	return asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: kept})
+}
+
+// Result is the outcome of checking the SCTs of a certificate
+type Result struct {
+	// SCTs is the number of embedded SCTs
+	SCTs int
+	// Logs are the descriptions of the distinct known logs with a valid SCT
+	Logs []string
+	// Errors are the SCTs that could not be verified
+	Errors []error
+}
+
+// Check parses the SCTs embedded in cert and verifies them against the known
+// logs. SCTs of unknown logs are reported in Errors.
+func Check(cert, issuer *x509.Certificate, logs LogList) (Result, error) {
+	scts, err := EmbeddedSCTs(cert)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{SCTs: len(scts)}
+	seen := make(map[[32]byte]bool)
+	for _, sct := range scts {
+		log, ok := logs[sct.LogID]
+		if !ok {
+			result.Errors = append(result.Errors, fmt.Errorf("SCT of unknown log %x", sct.LogID))
+			continue
+		}
+		if err := sct.Verify(cert, issuer, log); err != nil {
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+		if !seen[sct.LogID] {
+			seen[sct.LogID] = true
+			result.Logs = append(result.Logs, log.Description)
+		}
+	}
+	return result, nil
+}
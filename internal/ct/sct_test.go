@@ -0,0 +1,181 @@
+package ct
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// testLog is a CT log that signs SCTs for the test certificates
+type testLog struct {
+	key *ecdsa.PrivateKey
+	id  [32]byte
+	der []byte
+}
+
+func newTestLog(t *testing.T) *testLog {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testLog{key: key, id: sha256.Sum256(der), der: der}
+}
+
+// sign returns a TLS-encoded SCT over the precertificate tbs issued by issuer
+func (l *testLog) sign(t *testing.T, tbs []byte, issuer *x509.Certificate) []byte {
+	t.Helper()
+	millis := uint64(time.Now().UnixMilli())
+	issuerKeyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+
+	var signed cryptobyte.Builder
+	signed.AddUint8(0)
+	signed.AddUint8(0)
+	signed.AddUint64(millis)
+	signed.AddUint16(1)
+	signed.AddBytes(issuerKeyHash[:])
+	signed.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(tbs) })
+	signed.AddUint16(0)
+	digest := sha256.Sum256(signed.BytesOrPanic())
+	signature, err := ecdsa.SignASN1(rand.Reader, l.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sct cryptobyte.Builder
+	sct.AddUint8(0)
+	sct.AddBytes(l.id[:])
+	sct.AddUint64(millis)
+	sct.AddUint16(0)
+	sct.AddUint8(hashSHA256)
+	sct.AddUint8(signatureECDSA)
+	sct.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(signature) })
+	return sct.BytesOrPanic()
+}
+
+// issueWithSCTs issues a leaf whose SCT list holds an SCT of every log
+func issueWithSCTs(t *testing.T, logs ...*testLog) (leaf, issuer *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, _ = x509.ParseCertificate(caDER)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"example.com"},
+	}
+	precertDER, err := x509.CreateCertificate(rand.Reader, template, issuer, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	precert, _ := x509.ParseCertificate(precertDER)
+
+	var list cryptobyte.Builder
+	list.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, log := range logs {
+			sct := log.sign(t, precert.RawTBSCertificate, issuer)
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(sct) })
+		}
+	})
+	value, err := asn1.Marshal(list.BytesOrPanic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.ExtraExtensions = []pkix.Extension{{Id: oidSCTList, Value: value}}
+	leafDER, err := x509.CreateCertificate(rand.Reader, template, issuer, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ = x509.ParseCertificate(leafDER)
+	return leaf, issuer
+}
+
+func TestCheck(t *testing.T) {
+	known, unknown := newTestLog(t), newTestLog(t)
+	leaf, issuer := issueWithSCTs(t, known, unknown)
+	logs := LogList{known.id: {Description: "Test log", Key: known.key.Public()}}
+
+	result, err := Check(leaf, issuer, logs)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if result.SCTs != 2 {
+		t.Errorf("Expected 2 SCTs, got %d", result.SCTs)
+	}
+	if len(result.Logs) != 1 || result.Logs[0] != "Test log" {
+		t.Errorf("Expected a valid SCT of the known log, got %v", result.Logs)
+	}
+	if len(result.Errors) != 1 {
+		t.Errorf("Expected the SCT of the unknown log to be reported, got %v", result.Errors)
+	}
+
+	// An SCT does not verify for a certificate it was not issued for
+	other, _ := issueWithSCTs(t, known)
+	scts, _ := EmbeddedSCTs(other)
+	if err := scts[0].Verify(leaf, issuer, logs[known.id]); err == nil {
+		t.Error("Expected an SCT of another certificate to fail verification")
+	}
+}
+
+func TestCheckWithoutSCTs(t *testing.T) {
+	leaf, issuer := issueWithSCTs(t)
+	leaf.Extensions = nil
+	result, err := Check(leaf, issuer, nil)
+	if err != nil || result.SCTs != 0 {
+		t.Errorf("Expected no SCTs, got %d (%v)", result.SCTs, err)
+	}
+}
+
+func TestLoadLogList(t *testing.T) {
+	log := newTestLog(t)
+	data, _ := json.Marshal(map[string]any{
+		"operators": []any{map[string]any{
+			"name": "Test operator",
+			"logs": []any{map[string]any{"description": "Test log", "key": log.der, "url": "https://ct.example/"}},
+		}},
+	})
+	path := filepath.Join(t.TempDir(), "log_list.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	logs, err := LoadLogList(path)
+	if err != nil {
+		t.Fatalf("LoadLogList failed: %v", err)
+	}
+	if logs[log.id] == nil || logs[log.id].Description != "Test log" {
+		t.Errorf("Expected the log under its ID, got %v", logs)
+	}
+}
@@ -0,0 +1,241 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+// AWS metadata endpoints serving the credentials of the ECS task role and the
+// EC2 instance profile
+const (
+	ecsCredentialsURL = "http://169.254.170.2"
+	imdsURL           = "http://169.254.169.254"
+)
+
+// metadataTimeout bounds fetching credentials, so that hosts outside AWS fail fast
+const metadataTimeout = 5 * time.Second
+
+// AWSDeployer imports the certificate into existing ACM certificates, which
+// load balancers and CloudFront distributions using them pick up, and stores
+// it in Secrets Manager
+type AWSDeployer struct {
+	cfg    config.AWSConfig
+	client *http.Client
+	logger *logger.Logger
+
+	// endpoint returns the API endpoint of a service in a region
+	endpoint func(service, region string) string
+	// ecsURL and imdsURL are the base URLs of the metadata services
+	ecsURL, imdsURL string
+}
+
+// NewAWSDeployer creates a new AWS deployer
+func NewAWSDeployer(cfg config.AWSConfig, logger *logger.Logger) (*AWSDeployer, error) {
+	if len(cfg.ACMARNs) == 0 && cfg.SecretID == "" {
+		return nil, fmt.Errorf("IPSSL_AWS_ACM_CERTIFICATE_ARN or IPSSL_AWS_SECRET_ID is required")
+	}
+	for _, arn := range cfg.ACMARNs {
+		if arnRegion(arn) == "" {
+			return nil, fmt.Errorf("invalid IPSSL_AWS_ACM_CERTIFICATE_ARN %q (expected arn:aws:acm:<region>:<account>:certificate/<id>)", arn)
+		}
+	}
+	if cfg.SecretID != "" && cfg.Region == "" && arnRegion(cfg.SecretID) == "" {
+		return nil, fmt.Errorf("AWS_REGION is required unless IPSSL_AWS_SECRET_ID is an ARN")
+	}
+	if (cfg.AccessKeyID == "") != (cfg.SecretAccessKey == "") {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set together")
+	}
+	return &AWSDeployer{
+		cfg:    cfg,
+		client: newHTTPClient(false, apiTimeout),
+		logger: logger,
+		endpoint: func(service, region string) string {
+			return fmt.Sprintf("https://%s.%s.amazonaws.com/", service, region)
+		},
+		ecsURL:  ecsCredentialsURL,
+		imdsURL: imdsURL,
+	}, nil
+}
+
+// Name returns the deployer name
+func (d *AWSDeployer) Name() string {
+	return "aws"
+}
+
+// Deploy re-imports every ACM certificate and writes a new secret version
+func (d *AWSDeployer) Deploy(ctx context.Context, bundle *Bundle) error {
+	creds, err := d.credentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	leaf, intermediates := splitLeaf(bundle.Cert)
+	for _, arn := range d.cfg.ACMARNs {
+		payload := map[string]any{
+			"CertificateArn": arn,
+			"Certificate":    leaf,
+			"PrivateKey":     bundle.Key,
+		}
+		if len(intermediates) > 0 {
+			payload["CertificateChain"] = intermediates
+		}
+		if err := d.call(ctx, creds, "acm", arnRegion(arn), "CertificateManager.ImportCertificate", payload); err != nil {
+			return fmt.Errorf("failed to import certificate into ACM %s: %w", arn, err)
+		}
+		d.logger.Info("Certificate imported into ACM", "arn", arn)
+	}
+
+	if d.cfg.SecretID != "" {
+		secret, err := json.Marshal(map[string]string{
+			"certificate": string(bundle.Cert),
+			"private_key": string(bundle.Key),
+			"ip":          bundle.IP,
+		})
+		if err != nil {
+			return err
+		}
+		region := arnRegion(d.cfg.SecretID)
+		if region == "" {
+			region = d.cfg.Region
+		}
+		payload := map[string]any{"SecretId": d.cfg.SecretID, "SecretString": string(secret)}
+		if err := d.call(ctx, creds, "secretsmanager", region, "secretsmanager.PutSecretValue", payload); err != nil {
+			return fmt.Errorf("failed to store certificate in Secrets Manager %s: %w", d.cfg.SecretID, err)
+		}
+		d.logger.Info("Certificate stored in Secrets Manager", "secret", d.cfg.SecretID)
+	}
+	return nil
+}
+
+// call sends a signed request to an AWS JSON API
+func (d *AWSDeployer) call(ctx context.Context, creds awsCredentials, service, region, target string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.endpoint(service, region), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+	signV4(req, body, creds, region, service, time.Now())
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", target, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return fmt.Errorf("%s returned HTTP %d: %s", target, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// credentials returns the configured keys, or the temporary credentials of
+// the ECS task role or EC2 instance profile, which are fetched on every
+// deploy since they expire
+func (d *AWSDeployer) credentials(ctx context.Context) (awsCredentials, error) {
+	if d.cfg.AccessKeyID != "" {
+		return awsCredentials{AccessKeyID: d.cfg.AccessKeyID, SecretAccessKey: d.cfg.SecretAccessKey, SessionToken: d.cfg.SessionToken}, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, metadataTimeout)
+	defer cancel()
+	if d.cfg.ContainerCreds != "" {
+		creds, err := d.fetchCredentials(ctx, d.ecsURL+d.cfg.ContainerCreds, nil)
+		if err != nil {
+			return creds, fmt.Errorf("failed to get ECS task role credentials: %w", err)
+		}
+		return creds, nil
+	}
+
+	// IMDSv2 requires a session token for metadata requests
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, d.imdsURL+"/latest/api/token", nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "300")
+	token, err := d.metadata(req)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("no AWS credentials configured and the instance metadata service is unavailable: %w", err)
+	}
+	header := http.Header{"X-Aws-Ec2-Metadata-Token": {token}}
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, d.imdsURL+"/latest/meta-data/iam/security-credentials/", nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header = header.Clone()
+	role, err := d.metadata(req)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to get instance profile role: %w", err)
+	}
+	role, _, _ = strings.Cut(role, "\n")
+	creds, err := d.fetchCredentials(ctx, d.imdsURL+"/latest/meta-data/iam/security-credentials/"+role, header)
+	if err != nil {
+		return creds, fmt.Errorf("failed to get instance profile credentials: %w", err)
+	}
+	return creds, nil
+}
+
+// fetchCredentials reads temporary credentials from a metadata endpoint
+func (d *AWSDeployer) fetchCredentials(ctx context.Context, url string, header http.Header) (awsCredentials, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	if header != nil {
+		req.Header = header.Clone()
+	}
+	data, err := d.metadata(req)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	var creds struct {
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string `json:"SecretAccessKey"`
+		Token           string `json:"Token"`
+	}
+	if err := json.Unmarshal([]byte(data), &creds); err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to decode credentials: %w", err)
+	}
+	if creds.AccessKeyID == "" {
+		return awsCredentials{}, fmt.Errorf("credentials response contains no access key")
+	}
+	return awsCredentials{AccessKeyID: creds.AccessKeyID, SecretAccessKey: creds.SecretAccessKey, SessionToken: creds.Token}, nil
+}
+
+// metadata sends a metadata service request and returns the response body
+func (d *AWSDeployer) metadata(req *http.Request) (string, error) {
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s %s returned HTTP %d", req.Method, req.URL, resp.StatusCode)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// arnRegion returns the region field of an ARN, or "" if s is not an ARN
+func arnRegion(s string) string {
+	parts := strings.SplitN(s, ":", 6)
+	if len(parts) < 6 || parts[0] != "arn" {
+		return ""
+	}
+	return parts[3]
+}
@@ -0,0 +1,89 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+func TestAWSDeployer(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]map[string]any)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+			w.Write([]byte("imds-token"))
+		case r.Header.Get("X-Aws-Ec2-Metadata-Token") != "":
+			if r.URL.Path == "/latest/meta-data/iam/security-credentials/" {
+				w.Write([]byte("ipssl-role"))
+				return
+			}
+			w.Write([]byte(`{"AccessKeyId":"ASIAEXAMPLE","SecretAccessKey":"secret","Token":"session"}`))
+		default:
+			if !strings.Contains(r.Header.Get("Authorization"), "Credential=ASIAEXAMPLE/") || r.Header.Get("X-Amz-Security-Token") != "session" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			var payload map[string]any
+			json.NewDecoder(r.Body).Decode(&payload)
+			mu.Lock()
+			calls[r.Header.Get("X-Amz-Target")+" "+r.URL.Path] = payload
+			mu.Unlock()
+			w.Write([]byte("{}"))
+		}
+	}))
+	defer server.Close()
+
+	d, err := NewAWSDeployer(config.AWSConfig{
+		Region:   "eu-west-1",
+		ACMARNs:  []string{"arn:aws:acm:us-east-1:123456789012:certificate/abc"},
+		SecretID: "ipssl/cert",
+	}, logger.New())
+	if err != nil {
+		t.Fatalf("NewAWSDeployer failed: %v", err)
+	}
+	d.endpoint = func(service, region string) string { return server.URL + "/" + service + "/" + region }
+	d.imdsURL = server.URL
+
+	bundle := newTestBundle(t)
+	if err := d.Deploy(context.Background(), bundle); err != nil {
+		t.Fatalf("Deploy failed: %v", err)
+	}
+
+	imported := calls["CertificateManager.ImportCertificate /acm/us-east-1"]
+	if imported == nil || imported["CertificateArn"] != "arn:aws:acm:us-east-1:123456789012:certificate/abc" {
+		t.Fatalf("Expected an ACM import in the region of the ARN, got %v", calls)
+	}
+	if _, ok := imported["CertificateChain"]; ok {
+		t.Error("Expected no chain for a self-signed certificate")
+	}
+	stored := calls["secretsmanager.PutSecretValue /secretsmanager/eu-west-1"]
+	if stored == nil {
+		t.Fatalf("Expected a Secrets Manager write in AWS_REGION, got %v", calls)
+	}
+	var secret map[string]string
+	json.Unmarshal([]byte(stored["SecretString"].(string)), &secret)
+	if !bytes.Equal([]byte(secret["private_key"]), bundle.Key) {
+		t.Errorf("Expected the key in the secret, got %v", secret)
+	}
+}
+
+func TestNewAWSDeployerValidates(t *testing.T) {
+	if _, err := NewAWSDeployer(config.AWSConfig{Region: "eu-west-1"}, logger.New()); err == nil {
+		t.Error("Expected a deployer without ARN and secret to be rejected")
+	}
+	if _, err := NewAWSDeployer(config.AWSConfig{ACMARNs: []string{"abc"}}, logger.New()); err == nil {
+		t.Error("Expected an invalid ARN to be rejected")
+	}
+	if _, err := NewAWSDeployer(config.AWSConfig{SecretID: "ipssl/cert"}, logger.New()); err == nil {
+		t.Error("Expected a secret name without region to be rejected")
+	}
+}
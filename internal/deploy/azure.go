@@ -0,0 +1,170 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/keys"
+	"ipssl-client/internal/logger"
+)
+
+// Azure endpoints: Entra ID for service principals and the instance metadata
+// service for managed identities
+const (
+	azureLoginURL = "https://login.microsoftonline.com"
+	azureIMDSURL  = "http://169.254.169.254"
+)
+
+// azureVaultResource is the resource Key Vault access tokens are issued for
+const azureVaultResource = "https://vault.azure.net"
+
+// azureAPIVersion is the Key Vault REST API version used
+const azureAPIVersion = "7.4"
+
+// azureCertName matches the names Key Vault accepts for certificates
+var azureCertName = regexp.MustCompile(`^[0-9A-Za-z-]{1,127}$`)
+
+// AzureDeployer imports the certificate and key as a new version of an Azure
+// Key Vault certificate, which Application Gateway and App Service listeners
+// referencing the versionless secret ID pick up
+type AzureDeployer struct {
+	cfg    config.AzureConfig
+	client *http.Client
+	logger *logger.Logger
+
+	// loginURL and imdsURL are the base URLs of the token endpoints
+	loginURL, imdsURL string
+}
+
+// NewAzureDeployer creates a new Azure Key Vault deployer
+func NewAzureDeployer(cfg config.AzureConfig, logger *logger.Logger) (*AzureDeployer, error) {
+	if cfg.VaultURL == "" {
+		return nil, fmt.Errorf("IPSSL_AZURE_VAULT_URL is required")
+	}
+	if !azureCertName.MatchString(cfg.CertName) {
+		return nil, fmt.Errorf("invalid IPSSL_AZURE_CERT_NAME %q (expected letters, digits and dashes)", cfg.CertName)
+	}
+	if cfg.ClientSecret != "" && (cfg.TenantID == "" || cfg.ClientID == "") {
+		return nil, fmt.Errorf("AZURE_TENANT_ID and AZURE_CLIENT_ID are required with AZURE_CLIENT_SECRET")
+	}
+	return &AzureDeployer{
+		cfg:      cfg,
+		client:   newHTTPClient(false, apiTimeout),
+		logger:   logger,
+		loginURL: azureLoginURL,
+		imdsURL:  azureIMDSURL,
+	}, nil
+}
+
+// Name returns the deployer name
+func (d *AzureDeployer) Name() string {
+	return "azure"
+}
+
+// Deploy imports the key and chain in PEM form. The key is generated by this
+// client, so the certificate is imported rather than merged with a pending
+// Key Vault CSR.
+func (d *AzureDeployer) Deploy(ctx context.Context, bundle *Bundle) error {
+	key, err := keys.ConvertPEM(bundle.Key, keys.FormatPKCS8)
+	if err != nil {
+		return fmt.Errorf("failed to convert private key: %w", err)
+	}
+	token, err := d.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]any{
+		"value": string(append(key, bundle.Cert...)),
+		"policy": map[string]any{
+			"secret_props": map[string]string{"contentType": "application/x-pem-file"},
+		},
+		"tags": map[string]string{"ip": bundle.IP},
+	}
+	endpoint := fmt.Sprintf("%s/certificates/%s/import?api-version=%s", strings.TrimSuffix(d.cfg.VaultURL, "/"), d.cfg.CertName, azureAPIVersion)
+	var imported struct {
+		ID string `json:"id"`
+	}
+	err = doJSON(ctx, d.client, http.MethodPost, endpoint, payload, &imported, func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to import certificate into Key Vault: %w", err)
+	}
+	d.logger.Info("Certificate imported into Azure Key Vault", "name", d.cfg.CertName, "id", imported.ID)
+	return nil
+}
+
+// token returns a Key Vault access token of the service principal or the
+// managed identity. Tokens are requested on every deploy since they expire.
+func (d *AzureDeployer) token(ctx context.Context) (string, error) {
+	if d.cfg.ClientSecret != "" {
+		form := url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {d.cfg.ClientID},
+			"client_secret": {d.cfg.ClientSecret},
+			"scope":         {azureVaultResource + "/.default"},
+		}
+		endpoint := fmt.Sprintf("%s/%s/oauth2/v2.0/token", d.loginURL, url.PathEscape(d.cfg.TenantID))
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		token, err := d.requestToken(req)
+		if err != nil {
+			return "", fmt.Errorf("failed to authenticate service principal %s: %w", d.cfg.ClientID, err)
+		}
+		return token, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, metadataTimeout)
+	defer cancel()
+	query := url.Values{"api-version": {"2018-02-01"}, "resource": {azureVaultResource}}
+	if d.cfg.ClientID != "" {
+		query.Set("client_id", d.cfg.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.imdsURL+"/metadata/identity/oauth2/token?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata", "true")
+	token, err := d.requestToken(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get managed identity token (set AZURE_CLIENT_SECRET to use a service principal): %w", err)
+	}
+	return token, nil
+}
+
+// requestToken sends a token request and returns the access token
+func (d *AzureDeployer) requestToken(req *http.Request) (string, error) {
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s %s returned HTTP %d: %s", req.Method, req.URL.Redacted(), resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("token response contains no access token")
+	}
+	return token.AccessToken, nil
+}
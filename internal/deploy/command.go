@@ -0,0 +1,66 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"ipssl-client/internal/logger"
+)
+
+// baseEnv lists the variables every command inherits, enough to find
+// binaries and reach the Docker daemon. A trailing * matches a prefix.
+var baseEnv = []string{"PATH", "HOME", "USER", "LANG", "LC_*", "TZ", "TMPDIR", "DOCKER_*", "COMPOSE_*"}
+
+// CommandEnv returns the entries of environ named by baseEnv or allow, so that
+// secrets such as IPSSL_API_KEY do not reach hook scripts and check commands
+func CommandEnv(environ, allow []string) []string {
+	var env []string
+	for _, entry := range environ {
+		name, _, _ := strings.Cut(entry, "=")
+		if envAllowed(name, baseEnv) || envAllowed(name, allow) {
+			env = append(env, entry)
+		}
+	}
+	return env
+}
+
+// envAllowed reports whether name matches one of the patterns
+func envAllowed(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if pattern == name {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// runCommand executes an external command with the allowed environment,
+// exposing the bundle paths through environment variables
+func runCommand(ctx context.Context, logger *logger.Logger, bundle *Bundle, allow []string, name string, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = append(CommandEnv(os.Environ(), allow),
+		"CERT_IP="+bundle.IP,
+		"CERT_PATH="+bundle.CertPath,
+		"KEY_PATH="+bundle.KeyPath,
+		"IPSSL_CYCLE_ID="+bundle.CycleID,
+		"OLD_SERIAL="+bundle.PreviousSerial,
+		"NEW_SERIAL="+bundle.Serial,
+		"CERT_CHANGED="+strconv.FormatBool(bundle.Changed()),
+	)
+
+	logger.Info("Running command", "command", name, "args", args)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w (output: %s)", name, strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+
+	logger.Info("Command completed", "command", name, "output", strings.TrimSpace(string(output)))
+	return nil
+}
@@ -0,0 +1,23 @@
+package deploy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCommandEnv(t *testing.T) {
+	environ := []string{
+		"PATH=/usr/bin",
+		"LC_ALL=C.UTF-8",
+		"IPSSL_API_KEY=secret",
+		"IPSSL_SMTP_PASSWORD=secret",
+		"VAULT_ADDR=https://vault.local",
+		"NGINX_CONF=/etc/nginx",
+		"PGPASSWORD=secret",
+		"MYSQL_PWD=secret",
+	}
+	env := CommandEnv(environ, []string{"VAULT_*", "NGINX_CONF"})
+	if got := strings.Join(env, " "); got != "PATH=/usr/bin LC_ALL=C.UTF-8 VAULT_ADDR=https://vault.local NGINX_CONF=/etc/nginx" {
+		t.Errorf("Expected only the base and allowed variables, got %q", got)
+	}
+}
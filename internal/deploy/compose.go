@@ -0,0 +1,109 @@
+package deploy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/fsys"
+	"ipssl-client/internal/logger"
+)
+
+// ComposeDeployer records the new certificate version in an env file referenced by a
+// docker-compose stack and recreates the affected services with `docker compose up -d`
+type ComposeDeployer struct {
+	cfg    config.ComposeConfig
+	env    []string
+	logger *logger.Logger
+}
+
+// NewComposeDeployer creates a new compose deployer
+func NewComposeDeployer(cfg config.ComposeConfig, env []string, logger *logger.Logger) (*ComposeDeployer, error) {
+	if len(cfg.Services) == 0 {
+		return nil, fmt.Errorf("IPSSL_COMPOSE_SERVICES is required")
+	}
+	return &ComposeDeployer{cfg: cfg, env: env, logger: logger}, nil
+}
+
+// Name returns the deployer name
+func (d *ComposeDeployer) Name() string {
+	return "compose"
+}
+
+// Deploy updates the env file and brings the services up again
+func (d *ComposeDeployer) Deploy(ctx context.Context, bundle *Bundle) error {
+	if d.cfg.EnvFile != "" {
+		version := certificateVersion(bundle.Cert)
+		if err := setEnvFileValue(d.cfg.EnvFile, d.cfg.EnvKey, version); err != nil {
+			return fmt.Errorf("failed to update env file %s: %w", d.cfg.EnvFile, err)
+		}
+		d.logger.Info("Updated compose env file", "path", d.cfg.EnvFile, "key", d.cfg.EnvKey, "value", version)
+	}
+
+	args := []string{"compose"}
+	if d.cfg.File != "" {
+		args = append(args, "-f", d.cfg.File)
+	}
+	if d.cfg.Project != "" {
+		args = append(args, "-p", d.cfg.Project)
+	}
+	if d.cfg.EnvFile != "" {
+		args = append(args, "--env-file", d.cfg.EnvFile)
+	}
+	args = append(args, "up", "-d")
+	args = append(args, d.cfg.Services...)
+
+	return runCommand(ctx, d.logger, bundle, d.env, "docker", args...)
+}
+
+// certificateVersion derives a version string from the leaf certificate serial number,
+// falling back to a timestamp when the bundle cannot be parsed
+func certificateVersion(certPEM []byte) string {
+	if block, _ := pem.Decode(certPEM); block != nil {
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			return cert.SerialNumber.Text(16)
+		}
+	}
+	return time.Now().UTC().Format("20060102150405")
+}
+
+// setEnvFileValue sets KEY=value in a dotenv file, preserving all other lines
+func setEnvFileValue(path, key, value string) error {
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var out bytes.Buffer
+	replaced := false
+	scanner := bufio.NewScanner(bytes.NewReader(existing))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "export "))
+		if strings.HasPrefix(trimmed, key+"=") {
+			line = key + "=" + value
+			replaced = true
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if !replaced {
+		out.WriteString(key + "=" + value + "\n")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return fsys.WriteFileAtomic(fsys.OS, path, out.Bytes(), 0644)
+}
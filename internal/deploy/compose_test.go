@@ -0,0 +1,53 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestSetEnvFileValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	existing := "# certificate version\nexport CERT_VERSION=old\nCERT_VERSION_SUFFIX=keep\n\nOTHER=1\n"
+	if err := os.WriteFile(path, []byte(existing), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := setEnvFileValue(path, "CERT_VERSION", "1a"); err != nil {
+		t.Fatalf("setEnvFileValue failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if want := "# certificate version\nCERT_VERSION=1a\nCERT_VERSION_SUFFIX=keep\n\nOTHER=1\n"; string(data) != want {
+		t.Errorf("Expected only the key to change, got:\n%s", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0640 {
+		t.Errorf("Expected the file mode to be kept, got %v", info.Mode().Perm())
+	}
+
+	if err := setEnvFileValue(path, "NEW_KEY", "2"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(path)
+	if want := "# certificate version\nCERT_VERSION=1a\nCERT_VERSION_SUFFIX=keep\n\nOTHER=1\nNEW_KEY=2\n"; string(data) != want {
+		t.Errorf("Expected a missing key to be appended, got:\n%s", data)
+	}
+
+	created := filepath.Join(t.TempDir(), "new.env")
+	if err := setEnvFileValue(created, "CERT_VERSION", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(created); string(data) != "CERT_VERSION=1\n" {
+		t.Errorf("Expected a missing file to be created, got %q", data)
+	}
+}
+
+func TestCertificateVersion(t *testing.T) {
+	// The test bundle has serial 1
+	if version := certificateVersion(newTestBundle(t).Cert); version != "1" {
+		t.Errorf("Expected the hex serial, got %q", version)
+	}
+	if version := certificateVersion([]byte("not a certificate")); !regexp.MustCompile(`^\d{14}$`).MatchString(version) {
+		t.Errorf("Expected a timestamp for an unparsable bundle, got %q", version)
+	}
+}
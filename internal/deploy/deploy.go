@@ -0,0 +1,92 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+	"ipssl-client/internal/state"
+)
+
+// Bundle describes a freshly installed certificate handed to deployers
+type Bundle struct {
+	IP       string
+	CertPath string
+	KeyPath  string
+	Cert     []byte
+	Key      []byte
+
+	// CycleID identifies the renewal cycle that produced the certificate
+	CycleID string
+
+	// Serial and PreviousSerial are the hex serials of the certificate and of
+	// the one it replaced, which are equal when the same certificate was installed again
+	Serial         string
+	PreviousSerial string
+}
+
+// Changed reports whether the bundle holds a certificate other than the one it replaced
+func (b *Bundle) Changed() bool {
+	return b.Serial != b.PreviousSerial
+}
+
+// Deployer pushes a renewed certificate to a consumer after it has been saved
+type Deployer interface {
+	Name() string
+	Deploy(ctx context.Context, bundle *Bundle) error
+}
+
+// New creates the deployers listed in the configuration
+func New(cfg *config.Config, logger *logger.Logger) ([]Deployer, error) {
+	var deployers []Deployer
+	for _, name := range cfg.Deployers {
+		var (
+			d   Deployer
+			err error
+		)
+		switch name {
+		case "compose":
+			d, err = NewComposeDeployer(cfg.Compose, cfg.HookEnv, logger)
+		case "iis":
+			d, err = NewIISDeployer(cfg.IIS, logger)
+		case "pkcs12":
+			d, err = NewPKCS12Deployer(cfg.PKCS12, logger)
+		case "http":
+			d, err = NewHTTPDeployer(cfg.HTTP, logger)
+		case "vault":
+			d, err = NewVaultDeployer(cfg.Vault, logger)
+		case "aws":
+			d, err = NewAWSDeployer(cfg.AWS, logger)
+		case "azure":
+			d, err = NewAzureDeployer(cfg.Azure, logger)
+		case "kubernetes":
+			d, err = NewKubernetesDeployer(cfg.Kubernetes, logger)
+		case "upload":
+			d, err = NewUploadDeployer(cfg.Upload, logger)
+		case "mikrotik":
+			d, err = NewMikroTikDeployer(cfg.Appliances[name], logger)
+		case "pfsense":
+			d, err = NewPfSenseDeployer(cfg.Appliances[name], logger)
+		case "opnsense":
+			d, err = NewOPNsenseDeployer(cfg.Appliances[name], logger)
+		case "proxmox":
+			d, err = NewProxmoxDeployer(cfg.Appliances[name], logger)
+		case "vmware":
+			d, err = NewVMwareDeployer(cfg.Appliances[name], logger)
+		case "ssh":
+			d, err = NewSSHDeployer(cfg.SSH, filepath.Join(cfg.StateDir, state.AuditFileName), logger)
+		default:
+			if !IsPreset(name) {
+				return nil, fmt.Errorf("unknown deployer %q", name)
+			}
+			d, err = NewPresetDeployer(name, cfg.Presets[name], cfg.HookEnv, logger)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s deployer: %w", name, err)
+		}
+		deployers = append(deployers, d)
+	}
+	return deployers, nil
+}
@@ -0,0 +1,108 @@
+package deploy
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"ipssl-client/internal/logger"
+)
+
+// RunOptions controls how Run schedules deployers
+type RunOptions struct {
+	// Concurrency is the number of deployers running at once; values below 1 run them one by one
+	Concurrency int
+
+	// Retries is the number of extra rounds in which only failed deployers are run again
+	Retries int
+
+	// RetryDelay is the pause before each retry round
+	RetryDelay time.Duration
+}
+
+// Result is the outcome of one deployer across all attempts
+type Result struct {
+	Name     string
+	Err      error
+	Attempts int
+	Duration time.Duration
+}
+
+// Run deploys the bundle to every deployer concurrently and retries only the
+// deployers that failed. Results are returned in the order of deployers. The
+// bundle is shared between deployers and must not be modified by them.
+func Run(ctx context.Context, deployers []Deployer, bundle *Bundle, opts RunOptions, logger *logger.Logger) []Result {
+	results := make([]Result, len(deployers))
+	pending := make([]int, len(deployers))
+	for i, d := range deployers {
+		results[i].Name = d.Name()
+		pending[i] = i
+	}
+
+	for round := 0; round <= opts.Retries && len(pending) > 0; round++ {
+		if round > 0 {
+			logger.Info("Retrying failed deployers", "attempt", round+1, "deployers", names(results, pending))
+			select {
+			case <-ctx.Done():
+				return results
+			case <-time.After(opts.RetryDelay):
+			}
+		}
+
+		runRound(ctx, deployers, bundle, pending, results, opts.Concurrency, logger)
+
+		var failed []int
+		for _, i := range pending {
+			if results[i].Err != nil {
+				failed = append(failed, i)
+			}
+		}
+		pending = failed
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return results
+}
+
+// runRound runs the deployers at the given indexes with bounded concurrency
+func runRound(ctx context.Context, deployers []Deployer, bundle *Bundle, indexes []int, results []Result, concurrency int, logger *logger.Logger) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for _, i := range indexes {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			d := deployers[i]
+			started := time.Now()
+			err := d.Deploy(ctx, bundle)
+
+			// Each goroutine owns its result slot, so no locking is needed
+			results[i].Attempts++
+			results[i].Duration += time.Since(started)
+			results[i].Err = err
+			if err != nil {
+				logger.Error("Deployer failed", "deployer", d.Name(), "attempt", results[i].Attempts, "error", err)
+				return
+			}
+			logger.Info("Deployer completed", "deployer", d.Name(), "attempt", results[i].Attempts)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// names returns the deployer names at the given indexes
+func names(results []Result, indexes []int) []string {
+	out := make([]string, 0, len(indexes))
+	for _, i := range indexes {
+		out = append(out, results[i].Name)
+	}
+	return out
+}
@@ -0,0 +1,47 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"ipssl-client/internal/logger"
+)
+
+// flakyDeployer fails its first failures calls
+type flakyDeployer struct {
+	name     string
+	failures int32
+	calls    atomic.Int32
+}
+
+func (d *flakyDeployer) Name() string { return d.name }
+
+func (d *flakyDeployer) Deploy(context.Context, *Bundle) error {
+	if d.calls.Add(1) <= d.failures {
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+func TestRunRetriesOnlyFailed(t *testing.T) {
+	ok := &flakyDeployer{name: "ok"}
+	flaky := &flakyDeployer{name: "flaky", failures: 1}
+	broken := &flakyDeployer{name: "broken", failures: 10}
+
+	results := Run(context.Background(), []Deployer{ok, flaky, broken}, &Bundle{}, RunOptions{Concurrency: 3, Retries: 2}, logger.New())
+
+	if ok.calls.Load() != 1 {
+		t.Errorf("Expected successful deployer to run once, ran %d times", ok.calls.Load())
+	}
+	if results[1].Err != nil || results[1].Attempts != 2 {
+		t.Errorf("Expected flaky deployer to succeed on the second attempt, got %+v", results[1])
+	}
+	if results[2].Err == nil || results[2].Attempts != 3 {
+		t.Errorf("Expected broken deployer to fail after 3 attempts, got %+v", results[2])
+	}
+	if results[0].Name != "ok" || results[2].Name != "broken" {
+		t.Errorf("Expected results in deployer order, got %+v", results)
+	}
+}
@@ -0,0 +1,108 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+// HTTPDeployer performs a configurable HTTP request after renewal, e.g. to
+// upload the certificate to an appliance or trigger a reload endpoint
+type HTTPDeployer struct {
+	cfg     config.HTTPDeployConfig
+	body    *template.Template
+	headers http.Header
+	client  *http.Client
+	logger  *logger.Logger
+}
+
+// NewHTTPDeployer creates a new HTTP deployer
+func NewHTTPDeployer(cfg config.HTTPDeployConfig, logger *logger.Logger) (*HTTPDeployer, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("IPSSL_HTTP_URL is required")
+	}
+
+	bodyText := cfg.Body
+	if cfg.BodyFile != "" {
+		data, err := os.ReadFile(cfg.BodyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read body template: %w", err)
+		}
+		bodyText = string(data)
+	}
+	body, err := template.New("body").Funcs(templateFuncs).Parse(bodyText)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse body template: %w", err)
+	}
+
+	headers := make(http.Header)
+	for _, header := range cfg.Headers {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid header %q in IPSSL_HTTP_HEADERS (expected Name: value)", header)
+		}
+		headers.Set(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	return &HTTPDeployer{
+		cfg:     cfg,
+		body:    body,
+		headers: headers,
+		client:  newHTTPClient(cfg.InsecureSkipVerify, cfg.Timeout),
+		logger:  logger,
+	}, nil
+}
+
+// Name returns the deployer name
+func (d *HTTPDeployer) Name() string {
+	return "http"
+}
+
+// Deploy sends the configured request and checks the response status
+func (d *HTTPDeployer) Deploy(ctx context.Context, bundle *Bundle) error {
+	var body bytes.Buffer
+	if err := d.body.Execute(&body, newTemplateData(bundle)); err != nil {
+		return fmt.Errorf("failed to render body template: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, d.cfg.Method, d.cfg.URL, &body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range d.headers {
+		req.Header[name] = values
+	}
+
+	start := time.Now()
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", d.cfg.Method, d.cfg.URL, err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	if !d.statusExpected(resp.StatusCode) {
+		return fmt.Errorf("%s %s returned HTTP %d: %s", d.cfg.Method, d.cfg.URL, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	d.logger.Info("HTTP deploy request completed", "method", d.cfg.Method, "url", d.cfg.URL, "status", resp.StatusCode, "duration", time.Since(start))
+	return nil
+}
+
+// statusExpected checks a response status against the configured expectation (default: any 2xx)
+func (d *HTTPDeployer) statusExpected(status int) bool {
+	if len(d.cfg.ExpectedStatus) == 0 {
+		return status >= 200 && status < 300
+	}
+	return slices.Contains(d.cfg.ExpectedStatus, status)
+}
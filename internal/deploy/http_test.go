@@ -0,0 +1,78 @@
+package deploy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+func TestHTTPDeployer(t *testing.T) {
+	status := http.StatusNoContent
+	var got *http.Request
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		got, body = r, string(data)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	cfg := config.HTTPDeployConfig{
+		Method:  http.MethodPut,
+		URL:     server.URL + "/certs",
+		Headers: []string{"Content-Type: application/json", " Authorization :Bearer token:with:colons "},
+		Body:    `{"ip":{{json .IP}}}`,
+		Timeout: 5 * time.Second,
+	}
+	d, err := NewHTTPDeployer(cfg, logger.New())
+	if err != nil {
+		t.Fatalf("NewHTTPDeployer failed: %v", err)
+	}
+
+	if err := d.Deploy(context.Background(), newTestBundle(t)); err != nil {
+		t.Fatalf("Deploy failed: %v", err)
+	}
+	if got.Method != http.MethodPut || got.URL.Path != "/certs" || body != `{"ip":"192.0.2.1"}` {
+		t.Errorf("Unexpected request %s %s with body %s", got.Method, got.URL.Path, body)
+	}
+	if got.Header.Get("Content-Type") != "application/json" || got.Header.Get("Authorization") != "Bearer token:with:colons" {
+		t.Errorf("Expected the configured headers, got %v", got.Header)
+	}
+
+	// Without expected statuses any 2xx succeeds
+	for _, code := range []int{http.StatusFound, http.StatusInternalServerError} {
+		status = code
+		if err := d.Deploy(context.Background(), newTestBundle(t)); err == nil || !strings.Contains(err.Error(), "returned HTTP") {
+			t.Errorf("Expected HTTP %d to fail, got %v", code, err)
+		}
+	}
+
+	cfg.ExpectedStatus = []int{http.StatusAccepted}
+	if d, err = NewHTTPDeployer(cfg, logger.New()); err != nil {
+		t.Fatal(err)
+	}
+	status = http.StatusOK
+	if err := d.Deploy(context.Background(), newTestBundle(t)); err == nil {
+		t.Error("Expected a 2xx status outside IPSSL_HTTP_EXPECTED_STATUS to fail")
+	}
+	status = http.StatusAccepted
+	if err := d.Deploy(context.Background(), newTestBundle(t)); err != nil {
+		t.Errorf("Expected an expected status to succeed, got %v", err)
+	}
+}
+
+func TestHTTPDeployerInvalidHeader(t *testing.T) {
+	for _, header := range []string{"Authorization", ": value"} {
+		_, err := NewHTTPDeployer(config.HTTPDeployConfig{Method: http.MethodPost, URL: "https://appliance.local", Headers: []string{header}}, logger.New())
+		if err == nil || !strings.Contains(err.Error(), "invalid header") {
+			t.Errorf("Expected header %q to be rejected, got %v", header, err)
+		}
+	}
+}
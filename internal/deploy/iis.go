@@ -0,0 +1,17 @@
+package deploy
+
+import (
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+// IISDeployer installs the certificate into the Windows machine store and binds it to an IIS site
+type IISDeployer struct {
+	cfg    config.IISConfig
+	logger *logger.Logger
+}
+
+// Name returns the deployer name
+func (d *IISDeployer) Name() string {
+	return "iis"
+}
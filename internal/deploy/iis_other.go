@@ -0,0 +1,24 @@
+//go:build !windows
+
+package deploy
+
+import (
+	"context"
+	"errors"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+// errIISUnsupported is returned by the IIS deployer outside Windows
+var errIISUnsupported = errors.New("the iis deployer is only supported on Windows")
+
+// NewIISDeployer reports that the IIS deployer requires Windows
+func NewIISDeployer(cfg config.IISConfig, logger *logger.Logger) (*IISDeployer, error) {
+	return nil, errIISUnsupported
+}
+
+// Deploy reports that the IIS deployer requires Windows
+func (d *IISDeployer) Deploy(ctx context.Context, bundle *Bundle) error {
+	return errIISUnsupported
+}
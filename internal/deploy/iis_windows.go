@@ -0,0 +1,95 @@
+package deploy
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+// iisEnv lists the Windows variables PowerShell needs in addition to baseEnv,
+// which name them in their Windows spelling
+var iisEnv = []string{"Path", "PATHEXT", "SystemRoot", "SystemDrive", "windir", "ComSpec", "TEMP", "TMP", "USERPROFILE", "PSModulePath", "ProgramData", "ProgramFiles*", "APPDATA", "LOCALAPPDATA"}
+
+// iisScript imports the PFX into the machine store and rebinds the IIS HTTPS binding
+const iisScript = `
+$ErrorActionPreference = 'Stop'
+Import-Module WebAdministration
+$password = ConvertTo-SecureString -String $env:IPSSL_PFX_PASSWORD -AsPlainText -Force
+$cert = Import-PfxCertificate -FilePath $env:IPSSL_PFX_PATH -CertStoreLocation Cert:\LocalMachine\My -Password $password
+if ($cert.Thumbprint -ne $env:IPSSL_THUMBPRINT) { throw "imported thumbprint $($cert.Thumbprint) does not match $env:IPSSL_THUMBPRINT" }
+$binding = Get-WebBinding -Name $env:IPSSL_IIS_SITE -Protocol https -Port $env:IPSSL_IIS_PORT -IPAddress $env:IPSSL_IIS_BIND_IP
+if ($binding -eq $null) {
+  New-WebBinding -Name $env:IPSSL_IIS_SITE -Protocol https -Port $env:IPSSL_IIS_PORT -IPAddress $env:IPSSL_IIS_BIND_IP
+  $binding = Get-WebBinding -Name $env:IPSSL_IIS_SITE -Protocol https -Port $env:IPSSL_IIS_PORT -IPAddress $env:IPSSL_IIS_BIND_IP
+}
+$binding.AddSslCertificate($cert.Thumbprint, 'My')
+`
+
+// NewIISDeployer creates a new IIS deployer
+func NewIISDeployer(cfg config.IISConfig, logger *logger.Logger) (*IISDeployer, error) {
+	if cfg.Site == "" {
+		return nil, fmt.Errorf("IPSSL_IIS_SITE is required")
+	}
+	return &IISDeployer{cfg: cfg, logger: logger}, nil
+}
+
+// Deploy imports the certificate and updates the HTTPS binding to its thumbprint
+func (d *IISDeployer) Deploy(ctx context.Context, bundle *Bundle) error {
+	leaf, _, _, err := parseBundle(bundle)
+	if err != nil {
+		return err
+	}
+	sum := sha1.Sum(leaf.Raw)
+	thumbprint := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	// The PFX only lives for the duration of the import, protected by a one-time password
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("failed to generate PFX password: %w", err)
+	}
+	password := hex.EncodeToString(secret)
+
+	pfx, err := encodePFX(bundle, password)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp("", "ipssl-*.pfx")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary PFX file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(pfx); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary PFX file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write temporary PFX file: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, "powershell.exe", "-NoProfile", "-NonInteractive", "-Command", iisScript)
+	cmd.Env = append(CommandEnv(os.Environ(), iisEnv),
+		"IPSSL_PFX_PATH="+tmp.Name(),
+		"IPSSL_PFX_PASSWORD="+password,
+		"IPSSL_THUMBPRINT="+thumbprint,
+		"IPSSL_IIS_SITE="+d.cfg.Site,
+		"IPSSL_IIS_PORT="+strconv.Itoa(d.cfg.Port),
+		"IPSSL_IIS_BIND_IP="+d.cfg.BindIP,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to update IIS binding: %w (output: %s)", err, strings.TrimSpace(string(output)))
+	}
+
+	d.logger.Info("IIS binding updated", "site", d.cfg.Site, "port", d.cfg.Port, "thumbprint", thumbprint)
+	return nil
+}
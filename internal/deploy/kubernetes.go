@@ -0,0 +1,132 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+// kubeFieldManager owns the Secret fields written by server-side apply
+const kubeFieldManager = "ipssl-client"
+
+// kubeName matches Kubernetes namespace and Secret names
+var kubeName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+
+// KubernetesDeployer writes the certificate as a kubernetes.io/tls Secret,
+// which ingress controllers referencing it reload without shared volumes
+type KubernetesDeployer struct {
+	cfg    config.KubeConfig
+	client *http.Client
+	logger *logger.Logger
+}
+
+// NewKubernetesDeployer creates a new Kubernetes deployer. Without a
+// configured namespace the Secret is written to the pod's own namespace.
+func NewKubernetesDeployer(cfg config.KubeConfig, logger *logger.Logger) (*KubernetesDeployer, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("IPSSL_KUBERNETES_URL is required outside a pod")
+	}
+	if cfg.Namespace == "" {
+		data, err := os.ReadFile(filepath.Join(filepath.Dir(cfg.TokenFile), "namespace"))
+		if err != nil {
+			return nil, fmt.Errorf("IPSSL_KUBERNETES_NAMESPACE is required outside a pod: %w", err)
+		}
+		cfg.Namespace = strings.TrimSpace(string(data))
+	}
+	if !kubeName.MatchString(cfg.Namespace) {
+		return nil, fmt.Errorf("invalid IPSSL_KUBERNETES_NAMESPACE %q", cfg.Namespace)
+	}
+	if !kubeName.MatchString(cfg.Secret) {
+		return nil, fmt.Errorf("invalid IPSSL_KUBERNETES_SECRET %q (expected a DNS subdomain name)", cfg.Secret)
+	}
+
+	// Outside a pod the API server certificate is verified with the system roots
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	data, err := os.ReadFile(cfg.CAFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read Kubernetes CA: %w", err)
+	}
+	if err == nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return &KubernetesDeployer{
+		cfg:    cfg,
+		client: &http.Client{Transport: transport, Timeout: apiTimeout},
+		logger: logger,
+	}, nil
+}
+
+// Name returns the deployer name
+func (d *KubernetesDeployer) Name() string {
+	return "kubernetes"
+}
+
+// Deploy creates or updates the Secret with server-side apply
+func (d *KubernetesDeployer) Deploy(ctx context.Context, bundle *Bundle) error {
+	token, err := readSecretFile(d.cfg.TokenFile, "Kubernetes service account token")
+	if err != nil {
+		return err
+	}
+
+	secret := map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]any{
+			"name":        d.cfg.Secret,
+			"namespace":   d.cfg.Namespace,
+			"annotations": map[string]string{"ipssl-client/ip": bundle.IP, "ipssl-client/serial": bundle.Serial},
+		},
+		"type": "kubernetes.io/tls",
+		"data": map[string][]byte{
+			"tls.crt": bundle.Cert,
+			"tls.key": bundle.Key,
+		},
+	}
+	// JSON is valid YAML, which is what apply patches are sent as
+	body, err := json.Marshal(secret)
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/api/v1/namespaces/%s/secrets/%s?fieldManager=%s&force=true",
+		strings.TrimSuffix(d.cfg.URL, "/"), url.PathEscape(d.cfg.Namespace), url.PathEscape(d.cfg.Secret), kubeFieldManager)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/apply-patch+yaml")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to write Secret %s/%s: %w", d.cfg.Namespace, d.cfg.Secret, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return fmt.Errorf("failed to write Secret %s/%s: HTTP %d: %s", d.cfg.Namespace, d.cfg.Secret, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	d.logger.Info("Kubernetes TLS Secret updated", "namespace", d.cfg.Namespace, "secret", d.cfg.Secret)
+	return nil
+}
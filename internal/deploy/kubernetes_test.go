@@ -0,0 +1,83 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+func TestKubernetesDeployer(t *testing.T) {
+	var applied struct {
+		Type     string            `json:"type"`
+		Data     map[string][]byte `json:"data"`
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+	}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.URL.Path != "/api/v1/namespaces/ingress/secrets/ipssl-tls" ||
+			r.URL.Query().Get("fieldManager") != "ipssl-client" || r.Header.Get("Content-Type") != "application/apply-patch+yaml" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer sa-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&applied)
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	// Lay out the service account files the way they are mounted in a pod
+	dir := t.TempDir()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	for name, data := range map[string][]byte{"token": []byte("sa-token\n"), "namespace": []byte("ingress"), "ca.crt": ca} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d, err := NewKubernetesDeployer(config.KubeConfig{
+		URL:       server.URL,
+		Secret:    "ipssl-tls",
+		TokenFile: filepath.Join(dir, "token"),
+		CAFile:    filepath.Join(dir, "ca.crt"),
+	}, logger.New())
+	if err != nil {
+		t.Fatalf("NewKubernetesDeployer failed: %v", err)
+	}
+
+	bundle := newTestBundle(t)
+	if err := d.Deploy(context.Background(), bundle); err != nil {
+		t.Fatalf("Deploy failed: %v", err)
+	}
+	if applied.Type != "kubernetes.io/tls" || applied.Metadata.Name != "ipssl-tls" || applied.Metadata.Namespace != "ingress" {
+		t.Errorf("Unexpected Secret %+v", applied.Metadata)
+	}
+	if string(applied.Data["tls.crt"]) != string(bundle.Cert) || string(applied.Data["tls.key"]) != string(bundle.Key) {
+		t.Error("Expected the chain and key in tls.crt and tls.key")
+	}
+}
+
+func TestKubernetesDeployerOutsidePod(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "token")
+	if _, err := NewKubernetesDeployer(config.KubeConfig{URL: "https://k8s.local", Secret: "ipssl-tls", TokenFile: missing}, logger.New()); err == nil {
+		t.Error("Expected an error without a namespace outside a pod")
+	}
+	if _, err := NewKubernetesDeployer(config.KubeConfig{URL: "https://k8s.local", Namespace: "default", Secret: "IPSSL_TLS", TokenFile: missing}, logger.New()); err == nil {
+		t.Error("Expected an error for an invalid Secret name")
+	}
+	if _, err := NewKubernetesDeployer(config.KubeConfig{URL: "https://k8s.local", Namespace: "default", Secret: "ipssl-tls", TokenFile: missing, CAFile: missing}, logger.New()); err != nil {
+		t.Errorf("Expected the system roots without a CA file, got %v", err)
+	}
+}
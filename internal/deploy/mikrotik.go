@@ -0,0 +1,117 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+// mikrotikServices are the RouterOS services switched to the new certificate
+var mikrotikServices = []string{"www-ssl", "api-ssl"}
+
+// MikroTikDeployer installs the certificate on RouterOS 7 through its REST API
+type MikroTikDeployer struct {
+	cfg    config.ApplianceConfig
+	client *http.Client
+	logger *logger.Logger
+}
+
+// NewMikroTikDeployer creates a new MikroTik deployer
+func NewMikroTikDeployer(cfg config.ApplianceConfig, logger *logger.Logger) (*MikroTikDeployer, error) {
+	if cfg.URL == "" || cfg.Username == "" {
+		return nil, fmt.Errorf("IPSSL_MIKROTIK_URL and IPSSL_MIKROTIK_USERNAME are required")
+	}
+	return &MikroTikDeployer{
+		cfg:    cfg,
+		client: newHTTPClient(cfg.InsecureSkipVerify, apiTimeout),
+		logger: logger,
+	}, nil
+}
+
+// Name returns the deployer name
+func (d *MikroTikDeployer) Name() string {
+	return "mikrotik"
+}
+
+// routerOSItem is an entry returned by RouterOS list endpoints
+type routerOSItem struct {
+	ID   string `json:".id"`
+	Name string `json:"name"`
+}
+
+// Deploy uploads and imports the certificate under a versioned name, switches the
+// HTTPS and API-SSL services to it and removes previously imported versions
+func (d *MikroTikDeployer) Deploy(ctx context.Context, bundle *Bundle) error {
+	leaf, _, _, err := parseBundle(bundle)
+	if err != nil {
+		return err
+	}
+	certName := d.cfg.CertName + "-" + leaf.SerialNumber.Text(16)
+	files := map[string][]byte{
+		certName + ".crt": bundle.Cert,
+		certName + ".key": bundle.Key,
+	}
+
+	for fileName, contents := range files {
+		if err := d.call(ctx, http.MethodPut, "/file", map[string]string{"name": fileName, "contents": string(contents)}, nil); err != nil {
+			return fmt.Errorf("failed to upload %s: %w", fileName, err)
+		}
+	}
+	for _, fileName := range []string{certName + ".crt", certName + ".key"} {
+		payload := map[string]string{"file-name": fileName, "passphrase": "", "name": certName}
+		if err := d.call(ctx, http.MethodPost, "/certificate/import", payload, nil); err != nil {
+			return fmt.Errorf("failed to import %s: %w", fileName, err)
+		}
+	}
+
+	for _, service := range mikrotikServices {
+		var items []routerOSItem
+		if err := d.call(ctx, http.MethodGet, "/ip/service?name="+url.QueryEscape(service), nil, &items); err != nil {
+			return fmt.Errorf("failed to look up service %s: %w", service, err)
+		}
+		for _, item := range items {
+			if err := d.call(ctx, http.MethodPatch, "/ip/service/"+url.PathEscape(item.ID), map[string]string{"certificate": certName}, nil); err != nil {
+				return fmt.Errorf("failed to set certificate on service %s: %w", service, err)
+			}
+		}
+	}
+	d.logger.Info("MikroTik services switched to new certificate", "certificate", certName)
+
+	d.cleanup(ctx, certName, files)
+	return nil
+}
+
+// cleanup removes uploaded files and older certificates imported by this client
+func (d *MikroTikDeployer) cleanup(ctx context.Context, current string, uploaded map[string][]byte) {
+	var fileItems []routerOSItem
+	if err := d.call(ctx, http.MethodGet, "/file", nil, &fileItems); err == nil {
+		for _, item := range fileItems {
+			if _, ok := uploaded[item.Name]; ok {
+				_ = d.call(ctx, http.MethodDelete, "/file/"+url.PathEscape(item.ID), nil, nil)
+			}
+		}
+	}
+
+	var certs []routerOSItem
+	if err := d.call(ctx, http.MethodGet, "/certificate", nil, &certs); err != nil {
+		d.logger.Warn("Failed to list MikroTik certificates for cleanup", "error", err)
+		return
+	}
+	for _, cert := range certs {
+		if cert.Name != current && strings.HasPrefix(cert.Name, d.cfg.CertName+"-") {
+			if err := d.call(ctx, http.MethodDelete, "/certificate/"+url.PathEscape(cert.ID), nil, nil); err != nil {
+				d.logger.Warn("Failed to remove old MikroTik certificate", "certificate", cert.Name, "error", err)
+			}
+		}
+	}
+}
+
+// call performs a RouterOS REST API request
+func (d *MikroTikDeployer) call(ctx context.Context, method, endpoint string, payload, out any) error {
+	return doJSON(ctx, d.client, method, strings.TrimSuffix(d.cfg.URL, "/")+"/rest"+endpoint, payload, out, basicAuth(d.cfg.Username, d.cfg.Password))
+}
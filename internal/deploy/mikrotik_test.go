@@ -0,0 +1,68 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+func TestMikroTikDeployer(t *testing.T) {
+	var uploaded, imported, services, deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		switch r.Method + " " + r.URL.Path {
+		case "PUT /rest/file":
+			uploaded = append(uploaded, body["name"])
+		case "POST /rest/certificate/import":
+			imported = append(imported, body["file-name"])
+		case "GET /rest/ip/service":
+			w.Write([]byte(`[{".id":"*` + r.URL.Query().Get("name") + `","name":"` + r.URL.Query().Get("name") + `"}]`))
+		case "PATCH /rest/ip/service/*www-ssl", "PATCH /rest/ip/service/*api-ssl":
+			services = append(services, r.URL.Path+"="+body["certificate"])
+		case "GET /rest/file":
+			w.Write([]byte(`[{".id":"*F1","name":"ipssl-1.crt"},{".id":"*F2","name":"ipssl-1.key"},{".id":"*F3","name":"backup.rsc"}]`))
+		case "GET /rest/certificate":
+			w.Write([]byte(`[{".id":"*C1","name":"ipssl-1"},{".id":"*C2","name":"ipssl-0"},{".id":"*C3","name":"other"}]`))
+		case "DELETE /rest/file/*F1", "DELETE /rest/file/*F2", "DELETE /rest/file/*F3",
+			"DELETE /rest/certificate/*C1", "DELETE /rest/certificate/*C2", "DELETE /rest/certificate/*C3":
+			deleted = append(deleted, r.URL.Path)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	d, err := NewMikroTikDeployer(config.ApplianceConfig{URL: server.URL, Username: "admin", Password: "secret", CertName: "ipssl"}, logger.New())
+	if err != nil {
+		t.Fatalf("NewMikroTikDeployer failed: %v", err)
+	}
+	if err := d.Deploy(context.Background(), newTestBundle(t)); err != nil {
+		t.Fatalf("Deploy failed: %v", err)
+	}
+
+	// The test bundle has serial 1
+	slices.Sort(uploaded)
+	if !slices.Equal(uploaded, []string{"ipssl-1.crt", "ipssl-1.key"}) {
+		t.Errorf("Expected the versioned certificate and key to be uploaded, got %v", uploaded)
+	}
+	if !slices.Equal(imported, []string{"ipssl-1.crt", "ipssl-1.key"}) {
+		t.Errorf("Expected the certificate to be imported before its key, got %v", imported)
+	}
+	if !slices.Equal(services, []string{"/rest/ip/service/*www-ssl=ipssl-1", "/rest/ip/service/*api-ssl=ipssl-1"}) {
+		t.Errorf("Expected both services to be switched, got %v", services)
+	}
+	if !slices.Equal(deleted, []string{"/rest/file/*F1", "/rest/file/*F2", "/rest/certificate/*C2"}) {
+		t.Errorf("Expected the uploads and older versions to be removed, got %v", deleted)
+	}
+}
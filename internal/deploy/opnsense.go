@@ -0,0 +1,86 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+// OPNsenseDeployer updates a certificate in the OPNsense trust store. The certificate
+// is updated in place so services referencing it keep using it after renewal.
+type OPNsenseDeployer struct {
+	cfg    config.ApplianceConfig
+	client *http.Client
+	logger *logger.Logger
+}
+
+// NewOPNsenseDeployer creates a new OPNsense deployer
+func NewOPNsenseDeployer(cfg config.ApplianceConfig, logger *logger.Logger) (*OPNsenseDeployer, error) {
+	if cfg.URL == "" || cfg.APIKey == "" || cfg.APISecret == "" {
+		return nil, fmt.Errorf("IPSSL_OPNSENSE_URL, IPSSL_OPNSENSE_API_KEY and IPSSL_OPNSENSE_API_SECRET are required")
+	}
+	return &OPNsenseDeployer{
+		cfg:    cfg,
+		client: newHTTPClient(cfg.InsecureSkipVerify, apiTimeout),
+		logger: logger,
+	}, nil
+}
+
+// Name returns the deployer name
+func (d *OPNsenseDeployer) Name() string {
+	return "opnsense"
+}
+
+// Deploy imports the certificate, replacing the payload of an existing entry with the same description
+func (d *OPNsenseDeployer) Deploy(ctx context.Context, bundle *Bundle) error {
+	var search struct {
+		Rows []struct {
+			UUID  string `json:"uuid"`
+			Descr string `json:"descr"`
+		} `json:"rows"`
+	}
+	if err := d.call(ctx, http.MethodGet, "/trust/cert/search?searchPhrase="+url.QueryEscape(d.cfg.CertName), nil, &search); err != nil {
+		return fmt.Errorf("failed to look up certificate: %w", err)
+	}
+
+	endpoint := "/trust/cert/add"
+	for _, row := range search.Rows {
+		if row.Descr == d.cfg.CertName {
+			endpoint = "/trust/cert/set/" + url.PathEscape(row.UUID)
+			break
+		}
+	}
+
+	payload := map[string]any{
+		"cert": map[string]string{
+			"action":      "import",
+			"descr":       d.cfg.CertName,
+			"crt_payload": string(bundle.Cert),
+			"prv_payload": string(bundle.Key),
+		},
+	}
+	var result struct {
+		Result      string         `json:"result"`
+		UUID        string         `json:"uuid"`
+		Validations map[string]any `json:"validations"`
+	}
+	if err := d.call(ctx, http.MethodPost, endpoint, payload, &result); err != nil {
+		return fmt.Errorf("failed to store certificate: %w", err)
+	}
+	if result.Result != "saved" {
+		return fmt.Errorf("OPNsense rejected certificate (result: %s, validations: %v)", result.Result, result.Validations)
+	}
+
+	d.logger.Info("OPNsense certificate updated", "descr", d.cfg.CertName, "endpoint", endpoint)
+	return nil
+}
+
+// call performs an OPNsense API request
+func (d *OPNsenseDeployer) call(ctx context.Context, method, endpoint string, payload, out any) error {
+	return doJSON(ctx, d.client, method, strings.TrimSuffix(d.cfg.URL, "/")+"/api"+endpoint, payload, out, basicAuth(d.cfg.APIKey, d.cfg.APISecret))
+}
@@ -0,0 +1,53 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+func TestOPNsenseDeployer(t *testing.T) {
+	var endpoint string
+	var stored map[string]map[string]string
+	result := `{"result":"saved"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "key" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/api/trust/cert/search" {
+			w.Write([]byte(`{"rows":[{"uuid":"other","descr":"ipssl-old"},{"uuid":"1234","descr":"ipssl"}]}`))
+			return
+		}
+		endpoint = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&stored)
+		w.Write([]byte(result))
+	}))
+	defer server.Close()
+
+	d, err := NewOPNsenseDeployer(config.ApplianceConfig{URL: server.URL, APIKey: "key", APISecret: "secret", CertName: "ipssl"}, logger.New())
+	if err != nil {
+		t.Fatalf("NewOPNsenseDeployer failed: %v", err)
+	}
+	bundle := newTestBundle(t)
+	if err := d.Deploy(context.Background(), bundle); err != nil {
+		t.Fatalf("Deploy failed: %v", err)
+	}
+	if endpoint != "/api/trust/cert/set/1234" {
+		t.Errorf("Expected the certificate with the exact description to be updated, got %s", endpoint)
+	}
+	if cert := stored["cert"]; cert["action"] != "import" || cert["crt_payload"] != string(bundle.Cert) || cert["prv_payload"] != string(bundle.Key) {
+		t.Errorf("Unexpected certificate payload %v", cert)
+	}
+
+	result = `{"result":"failed","validations":{"cert.crt_payload":"invalid"}}`
+	if err := d.Deploy(context.Background(), bundle); err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Errorf("Expected a validation failure to be reported, got %v", err)
+	}
+}
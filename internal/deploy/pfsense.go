@@ -0,0 +1,93 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+// PfSenseDeployer updates a certificate on pfSense through the pfSense REST API
+// package (v2) and assigns it to the web GUI
+type PfSenseDeployer struct {
+	cfg    config.ApplianceConfig
+	client *http.Client
+	logger *logger.Logger
+}
+
+// NewPfSenseDeployer creates a new pfSense deployer
+func NewPfSenseDeployer(cfg config.ApplianceConfig, logger *logger.Logger) (*PfSenseDeployer, error) {
+	if cfg.URL == "" || cfg.APIKey == "" {
+		return nil, fmt.Errorf("IPSSL_PFSENSE_URL and IPSSL_PFSENSE_API_KEY are required")
+	}
+	return &PfSenseDeployer{
+		cfg:    cfg,
+		client: newHTTPClient(cfg.InsecureSkipVerify, apiTimeout),
+		logger: logger,
+	}, nil
+}
+
+// Name returns the deployer name
+func (d *PfSenseDeployer) Name() string {
+	return "pfsense"
+}
+
+// pfSenseCertificate is a certificate object of the pfSense REST API
+type pfSenseCertificate struct {
+	ID    int    `json:"id"`
+	RefID string `json:"refid"`
+	Descr string `json:"descr"`
+}
+
+// Deploy updates the certificate in place (keeping its refid) or creates it, then binds it to the web GUI
+func (d *PfSenseDeployer) Deploy(ctx context.Context, bundle *Bundle) error {
+	var existing struct {
+		Data []pfSenseCertificate `json:"data"`
+	}
+	if err := d.call(ctx, http.MethodGet, "/system/certificates?descr="+url.QueryEscape(d.cfg.CertName), nil, &existing); err != nil {
+		return fmt.Errorf("failed to look up certificate: %w", err)
+	}
+
+	payload := map[string]any{
+		"descr": d.cfg.CertName,
+		"crt":   string(bundle.Cert),
+		"prv":   string(bundle.Key),
+	}
+
+	var result struct {
+		Data pfSenseCertificate `json:"data"`
+	}
+	method := http.MethodPost
+	for _, cert := range existing.Data {
+		if cert.Descr == d.cfg.CertName {
+			payload["id"] = cert.ID
+			method = http.MethodPatch
+			break
+		}
+	}
+	if err := d.call(ctx, method, "/system/certificate", payload, &result); err != nil {
+		return fmt.Errorf("failed to store certificate: %w", err)
+	}
+	// Assigning an empty refid would leave the web GUI without a certificate
+	if result.Data.RefID == "" {
+		return fmt.Errorf("pfSense returned no refid for certificate %q", d.cfg.CertName)
+	}
+
+	if err := d.call(ctx, http.MethodPatch, "/system/webgui/settings", map[string]string{"sslcertref": result.Data.RefID}, nil); err != nil {
+		return fmt.Errorf("failed to assign certificate to web GUI: %w", err)
+	}
+
+	d.logger.Info("pfSense certificate updated", "descr", d.cfg.CertName, "refid", result.Data.RefID)
+	return nil
+}
+
+// call performs a pfSense REST API request
+func (d *PfSenseDeployer) call(ctx context.Context, method, endpoint string, payload, out any) error {
+	return doJSON(ctx, d.client, method, strings.TrimSuffix(d.cfg.URL, "/")+"/api/v2"+endpoint, payload, out, func(req *http.Request) {
+		req.Header.Set("X-API-Key", d.cfg.APIKey)
+	})
+}
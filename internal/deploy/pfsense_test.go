@@ -0,0 +1,80 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+// newPfSenseServer serves the pfSense REST API with an existing certificate
+// named ipssl, answering updates with refid
+func newPfSenseServer(t *testing.T, refid string) (*httptest.Server, *[]string, *map[string]any) {
+	t.Helper()
+	var calls []string
+	var stored map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/v2/system/certificates":
+			w.Write([]byte(`{"data":[{"id":3,"refid":"old","descr":"ipssl"}]}`))
+		case "PATCH /api/v2/system/certificate":
+			json.NewDecoder(r.Body).Decode(&stored)
+			w.Write([]byte(`{"data":{"id":3,"refid":"` + refid + `","descr":"ipssl"}}`))
+		case "PATCH /api/v2/system/webgui/settings":
+			var settings map[string]string
+			json.NewDecoder(r.Body).Decode(&settings)
+			if settings["sslcertref"] != refid {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls, &stored
+}
+
+func TestPfSenseDeployer(t *testing.T) {
+	server, calls, stored := newPfSenseServer(t, "5f0c")
+	d, err := NewPfSenseDeployer(config.ApplianceConfig{URL: server.URL + "/", APIKey: "key", CertName: "ipssl"}, logger.New())
+	if err != nil {
+		t.Fatalf("NewPfSenseDeployer failed: %v", err)
+	}
+
+	bundle := newTestBundle(t)
+	if err := d.Deploy(context.Background(), bundle); err != nil {
+		t.Fatalf("Deploy failed: %v", err)
+	}
+	if (*stored)["id"] != float64(3) || (*stored)["crt"] != string(bundle.Cert) || (*stored)["prv"] != string(bundle.Key) {
+		t.Errorf("Expected the existing certificate to be updated in place, got %v", *stored)
+	}
+	if got := strings.Join(*calls, ", "); !strings.HasSuffix(got, "PATCH /api/v2/system/webgui/settings") {
+		t.Errorf("Expected the certificate to be assigned to the web GUI, got %s", got)
+	}
+}
+
+func TestPfSenseDeployerRequiresRefID(t *testing.T) {
+	server, calls, _ := newPfSenseServer(t, "")
+	d, err := NewPfSenseDeployer(config.ApplianceConfig{URL: server.URL, APIKey: "key", CertName: "ipssl"}, logger.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Deploy(context.Background(), newTestBundle(t)); err == nil || !strings.Contains(err.Error(), "refid") {
+		t.Errorf("Expected the missing refid to be reported, got %v", err)
+	}
+	for _, call := range *calls {
+		if strings.Contains(call, "webgui") {
+			t.Error("Expected the web GUI to keep its certificate")
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package deploy
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// parseBundle decodes the certificate chain and private key of a bundle
+func parseBundle(bundle *Bundle) (*x509.Certificate, []*x509.Certificate, crypto.PrivateKey, error) {
+	var certs []*x509.Certificate
+	rest := bundle.Cert
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, nil, nil, fmt.Errorf("no certificate found in bundle")
+	}
+
+	block, _ := pem.Decode(bundle.Key)
+	if block == nil {
+		return nil, nil, nil, fmt.Errorf("failed to decode private key PEM")
+	}
+	key, err := parsePrivateKey(block)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return certs[0], certs[1:], key, nil
+}
+
+// splitLeaf splits a PEM chain into the first certificate block and the remaining blocks
+func splitLeaf(chainPEM []byte) ([]byte, []byte) {
+	block, rest := pem.Decode(chainPEM)
+	if block == nil {
+		return nil, nil
+	}
+	var chain []byte
+	for {
+		var next *pem.Block
+		next, rest = pem.Decode(rest)
+		if next == nil {
+			break
+		}
+		chain = append(chain, pem.EncodeToMemory(next)...)
+	}
+	return pem.EncodeToMemory(block), chain
+}
+
+// parsePrivateKey parses a PKCS#1, SEC 1 or PKCS#8 private key block
+func parsePrivateKey(block *pem.Block) (crypto.PrivateKey, error) {
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported private key type %q", block.Type)
+	}
+}
+
+// encodePFX builds a password-protected PKCS#12 archive from a bundle
+func encodePFX(bundle *Bundle, password string) ([]byte, error) {
+	return encodePFXWith(pkcs12.Modern, bundle, password)
+}
+
+// encodePFXWith builds a password-protected PKCS#12 archive from a bundle using the given encoder
+func encodePFXWith(encoder *pkcs12.Encoder, bundle *Bundle, password string) ([]byte, error) {
+	leaf, chain, key, err := parseBundle(bundle)
+	if err != nil {
+		return nil, err
+	}
+	pfx, err := encoder.Encode(key, leaf, chain, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode PKCS#12: %w", err)
+	}
+	return pfx, nil
+}
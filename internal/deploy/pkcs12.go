@@ -0,0 +1,76 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"software.sslmate.com/src/go-pkcs12"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/fsys"
+	"ipssl-client/internal/logger"
+)
+
+// PKCS12Deployer writes the certificate, chain and key as a password-protected
+// PKCS#12 bundle for consumers that cannot use PEM files
+type PKCS12Deployer struct {
+	path     string
+	password string
+	encoder  *pkcs12.Encoder
+	logger   *logger.Logger
+}
+
+// NewPKCS12Deployer creates a new PKCS#12 deployer. The password is read from
+// IPSSL_PKCS12_PASSWORD_FILE when set, so it can come from a mounted secret
+func NewPKCS12Deployer(cfg config.PKCS12Config, logger *logger.Logger) (*PKCS12Deployer, error) {
+	password := cfg.Password
+	if cfg.PasswordFile != "" {
+		if password != "" {
+			return nil, fmt.Errorf("IPSSL_PKCS12_PASSWORD and IPSSL_PKCS12_PASSWORD_FILE are mutually exclusive")
+		}
+		data, err := os.ReadFile(cfg.PasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PKCS#12 password: %w", err)
+		}
+		password = strings.TrimRight(string(data), "\r\n")
+	}
+	if password == "" {
+		return nil, fmt.Errorf("IPSSL_PKCS12_PASSWORD or IPSSL_PKCS12_PASSWORD_FILE is required")
+	}
+
+	encoder := pkcs12.Modern
+	if cfg.Legacy {
+		encoder = pkcs12.LegacyDES
+	}
+	return &PKCS12Deployer{path: cfg.Path, password: password, encoder: encoder, logger: logger}, nil
+}
+
+// Name returns the deployer name
+func (d *PKCS12Deployer) Name() string {
+	return "pkcs12"
+}
+
+// Deploy encodes the bundle and writes it next to the certificate unless a path is configured
+func (d *PKCS12Deployer) Deploy(ctx context.Context, bundle *Bundle) error {
+	pfx, err := encodePFXWith(d.encoder, bundle, d.password)
+	if err != nil {
+		return err
+	}
+
+	path := d.path
+	if path == "" {
+		path = strings.TrimSuffix(bundle.CertPath, filepath.Ext(bundle.CertPath)) + ".pfx"
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	if err := fsys.WriteFileAtomic(fsys.OS, path, pfx, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	d.logger.Info("PKCS#12 bundle written", "path", path)
+	return nil
+}
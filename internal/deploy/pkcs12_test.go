@@ -0,0 +1,87 @@
+package deploy
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+func TestPKCS12DeployerWritesBundle(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "192.0.2.1"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(passwordFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewPKCS12Deployer(config.PKCS12Config{PasswordFile: passwordFile}, logger.New())
+	if err != nil {
+		t.Fatalf("Failed to create deployer: %v", err)
+	}
+
+	bundle := &Bundle{
+		CertPath: filepath.Join(dir, "cert.pem"),
+		Cert:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		Key:      pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+	}
+	if err := d.Deploy(context.Background(), bundle); err != nil {
+		t.Fatalf("Deploy failed: %v", err)
+	}
+
+	path := filepath.Join(dir, "cert.pfx")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Expected %s to be written: %v", path, err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, cert, _, err := pkcs12.DecodeChain(data, "secret")
+	if err != nil {
+		t.Fatalf("Failed to decode PKCS#12 bundle: %v", err)
+	}
+	if cert.Subject.CommonName != "192.0.2.1" {
+		t.Errorf("Expected leaf 192.0.2.1, got %s", cert.Subject.CommonName)
+	}
+}
+
+func TestPKCS12DeployerRequiresPassword(t *testing.T) {
+	if _, err := NewPKCS12Deployer(config.PKCS12Config{}, logger.New()); err == nil {
+		t.Error("Expected error without a password, got nil")
+	}
+}
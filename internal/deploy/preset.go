@@ -0,0 +1,165 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/fsys"
+	"ipssl-client/internal/logger"
+)
+
+// Contents a preset file can hold
+const (
+	contentFullChain = "fullchain"
+	contentKey       = "key"
+)
+
+// presetFile is a file written by a preset
+type presetFile struct {
+	content string
+	path    string
+	mode    os.FileMode
+	owner   string
+	group   string
+}
+
+// preset describes where a well-known server expects its certificate and how to reload it
+type preset struct {
+	files  []presetFile
+	reload []string
+	// env lists the client variables the reload command needs beyond IPSSL_HOOK_ENV
+	env []string
+}
+
+// presets maps deployer names to the layouts of well-known servers
+var presets = map[string]preset{
+	"postfix": {
+		files: []presetFile{
+			{content: contentFullChain, path: "/etc/postfix/ssl/ipssl-fullchain.pem", mode: 0644},
+			{content: contentKey, path: "/etc/postfix/ssl/ipssl-key.pem", mode: 0600},
+		},
+		reload: []string{"postfix", "reload"},
+	},
+	"dovecot": {
+		files: []presetFile{
+			{content: contentFullChain, path: "/etc/dovecot/private/dovecot.pem", mode: 0644},
+			{content: contentKey, path: "/etc/dovecot/private/dovecot.key", mode: 0600},
+		},
+		reload: []string{"doveadm", "reload"},
+	},
+	"exim": {
+		files: []presetFile{
+			{content: contentFullChain, path: "/etc/exim4/exim.crt", mode: 0640, group: "Debian-exim"},
+			{content: contentKey, path: "/etc/exim4/exim.key", mode: 0640, group: "Debian-exim"},
+		},
+		reload: []string{"systemctl", "reload", "exim4"},
+	},
+	"postgresql": {
+		files: []presetFile{
+			{content: contentFullChain, path: "/etc/postgresql/ssl/server.crt", mode: 0644, owner: "postgres"},
+			{content: contentKey, path: "/etc/postgresql/ssl/server.key", mode: 0600, owner: "postgres"},
+		},
+		reload: []string{"psql", "-U", "postgres", "-c", "SELECT pg_reload_conf()"},
+		env:    []string{"PG*"},
+	},
+	"mysql": {
+		files: []presetFile{
+			{content: contentFullChain, path: "/etc/mysql/ssl/server-cert.pem", mode: 0644, owner: "mysql"},
+			{content: contentKey, path: "/etc/mysql/ssl/server-key.pem", mode: 0600, owner: "mysql"},
+		},
+		reload: []string{"mysql", "-e", "ALTER INSTANCE RELOAD TLS"},
+		env:    []string{"MYSQL_*"},
+	},
+	"mariadb": {
+		files: []presetFile{
+			{content: contentFullChain, path: "/etc/mysql/ssl/server-cert.pem", mode: 0644, owner: "mysql"},
+			{content: contentKey, path: "/etc/mysql/ssl/server-key.pem", mode: 0600, owner: "mysql"},
+		},
+		reload: []string{"mysql", "-e", "FLUSH SSL"},
+		env:    []string{"MYSQL_*"},
+	},
+}
+
+// IsPreset reports whether name refers to a built-in preset
+func IsPreset(name string) bool {
+	_, ok := presets[name]
+	return ok
+}
+
+// PresetDeployer writes the certificate where a well-known server expects it and reloads the server
+type PresetDeployer struct {
+	name   string
+	preset preset
+	env    []string
+	logger *logger.Logger
+}
+
+// NewPresetDeployer creates a deployer for a built-in preset, applying configured overrides
+func NewPresetDeployer(name string, overrides config.PresetConfig, env []string, logger *logger.Logger) (*PresetDeployer, error) {
+	base, ok := presets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q", name)
+	}
+
+	p := preset{reload: base.reload}
+	for _, f := range base.files {
+		switch {
+		case f.content == contentFullChain && overrides.CertPath != "":
+			f.path = overrides.CertPath
+		case f.content == contentKey && overrides.KeyPath != "":
+			f.path = overrides.KeyPath
+		}
+		if overrides.Owner != "" {
+			f.owner = overrides.Owner
+		}
+		if overrides.Group != "" {
+			f.group = overrides.Group
+		}
+		p.files = append(p.files, f)
+	}
+	// Overrides run through the shell, so that they can quote arguments and
+	// test CERT_CHANGED; the built-in commands are executed directly
+	if overrides.ReloadCommand != "" {
+		p.reload = []string{"sh", "-c", overrides.ReloadCommand}
+	}
+
+	return &PresetDeployer{name: name, preset: p, env: slices.Concat(env, base.env), logger: logger}, nil
+}
+
+// Name returns the deployer name
+func (d *PresetDeployer) Name() string {
+	return d.name
+}
+
+// Deploy writes the preset files and reloads the service
+func (d *PresetDeployer) Deploy(ctx context.Context, bundle *Bundle) error {
+	for _, f := range d.preset.files {
+		data := bundle.Cert
+		if f.content == contentKey {
+			data = bundle.Key
+		}
+
+		// The server cannot read a key it does not own, so reloading it after
+		// the owner could not be set would break TLS
+		uid, gid, err := fsys.LookupOwner(f.owner, f.group)
+		if err != nil {
+			return fmt.Errorf("failed to set the owner of %s: %w", f.path, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", f.path, err)
+		}
+		if err := fsys.WriteFileAtomicOwned(fsys.OS, f.path, data, f.mode, uid, gid); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.path, err)
+		}
+		d.logger.Info("Preset file written", "preset", d.name, "path", f.path)
+	}
+
+	if len(d.preset.reload) == 0 {
+		return nil
+	}
+	return runCommand(ctx, d.logger, bundle, d.env, d.preset.reload[0], d.preset.reload[1:]...)
+}
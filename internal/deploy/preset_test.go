@@ -0,0 +1,117 @@
+package deploy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+func TestPresetDeployerReloadCommand(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewPresetDeployer("postgresql", config.PresetConfig{
+		CertPath: filepath.Join(dir, "server.crt"),
+		KeyPath:  filepath.Join(dir, "server.key"),
+		Owner:    strconv.Itoa(os.Getuid()),
+		// Quoting and && only work through the shell
+		ReloadCommand: `[ "$CERT_CHANGED" = true ] && printf '%s' "SELECT pg_reload_conf()" > "` + filepath.Join(dir, "reload out") + `"`,
+	}, nil, logger.New())
+	if err != nil {
+		t.Fatalf("NewPresetDeployer failed: %v", err)
+	}
+
+	bundle := newTestBundle(t)
+	bundle.Serial = "02"
+	bundle.PreviousSerial = "01"
+	if err := d.Deploy(context.Background(), bundle); err != nil {
+		t.Fatalf("Deploy failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "reload out")); err != nil || string(data) != "SELECT pg_reload_conf()" {
+		t.Errorf("Expected the override to run through the shell, got %q (%v)", data, err)
+	}
+}
+
+func TestPresetDeployerDefaultReload(t *testing.T) {
+	d, err := NewPresetDeployer("postgresql", config.PresetConfig{}, nil, logger.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"psql", "-U", "postgres", "-c", "SELECT pg_reload_conf()"}
+	if !slices.Equal(d.preset.reload, want) {
+		t.Errorf("Expected the built-in command %q to run directly, got %q", want, d.preset.reload)
+	}
+
+	d, err = NewPresetDeployer("mysql", config.PresetConfig{}, []string{"VAULT_*"}, logger.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"VAULT_*", "MYSQL_*"}; !slices.Equal(d.env, want) {
+		t.Errorf("Expected the reload to inherit %q, got %q", want, d.env)
+	}
+}
+
+func TestPresetDeployerFiles(t *testing.T) {
+	dir := t.TempDir()
+	uid, gid := os.Getuid(), os.Getgid()
+	d, err := NewPresetDeployer("exim", config.PresetConfig{
+		CertPath:      filepath.Join(dir, "exim", "exim.crt"),
+		KeyPath:       filepath.Join(dir, "exim", "exim.key"),
+		Owner:         strconv.Itoa(uid),
+		Group:         strconv.Itoa(gid),
+		ReloadCommand: "true",
+	}, nil, logger.New())
+	if err != nil {
+		t.Fatalf("NewPresetDeployer failed: %v", err)
+	}
+
+	bundle := newTestBundle(t)
+	if err := d.Deploy(context.Background(), bundle); err != nil {
+		t.Fatalf("Deploy failed: %v", err)
+	}
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{{"exim.crt", bundle.Cert}, {"exim.key", bundle.Key}} {
+		path := filepath.Join(dir, "exim", f.name)
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != string(f.data) {
+			t.Errorf("Expected %s to hold its content: %v", f.name, err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The exim preset shares both files with the Debian-exim group
+		if info.Mode().Perm() != 0640 {
+			t.Errorf("Expected mode 0640 for %s, got %v", f.name, info.Mode().Perm())
+		}
+		if fileUID, fileGID, ok := fileOwner(info); ok && (fileUID != uid || fileGID != gid) {
+			t.Errorf("Expected %s to be owned by %d:%d, got %d:%d", f.name, uid, gid, fileUID, fileGID)
+		}
+	}
+
+	// An unknown owner fails the deployment before the reload
+	d, err = NewPresetDeployer("exim", config.PresetConfig{
+		CertPath:      filepath.Join(dir, "exim.crt"),
+		KeyPath:       filepath.Join(dir, "exim.key"),
+		Owner:         "no-such-user-ipssl",
+		ReloadCommand: "touch " + filepath.Join(dir, "reloaded"),
+	}, nil, logger.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Deploy(context.Background(), bundle); err == nil {
+		t.Error("Expected an ownership failure to fail the deployment")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "reloaded")); err == nil {
+		t.Error("Expected no reload after an ownership failure")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "exim.key")); err == nil {
+		t.Error("Expected no key with the wrong owner")
+	}
+}
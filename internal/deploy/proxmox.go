@@ -0,0 +1,57 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+// ProxmoxDeployer installs the certificate as the custom certificate of a Proxmox VE node
+type ProxmoxDeployer struct {
+	cfg    config.ApplianceConfig
+	client *http.Client
+	logger *logger.Logger
+}
+
+// NewProxmoxDeployer creates a new Proxmox deployer
+func NewProxmoxDeployer(cfg config.ApplianceConfig, logger *logger.Logger) (*ProxmoxDeployer, error) {
+	if cfg.URL == "" || cfg.APIKey == "" || cfg.APISecret == "" || cfg.Node == "" {
+		return nil, fmt.Errorf("IPSSL_PROXMOX_URL, IPSSL_PROXMOX_API_KEY (token ID), IPSSL_PROXMOX_API_SECRET and IPSSL_PROXMOX_NODE are required")
+	}
+	return &ProxmoxDeployer{
+		cfg:    cfg,
+		client: newHTTPClient(cfg.InsecureSkipVerify, apiTimeout),
+		logger: logger,
+	}, nil
+}
+
+// Name returns the deployer name
+func (d *ProxmoxDeployer) Name() string {
+	return "proxmox"
+}
+
+// Deploy uploads the chain and key and lets pveproxy restart with them
+func (d *ProxmoxDeployer) Deploy(ctx context.Context, bundle *Bundle) error {
+	endpoint := fmt.Sprintf("%s/api2/json/nodes/%s/certificates/custom", strings.TrimSuffix(d.cfg.URL, "/"), url.PathEscape(d.cfg.Node))
+	payload := map[string]any{
+		"certificates": string(bundle.Cert),
+		"key":          string(bundle.Key),
+		"force":        1,
+		"restart":      1,
+	}
+
+	err := doJSON(ctx, d.client, http.MethodPost, endpoint, payload, nil, func(req *http.Request) {
+		req.Header.Set("Authorization", fmt.Sprintf("PVEAPIToken=%s=%s", d.cfg.APIKey, d.cfg.APISecret))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload custom certificate: %w", err)
+	}
+
+	d.logger.Info("Proxmox node certificate updated", "node", d.cfg.Node)
+	return nil
+}
@@ -0,0 +1,44 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+func TestProxmoxDeployer(t *testing.T) {
+	var uploaded map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api2/json/nodes/pve1/certificates/custom" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "PVEAPIToken=ipssl@pve!deploy=token-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&uploaded)
+		w.Write([]byte(`{"data":null}`))
+	}))
+	defer server.Close()
+
+	if _, err := NewProxmoxDeployer(config.ApplianceConfig{URL: server.URL, APIKey: "ipssl@pve!deploy", APISecret: "token-secret"}, logger.New()); err == nil {
+		t.Error("Expected an error without a node")
+	}
+	d, err := NewProxmoxDeployer(config.ApplianceConfig{URL: server.URL, APIKey: "ipssl@pve!deploy", APISecret: "token-secret", Node: "pve1"}, logger.New())
+	if err != nil {
+		t.Fatalf("NewProxmoxDeployer failed: %v", err)
+	}
+	bundle := newTestBundle(t)
+	if err := d.Deploy(context.Background(), bundle); err != nil {
+		t.Fatalf("Deploy failed: %v", err)
+	}
+	if uploaded["certificates"] != string(bundle.Cert) || uploaded["key"] != string(bundle.Key) || uploaded["force"] != float64(1) || uploaded["restart"] != float64(1) {
+		t.Errorf("Unexpected custom certificate payload %v", uploaded)
+	}
+}
@@ -0,0 +1,125 @@
+package deploy
+
+import (
+	"embed"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"ipssl-client/internal/chain"
+)
+
+//go:embed recipes/*.yaml
+var builtinRecipes embed.FS
+
+// Contents a recipe upload can hold
+const (
+	contentCert     = "cert"
+	contentChain    = "chain"
+	contentCombined = "combined"
+	contentDER      = "der"
+	contentP7B      = "p7b"
+)
+
+// Recipe describes how to install a certificate on a device over SSH
+type Recipe struct {
+	Name        string         `yaml:"name"`
+	Description string         `yaml:"description"`
+	Uploads     []RecipeUpload `yaml:"uploads"`
+	Commands    []string       `yaml:"commands"`
+}
+
+// RecipeUpload is a file written to the device. Path and commands are templates
+// with the same fields as other deployer templates (e.g. {{.IP}}).
+type RecipeUpload struct {
+	Path    string `yaml:"path"`
+	Content string `yaml:"content"`
+	Mode    string `yaml:"mode"`
+}
+
+// LoadRecipe loads a built-in recipe by name or a recipe file by path
+func LoadRecipe(nameOrPath string) (*Recipe, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if strings.ContainsAny(nameOrPath, `/\`) || strings.HasSuffix(nameOrPath, ".yaml") || strings.HasSuffix(nameOrPath, ".yml") {
+		data, err = os.ReadFile(nameOrPath)
+	} else {
+		data, err = builtinRecipes.ReadFile("recipes/" + nameOrPath + ".yaml")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recipe %s: %w", nameOrPath, err)
+	}
+
+	var recipe Recipe
+	if err := yaml.Unmarshal(data, &recipe); err != nil {
+		return nil, fmt.Errorf("failed to parse recipe %s: %w", nameOrPath, err)
+	}
+	if len(recipe.Uploads) == 0 && len(recipe.Commands) == 0 {
+		return nil, fmt.Errorf("recipe %s defines no uploads or commands", nameOrPath)
+	}
+	for _, upload := range recipe.Uploads {
+		if upload.Path == "" {
+			return nil, fmt.Errorf("recipe %s has an upload without a path", nameOrPath)
+		}
+		switch upload.Content {
+		case contentFullChain, contentCert, contentChain, contentKey, contentCombined, contentDER, contentP7B:
+		default:
+			return nil, fmt.Errorf("recipe %s: unknown upload content %q", nameOrPath, upload.Content)
+		}
+		if _, err := upload.fileMode(); err != nil {
+			return nil, fmt.Errorf("recipe %s: %w", nameOrPath, err)
+		}
+	}
+	return &recipe, nil
+}
+
+// fileMode parses the octal mode of an upload (default 0644, 0600 for keys)
+func (u RecipeUpload) fileMode() (os.FileMode, error) {
+	if u.Mode == "" {
+		if u.Content == contentKey || u.Content == contentCombined {
+			return 0600, nil
+		}
+		return 0644, nil
+	}
+	mode, err := strconv.ParseUint(u.Mode, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid mode %q for %s", u.Mode, u.Path)
+	}
+	return os.FileMode(mode), nil
+}
+
+// uploadContent selects the bytes of a bundle an upload refers to: PEM, or
+// the DER leaf or PKCS#7 chain for appliances that refuse PEM
+func uploadContent(content string, bundle *Bundle) ([]byte, error) {
+	leaf, intermediates := splitLeaf(bundle.Cert)
+	switch content {
+	case contentCert:
+		return leaf, nil
+	case contentChain:
+		return intermediates, nil
+	case contentKey:
+		return bundle.Key, nil
+	case contentCombined:
+		return append(append([]byte{}, bundle.Key...), bundle.Cert...), nil
+	case contentDER:
+		block, _ := pem.Decode(leaf)
+		if block == nil {
+			return nil, fmt.Errorf("failed to decode certificate PEM")
+		}
+		return block.Bytes, nil
+	case contentP7B:
+		certs, err := chain.Parse(bundle.Cert)
+		if err != nil {
+			return nil, err
+		}
+		return chain.EncodePKCS7(certs)
+	default:
+		return bundle.Cert, nil
+	}
+}
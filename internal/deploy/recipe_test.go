@@ -0,0 +1,32 @@
+package deploy
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinRecipesLoad(t *testing.T) {
+	entries, err := fs.ReadDir(builtinRecipes, "recipes")
+	if err != nil {
+		t.Fatalf("Failed to list built-in recipes: %v", err)
+	}
+
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".yaml")
+		recipe, err := LoadRecipe(name)
+		if err != nil {
+			t.Errorf("Failed to load recipe %s: %v", name, err)
+			continue
+		}
+		if recipe.Name != name {
+			t.Errorf("Expected recipe name %s, got %s", name, recipe.Name)
+		}
+	}
+}
+
+func TestLoadRecipeUnknown(t *testing.T) {
+	if _, err := LoadRecipe("does-not-exist"); err == nil {
+		t.Error("Expected error for unknown recipe, got nil")
+	}
+}
@@ -0,0 +1,269 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+	"ipssl-client/internal/state"
+)
+
+// sshTimeout bounds establishing an SSH connection
+const sshTimeout = 30 * time.Second
+
+// sshTarget is an SSH host paired with the recipe used to install the certificate
+type sshTarget struct {
+	cfg    config.SSHTarget
+	recipe *Recipe
+}
+
+// SSHDeployer installs certificates on devices over SSH following recipes
+type SSHDeployer struct {
+	targets   []sshTarget
+	auditPath string
+	logger    *logger.Logger
+}
+
+// NewSSHDeployer creates a new SSH recipe deployer. Every deployment is
+// recorded with the fingerprint of the host key in the audit log at auditPath.
+func NewSSHDeployer(targets []config.SSHTarget, auditPath string, logger *logger.Logger) (*SSHDeployer, error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("IPSSL_SSH_TARGETS is required")
+	}
+
+	d := &SSHDeployer{auditPath: auditPath, logger: logger}
+	for _, target := range targets {
+		if target.Host == "" || target.User == "" || target.Recipe == "" {
+			return nil, fmt.Errorf("SSH target %s requires HOST, USER and RECIPE", target.Name)
+		}
+		if len(target.HostKeyFingerprints) > 0 && target.InsecureIgnoreHostKey {
+			return nil, fmt.Errorf("SSH target %s: HOST_KEY_FINGERPRINT and INSECURE_IGNORE_HOST_KEY are mutually exclusive", target.Name)
+		}
+		for _, fingerprint := range target.HostKeyFingerprints {
+			if !strings.HasPrefix(fingerprint, "SHA256:") {
+				return nil, fmt.Errorf("invalid host key fingerprint %q for SSH target %s (expected SHA256:...)", fingerprint, target.Name)
+			}
+		}
+		recipe, err := LoadRecipe(target.Recipe)
+		if err != nil {
+			return nil, err
+		}
+		d.targets = append(d.targets, sshTarget{cfg: target, recipe: recipe})
+	}
+	return d, nil
+}
+
+// Name returns the deployer name
+func (d *SSHDeployer) Name() string {
+	return "ssh"
+}
+
+// Deploy runs the recipe of every target, reporting all failures
+func (d *SSHDeployer) Deploy(ctx context.Context, bundle *Bundle) error {
+	var errs []error
+	for _, target := range d.targets {
+		if err := d.deployTarget(ctx, target, bundle); err != nil {
+			errs = append(errs, fmt.Errorf("target %s: %w", target.cfg.Name, err))
+			continue
+		}
+		d.logger.Info("SSH recipe completed", "target", target.cfg.Name, "recipe", target.recipe.Name)
+	}
+	return errors.Join(errs...)
+}
+
+// deployTarget uploads the recipe files and runs its commands on one host
+func (d *SSHDeployer) deployTarget(ctx context.Context, target sshTarget, bundle *Bundle) (err error) {
+	client, hostKey, err := dialSSH(ctx, target.cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	d.logger.Info("Connected over SSH", "target", target.cfg.Name, "host_key", hostKey)
+	defer func() { d.audit(target, hostKey, bundle, err) }()
+
+	// Close the connection if the context is cancelled mid-recipe
+	stop := context.AfterFunc(ctx, func() { client.Close() })
+	defer stop()
+
+	for _, upload := range target.recipe.Uploads {
+		remotePath, err := renderTemplate("path", upload.Path, bundle)
+		if err != nil {
+			return fmt.Errorf("failed to render path %q: %w", upload.Path, err)
+		}
+		mode, _ := upload.fileMode()
+		data, err := uploadContent(upload.Content, bundle)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", remotePath, err)
+		}
+		if err := sshWriteFile(client, remotePath, data, mode); err != nil {
+			return fmt.Errorf("failed to upload %s: %w", remotePath, err)
+		}
+		d.logger.Info("Uploaded file over SSH", "target", target.cfg.Name, "path", remotePath)
+	}
+
+	for _, command := range target.recipe.Commands {
+		rendered, err := renderTemplate("command", command, bundle)
+		if err != nil {
+			return fmt.Errorf("failed to render command %q: %w", command, err)
+		}
+		output, err := sshRun(client, rendered, nil)
+		if err != nil {
+			return fmt.Errorf("command %q failed: %w (output: %s)", rendered, err, strings.TrimSpace(output))
+		}
+		d.logger.Info("Ran command over SSH", "target", target.cfg.Name, "command", rendered, "output", strings.TrimSpace(output))
+	}
+	return nil
+}
+
+// audit records a deployment to target in the audit log with the fingerprint
+// of the host key it presented and, if it failed, the error as the reason
+func (d *SSHDeployer) audit(target sshTarget, hostKey string, bundle *Bundle, deployErr error) {
+	event := state.AuditEvent{
+		Time:    time.Now(),
+		Action:  state.AuditSSHDeploy,
+		Target:  target.cfg.Name,
+		HostKey: hostKey,
+		CycleID: bundle.CycleID,
+	}
+	if deployErr != nil {
+		event.Reason = deployErr.Error()
+	}
+	if err := state.AppendAudit(d.auditPath, event); err != nil {
+		d.logger.Warn("Failed to record SSH deployment in audit log", "error", err, "target", target.cfg.Name)
+	}
+}
+
+// dialSSH connects to an SSH target, verifying its host key against the
+// pinned fingerprints or known_hosts, and returns the SHA256 fingerprint of
+// the accepted host key
+func dialSSH(ctx context.Context, cfg config.SSHTarget) (*ssh.Client, string, error) {
+	var auth []ssh.AuthMethod
+	if cfg.KeyFile != "" {
+		keyData, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read SSH key: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(keyData)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to parse SSH key: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if cfg.Password != "" {
+		auth = append(auth, ssh.Password(cfg.Password))
+	}
+	if len(auth) == 0 {
+		return nil, "", fmt.Errorf("no SSH key file or password configured")
+	}
+
+	verify, err := hostKeyCallback(cfg)
+	if err != nil {
+		return nil, "", err
+	}
+	var hostKey string
+	callback := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if err := verify(hostname, remote, key); err != nil {
+			return err
+		}
+		hostKey = ssh.FingerprintSHA256(key)
+		return nil
+	}
+
+	addr := cfg.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+
+	dialer := net.Dialer{Timeout: sshTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+		User:            cfg.User,
+		Auth:            auth,
+		HostKeyCallback: callback,
+		Timeout:         sshTimeout,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, "", fmt.Errorf("SSH handshake with %s failed: %w", addr, err)
+	}
+	return ssh.NewClient(sshConn, chans, reqs), hostKey, nil
+}
+
+// hostKeyCallback builds the host key verification for a target
+func hostKeyCallback(cfg config.SSHTarget) (ssh.HostKeyCallback, error) {
+	if cfg.InsecureIgnoreHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	if len(cfg.HostKeyFingerprints) > 0 {
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			fingerprint := ssh.FingerprintSHA256(key)
+			if !slices.Contains(cfg.HostKeyFingerprints, fingerprint) {
+				return fmt.Errorf("host key %s of %s does not match the pinned fingerprints", fingerprint, hostname)
+			}
+			return nil
+		}, nil
+	}
+
+	knownHostsPath := cfg.KnownHosts
+	if knownHostsPath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("no known_hosts file configured: %w", err)
+		}
+		knownHostsPath = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	callback, err := knownhosts.New(knownHostsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known_hosts %s: %w", knownHostsPath, err)
+	}
+	return callback, nil
+}
+
+// sshRun runs a command in a new session, feeding stdin if given
+func sshRun(client *ssh.Client, command string, stdin []byte) (string, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to open SSH session: %w", err)
+	}
+	defer session.Close()
+
+	if stdin != nil {
+		session.Stdin = bytes.NewReader(stdin)
+	}
+	output, err := session.CombinedOutput(command)
+	return string(output), err
+}
+
+// sshWriteFile writes a remote file atomically using only POSIX shell tools
+func sshWriteFile(client *ssh.Client, remotePath string, data []byte, mode os.FileMode) error {
+	tmp := remotePath + ".ipssl-tmp"
+	command := fmt.Sprintf("umask 077 && mkdir -p %s && cat > %s && chmod %o %s && mv -f %s %s",
+		shellQuote(path.Dir(remotePath)), shellQuote(tmp), mode.Perm(), shellQuote(tmp), shellQuote(tmp), shellQuote(remotePath))
+	output, err := sshRun(client, command, data)
+	if err != nil {
+		return fmt.Errorf("%w (output: %s)", err, strings.TrimSpace(output))
+	}
+	return nil
+}
+
+// shellQuote quotes a string for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,52 @@
+package deploy
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+func TestHostKeyCallbackPinned(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := &net.TCPAddr{IP: net.ParseIP("192.0.2.10"), Port: 22}
+
+	callback, err := hostKeyCallback(config.SSHTarget{HostKeyFingerprints: []string{"SHA256:other", ssh.FingerprintSHA256(key)}})
+	if err != nil {
+		t.Fatalf("hostKeyCallback failed: %v", err)
+	}
+	if err := callback("router:22", remote, key); err != nil {
+		t.Errorf("Expected the pinned host key to be accepted: %v", err)
+	}
+
+	callback, _ = hostKeyCallback(config.SSHTarget{HostKeyFingerprints: []string{"SHA256:other"}})
+	if err := callback("router:22", remote, key); err == nil || !strings.Contains(err.Error(), ssh.FingerprintSHA256(key)) {
+		t.Errorf("Expected a mismatch naming the presented key, got %v", err)
+	}
+}
+
+func TestNewSSHDeployerRejectsInvalidPins(t *testing.T) {
+	target := config.SSHTarget{Name: "router", Host: "router", User: "root", Recipe: "openwrt", HostKeyFingerprints: []string{"ab:cd"}}
+	if _, err := NewSSHDeployer([]config.SSHTarget{target}, "", logger.New()); err == nil {
+		t.Error("Expected a fingerprint without SHA256: prefix to be rejected")
+	}
+
+	target.HostKeyFingerprints = []string{"SHA256:abc"}
+	target.InsecureIgnoreHostKey = true
+	if _, err := NewSSHDeployer([]config.SSHTarget{target}, "", logger.New()); err == nil {
+		t.Error("Expected pinning and ignoring the host key to be mutually exclusive")
+	}
+}
@@ -0,0 +1,62 @@
+package deploy
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"text/template"
+)
+
+// templateFuncs are available in request body templates
+var templateFuncs = template.FuncMap{
+	"json": func(s string) (string, error) {
+		b, err := json.Marshal(s)
+		return string(b), err
+	},
+	"base64": func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	},
+}
+
+// templateData is the data exposed to request templates
+type templateData struct {
+	IP       string
+	CertPath string
+	KeyPath  string
+	Cert     string
+	Key      string
+	CycleID  string
+
+	Serial         string
+	PreviousSerial string
+	Changed        bool
+}
+
+// newTemplateData builds template data from a bundle
+func newTemplateData(bundle *Bundle) templateData {
+	return templateData{
+		IP:       bundle.IP,
+		CertPath: bundle.CertPath,
+		KeyPath:  bundle.KeyPath,
+		Cert:     string(bundle.Cert),
+		Key:      string(bundle.Key),
+		CycleID:  bundle.CycleID,
+
+		Serial:         bundle.Serial,
+		PreviousSerial: bundle.PreviousSerial,
+		Changed:        bundle.Changed(),
+	}
+}
+
+// renderTemplate renders a template string against a bundle
+func renderTemplate(name, text string, bundle *Bundle) (string, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, newTemplateData(bundle)); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
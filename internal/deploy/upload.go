@@ -0,0 +1,226 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+// uploadTimeout bounds connecting to and talking with an upload target
+const uploadTimeout = 60 * time.Second
+
+// contentPFX is a password-protected PKCS#12 archive of the chain and key,
+// which upload target files can hold in addition to the recipe contents
+const contentPFX = "pfx"
+
+// UploadDeployer pushes the certificate files to FTP, FTPS or WebDAV servers,
+// for appliances that only accept certificates as file uploads
+type UploadDeployer struct {
+	targets []config.UploadTarget
+	logger  *logger.Logger
+}
+
+// NewUploadDeployer creates a new upload deployer
+func NewUploadDeployer(targets []config.UploadTarget, logger *logger.Logger) (*UploadDeployer, error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("IPSSL_UPLOAD_TARGETS is required")
+	}
+	for _, target := range targets {
+		u, err := url.Parse(target.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL for upload target %s: %w", target.Name, err)
+		}
+		switch u.Scheme {
+		case "ftps", "https", "davs":
+		case "ftp", "http", "dav":
+			logger.Warn("Upload target does not use TLS, the private key is sent in clear text", "target", target.Name, "scheme", u.Scheme)
+		default:
+			return nil, fmt.Errorf("unsupported scheme %q for upload target %s", u.Scheme, target.Name)
+		}
+		for _, file := range target.Files {
+			switch file.Content {
+			case contentFullChain, contentCert, contentChain, contentKey, contentCombined, contentDER, contentP7B:
+			case contentPFX:
+				if target.PFXPassword == "" {
+					return nil, fmt.Errorf("upload target %s requires IPSSL_UPLOAD_%s_PFX_PASSWORD for %s", target.Name, strings.ToUpper(target.Name), file.Path)
+				}
+			default:
+				return nil, fmt.Errorf("unknown content %q for %s of upload target %s", file.Content, file.Path, target.Name)
+			}
+		}
+	}
+	return &UploadDeployer{targets: targets, logger: logger}, nil
+}
+
+// Name returns the deployer name
+func (d *UploadDeployer) Name() string {
+	return "upload"
+}
+
+// Deploy uploads the certificate and key to every target, reporting all failures
+func (d *UploadDeployer) Deploy(ctx context.Context, bundle *Bundle) error {
+	var errs []error
+	for _, target := range d.targets {
+		if err := d.upload(ctx, target, bundle); err != nil {
+			errs = append(errs, fmt.Errorf("target %s: %w", target.Name, err))
+			continue
+		}
+		d.logger.Info("Certificate uploaded", "target", target.Name)
+	}
+	return errors.Join(errs...)
+}
+
+// uploadFile is a rendered file of an upload target
+type uploadFile struct {
+	path string
+	data []byte
+}
+
+// upload renders the destination paths of a target and uploads its files in
+// the configured order
+func (d *UploadDeployer) upload(ctx context.Context, target config.UploadTarget, bundle *Bundle) error {
+	u, err := url.Parse(target.URL)
+	if err != nil {
+		return err
+	}
+
+	var files []uploadFile
+	seen := make(map[string]string)
+	for _, file := range targetFiles(target) {
+		if file.Path == "" {
+			continue
+		}
+		rendered, err := renderTemplate("path", file.Path, bundle)
+		if err != nil {
+			return fmt.Errorf("failed to render path template %q: %w", file.Path, err)
+		}
+		var data []byte
+		if file.Content == contentPFX {
+			data, err = encodePFX(bundle, target.PFXPassword)
+		} else {
+			data, err = uploadContent(file.Content, bundle)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", rendered, err)
+		}
+		remotePath := path.Join("/", u.Path, rendered)
+		if other, ok := seen[remotePath]; ok {
+			return fmt.Errorf("path templates %q and %q both render to %s", other, file.Path, remotePath)
+		}
+		seen[remotePath] = file.Path
+		files = append(files, uploadFile{path: remotePath, data: data})
+	}
+
+	switch u.Scheme {
+	case "ftp", "ftps":
+		return d.uploadFTP(ctx, u, target, files)
+	default:
+		return d.uploadWebDAV(ctx, u, target, files)
+	}
+}
+
+// targetFiles returns the files of a target: its Files, or the full chain at
+// CertPath and the key at KeyPath
+func targetFiles(target config.UploadTarget) []config.UploadFile {
+	if len(target.Files) > 0 {
+		return target.Files
+	}
+	return []config.UploadFile{
+		{Path: target.CertPath, Content: contentFullChain},
+		{Path: target.KeyPath, Content: contentKey},
+	}
+}
+
+// uploadFTP stores files over FTP, using explicit TLS for ftps:// URLs
+func (d *UploadDeployer) uploadFTP(ctx context.Context, u *url.URL, target config.UploadTarget, files []uploadFile) error {
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "21")
+	}
+
+	options := []ftp.DialOption{ftp.DialWithContext(ctx), ftp.DialWithTimeout(uploadTimeout)}
+	if u.Scheme == "ftps" {
+		options = append(options, ftp.DialWithExplicitTLS(&tls.Config{
+			ServerName:         u.Hostname(),
+			InsecureSkipVerify: target.InsecureSkipVerify,
+		}))
+	}
+
+	conn, err := ftp.Dial(addr, options...)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	defer conn.Quit()
+
+	if err := conn.Login(target.Username, target.Password); err != nil {
+		return fmt.Errorf("failed to log in to %s: %w", addr, err)
+	}
+
+	for _, file := range files {
+		remotePath := file.path
+		// Create parent directories on a best-effort basis; most servers reject existing ones
+		dir := path.Dir(remotePath)
+		for i := 1; i < len(dir); i++ {
+			if dir[i] == '/' {
+				_ = conn.MakeDir(dir[:i])
+			}
+		}
+		_ = conn.MakeDir(dir)
+
+		if err := conn.Stor(remotePath, bytes.NewReader(file.data)); err != nil {
+			return fmt.Errorf("failed to store %s: %w", remotePath, err)
+		}
+	}
+	return nil
+}
+
+// uploadWebDAV stores files with HTTP PUT; dav:// and davs:// map to http and https
+func (d *UploadDeployer) uploadWebDAV(ctx context.Context, u *url.URL, target config.UploadTarget, files []uploadFile) error {
+	base := *u
+	switch base.Scheme {
+	case "dav":
+		base.Scheme = "http"
+	case "davs":
+		base.Scheme = "https"
+	}
+
+	client := newHTTPClient(target.InsecureSkipVerify, uploadTimeout)
+
+	for _, file := range files {
+		dest := base
+		dest.Path = file.path
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, dest.String(), bytes.NewReader(file.data))
+		if err != nil {
+			return err
+		}
+		if target.Username != "" {
+			req.SetBasicAuth(target.Username, target.Password)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("PUT %s failed: %w", dest.Redacted(), err)
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("PUT %s returned HTTP %d: %s", dest.Redacted(), resp.StatusCode, strings.TrimSpace(string(body)))
+		}
+	}
+	return nil
+}
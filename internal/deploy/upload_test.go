@@ -0,0 +1,127 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+// newTestBundle returns a bundle with a self-signed certificate and its key
+func newTestBundle(t *testing.T) *Bundle {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "192.0.2.1"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Bundle{
+		IP:   "192.0.2.1",
+		Cert: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		Key:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func TestUploadDeployerTargetFiles(t *testing.T) {
+	var mu sync.Mutex
+	uploaded := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		uploaded[r.URL.Path] = body
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	bundle := newTestBundle(t)
+	d, err := NewUploadDeployer([]config.UploadTarget{{
+		Name:        "nas",
+		URL:         server.URL + "/certs",
+		PFXPassword: "secret",
+		Files: []config.UploadFile{
+			{Path: "{{.IP}}.pfx", Content: contentPFX},
+			{Path: "combined.pem", Content: contentCombined},
+			{Path: "{{.IP}}.der", Content: contentDER},
+		},
+	}}, logger.New())
+	if err != nil {
+		t.Fatalf("NewUploadDeployer failed: %v", err)
+	}
+	if err := d.Deploy(context.Background(), bundle); err != nil {
+		t.Fatalf("Deploy failed: %v", err)
+	}
+
+	if len(uploaded) != 3 {
+		t.Fatalf("Expected only the configured files, got %d uploads", len(uploaded))
+	}
+	if _, cert, err := pkcs12.Decode(uploaded["/certs/192.0.2.1.pfx"], "secret"); err != nil || cert == nil {
+		t.Errorf("Expected a PKCS#12 archive at /certs/192.0.2.1.pfx: %v", err)
+	}
+	if combined := uploaded["/certs/combined.pem"]; !bytes.Contains(combined, bundle.Key) || !bytes.Contains(combined, bundle.Cert) {
+		t.Errorf("Expected the key and chain in /certs/combined.pem, got %q", combined)
+	}
+	if _, err := x509.ParseCertificate(uploaded["/certs/192.0.2.1.der"]); err != nil {
+		t.Errorf("Expected a DER certificate at /certs/192.0.2.1.der: %v", err)
+	}
+}
+
+func TestUploadDeployerRequiresPFXPassword(t *testing.T) {
+	_, err := NewUploadDeployer([]config.UploadTarget{{
+		Name:  "nas",
+		URL:   "davs://nas.local/certs",
+		Files: []config.UploadFile{{Path: "cert.pfx", Content: contentPFX}},
+	}}, logger.New())
+	if err == nil || !strings.Contains(err.Error(), "PFX_PASSWORD") {
+		t.Errorf("Expected the missing PFX password to be reported, got %v", err)
+	}
+}
+
+func TestUploadDeployerRejectsDuplicatePaths(t *testing.T) {
+	d, err := NewUploadDeployer([]config.UploadTarget{{
+		Name: "nas",
+		URL:  "davs://nas.local/certs",
+		Files: []config.UploadFile{
+			{Path: "{{.IP}}.pem", Content: contentFullChain},
+			{Path: "192.0.2.1.pem", Content: contentKey},
+		},
+	}}, logger.New())
+	if err != nil {
+		t.Fatalf("NewUploadDeployer failed: %v", err)
+	}
+	// The duplicate is found while rendering, before connecting to the target
+	err = d.Deploy(context.Background(), newTestBundle(t))
+	if err == nil || !strings.Contains(err.Error(), "both render to /certs/192.0.2.1.pem") {
+		t.Errorf("Expected the duplicate path to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+// VaultDeployer writes the certificate chain and key into a HashiCorp Vault
+// KV secrets engine, from where other services read them with their own policies
+type VaultDeployer struct {
+	cfg    config.VaultConfig
+	client *http.Client
+	logger *logger.Logger
+}
+
+// NewVaultDeployer creates a new Vault deployer
+func NewVaultDeployer(cfg config.VaultConfig, logger *logger.Logger) (*VaultDeployer, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("IPSSL_VAULT_URL is required")
+	}
+	if cfg.KVVersion != 1 && cfg.KVVersion != 2 {
+		return nil, fmt.Errorf("invalid IPSSL_VAULT_KV_VERSION %d (expected 1 or 2)", cfg.KVVersion)
+	}
+	if _, err := renderTemplate("path", cfg.Path, &Bundle{}); err != nil {
+		return nil, fmt.Errorf("invalid IPSSL_VAULT_PATH: %w", err)
+	}
+
+	tokens := 0
+	for _, set := range []bool{cfg.Token != "", cfg.TokenFile != "", cfg.RoleID != ""} {
+		if set {
+			tokens++
+		}
+	}
+	if tokens != 1 {
+		return nil, fmt.Errorf("exactly one of IPSSL_VAULT_TOKEN, IPSSL_VAULT_TOKEN_FILE or IPSSL_VAULT_ROLE_ID is required")
+	}
+	if cfg.RoleID != "" && (cfg.SecretID == "") == (cfg.SecretIDFile == "") {
+		return nil, fmt.Errorf("AppRole login requires one of IPSSL_VAULT_SECRET_ID or IPSSL_VAULT_SECRET_ID_FILE")
+	}
+
+	return &VaultDeployer{
+		cfg:    cfg,
+		client: newHTTPClient(cfg.InsecureSkipVerify, apiTimeout),
+		logger: logger,
+	}, nil
+}
+
+// Name returns the deployer name
+func (d *VaultDeployer) Name() string {
+	return "vault"
+}
+
+// Deploy writes the chain and key as a new version of the secret
+func (d *VaultDeployer) Deploy(ctx context.Context, bundle *Bundle) error {
+	secretPath, err := renderTemplate("path", d.cfg.Path, bundle)
+	if err != nil {
+		return fmt.Errorf("failed to render IPSSL_VAULT_PATH: %w", err)
+	}
+	secretPath = strings.Trim(secretPath, "/")
+
+	token, err := d.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]any{
+		"certificate": string(bundle.Cert),
+		"private_key": string(bundle.Key),
+		"ip":          bundle.IP,
+	}
+	var payload any = data
+	endpoint := d.endpoint(d.cfg.Mount, secretPath)
+	if d.cfg.KVVersion == 2 {
+		payload = map[string]any{"data": data}
+		endpoint = d.endpoint(d.cfg.Mount, "data", secretPath)
+	}
+
+	if err := doJSON(ctx, d.client, http.MethodPost, endpoint, payload, nil, d.authorize(token)); err != nil {
+		return fmt.Errorf("failed to write certificate to Vault: %w", err)
+	}
+	d.logger.Info("Certificate written to Vault", "mount", d.cfg.Mount, "path", secretPath)
+	return nil
+}
+
+// token returns the Vault token: the configured one, the content of the token
+// file (re-read on every deploy, as agents rotate it) or a fresh AppRole login
+func (d *VaultDeployer) token(ctx context.Context) (string, error) {
+	switch {
+	case d.cfg.Token != "":
+		return d.cfg.Token, nil
+	case d.cfg.TokenFile != "":
+		return readSecretFile(d.cfg.TokenFile, "Vault token")
+	}
+
+	secretID := d.cfg.SecretID
+	if d.cfg.SecretIDFile != "" {
+		var err error
+		if secretID, err = readSecretFile(d.cfg.SecretIDFile, "AppRole secret ID"); err != nil {
+			return "", err
+		}
+	}
+	var login struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	payload := map[string]string{"role_id": d.cfg.RoleID, "secret_id": secretID}
+	endpoint := d.endpoint("auth", d.cfg.AppRoleMount, "login")
+	if err := doJSON(ctx, d.client, http.MethodPost, endpoint, payload, &login, d.authorize("")); err != nil {
+		return "", fmt.Errorf("failed to log in to Vault with AppRole: %w", err)
+	}
+	if login.Auth.ClientToken == "" {
+		return "", fmt.Errorf("AppRole login to Vault returned no token")
+	}
+	return login.Auth.ClientToken, nil
+}
+
+// endpoint returns the URL of an API path below /v1
+func (d *VaultDeployer) endpoint(parts ...string) string {
+	for i, part := range parts {
+		parts[i] = strings.Trim(part, "/")
+	}
+	return strings.TrimSuffix(d.cfg.URL, "/") + "/v1/" + strings.Join(parts, "/")
+}
+
+// authorize returns an authorizer setting the token and namespace headers
+func (d *VaultDeployer) authorize(token string) func(*http.Request) {
+	return func(req *http.Request) {
+		if token != "" {
+			req.Header.Set("X-Vault-Token", token)
+		}
+		if d.cfg.Namespace != "" {
+			req.Header.Set("X-Vault-Namespace", d.cfg.Namespace)
+		}
+	}
+}
+
+// readSecretFile reads a secret from a file such as a mounted Docker secret
+func readSecretFile(path, what string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", what, err)
+	}
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return "", fmt.Errorf("%s file %s is empty", what, path)
+	}
+	return secret, nil
+}
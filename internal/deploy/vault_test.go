@@ -0,0 +1,71 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+func TestVaultDeployerAppRole(t *testing.T) {
+	var written map[string]map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/approle/login":
+			var login map[string]string
+			json.NewDecoder(r.Body).Decode(&login)
+			if login["role_id"] != "role" || login["secret_id"] != "secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"auth":{"client_token":"s.token"}}`))
+		case "/v1/kv/data/ipssl/192.0.2.1":
+			if r.Header.Get("X-Vault-Token") != "s.token" || r.Header.Get("X-Vault-Namespace") != "team" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			json.NewDecoder(r.Body).Decode(&written)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	d, err := NewVaultDeployer(config.VaultConfig{
+		URL:          server.URL,
+		Namespace:    "team",
+		Mount:        "kv",
+		Path:         "ipssl/{{.IP}}",
+		KVVersion:    2,
+		AppRoleMount: "approle",
+		RoleID:       "role",
+		SecretID:     "secret",
+	}, logger.New())
+	if err != nil {
+		t.Fatalf("NewVaultDeployer failed: %v", err)
+	}
+	bundle := newTestBundle(t)
+	if err := d.Deploy(context.Background(), bundle); err != nil {
+		t.Fatalf("Deploy failed: %v", err)
+	}
+	if written["data"]["certificate"] != string(bundle.Cert) || written["data"]["private_key"] != string(bundle.Key) {
+		t.Errorf("Expected the chain and key in the KV v2 data, got %v", written)
+	}
+}
+
+func TestNewVaultDeployerRequiresOneAuthMethod(t *testing.T) {
+	cfg := config.VaultConfig{URL: "https://vault.local", Path: "ipssl", KVVersion: 2, Token: "s.token", RoleID: "role", SecretID: "secret"}
+	if _, err := NewVaultDeployer(cfg, logger.New()); err == nil {
+		t.Error("Expected a token and AppRole to be mutually exclusive")
+	}
+	cfg.Token = ""
+	cfg.SecretID = ""
+	if _, err := NewVaultDeployer(cfg, logger.New()); err == nil {
+		t.Error("Expected AppRole without a secret ID to be rejected")
+	}
+}
@@ -0,0 +1,70 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+// VMwareDeployer replaces the machine SSL certificate of a vCenter Server (7.0 U2 or later)
+type VMwareDeployer struct {
+	cfg    config.ApplianceConfig
+	client *http.Client
+	logger *logger.Logger
+}
+
+// NewVMwareDeployer creates a new vCenter deployer
+func NewVMwareDeployer(cfg config.ApplianceConfig, logger *logger.Logger) (*VMwareDeployer, error) {
+	if cfg.URL == "" || cfg.Username == "" {
+		return nil, fmt.Errorf("IPSSL_VMWARE_URL and IPSSL_VMWARE_USERNAME are required")
+	}
+	return &VMwareDeployer{
+		cfg:    cfg,
+		client: newHTTPClient(cfg.InsecureSkipVerify, apiTimeout),
+		logger: logger,
+	}, nil
+}
+
+// Name returns the deployer name
+func (d *VMwareDeployer) Name() string {
+	return "vmware"
+}
+
+// Deploy opens an API session and replaces the vCenter TLS certificate
+func (d *VMwareDeployer) Deploy(ctx context.Context, bundle *Bundle) error {
+	_, chain, _, err := parseBundle(bundle)
+	if err != nil {
+		return err
+	}
+	leafPEM, chainPEM := splitLeaf(bundle.Cert)
+
+	base := strings.TrimSuffix(d.cfg.URL, "/")
+	var session string
+	if err := doJSON(ctx, d.client, http.MethodPost, base+"/api/session", nil, &session, basicAuth(d.cfg.Username, d.cfg.Password)); err != nil {
+		return fmt.Errorf("failed to create vCenter session: %w", err)
+	}
+	withSession := func(req *http.Request) {
+		req.Header.Set("vmware-api-session-id", session)
+	}
+	defer func() {
+		_ = doJSON(context.WithoutCancel(ctx), d.client, http.MethodDelete, base+"/api/session", nil, nil, withSession)
+	}()
+
+	payload := map[string]string{
+		"cert": string(leafPEM),
+		"key":  string(bundle.Key),
+	}
+	if len(chain) > 0 {
+		payload["root_cert"] = string(chainPEM)
+	}
+	if err := doJSON(ctx, d.client, http.MethodPut, base+"/api/vcenter/certificate-management/vcenter/tls", payload, nil, withSession); err != nil {
+		return fmt.Errorf("failed to replace vCenter certificate: %w", err)
+	}
+
+	d.logger.Info("vCenter certificate replaced; services restart automatically", "url", base)
+	return nil
+}
@@ -0,0 +1,63 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+)
+
+func TestVMwareDeployer(t *testing.T) {
+	var calls []string
+	var replaced map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		if r.URL.Path == "/api/session" && r.Method == http.MethodPost {
+			if user, pass, ok := r.BasicAuth(); !ok || user != "administrator@vsphere.local" || pass != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`"session-1"`))
+			return
+		}
+		if r.Header.Get("vmware-api-session-id") != "session-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "PUT /api/vcenter/certificate-management/vcenter/tls":
+			json.NewDecoder(r.Body).Decode(&replaced)
+			w.WriteHeader(http.StatusNoContent)
+		case "DELETE /api/session":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	d, err := NewVMwareDeployer(config.ApplianceConfig{URL: server.URL, Username: "administrator@vsphere.local", Password: "secret"}, logger.New())
+	if err != nil {
+		t.Fatalf("NewVMwareDeployer failed: %v", err)
+	}
+	bundle := newTestBundle(t)
+	if err := d.Deploy(context.Background(), bundle); err != nil {
+		t.Fatalf("Deploy failed: %v", err)
+	}
+
+	if replaced["cert"] != string(bundle.Cert) || replaced["key"] != string(bundle.Key) {
+		t.Errorf("Expected the leaf and key to be sent, got %v", replaced)
+	}
+	if _, ok := replaced["root_cert"]; ok {
+		t.Error("Expected no root_cert for a certificate without a chain")
+	}
+	want := []string{"POST /api/session", "PUT /api/vcenter/certificate-management/vcenter/tls", "DELETE /api/session"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("Expected the session to be closed after the replacement, got %v", calls)
+	}
+}
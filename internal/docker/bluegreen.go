@@ -0,0 +1,264 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+// Labels set on containers managed by the blue-green strategy
+const (
+	labelRole       = "ipssl.bluegreen.role"
+	labelDeployedAt = "ipssl.bluegreen.deployed-at"
+)
+
+// settlePeriod is how long a container without a health check must stay running to count as healthy
+const settlePeriod = 5 * time.Second
+
+// BlueGreenDeploy replaces a container with a fresh one created from the same image and
+// configuration. A candidate without published ports is started first and health-checked;
+// only then is the replacement created with the original's ports and labels. Host ports can
+// only be bound by one container at a time, so the original keeps serving until the
+// replacement is ready to start and is stopped right before it, and it is removed once the
+// replacement is healthy or restored if the replacement fails.
+func (c *Client) BlueGreenDeploy(ctx context.Context, containerName string, healthTimeout time.Duration) error {
+	c.logger.Info("Starting blue-green deployment", "container", containerName)
+
+	target, err := c.resolveContainer(ctx, containerName)
+	if err != nil {
+		return err
+	}
+	c.invalidate(containerName)
+
+	inspectCtx, cancel := c.withTimeout(ctx, 0)
+	defer cancel()
+	current, err := c.client.ContainerInspect(inspectCtx, target.ID)
+	if err != nil {
+		return c.wrapAPIError(inspectCtx, endpointInspect, fmt.Sprintf("failed to inspect container %s", containerName), err)
+	}
+
+	// Stage 1: health-check a candidate that does not publish any ports
+	candidateName := containerName + "-ipssl-green"
+	c.removeIfExists(ctx, candidateName)
+
+	candidateID, err := c.createFrom(ctx, current, candidateName, "candidate", false)
+	if err != nil {
+		return fmt.Errorf("failed to create candidate container: %w", err)
+	}
+	if err := c.startAndWait(ctx, candidateID, healthTimeout); err != nil {
+		c.removeContainer(ctx, candidateID)
+		return fmt.Errorf("candidate container %s failed health check, keeping %s: %w", candidateName, containerName, err)
+	}
+	c.removeContainer(ctx, candidateID)
+	c.logger.Info("Candidate container is healthy, switching over", "container", containerName)
+
+	// Stage 2: move the original aside and create the replacement with its published ports
+	previousName := containerName + "-ipssl-blue"
+	c.removeIfExists(ctx, previousName)
+
+	renameCtx, cancelRename := c.withTimeout(ctx, 0)
+	defer cancelRename()
+	if err := c.client.ContainerRename(renameCtx, current.ID, previousName); err != nil {
+		return c.wrapAPIError(renameCtx, endpointRename, fmt.Sprintf("failed to rename container %s", containerName), err)
+	}
+
+	replacementID, err := c.createFrom(ctx, current, containerName, "active", true)
+	if err != nil {
+		c.restorePrevious(ctx, current.ID, containerName, false)
+		return fmt.Errorf("failed to create replacement container, kept previous container: %w", err)
+	}
+
+	// Stage 3: hand the published ports over from the original to the replacement
+	timeout := restartStopTimeout
+	stopCtx, cancelStop := c.withTimeout(ctx, restartStopTimeout*time.Second)
+	defer cancelStop()
+	if err := c.client.ContainerStop(stopCtx, current.ID, container.StopOptions{Timeout: &timeout}); err != nil {
+		c.removeContainer(context.WithoutCancel(ctx), replacementID)
+		c.restorePrevious(ctx, current.ID, containerName, true)
+		return c.wrapAPIError(stopCtx, endpointStop, fmt.Sprintf("failed to stop container %s", containerName), err)
+	}
+	if err := c.startAndWait(ctx, replacementID, healthTimeout); err != nil {
+		c.removeContainer(context.WithoutCancel(ctx), replacementID)
+		c.restorePrevious(ctx, current.ID, containerName, true)
+		return fmt.Errorf("replacement container failed, restored previous container: %w", err)
+	}
+
+	c.removeContainer(ctx, current.ID)
+	c.logger.Info("Blue-green deployment completed", "container", containerName, "container_id", replacementID)
+	return nil
+}
+
+// createFrom creates a container that mirrors the configuration of an existing one
+func (c *Client) createFrom(ctx context.Context, source types.ContainerJSON, name, role string, publishPorts bool) (string, error) {
+	cfg := *source.Config
+	if strings.HasPrefix(source.ID, cfg.Hostname) {
+		// Docker defaulted the hostname to the container ID; let the new container get its own
+		cfg.Hostname = ""
+	}
+	cfg.Labels = make(map[string]string, len(source.Config.Labels)+2)
+	for k, v := range source.Config.Labels {
+		cfg.Labels[k] = v
+	}
+	cfg.Labels[labelRole] = role
+	cfg.Labels[labelDeployedAt] = time.Now().UTC().Format(time.RFC3339)
+
+	hostCfg := *source.HostConfig
+	if !publishPorts {
+		hostCfg.PortBindings = nil
+		hostCfg.PublishAllPorts = false
+		hostCfg.RestartPolicy = container.RestartPolicy{}
+	}
+
+	primary := string(hostCfg.NetworkMode)
+	var networking *network.NetworkingConfig
+	extra := make(map[string]*network.EndpointSettings)
+	if source.NetworkSettings != nil {
+		for netName, endpoint := range source.NetworkSettings.Networks {
+			settings := endpointFrom(endpoint, source.ID, publishPorts)
+			if netName == primary {
+				networking = &network.NetworkingConfig{
+					EndpointsConfig: map[string]*network.EndpointSettings{netName: settings},
+				}
+			} else {
+				extra[netName] = settings
+			}
+		}
+	}
+
+	createCtx, cancel := c.withTimeout(ctx, 0)
+	defer cancel()
+	created, err := c.client.ContainerCreate(createCtx, &cfg, &hostCfg, networking, nil, name)
+	if err != nil {
+		return "", c.wrapAPIError(createCtx, endpointCreate, fmt.Sprintf("failed to create container %s", name), err)
+	}
+
+	// Older API versions only accept a single network at creation time
+	for netName, settings := range extra {
+		connectCtx, cancelConnect := c.withTimeout(ctx, 0)
+		err := c.client.NetworkConnect(connectCtx, netName, created.ID, settings)
+		cancelConnect()
+		if err != nil {
+			c.removeContainer(ctx, created.ID)
+			return "", c.wrapAPIError(connectCtx, endpointConnect, fmt.Sprintf("failed to connect container %s to network %s", name, netName), err)
+		}
+	}
+
+	return created.ID, nil
+}
+
+// endpointFrom copies the user-specified parts of a network endpoint. Candidates get
+// no aliases or static addresses so that they neither receive traffic nor conflict.
+func endpointFrom(endpoint *network.EndpointSettings, sourceID string, active bool) *network.EndpointSettings {
+	settings := &network.EndpointSettings{}
+	if endpoint == nil || !active {
+		return settings
+	}
+	settings.Links = endpoint.Links
+	settings.IPAMConfig = endpoint.IPAMConfig
+	for _, alias := range endpoint.Aliases {
+		if !strings.HasPrefix(sourceID, alias) {
+			settings.Aliases = append(settings.Aliases, alias)
+		}
+	}
+	return settings
+}
+
+// startAndWait starts a container and waits until it reports healthy
+func (c *Client) startAndWait(ctx context.Context, containerID string, healthTimeout time.Duration) error {
+	startCtx, cancel := c.withTimeout(ctx, 0)
+	defer cancel()
+	if err := c.client.ContainerStart(startCtx, containerID, container.StartOptions{}); err != nil {
+		return c.wrapAPIError(startCtx, endpointStart, "failed to start container", err)
+	}
+
+	waitCtx, cancelWait := context.WithTimeout(ctx, healthTimeout)
+	defer cancelWait()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	runningSince := time.Time{}
+	for {
+		select {
+		case <-waitCtx.Done():
+			return fmt.Errorf("container did not become healthy within %s", healthTimeout)
+		case <-ticker.C:
+		}
+
+		details, err := c.client.ContainerInspect(waitCtx, containerID)
+		if err != nil {
+			continue
+		}
+		state := details.State
+		if state == nil {
+			continue
+		}
+		if !state.Running {
+			return fmt.Errorf("container exited (status: %s, exit code: %d)", state.Status, state.ExitCode)
+		}
+
+		if state.Health != nil {
+			switch state.Health.Status {
+			case types.Healthy:
+				return nil
+			case types.Unhealthy:
+				return fmt.Errorf("container health check reported unhealthy")
+			}
+			continue
+		}
+
+		// Without a health check, require the container to stay up for a short period
+		if runningSince.IsZero() {
+			runningSince = time.Now()
+		}
+		if time.Since(runningSince) >= settlePeriod {
+			return nil
+		}
+	}
+}
+
+// restorePrevious renames the original container back and, if it was stopped,
+// starts it again. It runs even after ctx was cancelled, since a shutdown in the
+// middle of the switch would otherwise leave the service down under another name.
+func (c *Client) restorePrevious(ctx context.Context, containerID, containerName string, start bool) {
+	ctx = context.WithoutCancel(ctx)
+	renameCtx, cancel := c.withTimeout(ctx, 0)
+	defer cancel()
+	if err := c.client.ContainerRename(renameCtx, containerID, containerName); err != nil {
+		c.logger.Error("Failed to restore container name", "container", containerName, "error", err)
+	}
+	if !start {
+		return
+	}
+	startCtx, cancelStart := c.withTimeout(ctx, 0)
+	defer cancelStart()
+	if err := c.client.ContainerStart(startCtx, containerID, container.StartOptions{}); err != nil {
+		c.logger.Error("Failed to restart previous container", "container", containerName, "error", err)
+		return
+	}
+	c.logger.Warn("Restored previous container", "container", containerName)
+}
+
+// removeIfExists force-removes a leftover container from an interrupted deployment
+func (c *Client) removeIfExists(ctx context.Context, name string) {
+	inspectCtx, cancel := c.withTimeout(ctx, 0)
+	defer cancel()
+	if details, err := c.client.ContainerInspect(inspectCtx, name); err == nil {
+		c.logger.Warn("Removing leftover container", "container", name)
+		c.removeContainer(ctx, details.ID)
+	}
+}
+
+// removeContainer force-removes a container, logging failures
+func (c *Client) removeContainer(ctx context.Context, containerID string) {
+	removeCtx, cancel := c.withTimeout(ctx, restartStopTimeout*time.Second)
+	defer cancel()
+	if err := c.client.ContainerRemove(removeCtx, containerID, container.RemoveOptions{Force: true}); err != nil {
+		c.logger.Error("Failed to remove container", "container_id", containerID, "error", err)
+	}
+}
@@ -0,0 +1,168 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+
+	"ipssl-client/internal/logger"
+)
+
+// fakeContainer is a container of the fake daemon
+type fakeContainer struct {
+	id, name string
+	running  bool
+	role     string
+}
+
+// fakeDaemon serves the container endpoints used by blue-green deployments
+// and records the calls it receives
+type fakeDaemon struct {
+	mu         sync.Mutex
+	containers []*fakeContainer
+	calls      []string
+	nextID     int
+
+	// failActive makes the replacement exit right after it starts, and onStop
+	// runs when a container is stopped
+	failActive bool
+	onStop     func()
+}
+
+func newFakeDaemon(t *testing.T) (*fakeDaemon, *Client) {
+	t.Helper()
+	d := &fakeDaemon{containers: []*fakeContainer{{id: "old", name: "web", running: true}}}
+	server := httptest.NewServer(http.HandlerFunc(d.serveHTTP))
+	t.Cleanup(server.Close)
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(server.URL, "http://")), client.WithVersion("1.43"), client.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, &Client{client: cli, logger: logger.New(), timeout: 5 * time.Second, cache: make(map[string]cachedContainer)}
+}
+
+// find returns the container with the given ID or name
+func (d *fakeDaemon) find(ref string) *fakeContainer {
+	for _, c := range d.containers {
+		if c.id == ref || c.name == ref {
+			return c
+		}
+	}
+	return nil
+}
+
+func (d *fakeDaemon) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/v1.43")
+	if path == "/containers/create" {
+		var cfg container.Config
+		json.NewDecoder(r.Body).Decode(&cfg)
+		d.nextID++
+		c := &fakeContainer{id: fmt.Sprintf("new%d", d.nextID), name: r.URL.Query().Get("name"), role: cfg.Labels[labelRole]}
+		d.containers = append(d.containers, c)
+		d.calls = append(d.calls, "create "+c.role)
+		json.NewEncoder(w).Encode(container.CreateResponse{ID: c.id})
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(path, "/containers/"), "/")
+	c := d.find(parts[0])
+	if c == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"message": "no such container"})
+		return
+	}
+	action := r.Method
+	if len(parts) > 1 {
+		action = parts[1]
+	}
+	if action != "json" {
+		d.calls = append(d.calls, action+" "+c.id)
+	}
+	switch action {
+	case "json":
+		state := &types.ContainerState{Running: c.running, Status: "running"}
+		if c.role == "active" && d.failActive {
+			state = &types.ContainerState{Status: "exited", ExitCode: 1}
+		} else if c.running {
+			state.Health = &types.Health{Status: types.Healthy}
+		}
+		json.NewEncoder(w).Encode(types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{ID: c.id, Name: "/" + c.name, State: state, HostConfig: &container.HostConfig{}},
+			Config:            &container.Config{Image: "nginx", Labels: map[string]string{}},
+		})
+		return
+	case "start":
+		c.running = true
+	case "stop":
+		c.running = false
+		if d.onStop != nil {
+			d.onStop()
+		}
+	case "rename":
+		c.name = r.URL.Query().Get("name")
+	case http.MethodDelete:
+		for i, other := range d.containers {
+			if other == c {
+				d.containers = append(d.containers[:i], d.containers[i+1:]...)
+				break
+			}
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestBlueGreenDeploy(t *testing.T) {
+	d, c := newFakeDaemon(t)
+	if err := c.BlueGreenDeploy(context.Background(), "web", 10*time.Second); err != nil {
+		t.Fatalf("BlueGreenDeploy failed: %v", err)
+	}
+
+	// The original serves until the replacement has been created
+	calls := strings.Join(d.calls, ", ")
+	if !strings.Contains(calls, "create active, stop old, start new2") {
+		t.Errorf("Expected the original to be stopped right before the replacement starts, got %s", calls)
+	}
+	if len(d.containers) != 1 || d.containers[0].id != "new2" || d.containers[0].name != "web" || !d.containers[0].running {
+		t.Errorf("Expected only the running replacement to remain, got %+v", d.containers)
+	}
+}
+
+func TestBlueGreenDeployRestoresOnFailure(t *testing.T) {
+	d, c := newFakeDaemon(t)
+	d.failActive = true
+	if err := c.BlueGreenDeploy(context.Background(), "web", 10*time.Second); err == nil {
+		t.Fatal("Expected the failing replacement to be reported")
+	}
+	if len(d.containers) != 1 || d.containers[0].id != "old" || d.containers[0].name != "web" || !d.containers[0].running {
+		t.Errorf("Expected the original to be restored, got %+v", d.containers)
+	}
+}
+
+func TestBlueGreenDeployRestoresAfterCancel(t *testing.T) {
+	d, c := newFakeDaemon(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// A shutdown arrives while the original is stopped
+	d.onStop = cancel
+
+	if err := c.BlueGreenDeploy(ctx, "web", 10*time.Second); err == nil {
+		t.Fatal("Expected the cancelled deployment to fail")
+	}
+	if len(d.containers) != 1 || d.containers[0].id != "old" || d.containers[0].name != "web" || !d.containers[0].running {
+		t.Errorf("Expected the original to be restored despite the cancellation, got %+v", d.containers)
+	}
+}
@@ -2,63 +2,178 @@ package docker
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
 
 	"ipssl-client/internal/logger"
 )
 
+// restartStopTimeout is the number of seconds Docker waits for a container to stop during restart
+const restartStopTimeout = 30
+
+// resolveCacheTTL is how long a resolved container is reused before inspecting it again
+const resolveCacheTTL = 5 * time.Second
+
+// containerInfo is the subset of container details needed by the reload operations
+type containerInfo struct {
+	ID    string
+	Name  string
+	State string
+}
+
+// cachedContainer is a resolved container together with its expiry time
+type cachedContainer struct {
+	info    containerInfo
+	expires time.Time
+}
+
 // Client represents a Docker API client
 type Client struct {
-	client *client.Client
-	logger *logger.Logger
+	client  *client.Client
+	logger  *logger.Logger
+	timeout time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedContainer
 }
 
-// NewClient creates a new Docker client
-func NewClient(logger *logger.Logger) (*Client, error) {
+// NewClient creates a new Docker client. Every API call is bounded by timeout
+// (zero disables the limit) in addition to the caller's context.
+func NewClient(logger *logger.Logger, timeout time.Duration) (*Client, error) {
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Docker client: %w", err)
 	}
 
 	return &Client{
-		client: cli,
-		logger: logger,
+		client:  cli,
+		logger:  logger,
+		timeout: timeout,
+		cache:   make(map[string]cachedContainer),
 	}, nil
 }
 
-// ReloadContainer reloads a Docker container by sending a SIGHUP signal
-func (c *Client) ReloadContainer(ctx context.Context, containerName string) error {
-	c.logger.Info("Reloading container", "container", containerName)
+// withTimeout derives a context for a single Docker API call
+func (c *Client) withTimeout(ctx context.Context, extra time.Duration) (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.timeout+extra)
+}
 
-	// Get container information
-	containers, err := c.client.ContainerList(ctx, types.ContainerListOptions{
-		All: true,
+// wrapContextError annotates errors caused by cancellation or an expired deadline
+func (c *Client) wrapContextError(ctx context.Context, op string, err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("%s: Docker API did not respond within %s: %w", op, c.timeout, err)
+	case errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled):
+		return fmt.Errorf("%s: cancelled: %w", op, err)
+	default:
+		return fmt.Errorf("%s: %w", op, err)
+	}
+}
+
+// resolveContainer looks up a container by name or ID. Results are cached
+// briefly so that consecutive operations on the same container only inspect it once.
+func (c *Client) resolveContainer(ctx context.Context, containerName string) (containerInfo, error) {
+	c.mu.Lock()
+	cached, ok := c.cache[containerName]
+	c.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.info, nil
+	}
+
+	info, err := c.inspectContainer(ctx, containerName)
+	if err != nil {
+		return containerInfo{}, err
+	}
+
+	c.mu.Lock()
+	c.cache[containerName] = cachedContainer{info: info, expires: time.Now().Add(resolveCacheTTL)}
+	c.mu.Unlock()
+
+	return info, nil
+}
+
+// inspectContainer resolves a container with a single inspect call, falling back
+// to a name-filtered list for names that the daemon does not resolve directly or
+// when inspecting is not permitted
+func (c *Client) inspectContainer(ctx context.Context, containerName string) (containerInfo, error) {
+	inspectCtx, cancel := c.withTimeout(ctx, 0)
+	defer cancel()
+
+	details, err := c.client.ContainerInspect(inspectCtx, containerName)
+	if err == nil {
+		info := containerInfo{
+			ID:   details.ID,
+			Name: strings.TrimPrefix(details.Name, "/"),
+		}
+		if details.State != nil {
+			info.State = details.State.Status
+		}
+		return info, nil
+	}
+	if err := c.wrapAPIError(inspectCtx, endpointInspect, fmt.Sprintf("failed to inspect container %s", containerName), err); IsPermissionDenied(err) {
+		// Socket proxies often allow listing containers but not inspecting them
+		c.logger.Warn("Container inspect denied, falling back to container list", "container", containerName, "error", err)
+	} else if !client.IsErrNotFound(err) {
+		return containerInfo{}, err
+	}
+
+	listCtx, cancelList := c.withTimeout(ctx, 0)
+	defer cancelList()
+
+	containers, err := c.client.ContainerList(listCtx, types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("name", containerName)),
 	})
 	if err != nil {
-		return fmt.Errorf("failed to list containers: %w", err)
+		return containerInfo{}, c.wrapAPIError(listCtx, endpointList, "failed to list containers", err)
 	}
 
-	var targetContainer types.Container
-	found := false
 	for _, container := range containers {
 		for _, name := range container.Names {
 			if name == "/"+containerName || name == containerName {
-				targetContainer = container
-				found = true
-				break
+				return containerInfo{
+					ID:    container.ID,
+					Name:  strings.TrimPrefix(name, "/"),
+					State: container.State,
+				}, nil
 			}
 		}
-		if found {
-			break
-		}
 	}
 
-	if !found {
-		return fmt.Errorf("container %s not found", containerName)
+	return containerInfo{}, fmt.Errorf("container %s not found", containerName)
+}
+
+// invalidate drops the cached resolution of a container whose state is about to change
+func (c *Client) invalidate(containerName string) {
+	c.mu.Lock()
+	delete(c.cache, containerName)
+	c.mu.Unlock()
+}
+
+// ReloadContainer reloads a Docker container by sending a SIGHUP signal
+func (c *Client) ReloadContainer(ctx context.Context, containerName string) error {
+	c.logger.Info("Reloading container", "container", containerName)
+
+	// Get container information. Proxies that only allow sending signals still
+	// work; the daemon then resolves the name and rejects stopped containers.
+	targetContainer, err := c.resolveContainer(ctx, containerName)
+	switch {
+	case IsPermissionDenied(err):
+		c.logger.Warn("Container lookup denied, signalling container by name", "container", containerName, "error", err)
+		targetContainer = containerInfo{ID: containerName, Name: containerName, State: "running"}
+	case err != nil:
+		return err
 	}
 
 	// Check if container is running
@@ -67,9 +182,12 @@ func (c *Client) ReloadContainer(ctx context.Context, containerName string) erro
 	}
 
 	// Send SIGHUP signal to reload configuration
-	err = c.client.ContainerKill(ctx, targetContainer.ID, "SIGHUP")
+	killCtx, cancel := c.withTimeout(ctx, 0)
+	defer cancel()
+	err = c.client.ContainerKill(killCtx, targetContainer.ID, "SIGHUP")
 	if err != nil {
-		return fmt.Errorf("failed to send SIGHUP signal to container %s: %w", containerName, err)
+		c.invalidate(containerName)
+		return c.wrapAPIError(killCtx, endpointKill, fmt.Sprintf("failed to send SIGHUP signal to container %s", containerName), err)
 	}
 
 	c.logger.Info("Successfully sent reload signal to container", "container", containerName)
@@ -81,61 +199,61 @@ func (c *Client) RestartContainer(ctx context.Context, containerName string) err
 	c.logger.Info("Restarting container", "container", containerName)
 
 	// Get container information
-	containers, err := c.client.ContainerList(ctx, types.ContainerListOptions{
-		All: true,
-	})
+	targetContainer, err := c.resolveContainer(ctx, containerName)
 	if err != nil {
-		return fmt.Errorf("failed to list containers: %w", err)
+		return err
 	}
+	defer c.invalidate(containerName)
 
-	var targetContainer types.Container
-	found := false
-	for _, container := range containers {
-		for _, name := range container.Names {
-			if name == "/"+containerName || name == containerName {
-				targetContainer = container
-				found = true
-				break
-			}
-		}
-		if found {
-			break
-		}
+	// Restart the container; the deadline also covers the graceful stop period
+	timeout := restartStopTimeout
+	restartCtx, cancel := c.withTimeout(ctx, restartStopTimeout*time.Second)
+	defer cancel()
+	err = c.client.ContainerRestart(restartCtx, targetContainer.ID, container.StopOptions{
+		Timeout: &timeout,
+	})
+	if err != nil {
+		return c.wrapAPIError(restartCtx, endpointRestart, fmt.Sprintf("failed to restart container %s", containerName), err)
 	}
 
-	if !found {
-		return fmt.Errorf("container %s not found", containerName)
+	c.logger.Info("Successfully restarted container", "container", containerName)
+	return nil
+}
+
+// StopContainer stops a Docker container
+func (c *Client) StopContainer(ctx context.Context, containerName string) error {
+	c.logger.Info("Stopping container", "container", containerName)
+
+	targetContainer, err := c.resolveContainer(ctx, containerName)
+	if err != nil {
+		return err
+	}
+	defer c.invalidate(containerName)
+	if targetContainer.State != "running" {
+		c.logger.Info("Container is not running", "container", containerName, "state", targetContainer.State)
+		return nil
 	}
 
-	// Restart the container
-	timeout := 30
-	err = c.client.ContainerRestart(ctx, targetContainer.ID, container.StopOptions{
+	timeout := restartStopTimeout
+	stopCtx, cancel := c.withTimeout(ctx, restartStopTimeout*time.Second)
+	defer cancel()
+	err = c.client.ContainerStop(stopCtx, targetContainer.ID, container.StopOptions{
 		Timeout: &timeout,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to restart container %s: %w", containerName, err)
+		return c.wrapAPIError(stopCtx, endpointStop, fmt.Sprintf("failed to stop container %s", containerName), err)
 	}
 
-	c.logger.Info("Successfully restarted container", "container", containerName)
+	c.logger.Info("Successfully stopped container", "container", containerName)
 	return nil
 }
 
 // GetContainerStatus gets the status of a Docker container
 func (c *Client) GetContainerStatus(ctx context.Context, containerName string) (string, error) {
-	containers, err := c.client.ContainerList(ctx, types.ContainerListOptions{
-		All: true,
-	})
+	targetContainer, err := c.resolveContainer(ctx, containerName)
 	if err != nil {
-		return "", fmt.Errorf("failed to list containers: %w", err)
-	}
-
-	for _, container := range containers {
-		for _, name := range container.Names {
-			if name == "/"+containerName || name == containerName {
-				return container.State, nil
-			}
-		}
+		return "", err
 	}
 
-	return "", fmt.Errorf("container %s not found", containerName)
+	return targetContainer.State, nil
 }
@@ -0,0 +1,53 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// ReadFile returns the content of path as the container sees it, by running
+// cat inside the container, so that files written to a volume can be checked
+// against what the server actually mounts
+func (c *Client) ReadFile(ctx context.Context, containerName, path string) ([]byte, error) {
+	targetContainer, err := c.resolveContainer(ctx, containerName)
+	if err != nil {
+		return nil, err
+	}
+	if targetContainer.State != "running" {
+		return nil, fmt.Errorf("container %s is not running (state: %s)", containerName, targetContainer.State)
+	}
+
+	execCtx, cancel := c.withTimeout(ctx, 0)
+	defer cancel()
+	exec, err := c.client.ContainerExecCreate(execCtx, targetContainer.ID, types.ExecConfig{
+		Cmd:          []string{"cat", path},
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return nil, c.wrapAPIError(execCtx, endpointExec, fmt.Sprintf("failed to create exec in container %s", containerName), err)
+	}
+	attached, err := c.client.ContainerExecAttach(execCtx, exec.ID, types.ExecStartCheck{})
+	if err != nil {
+		return nil, c.wrapAPIError(execCtx, endpointExecRun, fmt.Sprintf("failed to run cat in container %s", containerName), err)
+	}
+	defer attached.Close()
+
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, attached.Reader); err != nil {
+		return nil, c.wrapContextError(execCtx, fmt.Sprintf("failed to read %s in container %s", path, containerName), err)
+	}
+	inspect, err := c.client.ContainerExecInspect(execCtx, exec.ID)
+	if err != nil {
+		return nil, c.wrapContextError(execCtx, fmt.Sprintf("failed to inspect exec in container %s", containerName), err)
+	}
+	if inspect.ExitCode != 0 {
+		return nil, fmt.Errorf("failed to read %s in container %s: %s", path, containerName, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,63 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/docker/docker/errdefs"
+)
+
+// endpoint describes a Docker API call and the access a socket proxy must grant
+// for it. Grants use the environment variables of docker-socket-proxy style
+// proxies, which most restricted setups follow.
+type endpoint struct {
+	method string
+	path   string
+	grant  string
+}
+
+// Docker API calls made by the reload strategies and file verification
+var (
+	endpointInspect = endpoint{"GET", "/containers/{id}/json", "CONTAINERS=1"}
+	endpointList    = endpoint{"GET", "/containers/json", "CONTAINERS=1"}
+	endpointKill    = endpoint{"POST", "/containers/{id}/kill", "CONTAINERS=1, POST=1"}
+	endpointRestart = endpoint{"POST", "/containers/{id}/restart", "CONTAINERS=1, POST=1"}
+	endpointRename  = endpoint{"POST", "/containers/{id}/rename", "CONTAINERS=1, POST=1"}
+	endpointStop    = endpoint{"POST", "/containers/{id}/stop", "CONTAINERS=1, POST=1"}
+	endpointStart   = endpoint{"POST", "/containers/{id}/start", "CONTAINERS=1, POST=1"}
+	endpointCreate  = endpoint{"POST", "/containers/create", "CONTAINERS=1, POST=1"}
+	endpointConnect = endpoint{"POST", "/networks/{id}/connect", "NETWORKS=1, POST=1"}
+	endpointExec    = endpoint{"POST", "/containers/{id}/exec", "CONTAINERS=1, EXEC=1, POST=1"}
+	endpointExecRun = endpoint{"POST", "/exec/{id}/start", "EXEC=1, POST=1"}
+)
+
+// PermissionError reports a Docker API call rejected by the daemon or by a
+// socket proxy in front of it
+type PermissionError struct {
+	Method   string
+	Endpoint string
+	Grant    string
+	Err      error
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("Docker API denied %s %s (socket proxy must allow %s): %v", e.Method, e.Endpoint, e.Grant, e.Err)
+}
+
+func (e *PermissionError) Unwrap() error { return e.Err }
+
+// IsPermissionDenied reports whether err was caused by a rejected Docker API call
+func IsPermissionDenied(err error) bool {
+	var pe *PermissionError
+	return errors.As(err, &pe)
+}
+
+// wrapAPIError annotates a failed Docker API call, turning rejections into a
+// PermissionError that names the call and the access it needs
+func (c *Client) wrapAPIError(ctx context.Context, ep endpoint, op string, err error) error {
+	if errdefs.IsForbidden(err) || errdefs.IsUnauthorized(err) {
+		return fmt.Errorf("%s: %w", op, &PermissionError{Method: ep.method, Endpoint: ep.path, Grant: ep.grant, Err: err})
+	}
+	return c.wrapContextError(ctx, op, err)
+}
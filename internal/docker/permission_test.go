@@ -0,0 +1,27 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/errdefs"
+)
+
+func TestWrapAPIErrorPermission(t *testing.T) {
+	c := &Client{}
+
+	err := c.wrapAPIError(context.Background(), endpointKill, "failed to send SIGHUP", errdefs.Forbidden(errors.New("forbidden")))
+	if !IsPermissionDenied(err) {
+		t.Fatalf("Expected permission error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "POST /containers/{id}/kill") {
+		t.Errorf("Expected error to name the denied call, got %q", err)
+	}
+
+	err = c.wrapAPIError(context.Background(), endpointKill, "failed to send SIGHUP", errdefs.NotFound(errors.New("no such container")))
+	if IsPermissionDenied(err) {
+		t.Errorf("Expected not-found error not to be a permission error, got %v", err)
+	}
+}
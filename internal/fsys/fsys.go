@@ -0,0 +1,135 @@
+package fsys
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// FS is the set of filesystem operations the client performs on certificate,
+// state and validation files. Code takes an FS rather than calling package os
+// so that file handling can be tested against Mem, including failures such as
+// a read-only filesystem, without touching the real filesystem.
+type FS interface {
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	MkdirAll(path string, perm fs.FileMode) error
+	Remove(name string) error
+	Rename(oldpath, newpath string) error
+	Stat(name string) (fs.FileInfo, error)
+	Chmod(name string, mode fs.FileMode) error
+	Chown(name string, uid, gid int) error
+	Symlink(oldname, newname string) error
+	Readlink(name string) (string, error)
+
+	// CreateTemp creates a new file in dir as os.CreateTemp does
+	CreateTemp(dir, pattern string) (File, error)
+
+	// SyncDir flushes the entries of directory name to stable storage, so
+	// that a rename into it survives a crash
+	SyncDir(name string) error
+}
+
+// File is a file opened for writing by CreateTemp
+type File interface {
+	io.Writer
+	Name() string
+	Sync() error
+	Close() error
+}
+
+// OS is the real filesystem
+var OS FS = osFS{}
+
+// osFS implements FS with package os
+type osFS struct{}
+
+func (osFS) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+func (osFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+func (osFS) MkdirAll(path string, perm fs.FileMode) error { return os.MkdirAll(path, perm) }
+func (osFS) Remove(name string) error                     { return os.Remove(name) }
+func (osFS) Rename(oldpath, newpath string) error         { return os.Rename(oldpath, newpath) }
+func (osFS) Stat(name string) (fs.FileInfo, error)        { return os.Stat(name) }
+func (osFS) Chmod(name string, mode fs.FileMode) error    { return os.Chmod(name, mode) }
+func (osFS) Chown(name string, uid, gid int) error        { return os.Chown(name, uid, gid) }
+func (osFS) Symlink(oldname, newname string) error        { return os.Symlink(oldname, newname) }
+func (osFS) Readlink(name string) (string, error)         { return os.Readlink(name) }
+func (osFS) CreateTemp(dir, pattern string) (File, error) { return os.CreateTemp(dir, pattern) }
+
+// SyncDir fsyncs the directory. Windows cannot open directories for syncing
+// and persists renames with the file system journal instead.
+func (osFS) SyncDir(name string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	dir, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// WriteFileAtomic writes data to a temporary file next to name and renames it
+// into place, so that readers see either the old or the new content but never
+// a partially written file. The file and then the directory are synced, so
+// that after a crash or power loss name holds the old or the new content
+// rather than an empty file. As with os.WriteFile, perm only applies to new
+// files and an existing file keeps its permissions.
+//
+// Each call replaces a single file: a certificate and its key written with
+// two calls are two renames, between which a reader can see the new
+// certificate with the old key.
+func WriteFileAtomic(fsys FS, name string, data []byte, perm fs.FileMode) error {
+	if info, err := fsys.Stat(name); err == nil {
+		perm = info.Mode().Perm()
+	}
+	return writeFileAtomic(fsys, name, data, perm, -1, -1)
+}
+
+// WriteFileAtomicOwned is WriteFileAtomic for files another account reads,
+// such as the key of a database server. perm always applies, also to an
+// existing file, and the owner is set to uid and gid (-1 leaves either
+// unchanged) before the rename, so that name never holds the new content
+// with the wrong owner or permissions.
+func WriteFileAtomicOwned(fsys FS, name string, data []byte, perm fs.FileMode, uid, gid int) error {
+	return writeFileAtomic(fsys, name, data, perm, uid, gid)
+}
+
+// writeFileAtomic writes, syncs and renames the temporary file after giving
+// it perm and the owner uid:gid
+func writeFileAtomic(fsys FS, name string, data []byte, perm fs.FileMode, uid, gid int) error {
+	tmp, err := fsys.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer fsys.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := fsys.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	if uid != -1 || gid != -1 {
+		if err := fsys.Chown(tmp.Name(), uid, gid); err != nil {
+			return err
+		}
+	}
+	if err := fsys.Rename(tmp.Name(), name); err != nil {
+		return err
+	}
+	return fsys.SyncDir(filepath.Dir(name))
+}
@@ -0,0 +1,190 @@
+package fsys
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cert.pem")
+
+	for _, content := range []string{"first", "second"} {
+		if err := WriteFileAtomic(OS, path, []byte(content), 0640); err != nil {
+			t.Fatalf("WriteFileAtomic failed: %v", err)
+		}
+		if data, err := os.ReadFile(path); err != nil || string(data) != content {
+			t.Errorf("Expected %q, got %q (%v)", content, data, err)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("Expected mode 0640, got %v", info.Mode().Perm())
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the written file to remain, got %d entries", len(entries))
+	}
+}
+
+func TestMem(t *testing.T) {
+	m := NewMem()
+
+	if err := m.WriteFile("/ssl/cert.pem", []byte("cert"), 0644); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected writing into a missing directory to fail, got %v", err)
+	}
+	if err := m.MkdirAll("/ssl", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileAtomic(m, "/ssl/cert.pem", []byte("cert"), 0644); err != nil {
+		t.Fatalf("WriteFileAtomic failed: %v", err)
+	}
+	if data, err := m.ReadFile("/ssl/cert.pem"); err != nil || string(data) != "cert" {
+		t.Errorf("Expected cert, got %q (%v)", data, err)
+	}
+	if info, err := m.Stat("/ssl/cert.pem"); err != nil || info.Mode().Perm() != 0644 || info.Size() != 4 {
+		t.Errorf("Unexpected file info %v (%v)", info, err)
+	}
+	if info, err := m.Stat("/ssl"); err != nil || !info.IsDir() {
+		t.Errorf("Expected /ssl to be a directory, got %v (%v)", info, err)
+	}
+
+	if err := m.Remove("/ssl/cert.pem"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ReadFile("/ssl/cert.pem"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected removed file to be missing, got %v", err)
+	}
+}
+
+func TestMemSymlink(t *testing.T) {
+	m := NewMem()
+	if err := m.MkdirAll("/ssl/archive", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.MkdirAll("/ssl/live", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteFile("/ssl/archive/cert1.pem", []byte("cert"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Symlink("../archive/cert1.pem", "/ssl/live/cert.pem"); err != nil {
+		t.Fatalf("Symlink failed: %v", err)
+	}
+	if data, err := m.ReadFile("/ssl/live/cert.pem"); err != nil || string(data) != "cert" {
+		t.Errorf("Expected the link to be followed, got %q (%v)", data, err)
+	}
+	if target, err := m.Readlink("/ssl/live/cert.pem"); err != nil || target != "../archive/cert1.pem" {
+		t.Errorf("Expected ../archive/cert1.pem, got %q (%v)", target, err)
+	}
+	if _, err := m.Readlink("/ssl/archive/cert1.pem"); err == nil {
+		t.Error("Expected Readlink of a regular file to fail")
+	}
+
+	if err := m.Symlink("../archive/cert2.pem", "/ssl/live/broken.pem"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Stat("/ssl/live/broken.pem"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected a dangling link to be missing, got %v", err)
+	}
+}
+
+func TestMemReadOnly(t *testing.T) {
+	m := NewMem()
+	if err := m.MkdirAll("/ssl", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteFile("/ssl/cert.pem", []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m.ReadOnly = true
+
+	if err := WriteFileAtomic(m, "/ssl/cert.pem", []byte("new"), 0644); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Expected permission error, got %v", err)
+	}
+	if err := m.MkdirAll("/validation", 0755); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Expected permission error, got %v", err)
+	}
+	if data, err := m.ReadFile("/ssl/cert.pem"); err != nil || string(data) != "old" {
+		t.Errorf("Expected the existing file to be readable and unchanged, got %q (%v)", data, err)
+	}
+}
+
+func TestWriteFileAtomicKeepsMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, []byte("old"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileAtomic(OS, path, []byte("new"), 0600); err != nil {
+		t.Fatalf("WriteFileAtomic failed: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("Expected the existing mode 0640 to be kept, got %v", info.Mode().Perm())
+	}
+}
+
+func TestWriteFileAtomicOwned(t *testing.T) {
+	m := NewMem()
+	if err := m.MkdirAll("/etc/postgresql", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteFile("/etc/postgresql/server.key", []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileAtomicOwned(m, "/etc/postgresql/server.key", []byte("new"), 0600, 70, 70); err != nil {
+		t.Fatalf("WriteFileAtomicOwned failed: %v", err)
+	}
+
+	if data, err := m.ReadFile("/etc/postgresql/server.key"); err != nil || string(data) != "new" {
+		t.Errorf("Expected the new content, got %q (%v)", data, err)
+	}
+	info, err := m.Stat("/etc/postgresql/server.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600 to replace the existing mode, got %v", info.Mode().Perm())
+	}
+	if uid, gid, err := m.Owner("/etc/postgresql/server.key"); err != nil || uid != 70 || gid != 70 {
+		t.Errorf("Expected owner 70:70, got %d:%d (%v)", uid, gid, err)
+	}
+}
+
+func TestLookupOwner(t *testing.T) {
+	uid, gid, err := LookupOwner("1000", "")
+	if err != nil || uid != 1000 || gid != -1 {
+		t.Errorf("LookupOwner(1000, \"\") = %d, %d, %v; want 1000, -1", uid, gid, err)
+	}
+	if _, _, err := LookupOwner("no-such-user-ipssl", ""); err == nil {
+		t.Error("Expected an error for an unknown user")
+	}
+}
+
+func TestWriteFileAtomicSyncs(t *testing.T) {
+	m := NewMem()
+	if err := m.MkdirAll("/ssl", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileAtomic(m, "/ssl/cert.pem", []byte("cert"), 0644); err != nil {
+		t.Fatalf("WriteFileAtomic failed: %v", err)
+	}
+
+	// The content is synced before the rename and the directory after it
+	if len(m.Synced) != 2 || filepath.Dir(m.Synced[0]) != "/ssl" || m.Synced[0] == "/ssl/cert.pem" || m.Synced[1] != "/ssl" {
+		t.Errorf("Expected the temporary file and then the directory to be synced, got %v", m.Synced)
+	}
+}
@@ -0,0 +1,332 @@
+package fsys
+
+import (
+	"bytes"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mem is an in-memory FS for tests. Parent directories must exist before files
+// are written, as on a real filesystem. Symbolic links are followed when
+// files are read or described; writing to a link replaces it.
+type Mem struct {
+	mu    sync.Mutex
+	files map[string]*memFile
+	dirs  map[string]bool
+	temps int
+
+	// ReadOnly makes every modification fail with fs.ErrPermission
+	ReadOnly bool
+
+	// Synced lists the files and directories synced, in order
+	Synced []string
+}
+
+// memFile is a file stored in Mem, or a symbolic link to link
+type memFile struct {
+	data     []byte
+	mode     fs.FileMode
+	modTime  time.Time
+	uid, gid int
+	link     string
+}
+
+// maxLinks is the number of symbolic links followed before giving up
+const maxLinks = 40
+
+// NewMem creates an empty in-memory filesystem
+func NewMem() *Mem {
+	return &Mem{files: make(map[string]*memFile), dirs: make(map[string]bool)}
+}
+
+// ReadFile returns the content of name
+func (m *Mem) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := m.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Clone(f.data), nil
+}
+
+// WriteFile replaces the content of name, keeping the mode of an existing file
+func (m *Mem) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	if err := m.writable("open", name); err != nil {
+		return err
+	}
+	if f, ok := m.files[name]; ok {
+		perm = f.mode
+	}
+	m.files[name] = &memFile{data: bytes.Clone(data), mode: perm, modTime: time.Now()}
+	return nil
+}
+
+// MkdirAll creates path and its parents
+func (m *Mem) MkdirAll(path string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ReadOnly {
+		return &fs.PathError{Op: "mkdir", Path: path, Err: fs.ErrPermission}
+	}
+	for dir := filepath.Clean(path); !isRoot(dir); dir = filepath.Dir(dir) {
+		if _, ok := m.files[dir]; ok {
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: fs.ErrExist}
+		}
+		m.dirs[dir] = true
+	}
+	return nil
+}
+
+// Remove deletes a file or an empty directory
+func (m *Mem) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	if m.ReadOnly {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+	}
+	if _, ok := m.files[name]; ok {
+		delete(m.files, name)
+		return nil
+	}
+	if m.dirs[name] {
+		delete(m.dirs, name)
+		return nil
+	}
+	return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+}
+
+// Rename moves a file, replacing any file at newpath
+func (m *Mem) Rename(oldpath, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	oldpath, newpath = filepath.Clean(oldpath), filepath.Clean(newpath)
+	if err := m.writable("rename", newpath); err != nil {
+		return err
+	}
+	f, ok := m.files[oldpath]
+	if !ok {
+		return &fs.PathError{Op: "rename", Path: oldpath, Err: fs.ErrNotExist}
+	}
+	delete(m.files, oldpath)
+	m.files[newpath] = f
+	return nil
+}
+
+// Stat describes a file or directory
+func (m *Mem) Stat(name string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	if _, ok := m.files[name]; ok {
+		f, err := m.resolve("stat", name)
+		if err != nil {
+			return nil, err
+		}
+		return memInfo{name: filepath.Base(name), size: int64(len(f.data)), mode: f.mode, modTime: f.modTime}, nil
+	}
+	if m.dirs[name] || isRoot(name) {
+		return memInfo{name: filepath.Base(name), mode: fs.ModeDir | 0755}, nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// Chmod changes the permissions of a file
+func (m *Mem) Chmod(name string, mode fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	if m.ReadOnly {
+		return &fs.PathError{Op: "chmod", Path: name, Err: fs.ErrPermission}
+	}
+	f, ok := m.files[name]
+	if !ok {
+		return &fs.PathError{Op: "chmod", Path: name, Err: fs.ErrNotExist}
+	}
+	f.mode = mode
+	return nil
+}
+
+// Chown changes the owner and group of a file or directory; -1 leaves either unchanged
+func (m *Mem) Chown(name string, uid, gid int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	if m.ReadOnly {
+		return &fs.PathError{Op: "chown", Path: name, Err: fs.ErrPermission}
+	}
+	if m.dirs[name] {
+		return nil
+	}
+	f, ok := m.files[name]
+	if !ok {
+		return &fs.PathError{Op: "chown", Path: name, Err: fs.ErrNotExist}
+	}
+	if uid >= 0 {
+		f.uid = uid
+	}
+	if gid >= 0 {
+		f.gid = gid
+	}
+	return nil
+}
+
+// Symlink creates newname as a symbolic link to oldname
+func (m *Mem) Symlink(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	newname = filepath.Clean(newname)
+	if err := m.writable("symlink", newname); err != nil {
+		return err
+	}
+	if _, ok := m.files[newname]; ok {
+		return &fs.PathError{Op: "symlink", Path: newname, Err: fs.ErrExist}
+	}
+	m.files[newname] = &memFile{mode: fs.ModeSymlink | 0777, modTime: time.Now(), link: oldname}
+	return nil
+}
+
+// Readlink returns the destination of the symbolic link name
+func (m *Mem) Readlink(name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[filepath.Clean(name)]
+	if !ok {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrNotExist}
+	}
+	if f.link == "" {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return f.link, nil
+}
+
+// resolve returns the file at name, following symbolic links; the caller holds m.mu
+func (m *Mem) resolve(op, name string) (*memFile, error) {
+	path := filepath.Clean(name)
+	for range maxLinks {
+		f, ok := m.files[path]
+		if !ok {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		if f.link == "" {
+			return f, nil
+		}
+		if filepath.IsAbs(f.link) {
+			path = filepath.Clean(f.link)
+		} else {
+			path = filepath.Join(filepath.Dir(path), f.link)
+		}
+	}
+	return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+}
+
+// Owner returns the owner and group of a file
+func (m *Mem) Owner(name string) (uid, gid int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[filepath.Clean(name)]
+	if !ok {
+		return 0, 0, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return f.uid, f.gid, nil
+}
+
+// CreateTemp creates an empty file in dir whose name replaces the last "*" in
+// pattern with a unique number; the content is stored when the file is closed
+func (m *Mem) CreateTemp(dir, pattern string) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.temps++
+	name := pattern + strconv.Itoa(m.temps)
+	if i := strings.LastIndex(pattern, "*"); i >= 0 {
+		name = pattern[:i] + strconv.Itoa(m.temps) + pattern[i+1:]
+	}
+	name = filepath.Join(dir, name)
+	if err := m.writable("open", name); err != nil {
+		return nil, err
+	}
+	m.files[name] = &memFile{mode: 0600, modTime: time.Now()}
+	return &memTemp{mem: m, name: name}, nil
+}
+
+// SyncDir records that the directory was synced
+func (m *Mem) SyncDir(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.dirs[name] && !isRoot(name) {
+		return &fs.PathError{Op: "sync", Path: name, Err: fs.ErrNotExist}
+	}
+	m.Synced = append(m.Synced, name)
+	return nil
+}
+
+// writable checks that name may be created; the caller holds m.mu
+func (m *Mem) writable(op, name string) error {
+	if m.ReadOnly {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	if dir := filepath.Dir(name); !m.dirs[dir] && !isRoot(dir) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if m.dirs[name] {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrExist}
+	}
+	return nil
+}
+
+// isRoot reports whether dir is the top of a path
+func isRoot(dir string) bool {
+	return dir == "." || dir == string(filepath.Separator) || filepath.Dir(dir) == dir
+}
+
+// memTemp is a file being written by CreateTemp
+type memTemp struct {
+	mem  *Mem
+	name string
+	buf  bytes.Buffer
+}
+
+func (t *memTemp) Write(p []byte) (int, error) { return t.buf.Write(p) }
+func (t *memTemp) Name() string                { return t.name }
+
+// Sync records that the file was synced
+func (t *memTemp) Sync() error {
+	t.mem.mu.Lock()
+	defer t.mem.mu.Unlock()
+	t.mem.Synced = append(t.mem.Synced, t.name)
+	return nil
+}
+
+// Close stores the written content
+func (t *memTemp) Close() error {
+	t.mem.mu.Lock()
+	defer t.mem.mu.Unlock()
+	if f, ok := t.mem.files[t.name]; ok {
+		f.data = bytes.Clone(t.buf.Bytes())
+		f.modTime = time.Now()
+	}
+	return nil
+}
+
+// memInfo describes a file or directory in Mem
+type memInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) Mode() fs.FileMode  { return i.mode }
+func (i memInfo) ModTime() time.Time { return i.modTime }
+func (i memInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i memInfo) Sys() any           { return nil }
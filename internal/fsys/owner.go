@@ -0,0 +1,37 @@
+package fsys
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+)
+
+// LookupOwner resolves a user and a group, each a name or a numeric ID, to
+// the IDs passed to Chown. Numeric IDs need not exist on this host; empty
+// values resolve to -1, which leaves the owner or group unchanged.
+func LookupOwner(owner, group string) (uid, gid int, err error) {
+	uid, gid = -1, -1
+	if owner != "" {
+		if uid, err = strconv.Atoi(owner); err != nil {
+			u, err := user.Lookup(owner)
+			if err != nil {
+				return -1, -1, fmt.Errorf("failed to look up user %s: %w", owner, err)
+			}
+			if uid, err = strconv.Atoi(u.Uid); err != nil {
+				return -1, -1, fmt.Errorf("invalid uid %q for user %s", u.Uid, owner)
+			}
+		}
+	}
+	if group != "" {
+		if gid, err = strconv.Atoi(group); err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return -1, -1, fmt.Errorf("failed to look up group %s: %w", group, err)
+			}
+			if gid, err = strconv.Atoi(g.Gid); err != nil {
+				return -1, -1, fmt.Errorf("invalid gid %q for group %s", g.Gid, group)
+			}
+		}
+	}
+	return uid, gid, nil
+}
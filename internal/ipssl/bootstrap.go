@@ -0,0 +1,50 @@
+package ipssl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ipssl-client/internal/zerossl"
+)
+
+// certificateMissing reports whether no certificate has been installed yet
+func (c *Client) certificateMissing() bool {
+	_, err := c.files.Stat(filepath.Join(c.config.SSLDir, "cert.pem"))
+	return errors.Is(err, os.ErrNotExist)
+}
+
+// obtainFirstCertificate retries issuance with exponential backoff until the
+// first certificate is installed, rather than leaving the server without one
+// for a whole renewal interval. It gives up only when ctx is done.
+func (c *Client) obtainFirstCertificate(ctx context.Context) error {
+	delay := c.config.StartupRetry.Delay
+	for attempt := 1; ; attempt++ {
+		err := c.renew(ctx)
+		if err == nil {
+			return nil
+		}
+
+		wait := delay
+		if errors.Is(err, zerossl.ErrRateLimited) || errors.Is(err, zerossl.ErrQuotaExceeded) {
+			wait = c.config.StartupRetry.MaxDelay
+		}
+		c.logger.Warn("Failed to obtain first certificate, retrying", "attempt", attempt, "delay", wait, "error", err)
+		c.planCheck(wait, &backoffState{Reason: backoffStartup, Attempt: attempt, Error: err.Error()})
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("no certificate obtained after %d attempts: %w", attempt, err)
+		case <-time.After(wait):
+		}
+		delay = min(delay*2, c.config.StartupRetry.MaxDelay)
+
+		// An operator may have supplied a certificate in the meantime
+		c.recordCheck()
+		if c.applyOverride(ctx) || c.standby(ctx) || c.isCertificateValid() || c.frozen() {
+			return nil
+		}
+	}
+}
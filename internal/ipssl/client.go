@@ -2,51 +2,273 @@ package ipssl
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
+	"ipssl-client/internal/acme"
+	"ipssl-client/internal/chain"
 	"ipssl-client/internal/config"
+	"ipssl-client/internal/ct"
+	"ipssl-client/internal/deploy"
 	"ipssl-client/internal/docker"
+	"ipssl-client/internal/fsys"
+	"ipssl-client/internal/keys"
 	"ipssl-client/internal/logger"
+	"ipssl-client/internal/metrics"
+	"ipssl-client/internal/notify"
+	"ipssl-client/internal/ocsp"
+	"ipssl-client/internal/state"
+	"ipssl-client/internal/timestamp"
 	"ipssl-client/internal/zerossl"
 )
 
+// CertificateProvider obtains and revokes certificates from a certificate authority
+type CertificateProvider interface {
+	// RequestCertificate issues a certificate covering all identifiers with a
+	// new key of keyType and returns the PEM-encoded chain and private key
+	RequestCertificate(ctx context.Context, identifiers []string, keyType string) ([]byte, []byte, error)
+
+	// RevokeCertificate revokes the PEM-encoded certificate
+	RevokeCertificate(ctx context.Context, certPEM []byte) error
+
+	// SupportedKeyTypes lists the key types the certificate authority accepts
+	SupportedKeyTypes() []string
+
+	// IsCertificateValid reports whether the certificate at certPath remains
+	// valid for at least validityDuration
+	IsCertificateValid(certPath string, validityDuration time.Duration) (bool, error)
+}
+
+// usageReporter is implemented by providers that can report the account's certificate quota usage
+type usageReporter interface {
+	Usage(ctx context.Context) (zerossl.Usage, error)
+}
+
 // Client represents the IPSSL client
 type Client struct {
-	config  *config.Config
-	logger  *logger.Logger
-	zerossl *zerossl.Client
-	docker  *docker.Client
+	config    *config.Config
+	logger    *logger.Logger
+	provider  CertificateProvider
+	keyType   string
+	roots     *x509.CertPool
+	ctLogs    ct.LogList
+	docker    *docker.Client
+	files     fsys.FS
+	deployers []deploy.Deployer
+	tsa       *timestamp.Client
+	ocsp      *ocsp.Client
+	email     *notify.Email
+	siem      *notify.SIEM
+	metrics   *metrics.Metrics
+
+	// stoppedExpired is set when the container was stopped because its certificate expired
+	stoppedExpired bool
+
+	// vrrpRole is the last observed VRRP role and followedSerial the serial of
+	// the certificate last installed from the active node while on standby
+	vrrpRole       string
+	followedSerial string
+
+	// revokedSerial is the serial of the installed certificate last reported revoked
+	revokedSerial string
+
+	// nextCheckAt and backoff are the planned next check, served on /status
+	planMu      sync.Mutex
+	nextCheckAt time.Time
+	backoff     *backoffState
 }
 
 // NewClient creates a new IPSSL client
 func NewClient(cfg *config.Config, logger *logger.Logger) (*Client, error) {
-	// Initialize ZeroSSL client
-	zerosslClient, err := zerossl.NewClient(cfg.APIKey, logger)
+	return newClient(cfg, logger, metrics.New())
+}
+
+// newClient creates a client recording its metrics in m
+func newClient(cfg *config.Config, logger *logger.Logger, m *metrics.Metrics) (*Client, error) {
+	// Fail at startup rather than after issuance if the owner does not exist
+	if _, _, err := fsys.LookupOwner(cfg.Files.Owner, cfg.Files.Group); err != nil {
+		return nil, err
+	}
+
+	// Initialize the certificate provider
+	provider, err := NewProvider(cfg, logger, m)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("Certificate provider selected", "provider", cfg.Provider)
+
+	// Check that the provider accepts the configured key type
+	keyType, err := keys.Negotiate(cfg.KeyType, provider.SupportedKeyTypes(), cfg.KeyTypeFallback)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IPSSL_KEY_TYPE for provider %s: %w", cfg.Provider, err)
+	}
+	if keyType != cfg.KeyType {
+		logger.Warn("Key type not supported by provider, falling back", "requested", cfg.KeyType, "key_type", keyType, "provider", cfg.Provider)
+	}
+
+	roots, err := loadRoots(cfg.ChainRoots)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create ZeroSSL client: %w", err)
+		return nil, err
+	}
+	var ctLogs ct.LogList
+	if cfg.VerifyCT && cfg.CTLogList != "" {
+		if ctLogs, err = ct.LoadLogList(cfg.CTLogList); err != nil {
+			return nil, err
+		}
 	}
 
-	// Initialize Docker client only if container name is specified
+	// Initialize Docker client only if container name is specified and the
+	// reload strategy talks to the Docker API
 	var dockerClient *docker.Client
-	if cfg.ContainerName != "" {
-		dockerClient, err = docker.NewClient(logger)
+	if cfg.ReloadStrategy == config.ReloadTrigger {
+		logger.Info("Docker client not initialized - reloads are signalled through a trigger file", "reload_file", cfg.ReloadFile)
+	} else if cfg.ContainerName != "" {
+		dockerClient, err = docker.NewClient(logger, cfg.DockerTimeout)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create Docker client: %w", err)
 		}
-		logger.Info("Docker client initialized", "container_name", cfg.ContainerName)
+		logger.Info("Docker client initialized", "container_name", cfg.ContainerName, "timeout", cfg.DockerTimeout)
 	} else {
 		logger.Info("Docker client not initialized - no container name specified")
 	}
 
-	return &Client{
-		config:  cfg,
-		logger:  logger,
-		zerossl: zerosslClient,
-		docker:  dockerClient,
-	}, nil
+	// Initialize additional deployers
+	deployers, err := deploy.New(cfg, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create deployers: %w", err)
+	}
+
+	client := &Client{
+		config:    cfg,
+		logger:    logger,
+		provider:  provider,
+		keyType:   keyType,
+		roots:     roots,
+		ctLogs:    ctLogs,
+		docker:    dockerClient,
+		files:     fsys.OS,
+		deployers: deployers,
+		metrics:   m,
+	}
+	client.restoreMetrics()
+	m.SetCertificateLabels(cfg.Primary(), cfg.Labels)
+	if cfg.TSAURL != "" {
+		client.tsa = timestamp.NewClient(cfg.TSAURL, logger)
+	}
+	if cfg.OCSPStaple || cfg.RevocationCheck > 0 {
+		client.ocsp = ocsp.NewClient(logger)
+	}
+	if cfg.SMTP.Host != "" && len(cfg.Report.Recipients) > 0 {
+		client.email, err = notify.NewEmail(cfg.SMTP)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create email notifier: %w", err)
+		}
+	}
+	if cfg.SIEM.Addr != "" {
+		client.siem, err = notify.NewSIEM(cfg.SIEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create SIEM exporter: %w", err)
+		}
+		logger.Info("Certificate events exported to SIEM", "addr", cfg.SIEM.Addr, "format", cfg.SIEM.Format, "signed", cfg.SIEM.SigningKey != "")
+	}
+	return client, nil
+}
+
+// NewProvider creates the certificate provider selected by the configuration.
+// API calls are recorded in m.
+func NewProvider(cfg *config.Config, logger *logger.Logger, m *metrics.Metrics) (CertificateProvider, error) {
+	httpClient := m.InstrumentClient(cfg.Provider, nil)
+	if cfg.Sandbox {
+		logger.Warn("Sandbox mode enabled, issued certificates are not publicly trusted", "provider", cfg.Provider)
+	}
+	switch cfg.Provider {
+	case config.ProviderACME:
+		acmeClient, err := acme.NewClient(cfg.ACME.DirectoryURL, cfg.ACME.Email, cfg.ValidationDirs, cfg.SSLDir, httpClient, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create ACME client: %w", err)
+		}
+		switch {
+		case cfg.ACME.EABKID != "":
+			acmeClient.SetExternalAccountBinding(acme.StaticEAB(cfg.ACME.EABKID, cfg.ACME.EABHMACKey))
+		case cfg.APIKey != "" && cfg.ACME.DirectoryURL == config.ZeroSSLDirectory:
+			// Credentials are only requested when a new account is registered
+			zerosslClient, err := zerossl.NewClient(cfg.APIKey, cfg.APIURL, m.InstrumentClient(config.ProviderZeroSSL, nil), logger)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create ZeroSSL client: %w", err)
+			}
+			zerosslClient.SetRetryPolicy(retryPolicy(cfg))
+			acmeClient.SetExternalAccountBinding(zerosslClient.GenerateEABCredentials)
+		}
+		acmeClient.SetMustStaple(cfg.MustStaple)
+		return acmeClient, nil
+	default:
+		zerosslClient, err := zerossl.NewClient(cfg.APIKey, cfg.APIURL, httpClient, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create ZeroSSL client: %w", err)
+		}
+		zerosslClient.SetCleanupStale(cfg.CleanupStale)
+		zerosslClient.SetMustStaple(cfg.MustStaple)
+		zerosslClient.SetQuota(cfg.CertQuota)
+		zerosslClient.SetIssuancePolling(cfg.PollInterval, cfg.IssuanceTimeout)
+		zerosslClient.SetValidationDirs(cfg.ValidationDirs)
+		zerosslClient.SetKeyDir(filepath.Join(cfg.SSLDir, "keys"))
+		zerosslClient.SetSelfCheck(cfg.SelfCheck)
+		zerosslClient.SetValidationFormat(validationEOL(cfg.ValidationEOL), cfg.ValidationOrder == config.ValidationOrderCanonical)
+		zerosslClient.SetRetryPolicy(retryPolicy(cfg))
+		if cfg.VerifyLimit > 0 {
+			zerosslClient.SetVerifyQueue(state.NewQueue(verifyQueueDir(cfg), cfg.VerifyLimit))
+		}
+		return zerosslClient, nil
+	}
+}
+
+// verifyQueueDir returns the verify queue directory of the account, so that
+// clients using different accounts can share IPSSL_VERIFY_QUEUE_DIR
+func verifyQueueDir(cfg *config.Config) string {
+	account := sha256.Sum256([]byte(cfg.APIURL + "\n" + cfg.APIKey))
+	return filepath.Join(cfg.VerifyQueueDir, hex.EncodeToString(account[:8]))
+}
+
+// validationEOL returns the line ending configured for validation files
+func validationEOL(eol string) string {
+	if eol == config.ValidationEOLCRLF {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// retryPolicy returns the configured retry policy for ZeroSSL API calls
+func retryPolicy(cfg *config.Config) zerossl.RetryPolicy {
+	return zerossl.RetryPolicy{
+		MaxAttempts: cfg.APIRetry.MaxAttempts,
+		BaseDelay:   cfg.APIRetry.BaseDelay,
+		MaxDelay:    cfg.APIRetry.MaxDelay,
+	}
+}
+
+// RevokeCertificate revokes the installed certificate
+func (c *Client) RevokeCertificate(ctx context.Context) error {
+	certPath := filepath.Join(c.config.SSLDir, "cert.pem")
+	cert, err := c.files.ReadFile(certPath)
+	if err != nil {
+		return fmt.Errorf("failed to read certificate: %w", err)
+	}
+	if err := c.provider.RevokeCertificate(ctx, cert); err != nil {
+		return err
+	}
+	c.logger.Info("Installed certificate revoked", "cert_path", certPath)
+	return nil
 }
 
 // Start starts the IPSSL client with automatic renewal
@@ -57,51 +279,165 @@ func (c *Client) Start(ctx context.Context) error {
 	if err := c.ensureDirectories(); err != nil {
 		return fmt.Errorf("failed to ensure directories: %w", err)
 	}
+	if err := c.secureSSLDir(); err != nil {
+		return err
+	}
+
+	// Expose Prometheus metrics (optional)
+	if c.config.MetricsAddr != "" {
+		go func() {
+			c.logger.Info("Serving metrics", "addr", c.config.MetricsAddr)
+			if err := c.metrics.Serve(ctx, c.config.MetricsAddr, map[string]http.Handler{
+				"/healthz": c.healthHandler(),
+				"/status":  c.statusHandler(),
+			}); err != nil {
+				c.logger.Error("Metrics server failed", "error", err)
+			}
+		}()
+	}
+
+	c.logUsage(ctx)
 
 	// Check if certificate already exists and is valid
-	if c.isCertificateValid() {
+	c.recordCheck()
+	c.pruneHistory()
+	if c.applyOverride(ctx) {
+		c.logger.Info("Override certificate in effect, skipping initial download")
+	} else if c.standby(ctx) {
+		c.followActive(ctx)
+	} else if c.isCertificateValid() {
 		c.logger.Info("Valid certificate already exists, skipping initial download")
-	} else {
+	} else if !c.frozen() && c.certificateMissing() && c.config.StartupRetry.Delay > 0 {
+		// Nothing is being served yet, so keep retrying instead of waiting for the ticker
+		c.logger.Info("No certificate installed, retrying until the first certificate is obtained")
+		if err := c.obtainFirstCertificate(ctx); err != nil {
+			return fmt.Errorf("failed to request certificate: %w", err)
+		}
+	} else if !c.frozen() {
 		// Request new certificate (file missing or expired)
 		c.logger.Info("Certificate needs to be downloaded (missing or invalid)")
-		if err := c.requestCertificate(ctx); err != nil {
+		if err := c.renew(ctx); err != nil {
+			c.handleExpired(ctx)
 			return fmt.Errorf("failed to request certificate: %w", err)
 		}
 	}
+	c.recordCurrent()
+	c.writeCalendar()
+
+	// Schedule the next check for when the certificate is due for renewal
+	checks := time.NewTimer(c.nextCheck())
+	defer checks.Stop()
+
+	// Keep the OCSP staple file fresh (only if stapling is enabled)
+	var staples <-chan time.Time
+	stapleTimer := time.NewTimer(0)
+	defer stapleTimer.Stop()
+	if c.config.OCSPStaple {
+		staples = stapleTimer.C
+	}
 
-	// Start renewal ticker
-	ticker := time.NewTicker(c.config.RenewalInterval)
-	defer ticker.Stop()
+	// Check the installed certificate for revocation (only if enabled)
+	var revocations <-chan time.Time
+	revocationTimer := time.NewTimer(0)
+	defer revocationTimer.Stop()
+	if c.config.RevocationCheck > 0 {
+		revocations = revocationTimer.C
+	}
+
+	// Start report ticker (only if scheduled reports are enabled)
+	var reports <-chan time.Time
+	if c.email != nil && c.config.Report.Interval > 0 {
+		reportTicker := time.NewTicker(c.config.Report.Interval)
+		defer reportTicker.Stop()
+		reports = reportTicker.C
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
 			c.logger.Info("IPSSL client stopped")
 			return ctx.Err()
-		case <-ticker.C:
-			if !c.isCertificateValid() {
+		case <-staples:
+			stapleTimer.Reset(c.refreshStaple(ctx))
+		case <-revocations:
+			if c.checkRevocation(ctx) {
+				checks.Reset(0)
+			}
+			revocationTimer.Reset(c.config.RevocationCheck)
+		case <-reports:
+			if err := c.sendReport(); err != nil {
+				c.logger.Error("Failed to send certificate report", "error", err)
+			}
+		case <-checks.C:
+			var retry time.Duration
+			var failure error
+			c.recordCheck()
+			c.pruneHistory()
+			if c.applyOverride(ctx) {
+				c.logger.Info("Override certificate in effect, skipping renewal")
+			} else if c.standby(ctx) {
+				c.followActive(ctx)
+			} else if c.isCertificateValid() {
+				c.logger.Info("Certificate is still valid, skipping renewal")
+			} else if !c.frozen() {
 				c.logger.Info("Certificate needs renewal (missing, expired, or expiring soon)")
-				if err := c.requestCertificate(ctx); err != nil {
+				if err := c.renew(ctx); err != nil {
 					c.logger.Error("Failed to renew certificate", "error", err)
-					continue
+					failure = err
+					c.handleExpired(ctx)
+					if errors.Is(err, errRenewalTimeout) {
+						// A stuck cycle says nothing about the certificate authority, so retry soon
+						retry = c.config.RenewalRetry
+					}
 				}
+			}
+			c.recordCurrent()
+			c.writeCalendar()
+			if staples != nil {
+				// A renewal or rollback may have replaced the stapled certificate
+				stapleTimer.Reset(0)
+			}
+			if retry > 0 {
+				c.logger.Info("Retrying renewal after watchdog timeout", "next_check", time.Now().Add(retry).Round(time.Second))
+				c.planCheck(retry, &backoffState{Reason: backoffRenewalTimeout, Error: failure.Error()})
+				checks.Reset(retry)
 			} else {
-				c.logger.Info("Certificate is still valid, skipping renewal")
+				wait := c.nextCheck()
+				if failure != nil {
+					c.planCheck(wait, &backoffState{Reason: backoffRenewalFailed, Error: failure.Error()})
+				}
+				checks.Reset(wait)
 			}
 		}
 	}
 }
 
+// logUsage logs the account's certificate quota usage if the provider reports it
+func (c *Client) logUsage(ctx context.Context) {
+	reporter, ok := c.provider.(usageReporter)
+	if !ok {
+		return
+	}
+	usage, err := reporter.Usage(ctx)
+	if err != nil {
+		c.logger.Warn("Failed to query account usage", "error", err, "provider", c.config.Provider)
+		return
+	}
+	c.logger.Info("Account certificate usage", "issued", usage.Issued, "pending", usage.Pending, "limit", usage.Limit)
+	if usage.Exhausted() {
+		c.logger.Warn("Account certificate quota exhausted, new certificates will be refused", "used", usage.Used(), "limit", usage.Limit)
+	}
+}
+
 // ensureDirectories ensures that required directories exist
 func (c *Client) ensureDirectories() error {
-	dirs := []string{
-		c.config.SSLDir,
-		c.config.ValidationDir,
-		filepath.Join(c.config.ValidationDir, ".well-known", "pki-validation"),
+	dirs := []string{c.config.SSLDir, c.config.StateDir}
+	for _, dir := range c.config.ValidationDirs {
+		dirs = append(dirs, dir, filepath.Join(dir, ".well-known", "pki-validation"))
 	}
 
 	for _, dir := range dirs {
-		if err := os.MkdirAll(dir, 0755); err != nil {
+		if err := c.files.MkdirAll(dir, 0755); err != nil {
 			return fmt.Errorf("failed to create directory %s: %w", dir, err)
 		}
 	}
@@ -114,11 +450,11 @@ func (c *Client) certificateFilesExist() (bool, string) {
 	certPath := filepath.Join(c.config.SSLDir, "cert.pem")
 	keyPath := filepath.Join(c.config.SSLDir, "key.pem")
 
-	if _, err := os.Stat(certPath); os.IsNotExist(err) {
+	if _, err := c.files.Stat(certPath); os.IsNotExist(err) {
 		return false, "certificate file missing"
 	}
 
-	if _, err := os.Stat(keyPath); os.IsNotExist(err) {
+	if _, err := c.files.Stat(keyPath); os.IsNotExist(err) {
 		return false, "private key file missing"
 	}
 
@@ -137,7 +473,7 @@ func (c *Client) isCertificateValid() bool {
 	}
 
 	// Check certificate validity (expiration, etc.)
-	valid, err := c.zerossl.IsCertificateValid(certPath, c.config.CertValidity)
+	valid, err := c.provider.IsCertificateValid(certPath, c.config.CertValidity)
 	if err != nil {
 		c.logger.Error("Failed to check certificate validity", "error", err, "cert_path", certPath)
 		return false
@@ -145,58 +481,386 @@ func (c *Client) isCertificateValid() bool {
 
 	if !valid {
 		c.logger.Info("Certificate is expired or will expire soon, will download new certificate", "cert_path", certPath)
+		return false
+	}
+
+	// The remaining checks share one parse of the installed certificate
+	cert, err := c.readCertificate(certPath)
+	if err != nil {
+		c.logger.Error("Failed to parse certificate", "error", err, "cert_path", certPath)
+		return false
 	}
 
-	return valid
+	// A self-signed stopgap is only a placeholder until renewal succeeds
+	if isStopgap(cert) {
+		c.logger.Info("Self-signed stopgap certificate installed, will download new certificate")
+		return false
+	}
+
+	// Renew once the configured fraction of the lifetime has elapsed
+	if due := c.renewalDue(cert); !time.Now().Before(due) {
+		c.logger.Info("Certificate is due for renewal, will download new certificate", "renewal_due", due, "not_after", cert.NotAfter)
+		return false
+	}
+
+	// A certificate that misses a configured identifier must be reissued
+	if missing := c.missingIdentifiers(cert); len(missing) > 0 {
+		c.logger.Info("Certificate does not cover all configured identifiers, will download new certificate", "missing", missing)
+		return false
+	}
+
+	// A changed IPSSL_KEY_TYPE is adopted at the next check rather than at expiry
+	if installed := keys.TypeOf(cert.PublicKey); installed != "" && installed != c.keyType {
+		c.logger.Info("Certificate key type differs from the configured key type, will download new certificate", "installed", installed, "key_type", c.keyType)
+		return false
+	}
+
+	// A certificate reported revoked is reissued right away with IPSSL_REVOKED_REISSUE
+	if c.isRevoked(fmt.Sprintf("%x", cert.SerialNumber)) {
+		c.logger.Info("Certificate has been revoked, will download new certificate", "serial", c.revokedSerial)
+		return false
+	}
+
+	return true
+}
+
+// missingIdentifiers returns the configured identifiers not covered by cert
+func (c *Client) missingIdentifiers(cert *x509.Certificate) []string {
+	var missing []string
+	for _, identifier := range c.config.Identifiers() {
+		if cert.VerifyHostname(identifier) != nil {
+			missing = append(missing, identifier)
+		}
+	}
+	return missing
+}
+
+// readCertificate parses the leaf certificate of a PEM file
+func (c *Client) readCertificate(certPath string) (*x509.Certificate, error) {
+	certPEM, err := c.files.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode PEM block")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// renew runs a full renewal cycle and checks the time from detecting that renewal
+// is needed until the new certificate is deployed and verified against the latency budget
+func (c *Client) renew(ctx context.Context) error {
+	cycleID := c.logger.StartCycle()
+	defer c.logger.EndCycle()
+
+	started := time.Now()
+	cycleCtx, cancel := c.watchdog(ctx)
+	defer cancel()
+	if err := c.requestCertificate(cycleCtx); err != nil {
+		err = c.timedOut(cycleCtx, err)
+		c.reportFailure(ctx, started, err)
+		return err
+	}
+	if !c.isCertificateValid() {
+		err := failStep(stepVerify, fmt.Errorf("installed certificate failed verification after renewal"))
+		if c.rollbackAfter(ctx, err) {
+			c.updateState(func(st *state.State) {
+				if st.LastRenewal != nil {
+					st.LastRenewal.Success = false
+					st.LastRenewal.Error = err.Error()
+					st.LastRenewal.RolledBack = true
+				}
+			})
+		}
+		c.reportFailure(ctx, started, err)
+		return err
+	}
+	c.clearFailureReport()
+
+	elapsed := time.Since(started)
+	c.metrics.ObserveRenewalDuration(elapsed, cycleID)
+	c.logger.Info("Renewal cycle completed", "duration", elapsed)
+
+	if budget := c.config.RenewalBudget; budget > 0 && elapsed > budget {
+		c.recordBudgetExceeded()
+		c.logger.Warn("Renewal cycle exceeded latency budget", "duration", elapsed, "budget", budget)
+		c.alert("IPSSL renewal exceeded latency budget",
+			fmt.Sprintf("Renewal of %s took %s, exceeding the budget of %s.\n", strings.Join(c.config.Identifiers(), ", "), elapsed.Round(time.Second), budget))
+	}
+	return nil
 }
 
-// requestCertificate requests a new certificate from ZeroSSL
-func (c *Client) requestCertificate(ctx context.Context) error {
-	c.logger.Info("Requesting new certificate", "ip", c.config.ClientIP)
+// requestCertificate requests a new certificate from the configured provider
+func (c *Client) requestCertificate(ctx context.Context) (err error) {
+	renewal := &state.Renewal{CycleID: c.logger.CycleID(), Time: time.Now()}
+	defer func() { c.recordRenewal(renewal, err) }()
 
-	// Request certificate from ZeroSSL
-	cert, key, err := c.zerossl.RequestCertificate(ctx, c.config.ClientIP)
+	identifiers := c.config.Identifiers()
+	c.logger.Info("Requesting new certificate", "primary", c.config.Primary(), "identifiers", identifiers)
+
+	// Guard against issuing for an address picked up by mistake, such as a
+	// VPN or NAT address
+	for _, ip := range c.config.ClientIPs {
+		if err := c.config.CheckIP(ip); err != nil {
+			return failStep(stepIssue, fmt.Errorf("refusing to request a certificate: %w", err))
+		}
+	}
+	c.checkOwnership(ctx)
+
+	// Request certificate from the provider
+	cert, key, err := c.provider.RequestCertificate(ctx, identifiers, c.keyType)
 	if err != nil {
-		return fmt.Errorf("failed to request certificate from ZeroSSL: %w", err)
+		return failStep(stepIssue, fmt.Errorf("failed to request certificate from %s: %w", c.config.Provider, err))
 	}
 
-	// Log certificate chain information
-	certStr := string(cert)
-	// Count certificate blocks (each certificate starts with -----BEGIN CERTIFICATE-----)
-	certBlocks := 0
-	beginMarker := "-----BEGIN CERTIFICATE-----"
-	for i := 0; i < len(certStr)-len(beginMarker); i++ {
-		if certStr[i:i+len(beginMarker)] == beginMarker {
-			certBlocks++
+	// Install the chain in a clean form whatever form the provider returned it in
+	certs, err := chain.Parse(cert)
+	if err != nil {
+		return failStep(stepIssue, fmt.Errorf("invalid certificate chain from %s: %w", c.config.Provider, err))
+	}
+	cert = chain.Encode(certs)
+	c.logger.Info("Certificate chain received", "total_certificates", len(certs), "cert_size_bytes", len(cert))
+
+	// CAs may silently drop the requested Must-Staple extension
+	if c.config.MustStaple && !keys.HasMustStaple(certs[0]) {
+		c.logger.Warn("OCSP Must-Staple was requested but the issued certificate does not require stapling", "provider", c.config.Provider)
+	}
+
+	if err := c.verifyChain(certs, identifiers); err != nil {
+		return failStep(stepIssue, err)
+	}
+	if c.config.VerifyCT {
+		c.verifyCT(certs)
+	}
+
+	// A key that does not belong to the certificate breaks TLS once installed
+	if _, err := tls.X509KeyPair(cert, key); err != nil {
+		return failStep(stepIssue, fmt.Errorf("%w from %s: %v", zerossl.ErrKeyMismatch, c.config.Provider, err))
+	}
+
+	return c.installCertificate(ctx, renewal, cert, key)
+}
+
+// installCertificate saves the certificate and key into the SSL directory,
+// reloads the container and runs the deployers. The serial, expiry and
+// deployer results are recorded in renewal.
+func (c *Client) installCertificate(ctx context.Context, renewal *state.Renewal, cert, key []byte) error {
+	// Some servers and libraries only accept PKCS#8 private keys
+	if c.config.KeyFormat == keys.FormatPKCS8 {
+		converted, err := keys.ConvertPEM(key, keys.FormatPKCS8)
+		if err != nil {
+			return failStep(stepSave, fmt.Errorf("failed to encode private key as PKCS#8: %w", err))
 		}
+		key = converted
 	}
-	c.logger.Info("Certificate chain received", "total_certificates", certBlocks, "cert_size_bytes", len(cert))
 
 	// Save certificate files
 	certPath := filepath.Join(c.config.SSLDir, "cert.pem")
 	keyPath := filepath.Join(c.config.SSLDir, "key.pem")
 
-	if err := os.WriteFile(certPath, cert, 0644); err != nil {
-		return fmt.Errorf("failed to save certificate: %w", err)
+	// Hooks can skip their work when the same certificate is installed again
+	if renewal.PreviousSerial == "" {
+		if previous, err := c.readCertificate(certPath); err == nil {
+			renewal.PreviousSerial = fmt.Sprintf("%x", previous.SerialNumber)
+		}
+	}
+
+	// Keep the installed pair so that a failed installation can be rolled back
+	if err := c.backupCertificate(); err != nil {
+		return failStep(stepSave, fmt.Errorf("failed to back up the installed certificate: %w", err))
 	}
 
-	if err := os.WriteFile(keyPath, key, 0600); err != nil {
-		return fmt.Errorf("failed to save private key: %w", err)
+	// Files are replaced atomically so that the web server never loads a
+	// truncated certificate or key. They are two renames, so a server that
+	// reads them in between sees the new certificate with the old key; the
+	// container is only reloaded once both are in place.
+	certFile, err := c.certFile(cert)
+	if err != nil {
+		return failStep(stepSave, err)
+	}
+	if err := c.writeArtifact(certPath, certFile, false); err != nil {
+		return failStep(stepSave, fmt.Errorf("failed to save certificate: %w", err))
+	}
+
+	// From here on the new certificate has replaced the previous one, which is
+	// restored if any step up to the verification in the container fails
+	if err := c.writeArtifact(keyPath, key, true); err != nil {
+		err = fmt.Errorf("failed to save private key: %w", err)
+		renewal.RolledBack = c.rollbackAfter(ctx, err)
+		return failStep(stepSave, err)
+	}
+	if err := c.writeOutputs(cert, key); err != nil {
+		renewal.RolledBack = c.rollbackAfter(ctx, err)
+		return failStep(stepSave, err)
+	}
+	written := []writtenFile{{"cert.pem", certFile}, {"key.pem", key}}
+	if c.config.VerifyWrites {
+		if err := c.verifyWrites(written); err != nil {
+			renewal.RolledBack = c.rollbackAfter(ctx, err)
+			return failStep(stepSave, err)
+		}
+	}
+	if c.ocsp != nil {
+		c.stapleInstalled(ctx, cert)
+	}
+	if block, _ := pem.Decode(cert); block != nil {
+		if leaf, err := x509.ParseCertificate(block.Bytes); err == nil {
+			renewal.Serial = fmt.Sprintf("%x", leaf.SerialNumber)
+			renewal.NotAfter = leaf.NotAfter
+		}
 	}
 
 	c.logger.Info("Certificate saved successfully",
 		"cert_path", certPath,
 		"key_path", keyPath)
 
-	// Reload Caddy container (only if Docker client is available)
-	if c.docker != nil && c.config.ContainerName != "" {
-		if err := c.docker.ReloadContainer(ctx, c.config.ContainerName); err != nil {
-			c.logger.Error("Failed to reload Caddy container", "error", err)
-			// Don't return error here as certificate was saved successfully
+	// Timestamp the issued certificate for audit trails (optional)
+	if c.tsa != nil {
+		c.timestampCertificate(ctx, certPath, certFile)
+	}
+
+	// Reload Caddy container (only if Docker client is available or a trigger file is used)
+	if c.canReload() {
+		if err := c.reloadContainer(ctx, renewal); err != nil {
+			if ctx.Err() != nil {
+				err = fmt.Errorf("container reload interrupted: %w", ctx.Err())
+				renewal.RolledBack = c.rollbackAfter(ctx, err)
+				return failStep(stepReload, err)
+			}
+			c.logger.Error("Failed to reload Caddy container", "error", err, "strategy", c.config.ReloadStrategy)
+			renewal.RolledBack = c.rollbackAfter(ctx, err)
+			return failStep(stepReload, fmt.Errorf("failed to reload container: %w", err))
+		} else if c.config.ReloadStrategy == config.ReloadSignal && c.config.ReloadFallback == config.FallbackRestart && c.docker != nil {
+			if err := c.ensureServed(ctx, renewal.Serial); err != nil {
+				if ctx.Err() != nil {
+					err = fmt.Errorf("container reload interrupted: %w", ctx.Err())
+					renewal.RolledBack = c.rollbackAfter(ctx, err)
+					return failStep(stepReload, err)
+				}
+				c.logger.Error("New certificate is not served after fallback restart", "error", err)
+				renewal.RolledBack = c.rollbackAfter(ctx, err)
+				return failStep(stepReload, fmt.Errorf("new certificate is not served: %w", err))
+			}
 		}
 	} else {
 		c.logger.Info("Skipping container reload - Docker client not available or no container name specified")
 	}
 
+	// The server only sees the new files if the SSL directory is mounted where it reads them
+	if c.config.ContainerSSLDir != "" && c.docker != nil {
+		if err := c.verifyContainerFiles(ctx, written); err != nil {
+			if ctx.Err() != nil {
+				err = fmt.Errorf("container file verification interrupted: %w", ctx.Err())
+				renewal.RolledBack = c.rollbackAfter(ctx, err)
+				return failStep(stepVerify, err)
+			}
+			c.logger.Error("Certificate files in the container differ from the written files", "error", err)
+			renewal.RolledBack = c.rollbackAfter(ctx, err)
+			return failStep(stepVerify, err)
+		}
+	}
+
+	// Run additional deployers
+	bundle := &deploy.Bundle{
+		IP:       c.config.Primary(),
+		CertPath: c.chainPath(),
+		KeyPath:  keyPath,
+		Cert:     cert,
+		Key:      key,
+		CycleID:  renewal.CycleID,
+
+		Serial:         renewal.Serial,
+		PreviousSerial: renewal.PreviousSerial,
+	}
+	if len(c.deployers) == 0 {
+		return nil
+	}
+	results := deploy.Run(ctx, c.deployers, bundle, deploy.RunOptions{
+		Concurrency: c.config.DeployWorkers,
+		Retries:     c.config.DeployRetries,
+		RetryDelay:  c.config.DeployBackoff,
+	}, c.logger)
+
+	var failed []string
+	for _, r := range results {
+		// A cancellation can stop deployers before their first attempt
+		if r.Attempts == 0 {
+			continue
+		}
+		result := state.DeployResult{Name: r.Name, Success: r.Err == nil, Attempts: r.Attempts}
+		if r.Err != nil {
+			result.Error = r.Err.Error()
+			failed = append(failed, fmt.Sprintf("%s: %v", r.Name, r.Err))
+			c.metrics.DeployerFailed(r.Name)
+		}
+		renewal.Deployers = append(renewal.Deployers, result)
+	}
+	if ctx.Err() != nil {
+		// Roll back as after every other failure once the files are written:
+		// with the new certificate left in place the next check would find it
+		// valid and never deploy it to the interrupted deployers
+		err := fmt.Errorf("deployment interrupted: %w", ctx.Err())
+		renewal.RolledBack = c.rollbackAfter(ctx, err)
+		return failStep(stepDeploy, err)
+	}
+	if len(failed) > 0 {
+		c.logger.Warn("Deployment partially failed", "failed", len(failed), "total", len(results))
+		c.alert("IPSSL deployment partially failed",
+			fmt.Sprintf("%d of %d deployers failed after retries:\n\n%s\n", len(failed), len(results), strings.Join(failed, "\n")))
+	}
+
+	return nil
+}
+
+// timestampCertificate stores an RFC 3161 timestamp over the certificate file next to it
+func (c *Client) timestampCertificate(ctx context.Context, certPath string, cert []byte) {
+	token, err := c.tsa.Timestamp(ctx, cert)
+	if err != nil {
+		c.logger.Error("Failed to timestamp certificate", "error", err)
+		return
+	}
+	tsrPath := certPath + ".tsr"
+	if err := c.files.WriteFile(tsrPath, token.Response, 0644); err != nil {
+		c.logger.Error("Failed to save timestamp response", "error", err, "path", tsrPath)
+		return
+	}
+	c.logger.Info("Certificate timestamp saved", "path", tsrPath, "gen_time", token.GenTime)
+}
+
+// reloadContainer applies the configured reload strategy to the target container
+func (c *Client) reloadContainer(ctx context.Context, renewal *state.Renewal) error {
+	if c.stoppedExpired && c.docker != nil {
+		// A container stopped over an expired certificate cannot be signalled
+		if err := c.docker.RestartContainer(ctx, c.config.ContainerName); err != nil {
+			return err
+		}
+		c.stoppedExpired = false
+		return nil
+	}
+
+	switch c.config.ReloadStrategy {
+	case config.ReloadTrigger:
+		return c.writeReloadTrigger(renewal)
+	case config.ReloadRestart:
+		return c.docker.RestartContainer(ctx, c.config.ContainerName)
+	case config.ReloadBlueGreen:
+		return c.docker.BlueGreenDeploy(ctx, c.config.ContainerName, c.config.HealthTimeout)
+	default:
+		return c.docker.ReloadContainer(ctx, c.config.ContainerName)
+	}
+}
+
+// writeReloadTrigger replaces the trigger file watched by a sidecar in the target
+// container. The file is renamed into place so that watchers never see partial
+// content, and it records the renewal for the sidecar's logs.
+func (c *Client) writeReloadTrigger(renewal *state.Renewal) error {
+	path := c.config.ReloadFile
+	content := fmt.Sprintf("time=%s\nserial=%s\ncycle_id=%s\n", time.Now().UTC().Format(time.RFC3339), renewal.Serial, renewal.CycleID)
+
+	if err := fsys.WriteFileAtomic(c.files, path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write reload trigger: %w", err)
+	}
+	c.logger.Info("Reload trigger written", "path", path)
 	return nil
 }
@@ -0,0 +1,1429 @@
+package ipssl
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/deploy"
+	"ipssl-client/internal/fsys"
+	"ipssl-client/internal/keys"
+	"ipssl-client/internal/logger"
+	"ipssl-client/internal/metrics"
+	"ipssl-client/internal/ocsp"
+	"ipssl-client/internal/state"
+	"ipssl-client/internal/zerossl"
+)
+
+// fakeProvider issues self-signed certificates and records calls
+type fakeProvider struct {
+	err        error
+	requested  [][]string
+	revoked    [][]byte
+	validUntil time.Time
+}
+
+func (f *fakeProvider) SupportedKeyTypes() []string {
+	return []string{keys.ECDSAP256}
+}
+
+func (f *fakeProvider) RequestCertificate(ctx context.Context, identifiers []string, keyType string) ([]byte, []byte, error) {
+	f.requested = append(f.requested, identifiers)
+	if f.err != nil {
+		return nil, nil, f.err
+	}
+
+	signer, err := keys.Generate(keyType)
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now(),
+		NotAfter:     f.validUntil,
+	}
+	for _, identifier := range identifiers {
+		if ip := net.ParseIP(identifier); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, identifier)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, signer.Public(), signer)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := keys.EncodePEM(signer)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), keyPEM, nil
+}
+
+func (f *fakeProvider) RevokeCertificate(ctx context.Context, certPEM []byte) error {
+	f.revoked = append(f.revoked, certPEM)
+	return nil
+}
+
+func (f *fakeProvider) IsCertificateValid(certPath string, validityDuration time.Duration) (bool, error) {
+	return time.Now().Add(validityDuration).Before(f.validUntil), nil
+}
+
+func newTestClient(t *testing.T, provider CertificateProvider) *Client {
+	t.Helper()
+	dir := t.TempDir()
+	return &Client{
+		config: &config.Config{
+			ClientIPs:    []string{"192.0.2.1"},
+			Domains:      []string{"example.com"},
+			SSLDir:       dir,
+			StateDir:     dir,
+			CertValidity: 30 * 24 * time.Hour,
+		},
+		logger:   logger.New(),
+		provider: provider,
+		keyType:  keys.ECDSAP256,
+		files:    fsys.OS,
+		metrics:  metrics.New(),
+	}
+}
+
+func TestRequestCertificate(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+
+	if client.isCertificateValid() {
+		t.Fatal("Expected missing certificate to be invalid")
+	}
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+
+	if len(provider.requested) != 1 || len(provider.requested[0]) != 2 {
+		t.Fatalf("Expected one request for both identifiers, got %v", provider.requested)
+	}
+	if !client.isCertificateValid() {
+		t.Error("Expected issued certificate to be valid")
+	}
+
+	st, err := state.Load(filepath.Join(client.config.SSLDir, state.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.LastRenewal == nil || !st.LastRenewal.Success {
+		t.Errorf("Expected successful renewal to be recorded, got %+v", st.LastRenewal)
+	}
+}
+
+func TestRequestCertificateFailure(t *testing.T) {
+	provider := &fakeProvider{err: errors.New("rate limited")}
+	client := newTestClient(t, provider)
+
+	if err := client.requestCertificate(context.Background()); err == nil {
+		t.Fatal("Expected error from failing provider, got nil")
+	}
+	if _, err := os.Stat(filepath.Join(client.config.SSLDir, "cert.pem")); !os.IsNotExist(err) {
+		t.Error("Expected no certificate to be written")
+	}
+
+	st, err := state.Load(filepath.Join(client.config.SSLDir, state.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.LastRenewal == nil || st.LastRenewal.Success || st.LastRenewal.Error == "" {
+		t.Errorf("Expected failed renewal to be recorded, got %+v", st.LastRenewal)
+	}
+}
+
+func TestIsCertificateValidMissingIdentifier(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	client.config.Domains = append(client.config.Domains, "www.example.com")
+	if client.isCertificateValid() {
+		t.Error("Expected certificate missing a configured identifier to be invalid")
+	}
+}
+
+func TestIsCertificateValidExpiring(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(10 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if client.isCertificateValid() {
+		t.Error("Expected certificate inside the renewal window to be invalid")
+	}
+}
+
+func TestRevokeCertificate(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.RevokeCertificate(context.Background()); err != nil {
+		t.Fatalf("RevokeCertificate failed: %v", err)
+	}
+	if len(provider.revoked) != 1 {
+		t.Errorf("Expected one revocation, got %d", len(provider.revoked))
+	}
+}
+
+func TestRenewFailsVerification(t *testing.T) {
+	// A freshly issued certificate that is already inside the renewal window fails verification
+	provider := &fakeProvider{validUntil: time.Now().Add(10 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	client.config.RenewalBudget = time.Nanosecond
+
+	if err := client.renew(context.Background()); err == nil {
+		t.Fatal("Expected verification error, got nil")
+	}
+
+	data, err := os.ReadFile(filepath.Join(client.config.SSLDir, FailureReportFile))
+	if err != nil {
+		t.Fatalf("Expected failure report: %v", err)
+	}
+	var r FailureReport
+	if err := json.Unmarshal(data, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Step != stepVerify || r.ErrorClass != errorLocal || r.CycleID == "" {
+		t.Errorf("Unexpected failure report: %+v", r)
+	}
+}
+
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		name  string
+		step  string
+		err   error
+		calls []metrics.APICall
+		want  string
+	}{
+		{"timeout", stepIssue, fmt.Errorf("wait: %w", context.DeadlineExceeded), nil, errorTimeout},
+		{"last failed call", stepIssue, errors.New("failed"), []metrics.APICall{{Class: metrics.ErrorServer}, {Class: metrics.ErrorRateLimit}, {}}, metrics.ErrorRateLimit},
+		{"validation", stepIssue, errors.New("domain validation failed"), []metrics.APICall{{}}, errorValidation},
+		{"local step", stepSave, errors.New("disk full"), []metrics.APICall{{Class: metrics.ErrorServer}}, errorLocal},
+		{"unknown", stepIssue, errors.New("failed"), nil, errorUnknown},
+		{"typed quota", stepIssue, fmt.Errorf("request: %w", zerossl.ErrQuotaExceeded), []metrics.APICall{{Class: metrics.ErrorClient}}, errorQuota},
+		{"typed rate limit", stepIssue, zerossl.ErrRateLimited, nil, metrics.ErrorRateLimit},
+		{"key mismatch", stepIssue, fmt.Errorf("%w from zerossl", zerossl.ErrKeyMismatch), []metrics.APICall{{Class: metrics.ErrorServer}}, errorKey},
+	}
+	for _, tt := range tests {
+		if got := classifyFailure(tt.step, tt.err, tt.calls); got != tt.want {
+			t.Errorf("%s: classifyFailure() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRequestCertificateReloadTrigger(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	client.config.ReloadStrategy = config.ReloadTrigger
+	client.config.ReloadFile = filepath.Join(client.config.SSLDir, "reload.trigger")
+
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+
+	data, err := os.ReadFile(client.config.ReloadFile)
+	if err != nil {
+		t.Fatalf("Expected reload trigger to be written: %v", err)
+	}
+	if !strings.Contains(string(data), "serial=") {
+		t.Errorf("Unexpected reload trigger content %q", data)
+	}
+}
+
+func TestWaitForServed(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	client := newTestClient(t, &fakeProvider{})
+	client.config.TLSProbeAddr = server.Listener.Addr().String()
+	client.config.TLSProbeTimeout = time.Second
+
+	serial := fmt.Sprintf("%x", server.Certificate().SerialNumber)
+	if err := client.waitForServed(context.Background(), serial); err != nil {
+		t.Errorf("Expected served certificate to match, got %v", err)
+	}
+	if err := client.waitForServed(context.Background(), "00"); err == nil {
+		t.Error("Expected error for a certificate that is not served, got nil")
+	}
+}
+
+func TestFrozen(t *testing.T) {
+	client := newTestClient(t, &fakeProvider{})
+	st := &state.State{Freeze: &state.Freeze{Until: time.Now().Add(time.Hour), Reason: "year-end change freeze"}}
+	if err := st.Save(client.statePath()); err != nil {
+		t.Fatal(err)
+	}
+	if !client.frozen() {
+		t.Fatal("Expected active freeze to suspend renewal")
+	}
+
+	st.Freeze.Until = time.Now().Add(-time.Minute)
+	if err := st.Save(client.statePath()); err != nil {
+		t.Fatal(err)
+	}
+	if client.frozen() {
+		t.Fatal("Expected expired freeze not to suspend renewal")
+	}
+	if st, err := state.Load(client.statePath()); err != nil || st.Freeze != nil {
+		t.Errorf("Expected expired freeze to be cleared, got %+v (%v)", st, err)
+	}
+	audit, err := os.ReadFile(filepath.Join(client.config.SSLDir, state.AuditFileName))
+	if err != nil || !strings.Contains(string(audit), state.AuditFreezeExpire) {
+		t.Errorf("Expected freeze expiry in audit log, got %q (%v)", audit, err)
+	}
+}
+
+func TestApplyOverride(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	client.config.OverrideDir = t.TempDir()
+
+	if client.applyOverride(context.Background()) {
+		t.Fatal("Expected no override without override files")
+	}
+
+	cert, key, err := provider.RequestCertificate(context.Background(), []string{"192.0.2.1"}, keys.ECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(client.config.OverrideDir, "cert.pem"), cert, 0644)
+	os.WriteFile(filepath.Join(client.config.OverrideDir, "key.pem"), key, 0600)
+
+	if !client.applyOverride(context.Background()) {
+		t.Fatal("Expected override to be in effect")
+	}
+	installed, err := os.ReadFile(filepath.Join(client.config.SSLDir, "cert.pem"))
+	if err != nil || !bytes.Equal(installed, cert) {
+		t.Errorf("Expected override certificate to be installed (%v)", err)
+	}
+	st, err := state.Load(client.statePath())
+	if err != nil || st.LastRenewal == nil || !st.LastRenewal.Override {
+		t.Errorf("Expected override installation to be recorded, got %+v (%v)", st, err)
+	}
+
+	// An expired override is ignored so that automation resumes
+	provider.validUntil = time.Now().Add(-time.Hour)
+	cert, key, _ = provider.RequestCertificate(context.Background(), []string{"192.0.2.1"}, keys.ECDSAP256)
+	os.WriteFile(filepath.Join(client.config.OverrideDir, "cert.pem"), cert, 0644)
+	os.WriteFile(filepath.Join(client.config.OverrideDir, "key.pem"), key, 0600)
+	if client.applyOverride(context.Background()) {
+		t.Error("Expected expired override to be ignored")
+	}
+}
+
+func TestHandleExpiredSelfSigned(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(-time.Hour)}
+	client := newTestClient(t, provider)
+	client.config.ExpiredAction = config.ExpiredSelfSigned
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	client.handleExpired(context.Background())
+
+	cert, err := client.readCertificate(filepath.Join(client.config.SSLDir, "cert.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isStopgap(cert) || time.Now().After(cert.NotAfter) {
+		t.Fatalf("Expected an unexpired stopgap certificate, got %v valid until %s", cert.Subject, cert.NotAfter)
+	}
+	if client.missingIdentifiers(cert) != nil {
+		t.Error("Expected the stopgap to cover all identifiers")
+	}
+	provider.validUntil = cert.NotAfter.Add(90 * 24 * time.Hour)
+	if client.isCertificateValid() {
+		t.Error("Expected the stopgap certificate to need renewal")
+	}
+}
+
+// flakyProvider fails a number of requests before delegating to fakeProvider
+type flakyProvider struct {
+	*fakeProvider
+	failures int
+}
+
+func (f *flakyProvider) RequestCertificate(ctx context.Context, identifiers []string, keyType string) ([]byte, []byte, error) {
+	if f.failures > 0 {
+		f.failures--
+		f.requested = append(f.requested, identifiers)
+		return nil, nil, errors.New("service unavailable")
+	}
+	return f.fakeProvider.RequestCertificate(ctx, identifiers, keyType)
+}
+
+func TestObtainFirstCertificate(t *testing.T) {
+	provider := &flakyProvider{fakeProvider: &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}, failures: 2}
+	client := newTestClient(t, provider)
+	client.config.StartupRetry = config.StartupRetry{Delay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+	if !client.certificateMissing() {
+		t.Fatal("Expected no certificate before the first issuance")
+	}
+	if err := client.obtainFirstCertificate(context.Background()); err != nil {
+		t.Fatalf("obtainFirstCertificate failed: %v", err)
+	}
+	if len(provider.requested) != 3 {
+		t.Errorf("Expected 3 issuance attempts, got %d", len(provider.requested))
+	}
+	if client.certificateMissing() {
+		t.Error("Expected the certificate to be installed")
+	}
+}
+
+func TestObtainFirstCertificateCanceled(t *testing.T) {
+	client := newTestClient(t, &fakeProvider{err: errors.New("service unavailable")})
+	client.config.StartupRetry = config.StartupRetry{Delay: time.Hour, MaxDelay: time.Hour}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := client.obtainFirstCertificate(ctx); err == nil {
+		t.Fatal("Expected an error once the context is done")
+	}
+}
+
+func TestStandbyFollowsActive(t *testing.T) {
+	active := newTestClient(t, &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)})
+	provider := &fakeProvider{}
+	standby := newTestClient(t, provider)
+	standby.config.SSLDir = active.config.SSLDir
+	standby.config.VRRP.CheckCommand = "exit 1"
+	standby.config.ReloadStrategy = config.ReloadTrigger
+	standby.config.ReloadFile = filepath.Join(t.TempDir(), "reload.trigger")
+	ctx := context.Background()
+
+	if !standby.standby(ctx) {
+		t.Fatal("Expected a failing check command to mean standby")
+	}
+	if err := active.requestCertificate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	standby.followActive(ctx)
+	if _, err := os.Stat(standby.config.ReloadFile); !os.IsNotExist(err) {
+		t.Fatal("Expected the certificate present at startup not to be reinstalled")
+	}
+
+	if err := active.installStopgap(ctx); err != nil {
+		t.Fatal(err)
+	}
+	standby.followActive(ctx)
+	if _, err := os.Stat(standby.config.ReloadFile); err != nil {
+		t.Errorf("Expected the new certificate from the active node to be reloaded: %v", err)
+	}
+	if len(provider.requested) != 0 {
+		t.Errorf("Expected the standby node never to request a certificate, got %d requests", len(provider.requested))
+	}
+
+	standby.config.VRRP.CheckCommand = "true"
+	if standby.standby(ctx) {
+		t.Error("Expected a succeeding check command to mean active")
+	}
+}
+
+func TestVRRPCheckEnvironment(t *testing.T) {
+	t.Setenv("IPSSL_API_KEY", "secret")
+	t.Setenv("KEEPALIVED_STATE", "MASTER")
+	client := newTestClient(t, &fakeProvider{})
+	client.config.VRRP.CheckCommand = `test -z "$IPSSL_API_KEY" && test "$KEEPALIVED_STATE" = MASTER`
+	client.config.HookEnv = []string{"KEEPALIVED_*"}
+
+	if client.standby(context.Background()) {
+		t.Error("Expected the check command to see only the allowed environment")
+	}
+}
+
+func TestRenewalDue(t *testing.T) {
+	client := newTestClient(t, &fakeProvider{})
+	notBefore := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cert := &x509.Certificate{NotBefore: notBefore, NotAfter: notBefore.Add(90 * 24 * time.Hour)}
+
+	if due := client.renewalDue(cert); !due.Equal(notBefore.Add(60 * 24 * time.Hour)) {
+		t.Errorf("Expected renewal 30 days before expiry without a fraction, got %s", due)
+	}
+
+	client.config.RenewalFraction = 0.5
+	if due := client.renewalDue(cert); !due.Equal(notBefore.Add(45 * 24 * time.Hour)) {
+		t.Errorf("Expected renewal halfway through the lifetime, got %s", due)
+	}
+
+	// A short-lived certificate is renewed by CertValidity if that comes first
+	client.config.CertValidity = 80 * 24 * time.Hour
+	if due := client.renewalDue(cert); !due.Equal(notBefore.Add(10 * 24 * time.Hour)) {
+		t.Errorf("Expected the earlier of both due times, got %s", due)
+	}
+}
+
+func TestNextCheck(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	client.config.RenewalInterval = 24 * time.Hour
+
+	if wait := client.nextCheck(); wait != 24*time.Hour {
+		t.Errorf("Expected RenewalInterval without a certificate, got %s", wait)
+	}
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if wait := client.nextCheck(); wait != 24*time.Hour {
+		t.Errorf("Expected RenewalInterval to cap the wait, got %s", wait)
+	}
+
+	client.config.CertValidity = 90*24*time.Hour - 2*time.Hour
+	if wait := client.nextCheck(); wait <= 0 || wait > 2*time.Hour {
+		t.Errorf("Expected to wake when renewal is due in about 2h, got %s", wait)
+	}
+}
+
+func TestEnsureDirectoriesReadOnly(t *testing.T) {
+	client := newTestClient(t, &fakeProvider{})
+	mem := fsys.NewMem()
+	mem.ReadOnly = true
+	client.files = mem
+
+	if err := client.ensureDirectories(); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Expected permission error, got %v", err)
+	}
+}
+
+func TestRequestCertificateReadOnly(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	mem := fsys.NewMem()
+	client.files = mem
+	if err := client.ensureDirectories(); err != nil {
+		t.Fatal(err)
+	}
+	mem.ReadOnly = true
+
+	err := client.requestCertificate(context.Background())
+	var se *stepError
+	if !errors.As(err, &se) || se.step != stepSave {
+		t.Fatalf("Expected the save step to fail, got %v", err)
+	}
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Expected permission error, got %v", err)
+	}
+	if exists, _ := client.certificateFilesExist(); exists {
+		t.Error("Expected no certificate files on the read-only filesystem")
+	}
+}
+
+func TestInstallCertificateAtomic(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+
+	for range 2 {
+		if err := client.requestCertificate(context.Background()); err != nil {
+			t.Fatalf("requestCertificate failed: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(client.config.SSLDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp-") {
+			t.Errorf("Expected no temporary files to remain, found %s", entry.Name())
+		}
+	}
+	info, err := os.Stat(filepath.Join(client.config.SSLDir, "key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected key mode 0600, got %v", info.Mode().Perm())
+	}
+}
+
+// messyProvider returns the certificate twice with Windows line endings
+type messyProvider struct {
+	fakeProvider
+}
+
+func (m *messyProvider) RequestCertificate(ctx context.Context, identifiers []string, keyType string) ([]byte, []byte, error) {
+	cert, key, err := m.fakeProvider.RequestCertificate(ctx, identifiers, keyType)
+	messy := bytes.ReplaceAll(bytes.TrimSpace(cert), []byte("\n"), []byte("\r\n"))
+	return append(messy, cert...), key, err
+}
+
+func TestRequestCertificateNormalizesChain(t *testing.T) {
+	provider := &messyProvider{fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}}
+	client := newTestClient(t, provider)
+
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(client.config.SSLDir, "cert.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(data, []byte("-----BEGIN CERTIFICATE-----")); n != 1 {
+		t.Errorf("Expected the duplicate certificate to be dropped, got %d", n)
+	}
+	if bytes.Contains(data, []byte("\r")) {
+		t.Error("Expected line endings to be normalized")
+	}
+}
+
+func TestRenewRollsBackFailedVerification(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	certPath := filepath.Join(client.config.SSLDir, "cert.pem")
+
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+	previous, err := os.ReadFile(certPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The replacement is already inside the renewal window
+	provider.validUntil = time.Now().Add(10 * 24 * time.Hour)
+	if err := client.renew(context.Background()); err == nil {
+		t.Fatal("Expected verification error, got nil")
+	}
+
+	if installed, err := os.ReadFile(certPath); err != nil || !bytes.Equal(installed, previous) {
+		t.Errorf("Expected the previous certificate to be restored (%v)", err)
+	}
+	st, err := state.Load(filepath.Join(client.config.StateDir, state.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.LastRenewal == nil || st.LastRenewal.Success || !st.LastRenewal.RolledBack {
+		t.Errorf("Expected a rolled back renewal to be recorded, got %+v", st.LastRenewal)
+	}
+}
+
+func TestInstallRollsBackFailedReload(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	certPath := filepath.Join(client.config.SSLDir, "cert.pem")
+
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+	previous, err := os.ReadFile(certPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.config.ReloadStrategy = config.ReloadTrigger
+	client.config.ReloadFile = filepath.Join(client.config.SSLDir, "missing", "reload.trigger")
+	err = client.requestCertificate(context.Background())
+	var se *stepError
+	if !errors.As(err, &se) || se.step != stepReload {
+		t.Fatalf("Expected the reload step to fail, got %v", err)
+	}
+	if installed, err := os.ReadFile(certPath); err != nil || !bytes.Equal(installed, previous) {
+		t.Errorf("Expected the previous certificate to be restored (%v)", err)
+	}
+}
+
+func TestInstallRollsBackFailedOutputs(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	certPath := filepath.Join(client.config.SSLDir, "cert.pem")
+	keyPath := filepath.Join(client.config.SSLDir, "key.pem")
+
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+	previousCert, _ := os.ReadFile(certPath)
+	previousKey, _ := os.ReadFile(keyPath)
+
+	// A directory in the way of cert.der fails the additional formats after cert.pem and key.pem are replaced
+	client.config.OutputFormats = []string{config.OutputPEM, config.OutputDER}
+	if err := os.Mkdir(filepath.Join(client.config.SSLDir, "cert.der"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	err := client.requestCertificate(context.Background())
+	var se *stepError
+	if !errors.As(err, &se) || se.step != stepSave {
+		t.Fatalf("Expected the save step to fail, got %v", err)
+	}
+	installedCert, _ := os.ReadFile(certPath)
+	installedKey, _ := os.ReadFile(keyPath)
+	if !bytes.Equal(installedCert, previousCert) || !bytes.Equal(installedKey, previousKey) {
+		t.Error("Expected the previous certificate and key to be restored")
+	}
+}
+
+// funcDeployer is a deployer running a function
+type funcDeployer struct {
+	name   string
+	deploy func(ctx context.Context) error
+}
+
+func (d *funcDeployer) Name() string { return d.name }
+func (d *funcDeployer) Deploy(ctx context.Context, bundle *deploy.Bundle) error {
+	return d.deploy(ctx)
+}
+
+func TestInstallRollsBackInterruptedDeployment(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	certPath := filepath.Join(client.config.SSLDir, "cert.pem")
+
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+	previous, _ := os.ReadFile(certPath)
+
+	// The shutdown arrives while the second deployer runs
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client.deployers = []deploy.Deployer{
+		&funcDeployer{name: "done", deploy: func(context.Context) error { return nil }},
+		&funcDeployer{name: "interrupted", deploy: func(ctx context.Context) error {
+			cancel()
+			return ctx.Err()
+		}},
+	}
+	err := client.requestCertificate(ctx)
+	var se *stepError
+	if !errors.As(err, &se) || se.step != stepDeploy {
+		t.Fatalf("Expected the deploy step to fail, got %v", err)
+	}
+
+	if installed, _ := os.ReadFile(certPath); !bytes.Equal(installed, previous) {
+		t.Error("Expected the previous certificate to be restored")
+	}
+	st, err := state.Load(filepath.Join(client.config.StateDir, state.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.LastRenewal == nil || !st.LastRenewal.RolledBack {
+		t.Fatalf("Expected a rolled back renewal to be recorded, got %+v", st.LastRenewal)
+	}
+	if got := st.LastRenewal.Deployers; len(got) != 2 || !got[0].Success || got[1].Success {
+		t.Errorf("Expected the finished deployers to be recorded, got %+v", got)
+	}
+}
+
+// mismatchedProvider returns a private key that does not belong to the certificate
+type mismatchedProvider struct {
+	fakeProvider
+}
+
+func (m *mismatchedProvider) RequestCertificate(ctx context.Context, identifiers []string, keyType string) ([]byte, []byte, error) {
+	cert, _, err := m.fakeProvider.RequestCertificate(ctx, identifiers, keyType)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, otherKey, err := m.fakeProvider.RequestCertificate(ctx, identifiers, keyType)
+	return cert, otherKey, err
+}
+
+func TestRequestCertificateKeyMismatch(t *testing.T) {
+	provider := &mismatchedProvider{fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}}
+	client := newTestClient(t, provider)
+
+	err := client.requestCertificate(context.Background())
+	if !errors.Is(err, zerossl.ErrKeyMismatch) {
+		t.Fatalf("Expected ErrKeyMismatch, got %v", err)
+	}
+	if exists, _ := client.certificateFilesExist(); exists {
+		t.Error("Expected the mismatched pair not to be installed")
+	}
+}
+
+func TestRequestCertificateVerifiesChain(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	client.config.VerifyChain = true
+
+	// The fake provider's self-signed certificates chain to no trusted root
+	if err := client.requestCertificate(context.Background()); err == nil || !strings.Contains(err.Error(), "trusted root") {
+		t.Fatalf("Expected chain verification to fail, got %v", err)
+	}
+	if exists, _ := client.certificateFilesExist(); exists {
+		t.Error("Expected the untrusted certificate not to be installed")
+	}
+
+	// Sandbox certificates are only checked for their identifiers
+	client.config.Sandbox = true
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed in sandbox mode: %v", err)
+	}
+}
+
+func TestRequestCertificateMissingIdentifier(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, &dropIdentifierProvider{provider})
+
+	err := client.requestCertificate(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "does not cover example.com") {
+		t.Fatalf("Expected a missing identifier error, got %v", err)
+	}
+}
+
+// dropIdentifierProvider issues certificates for all but the last identifier
+type dropIdentifierProvider struct {
+	*fakeProvider
+}
+
+func (d *dropIdentifierProvider) RequestCertificate(ctx context.Context, identifiers []string, keyType string) ([]byte, []byte, error) {
+	return d.fakeProvider.RequestCertificate(ctx, identifiers[:len(identifiers)-1], keyType)
+}
+
+func TestRequestCertificateDeniedIP(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	client.config.IPDeny = []string{"192.0.2.0/24"}
+
+	if err := client.requestCertificate(context.Background()); err == nil {
+		t.Fatal("Expected issuance for a denied IP to be refused")
+	}
+	if len(provider.requested) != 0 {
+		t.Errorf("Expected no request to the provider, got %v", provider.requested)
+	}
+}
+
+func TestReachableOwnershipPorts(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	open := listener.Addr().(*net.TCPAddr).Port
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	client := newTestClient(t, &fakeProvider{})
+	client.config.OwnershipPorts = []int{closedPort, open}
+	if port, ok := client.reachable(context.Background(), "127.0.0.1"); !ok || port != open {
+		t.Errorf("Expected 127.0.0.1 to be reachable on port %d, got %d, %v", open, port, ok)
+	}
+
+	client.config.OwnershipPorts = []int{closedPort}
+	if _, ok := client.reachable(context.Background(), "127.0.0.1"); ok {
+		t.Error("Expected 127.0.0.1 to be unreachable on a closed port")
+	}
+}
+
+func TestRequestCertificateUnownedIPWarnsOnly(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	client.config.OwnershipCheck = true
+
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("Expected the ownership check not to block issuance, got %v", err)
+	}
+	if len(provider.requested) != 1 {
+		t.Errorf("Expected one request to the provider, got %v", provider.requested)
+	}
+}
+
+// stuckProvider blocks until the request is cancelled, like a hung API call
+type stuckProvider struct {
+	fakeProvider
+}
+
+func (s *stuckProvider) RequestCertificate(ctx context.Context, identifiers []string, keyType string) ([]byte, []byte, error) {
+	<-ctx.Done()
+	return nil, nil, ctx.Err()
+}
+
+func TestRenewWatchdogCancelsStuckCycle(t *testing.T) {
+	client := newTestClient(t, &stuckProvider{})
+	client.config.RenewalTimeout = 50 * time.Millisecond
+
+	err := client.renew(context.Background())
+	if !errors.Is(err, errRenewalTimeout) {
+		t.Fatalf("Expected the watchdog to cancel the cycle, got %v", err)
+	}
+
+	st, err := state.Load(client.statePath())
+	if err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	if st.Counters.RenewalTimeouts != 1 {
+		t.Errorf("Expected one renewal timeout, got %d", st.Counters.RenewalTimeouts)
+	}
+	if st.LastRenewal == nil || st.LastRenewal.Success {
+		t.Errorf("Expected a failed renewal to be recorded, got %+v", st.LastRenewal)
+	}
+}
+
+func TestInstallCertificateWritesDER(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	client.config.OutputFormats = []string{config.OutputPEM, config.OutputDER}
+
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+
+	certDER, err := os.ReadFile(filepath.Join(client.config.SSLDir, "cert.der"))
+	if err != nil {
+		t.Fatalf("Expected cert.der to be written: %v", err)
+	}
+	if _, err := x509.ParseCertificate(certDER); err != nil {
+		t.Errorf("cert.der is not a DER certificate: %v", err)
+	}
+	keyDER, err := os.ReadFile(filepath.Join(client.config.SSLDir, "key.der"))
+	if err != nil {
+		t.Fatalf("Expected key.der to be written: %v", err)
+	}
+	if _, err := x509.ParseECPrivateKey(keyDER); err != nil {
+		t.Errorf("key.der is not a DER private key: %v", err)
+	}
+}
+
+func TestHealthHandlerStale(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	client.config.HealthIntervals = 2
+	client.config.RenewalInterval = 5 * time.Millisecond
+	handler := client.healthHandler()
+
+	status := func() int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		return rec.Code
+	}
+	if code := status(); code != http.StatusOK {
+		t.Errorf("Expected a fresh instance to be healthy, got %d", code)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if code := status(); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected an instance without progress to be stale, got %d", code)
+	}
+
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+	if code := status(); code != http.StatusOK {
+		t.Errorf("Expected a successful renewal to make the instance healthy, got %d", code)
+	}
+
+	st, err := state.Load(client.statePath())
+	if err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	if cert := st.Certificates[client.certificateKey()]; cert == nil || cert.LastSuccess.IsZero() {
+		t.Errorf("Expected the last success to be recorded per certificate, got %+v", st.Certificates)
+	}
+}
+
+func TestManagerHealthDegraded(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	web := &managedCertificate{name: "web", client: newTestClient(t, provider)}
+	api := &managedCertificate{name: "api", client: newTestClient(t, provider)}
+	manager := &Manager{config: &config.Config{}, certs: []*managedCertificate{web, api}}
+	handler := manager.healthHandler()
+
+	status := func() (int, combinedHealth) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		var body combinedHealth
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Failed to decode health: %v", err)
+		}
+		return rec.Code, body
+	}
+	if code, body := status(); code != http.StatusOK || body.Status != HealthOK {
+		t.Errorf("Expected healthy certificates to be ok, got %d %s", code, body.Status)
+	}
+
+	web.failure, web.failedAt = errors.New("issuance failed"), time.Now()
+	code, body := status()
+	if code != http.StatusOK || body.Status != HealthDegraded {
+		t.Errorf("Expected one failed certificate to degrade the manager, got %d %s", code, body.Status)
+	}
+	if got := body.Certificates["web"]; got.Status != HealthFailed || got.Error != "issuance failed" {
+		t.Errorf("Expected web to report its failure, got %+v", got)
+	}
+	if got := body.Certificates["api"]; got.Status != HealthOK {
+		t.Errorf("Expected api to stay healthy, got %+v", got)
+	}
+
+	api.failure, api.failedAt = errors.New("deployment failed"), time.Now()
+	if code, body := status(); code != http.StatusServiceUnavailable || body.Status != HealthFailed {
+		t.Errorf("Expected all certificates failing to fail the manager, got %d %s", code, body.Status)
+	}
+
+	// A certificate that became healthy again no longer counts its old failure
+	if err := web.client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+	if _, body := status(); body.Status != HealthDegraded || body.Certificates["web"].Status != HealthOK {
+		t.Errorf("Expected web to recover, got %+v", body)
+	}
+}
+
+func TestStatusHandlerPlan(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	client.config.RenewalInterval = time.Hour
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+	client.nextCheck()
+	handler := client.statusHandler()
+
+	status := func() renewalPlan {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+		var body statusResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Failed to decode status: %v", err)
+		}
+		if len(body.Certificates) != 1 {
+			t.Fatalf("Expected one certificate, got %+v", body)
+		}
+		return body.Certificates[0]
+	}
+
+	plan := status()
+	if plan.NextCheck.IsZero() || plan.NextCheck.After(time.Now().Add(time.Hour)) {
+		t.Errorf("Expected the next check within the renewal interval, got %s", plan.NextCheck)
+	}
+	if !plan.NextRenewal.Equal(plan.RenewalDue) || !plan.RenewalDue.Before(plan.NotAfter) {
+		t.Errorf("Expected the renewal when due before expiry, got %+v", plan)
+	}
+	if plan.Backoff != nil {
+		t.Errorf("Expected no backoff, got %+v", plan.Backoff)
+	}
+
+	client.planCheck(10*time.Minute, &backoffState{Reason: backoffRenewalFailed, Error: "issuance failed"})
+	plan = status()
+	if plan.Backoff == nil || plan.Backoff.Reason != backoffRenewalFailed || !plan.Backoff.RetryAt.Equal(plan.NextCheck) {
+		t.Errorf("Expected the renewal failure backoff, got %+v", plan.Backoff)
+	}
+}
+
+func TestInstallCertificatePKCS8Key(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	client.config.KeyFormat = keys.FormatPKCS8
+
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(client.config.SSLDir, "key.pem"))
+	if err != nil {
+		t.Fatalf("Failed to read key.pem: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		t.Fatalf("Expected a PKCS#8 private key, got %v", block)
+	}
+	if _, err := x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+		t.Errorf("key.pem is not a PKCS#8 key: %v", err)
+	}
+}
+
+func TestInstallCertificateWritesHAProxyBundle(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	client.config.OutputFormats = []string{config.OutputPEM, config.OutputHAProxy}
+
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+
+	path := filepath.Join(client.config.SSLDir, "haproxy.pem")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected haproxy.pem to be written: %v", err)
+	}
+	if _, err := tls.X509KeyPair(data, data); err != nil {
+		t.Errorf("haproxy.pem does not hold a matching certificate and key: %v", err)
+	}
+	if block, _ := pem.Decode(data); block == nil || block.Type != "CERTIFICATE" {
+		t.Errorf("Expected haproxy.pem to start with the certificate, got %v", block)
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm() != 0600 {
+		t.Errorf("Expected haproxy.pem to be private, got mode %v", info.Mode().Perm())
+	}
+}
+
+func TestInstallCertificateSplitLayout(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	client.config.OutputFormats = []string{config.OutputPEM, config.OutputSplit}
+
+	leaf, key, err := provider.RequestCertificate(context.Background(), []string{"192.0.2.1"}, keys.ECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate, _, err := provider.RequestCertificate(context.Background(), []string{"192.0.2.1"}, keys.ECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fullchain := append(append([]byte{}, leaf...), intermediate...)
+
+	for i := 0; i < 2; i++ {
+		if err := client.installCertificate(context.Background(), &state.Renewal{}, fullchain, key); err != nil {
+			t.Fatalf("installCertificate failed: %v", err)
+		}
+	}
+
+	for name, want := range map[string][]byte{
+		"cert.pem":          leaf,
+		"chain.pem":         intermediate,
+		"fullchain.pem":     fullchain,
+		"fullchain.pem.bak": fullchain,
+	} {
+		data, err := os.ReadFile(filepath.Join(client.config.SSLDir, name))
+		if err != nil {
+			t.Fatalf("Expected %s to be written: %v", name, err)
+		}
+		if !bytes.Equal(data, want) {
+			t.Errorf("Unexpected contents of %s:\n%s", name, data)
+		}
+	}
+}
+
+func TestInstallCertificateWritesTemplatedPaths(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	client.config.CertTemplate = "{{.IP}}/fullchain.pem"
+	client.config.KeyTemplate = filepath.Join(t.TempDir(), "{{index .Identifiers 1}}.key")
+
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+
+	cert, err := os.ReadFile(filepath.Join(client.config.SSLDir, "192.0.2.1", "fullchain.pem"))
+	if err != nil {
+		t.Fatalf("Expected the templated certificate path relative to the SSL directory: %v", err)
+	}
+	keyPath := strings.Replace(client.config.KeyTemplate, "{{index .Identifiers 1}}", "example.com", 1)
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		t.Fatalf("Expected the absolute templated key path: %v", err)
+	}
+	if _, err := tls.X509KeyPair(cert, key); err != nil {
+		t.Errorf("Templated files do not hold a matching certificate and key: %v", err)
+	}
+	if info, err := os.Stat(keyPath); err == nil && info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the templated key to be private, got mode %v", info.Mode().Perm())
+	}
+}
+
+func TestInstallCertificateLiveLayout(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	client.config.OutputFormats = []string{config.OutputPEM, config.OutputLive}
+	liveDir := filepath.Join(client.config.SSLDir, "live", "192.0.2.1")
+
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+	first, err := os.ReadFile(filepath.Join(liveDir, "fullchain.pem"))
+	if err != nil {
+		t.Fatalf("Expected live/192.0.2.1/fullchain.pem: %v", err)
+	}
+	firstKey, err := os.ReadFile(filepath.Join(liveDir, "privkey.pem"))
+	if err != nil {
+		t.Fatalf("Expected live/192.0.2.1/privkey.pem: %v", err)
+	}
+
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+	for _, file := range liveFiles {
+		target, err := os.Readlink(filepath.Join(liveDir, file+".pem"))
+		if err != nil {
+			t.Fatalf("Expected %s.pem to be a link: %v", file, err)
+		}
+		if want := filepath.Join("..", "..", "archive", "192.0.2.1", file+"2.pem"); target != want {
+			t.Errorf("Expected %s.pem to link to %s, got %s", file, want, target)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(client.config.SSLDir, "archive", "192.0.2.1", "cert1.pem")); err != nil {
+		t.Errorf("Expected the first version to be archived: %v", err)
+	}
+
+	// Restoring an archived pair links it again instead of adding a version
+	if err := client.writeLive(first, firstKey); err != nil {
+		t.Fatalf("writeLive failed: %v", err)
+	}
+	if target, _ := os.Readlink(filepath.Join(liveDir, "fullchain.pem")); target != filepath.Join("..", "..", "archive", "192.0.2.1", "fullchain1.pem") {
+		t.Errorf("Expected the restored pair to link to version 1, got %s", target)
+	}
+	if _, err := os.Stat(filepath.Join(client.config.SSLDir, "archive", "192.0.2.1", "cert3.pem")); !os.IsNotExist(err) {
+		t.Errorf("Expected no third version, got %v", err)
+	}
+}
+
+func TestInstallCertificateAppliesPermissions(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	files := fsys.NewMem()
+	client.files = files
+	client.config.SSLDir = "/ipssl"
+	if err := files.MkdirAll(client.config.SSLDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	client.config.Files = config.FilesConfig{CertMode: 0640, KeyMode: 0640, Owner: "1000", Group: "1000"}
+
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+
+	for _, name := range []string{"cert.pem", "key.pem"} {
+		path := filepath.Join(client.config.SSLDir, name)
+		info, err := files.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0640 {
+			t.Errorf("Expected %s to have mode 0640, got %v", name, info.Mode().Perm())
+		}
+		if uid, gid, _ := files.Owner(path); uid != 1000 || gid != 1000 {
+			t.Errorf("Expected %s to be owned by 1000:1000, got %d:%d", name, uid, gid)
+		}
+	}
+}
+
+func TestInstallCertificateVerifiesWrites(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	client.config.VerifyWrites = true
+
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+
+	// A file that differs from what was written names both hashes
+	err := client.verifyWrites([]writtenFile{{"cert.pem", []byte("not the written certificate")}})
+	if err == nil || !strings.Contains(err.Error(), "sha256") {
+		t.Errorf("Expected a hash mismatch, got %v", err)
+	}
+}
+
+func TestInstallCertificateWritesPins(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	client.config.OutputFormats = []string{config.OutputPEM, config.OutputPins}
+	pinsPath := filepath.Join(client.config.SSLDir, "pins.txt")
+
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+	first, err := os.ReadFile(pinsPath)
+	if err != nil {
+		t.Fatalf("Expected pins.txt: %v", err)
+	}
+
+	// The pin of the replaced key is kept after the new one
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+	data, err := os.ReadFile(pinsPath)
+	if err != nil {
+		t.Fatalf("Expected pins.txt: %v", err)
+	}
+	pins := strings.Fields(string(data))
+	if len(pins) != 2 || pins[1] != strings.TrimSpace(string(first)) {
+		t.Fatalf("Expected the new pin followed by %s, got %q", first, pins)
+	}
+	cert, err := client.readCertificate(filepath.Join(client.config.SSLDir, "cert.pem"))
+	if err != nil {
+		t.Fatalf("Failed to read certificate: %v", err)
+	}
+	if want := keys.SPKIPin(cert.RawSubjectPublicKeyInfo); pins[0] != want {
+		t.Errorf("Expected pin %s of the installed key, got %s", want, pins[0])
+	}
+}
+
+func TestInstallCertificateRemovesStaleStaple(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	client.ocsp = ocsp.NewClient(client.logger)
+	staplePath := filepath.Join(client.config.SSLDir, stapleFile)
+	if err := os.WriteFile(staplePath, []byte("response for the old certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The self-signed test certificate has no issuer to fetch a response for
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+	if _, err := os.Stat(staplePath); !os.IsNotExist(err) {
+		t.Errorf("Expected the stale OCSP response to be removed, got %v", err)
+	}
+}
+
+func TestRequestCertificateNotCTLoggedInstalls(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	client.config.VerifyCT = true
+	client.config.CTMinSCTs = 2
+
+	// Missing CT evidence is alerted but does not hold up the installation
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+	cert, err := client.readCertificate(filepath.Join(client.config.SSLDir, "cert.pem"))
+	if err != nil {
+		t.Fatalf("Expected the certificate to be installed: %v", err)
+	}
+	if logged, err := client.ctLogged([]*x509.Certificate{cert}); err != nil || logged != 0 {
+		t.Errorf("Expected no CT logs, got %d (%v)", logged, err)
+	}
+}
+
+func TestInstallCertificateWritesMetadata(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	client.config.OutputFormats = []string{config.OutputPEM, config.OutputJSON}
+
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(client.config.SSLDir, "cert.json"))
+	if err != nil {
+		t.Fatalf("Expected cert.json: %v", err)
+	}
+	var meta certMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		t.Fatalf("cert.json is not valid JSON: %v", err)
+	}
+	cert, err := client.readCertificate(filepath.Join(client.config.SSLDir, "cert.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Serial != fmt.Sprintf("%x", cert.SerialNumber) || len(meta.SHA256Fingerprint) != 64 {
+		t.Errorf("Unexpected serial or fingerprint in %s", data)
+	}
+	if len(meta.IPAddresses) != 1 || meta.IPAddresses[0] != "192.0.2.1" || len(meta.DNSNames) != 1 {
+		t.Errorf("Expected the SANs of the certificate, got %v and %v", meta.IPAddresses, meta.DNSNames)
+	}
+	if !meta.NotAfter.Equal(cert.NotAfter) {
+		t.Errorf("Expected not_after %s, got %s", cert.NotAfter, meta.NotAfter)
+	}
+}
+
+func TestInstallCertificateWritesCaddySnippet(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	client.config.OutputFormats = []string{config.OutputSplit, config.OutputCaddy}
+	client.config.ContainerSSLDir = "/etc/caddy/ssl"
+
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(client.config.SSLDir, "caddy.snippet"))
+	if err != nil {
+		t.Fatalf("Expected caddy.snippet: %v", err)
+	}
+	snippet := string(data)
+	if !strings.Contains(snippet, "\nbind 192.0.2.1\n") {
+		t.Errorf("Expected the certificate IP as bind address, got %q", snippet)
+	}
+	if !strings.Contains(snippet, "\ntls /etc/caddy/ssl/fullchain.pem /etc/caddy/ssl/key.pem\n") {
+		t.Errorf("Expected the container paths of the full chain and key, got %q", snippet)
+	}
+}
+
+func TestKeyTypeChangeTriggersReissue(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	client.keyType = keys.RSA2048
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+	if !client.isCertificateValid() {
+		t.Fatal("Expected the freshly issued certificate to be valid")
+	}
+
+	client.keyType = keys.ECDSAP256
+	if client.isCertificateValid() {
+		t.Error("Expected a certificate with the previous key type to be reissued")
+	}
+}
+
+func TestInstallCertificateRecordsSerialChange(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	statePath := filepath.Join(client.config.SSLDir, state.FileName)
+
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+	st, err := state.Load(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.LastRenewal == nil || st.LastRenewal.PreviousSerial != "" || !st.LastRenewal.Changed() {
+		t.Fatalf("Expected the first certificate to be recorded as changed, got %+v", st.LastRenewal)
+	}
+
+	// The fake provider issues every certificate with the same serial
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+	if st, err = state.Load(statePath); err != nil {
+		t.Fatal(err)
+	}
+	if st.LastRenewal.PreviousSerial != st.LastRenewal.Serial || st.LastRenewal.Changed() {
+		t.Errorf("Expected a reinstall of the same serial to be recorded as unchanged, got %+v", st.LastRenewal)
+	}
+}
+
+func TestRevokedCertificateTriggersReissue(t *testing.T) {
+	provider := &fakeProvider{validUntil: time.Now().Add(90 * 24 * time.Hour)}
+	client := newTestClient(t, provider)
+	client.ocsp = ocsp.NewClient(client.logger)
+	if err := client.requestCertificate(context.Background()); err != nil {
+		t.Fatalf("requestCertificate failed: %v", err)
+	}
+
+	// The self-signed test certificate has no issuer to check revocation with
+	client.config.RevokedReissue = true
+	if client.checkRevocation(context.Background()) {
+		t.Error("Expected no reissue without an issuer to check revocation with")
+	}
+
+	// The fake provider issues every certificate with serial 1
+	client.revokedSerial = "1"
+	if client.isCertificateValid() {
+		t.Error("Expected a revoked certificate to be reissued")
+	}
+	client.config.RevokedReissue = false
+	if !client.isCertificateValid() {
+		t.Error("Expected a revoked certificate to be kept without IPSSL_REVOKED_REISSUE")
+	}
+}
@@ -0,0 +1,113 @@
+package ipssl
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/keys"
+	"ipssl-client/internal/state"
+)
+
+// stopgapOrganization marks self-signed stopgap certificates so that they are
+// always treated as needing renewal
+const stopgapOrganization = "IPSSL Client stopgap"
+
+// stopgapValidity is the lifetime of a self-signed stopgap certificate
+const stopgapValidity = 7 * 24 * time.Hour
+
+// handleExpired applies the configured action when the installed certificate
+// has fully expired, typically after renewal failed once more
+func (c *Client) handleExpired(ctx context.Context) {
+	cert, err := c.readCertificate(filepath.Join(c.config.SSLDir, "cert.pem"))
+	if err != nil || time.Now().Before(cert.NotAfter) {
+		return
+	}
+
+	switch c.config.ExpiredAction {
+	case config.ExpiredSelfSigned:
+		if err := c.installStopgap(ctx); err != nil {
+			c.logger.Error("Failed to install self-signed stopgap certificate", "error", err)
+			return
+		}
+		c.alert("IPSSL certificate expired, stopgap installed",
+			fmt.Sprintf("The certificate for %s expired on %s and could not be renewed. A self-signed stopgap certificate has been installed; clients will see certificate warnings until renewal succeeds.\n",
+				c.config.Primary(), c.config.In(cert.NotAfter).Format(time.RFC3339)))
+	case config.ExpiredStop:
+		if c.docker == nil {
+			c.logger.Error("Certificate has expired but no Docker client is available to stop the container", "not_after", cert.NotAfter)
+			return
+		}
+		if err := c.docker.StopContainer(ctx, c.config.ContainerName); err != nil {
+			c.logger.Error("Failed to stop container serving the expired certificate", "error", err, "container", c.config.ContainerName)
+			return
+		}
+		c.stoppedExpired = true
+		c.logger.Warn("Container stopped because its certificate has expired", "container", c.config.ContainerName, "not_after", cert.NotAfter)
+		c.alert("IPSSL certificate expired, container stopped",
+			fmt.Sprintf("The certificate for %s expired on %s and could not be renewed. Container %s has been stopped and is restarted once renewal succeeds.\n",
+				c.config.Primary(), c.config.In(cert.NotAfter).Format(time.RFC3339), c.config.ContainerName))
+	default:
+		c.logger.Warn("Certificate has expired, keeping it in place while retrying", "not_after", cert.NotAfter)
+	}
+}
+
+// installStopgap installs and deploys a short-lived self-signed certificate
+// for the configured identifiers
+func (c *Client) installStopgap(ctx context.Context) error {
+	signer, err := keys.Generate(c.keyType)
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: []string{stopgapOrganization},
+			CommonName:   c.config.Primary(),
+		},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.Add(stopgapValidity),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, identifier := range c.config.Identifiers() {
+		if ip := net.ParseIP(identifier); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, identifier)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, signer.Public(), signer)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate: %w", err)
+	}
+	keyPEM, err := keys.EncodePEM(signer)
+	if err != nil {
+		return err
+	}
+
+	cycleID := c.logger.StartCycle()
+	defer c.logger.EndCycle()
+	c.logger.Warn("Installing self-signed stopgap certificate", "not_after", template.NotAfter)
+	return c.installCertificate(ctx, &state.Renewal{CycleID: cycleID, Time: now}, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), keyPEM)
+}
+
+// isStopgap reports whether cert is a self-signed stopgap certificate
+func isStopgap(cert *x509.Certificate) bool {
+	return slices.Contains(cert.Subject.Organization, stopgapOrganization)
+}
@@ -0,0 +1,331 @@
+package ipssl
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/fsys"
+	"ipssl-client/internal/metrics"
+	"ipssl-client/internal/webroot"
+	"ipssl-client/internal/zerossl"
+)
+
+// FailureReportFile is the name of the failure report inside the SSL directory
+const FailureReportFile = "failure-report.json"
+
+// Renewal steps recorded in failure reports
+const (
+	stepIssue  = "issue"
+	stepSave   = "save"
+	stepReload = "reload"
+	stepDeploy = "deploy"
+	stepVerify = "verify"
+)
+
+// Error classes of failed renewals beyond those of individual API calls
+const (
+	errorTimeout    = "timeout"
+	errorCancelled  = "cancelled"
+	errorValidation = "validation"
+	errorQuota      = "quota"
+	errorKey        = "key"
+	errorLocal      = "local"
+	errorUnknown    = "unknown"
+)
+
+// reachabilityTimeout bounds each reachability probe
+const reachabilityTimeout = 10 * time.Second
+
+// stepError tags an error with the renewal step that produced it
+type stepError struct {
+	step string
+	err  error
+}
+
+func (e *stepError) Error() string { return e.err.Error() }
+
+func (e *stepError) Unwrap() error { return e.err }
+
+// failStep wraps err with the renewal step it happened in
+func failStep(step string, err error) error {
+	return &stepError{step: step, err: err}
+}
+
+// FailureReport describes a failed renewal cycle in enough detail to diagnose
+// it without shell access to the host
+type FailureReport struct {
+	CycleID      string               `json:"cycle_id,omitempty"`
+	Time         time.Time            `json:"time"`
+	Provider     string               `json:"provider"`
+	Identifiers  []string             `json:"identifiers"`
+	Step         string               `json:"step"`
+	ErrorClass   string               `json:"error_class"`
+	Error        string               `json:"error"`
+	APICalls     []metrics.APICall    `json:"api_calls,omitempty"`
+	Reachability []ReachabilityResult `json:"reachability,omitempty"`
+}
+
+// ReachabilityResult is the outcome of fetching a probe file from the
+// validation directory through an identifier, as the CA would
+type ReachabilityResult struct {
+	Identifier string   `json:"identifier"`
+	Addresses  []string `json:"addresses,omitempty"`
+	URL        string   `json:"url"`
+	Reachable  bool     `json:"reachable"`
+	Status     int      `json:"status,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// Summary returns a short plain-text description of the failure for notifications
+func (r *FailureReport) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Renewal of %s failed.\n\n", strings.Join(r.Identifiers, ", "))
+	fmt.Fprintf(&b, "Step:        %s\n", r.Step)
+	fmt.Fprintf(&b, "Error class: %s\n", r.ErrorClass)
+	fmt.Fprintf(&b, "Error:       %s\n", r.Error)
+
+	if failed := r.failedCalls(); len(failed) > 0 {
+		b.WriteString("\nFailed API calls:\n")
+		for _, call := range failed {
+			status := "no response"
+			if call.Status != 0 {
+				status = fmt.Sprintf("HTTP %d", call.Status)
+			}
+			fmt.Fprintf(&b, "  %s %s: %s (%s)\n", call.Method, call.Endpoint, status, call.Class)
+		}
+	}
+
+	if len(r.Reachability) > 0 {
+		b.WriteString("\nReachability:\n")
+		for _, result := range r.Reachability {
+			switch {
+			case result.Reachable:
+				fmt.Fprintf(&b, "  %s: reachable\n", result.Identifier)
+			case result.Status != 0:
+				fmt.Fprintf(&b, "  %s: unreachable (HTTP %d)\n", result.Identifier, result.Status)
+			default:
+				fmt.Fprintf(&b, "  %s: unreachable (%s)\n", result.Identifier, result.Error)
+			}
+		}
+	}
+	return b.String()
+}
+
+// failedCalls returns the API calls that were classified as errors
+func (r *FailureReport) failedCalls() []metrics.APICall {
+	var failed []metrics.APICall
+	for _, call := range r.APICalls {
+		if call.Class != "" {
+			failed = append(failed, call)
+		}
+	}
+	return failed
+}
+
+// reportFailure writes a failure report for a renewal cycle started at started
+// into the SSL directory and sends its summary to the configured recipients.
+// Rate limits are transient and only logged; the renewal backs off until the
+// next check.
+func (c *Client) reportFailure(ctx context.Context, started time.Time, err error) {
+	r := c.buildFailureReport(ctx, started, err)
+
+	path := filepath.Join(c.config.SSLDir, FailureReportFile)
+	if err := writeJSON(c.files, path, r); err != nil {
+		c.logger.Error("Failed to write failure report", "error", err, "path", path)
+	} else {
+		c.logger.Info("Failure report written", "path", path, "step", r.Step, "error_class", r.ErrorClass)
+	}
+
+	subject := "IPSSL renewal failed"
+	switch {
+	case errors.Is(err, zerossl.ErrRateLimited):
+		c.logger.Warn("Rate limited by the certificate authority, backing off until the next check", "next_check", time.Now().Add(c.config.RenewalInterval))
+		return
+	case errors.Is(err, zerossl.ErrQuotaExceeded):
+		subject = "IPSSL renewal failed: certificate quota exceeded"
+	case errors.Is(err, zerossl.ErrValidationFailed):
+		subject = "IPSSL renewal failed: domain validation failed"
+	case errors.Is(err, zerossl.ErrKeyNotFound), errors.Is(err, zerossl.ErrKeyMismatch):
+		subject = "IPSSL renewal failed: no private key matches the issued certificate"
+	}
+	c.alert(subject, r.Summary()+"\nFull report: "+path+"\n")
+}
+
+// clearFailureReport removes the report of an earlier failure once renewal succeeds
+func (c *Client) clearFailureReport() {
+	path := filepath.Join(c.config.SSLDir, FailureReportFile)
+	if err := c.files.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		c.logger.Warn("Failed to remove failure report", "error", err, "path", path)
+	}
+}
+
+// buildFailureReport gathers the failed step, error class, recent API calls
+// and reachability of the identifiers
+func (c *Client) buildFailureReport(ctx context.Context, started time.Time, err error) *FailureReport {
+	step := stepIssue
+	var se *stepError
+	if errors.As(err, &se) {
+		step = se.step
+	}
+	calls := c.metrics.RecentCalls(started)
+
+	r := &FailureReport{
+		CycleID:     c.logger.CycleID(),
+		Time:        time.Now(),
+		Provider:    c.config.Provider,
+		Identifiers: c.config.Identifiers(),
+		Step:        step,
+		ErrorClass:  classifyFailure(step, err, calls),
+		Error:       err.Error(),
+		APICalls:    calls,
+	}
+
+	// Reachability only matters for failures to get the certificate issued
+	if step == stepIssue && ctx.Err() == nil {
+		r.Reachability = c.checkReachability(ctx)
+	}
+	return r
+}
+
+// classifyFailure assigns an error class to a failed renewal. Failures during
+// issuance take the class of the last failed API call when there is one.
+func classifyFailure(step string, err error, calls []metrics.APICall) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, errRenewalTimeout):
+		return errorTimeout
+	case errors.Is(err, context.Canceled):
+		return errorCancelled
+	case step != stepIssue:
+		return errorLocal
+	case errors.Is(err, zerossl.ErrRateLimited):
+		return metrics.ErrorRateLimit
+	case errors.Is(err, zerossl.ErrQuotaExceeded):
+		return errorQuota
+	case errors.Is(err, zerossl.ErrValidationFailed):
+		return errorValidation
+	case errors.Is(err, zerossl.ErrKeyMismatch), errors.Is(err, zerossl.ErrKeyNotFound):
+		return errorKey
+	}
+
+	for i := len(calls) - 1; i >= 0; i-- {
+		if calls[i].Class != "" {
+			return calls[i].Class
+		}
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr):
+		return metrics.ErrorNetwork
+	case strings.Contains(strings.ToLower(err.Error()), "validat"):
+		return errorValidation
+	default:
+		return errorUnknown
+	}
+}
+
+// checkReachability places a probe file in the validation directory and fetches
+// it over plain HTTP through every identifier, the way HTTP validation does
+func (c *Client) checkReachability(ctx context.Context) []ReachabilityResult {
+	token := make([]byte, 16)
+	rand.Read(token)
+	name := "ipssl-probe-" + hex.EncodeToString(token) + ".txt"
+	content := hex.EncodeToString(token)
+
+	webPath := c.validationPath() + "/" + name
+	probePaths, err := webroot.Write(c.files, c.config.ValidationDirs, webPath, []byte(content))
+	if err != nil {
+		c.logger.Warn("Failed to write reachability probe", "error", err)
+		return nil
+	}
+	defer webroot.Remove(c.files, probePaths)
+
+	client := &http.Client{
+		Timeout: reachabilityTimeout,
+		// The CA follows redirects but the first hop is what fails most often
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	results := make([]ReachabilityResult, 0, len(c.config.Identifiers()))
+	for _, identifier := range c.config.Identifiers() {
+		results = append(results, probe(ctx, client, identifier, webPath, content))
+	}
+	return results
+}
+
+// validationPath returns the URL path under which the provider looks for validation files
+func (c *Client) validationPath() string {
+	if c.config.Provider == config.ProviderACME {
+		return "/.well-known/acme-challenge"
+	}
+	return "/.well-known/pki-validation"
+}
+
+// probe fetches the probe file through identifier and compares its content
+func probe(ctx context.Context, client *http.Client, identifier, path, content string) ReachabilityResult {
+	host := identifier
+	if ip := net.ParseIP(identifier); ip != nil && ip.To4() == nil {
+		host = "[" + identifier + "]"
+	}
+	u := (&url.URL{Scheme: "http", Host: host, Path: path}).String()
+	result := ReachabilityResult{Identifier: identifier, URL: u}
+
+	if net.ParseIP(identifier) == nil {
+		addrs, err := net.DefaultResolver.LookupHost(ctx, identifier)
+		if err != nil {
+			result.Error = fmt.Sprintf("DNS lookup failed: %v", err)
+			return result
+		}
+		result.Addresses = addrs
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+
+	result.Status = resp.StatusCode
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	switch {
+	case resp.StatusCode != http.StatusOK:
+		result.Error = fmt.Sprintf("unexpected status %s", resp.Status)
+	case strings.TrimSpace(string(body)) != content:
+		result.Error = "probe content does not match; requests may reach a different server"
+	default:
+		result.Reachable = true
+	}
+	return result
+}
+
+// writeJSON writes v as indented JSON to path, replacing the previous file atomically
+func writeJSON(files fsys.FS, path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsys.WriteFileAtomic(files, path, data, 0600)
+}
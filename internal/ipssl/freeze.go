@@ -0,0 +1,46 @@
+package ipssl
+
+import (
+	"path/filepath"
+	"time"
+
+	"ipssl-client/internal/state"
+)
+
+// frozen reports whether renewal and deployment are suspended by a freeze set
+// with the freeze command. A freeze that has run out is cleared and recorded
+// in the audit log.
+func (c *Client) frozen() bool {
+	st, err := state.Load(c.statePath())
+	if err != nil {
+		c.logger.Warn("Failed to load state, ignoring renewal freeze", "error", err)
+		return false
+	}
+
+	now := time.Now()
+	if freeze := st.ActiveFreeze(now); freeze != nil {
+		c.logger.Warn("Renewal frozen, skipping renewal and deployment", "reason", freeze.Reason, "until", freeze.Until)
+		return true
+	}
+	if st.Freeze == nil {
+		return false
+	}
+
+	expired := *st.Freeze
+	c.updateState(func(st *state.State) {
+		// The freeze may have been renewed in the meantime
+		if st.Freeze != nil && st.ActiveFreeze(now) == nil {
+			st.Freeze = nil
+		}
+	})
+	c.logger.Info("Renewal freeze expired", "reason", expired.Reason, "until", expired.Until)
+	if err := state.AppendAudit(filepath.Join(c.config.StateDir, state.AuditFileName), state.AuditEvent{
+		Time:   now,
+		Action: state.AuditFreezeExpire,
+		Reason: expired.Reason,
+		Until:  expired.Until,
+	}); err != nil {
+		c.logger.Warn("Failed to record freeze expiry in audit log", "error", err)
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package ipssl
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ipssl-client/internal/state"
+)
+
+// healthStatus is the body of the /healthz response
+type healthStatus struct {
+	Status      string    `json:"status"`
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	LastHealthy time.Time `json:"last_healthy,omitempty"`
+	StaleAfter  string    `json:"stale_after,omitempty"`
+
+	// Error and RetryAt describe a certificate whose client failed to start
+	// and is waiting to be restarted
+	Error   string    `json:"error,omitempty"`
+	RetryAt time.Time `json:"retry_at,omitempty"`
+}
+
+// certificateKey identifies the managed certificate in the state file
+func (c *Client) certificateKey() string {
+	return strings.Join(c.config.Identifiers(), ",")
+}
+
+// recordCurrent records that a check found the certificate installed and not
+// yet due for renewal, the sign that the renewal loop is doing its job
+func (c *Client) recordCurrent() {
+	cert, err := c.readCertificate(filepath.Join(c.config.SSLDir, "cert.pem"))
+	if err != nil || isStopgap(cert) || !time.Now().Before(c.renewalDue(cert)) {
+		return
+	}
+	c.updateState(func(st *state.State) {
+		st.Certificate(c.certificateKey()).LastCurrent = time.Now()
+	})
+}
+
+// healthHandler serves /healthz: unhealthy once neither a renewal succeeded nor
+// a check found the certificate current for IPSSL_HEALTHZ_INTERVALS renewal
+// intervals, so that orchestrators restart an instance whose loop is stuck.
+// The process start counts as healthy, so a restart gets a full grace period.
+func (c *Client) healthHandler() http.Handler {
+	started := time.Now()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := c.health(started)
+		code := http.StatusOK
+		if status.Status != HealthOK {
+			code = http.StatusServiceUnavailable
+		}
+		serveJSON(w, code, status)
+	})
+}
+
+// health returns the health of the certificate, counting the time started as healthy
+func (c *Client) health(started time.Time) healthStatus {
+	status := healthStatus{Status: HealthOK, LastHealthy: started}
+	st, err := state.Load(c.statePath())
+	if err == nil && st.Certificates[c.certificateKey()] != nil {
+		cert := st.Certificates[c.certificateKey()]
+		status.LastSuccess = cert.LastSuccess
+		if healthy := cert.LastHealthy(); healthy.After(started) {
+			status.LastHealthy = healthy
+		}
+	}
+
+	if n := c.config.HealthIntervals; n > 0 {
+		staleAfter := time.Duration(n) * c.config.RenewalInterval
+		status.StaleAfter = staleAfter.String()
+		if time.Since(status.LastHealthy) > staleAfter {
+			status.Status = "stale"
+		}
+	}
+	return status
+}
+
+// serveJSON writes an HTTP response encoded as JSON
+func serveJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,120 @@
+package ipssl
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// liveFiles are the files of a version in certbot's layout, stored as
+// archive/<name>/<file><version>.pem and linked from live/<name>/<file>.pem
+var liveFiles = []string{"cert", "chain", "fullchain", "privkey"}
+
+// liveName returns the name of the certificate in certbot's layout:
+// IPSSL_LIVE_NAME, or the primary identifier
+func (c *Client) liveName() string {
+	if c.config.LiveName != "" {
+		return c.config.LiveName
+	}
+	return c.config.Primary()
+}
+
+// writeLive stores the certificate in certbot's layout: every installed pair
+// is kept as a numbered version in archive/<name>/, and the links in
+// live/<name>/ are replaced with links to it one at a time, each atomically.
+// A pair that is already archived, such as one restored by a rollback, is
+// linked again instead of being stored as a new version.
+func (c *Client) writeLive(cert, key []byte) error {
+	leaf, chain, err := splitChain(cert)
+	if err != nil {
+		return err
+	}
+	contents := map[string][]byte{
+		"cert":      leaf,
+		"chain":     chain,
+		"fullchain": append(append([]byte{}, leaf...), chain...),
+		"privkey":   key,
+	}
+
+	name := c.liveName()
+	archiveDir := filepath.Join(c.config.SSLDir, "archive", name)
+	liveDir := filepath.Join(c.config.SSLDir, "live", name)
+	for _, dir := range []string{archiveDir, liveDir} {
+		if err := c.files.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+
+	latest := c.latestLiveVersion(archiveDir)
+	version := c.archivedLiveVersion(archiveDir, latest, contents)
+	if version == 0 {
+		version = latest + 1
+		for _, file := range liveFiles {
+			path := filepath.Join(archiveDir, file+strconv.Itoa(version)+".pem")
+			if err := c.writeArtifact(path, contents[file], file == "privkey"); err != nil {
+				return fmt.Errorf("failed to save %s: %w", path, err)
+			}
+		}
+	}
+
+	for _, file := range liveFiles {
+		target := filepath.Join("..", "..", "archive", name, file+strconv.Itoa(version)+".pem")
+		if err := c.replaceLink(filepath.Join(liveDir, file+".pem"), target); err != nil {
+			return err
+		}
+	}
+	c.logger.Info("Live certificate links updated", "live_dir", liveDir, "version", version, "new_version", version > latest)
+	return nil
+}
+
+// latestLiveVersion returns the highest version in archiveDir, or 0 if it is empty
+func (c *Client) latestLiveVersion(archiveDir string) int {
+	version := 0
+	for {
+		if _, err := c.files.Stat(filepath.Join(archiveDir, "fullchain"+strconv.Itoa(version+1)+".pem")); err != nil {
+			return version
+		}
+		version++
+	}
+}
+
+// archivedLiveVersion returns the newest version up to latest holding exactly
+// contents, or 0 if none does
+func (c *Client) archivedLiveVersion(archiveDir string, latest int, contents map[string][]byte) int {
+	for version := latest; version > 0; version-- {
+		matches := true
+		for _, file := range liveFiles {
+			data, err := c.files.ReadFile(filepath.Join(archiveDir, file+strconv.Itoa(version)+".pem"))
+			if err != nil || !bytes.Equal(data, contents[file]) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return version
+		}
+	}
+	return 0
+}
+
+// replaceLink points the symbolic link at path to target by renaming a new
+// link over it, so that readers see either the old or the new target
+func (c *Client) replaceLink(path, target string) error {
+	if current, err := c.files.Readlink(path); err == nil && current == target {
+		return nil
+	}
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp-link")
+	if err := c.files.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace link %s: %w", path, err)
+	}
+	if err := c.files.Symlink(target, tmp); err != nil {
+		return fmt.Errorf("failed to replace link %s: %w", path, err)
+	}
+	if err := c.files.Rename(tmp, path); err != nil {
+		c.files.Remove(tmp)
+		return fmt.Errorf("failed to replace link %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package ipssl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/logger"
+	"ipssl-client/internal/metrics"
+)
+
+// Combined health states of a manager
+const (
+	// HealthOK means every certificate is healthy
+	HealthOK = "ok"
+	// HealthDegraded means some certificates are unhealthy but others are served
+	HealthDegraded = "degraded"
+	// HealthFailed means no certificate is healthy
+	HealthFailed = "failed"
+)
+
+// Manager runs one client per certificate set in IPSSL_CERTIFICATES. Every
+// client keeps its own renewal schedule and retries, so that a certificate
+// whose issuance or deployment fails does not hold up the others.
+type Manager struct {
+	config  *config.Config
+	logger  *logger.Logger
+	metrics *metrics.Metrics
+	certs   []*managedCertificate
+}
+
+// managedCertificate is the client of a certificate set and its last failure
+type managedCertificate struct {
+	name   string
+	client *Client
+
+	mu       sync.Mutex
+	failure  error
+	failedAt time.Time
+	retryAt  time.Time
+}
+
+// combinedHealth is the body of the /healthz response of a manager
+type combinedHealth struct {
+	Status       string                  `json:"status"`
+	Certificates map[string]healthStatus `json:"certificates"`
+}
+
+// NewManager creates the clients of the configured certificate sets
+func NewManager(cfg *config.Config, log *logger.Logger) (*Manager, error) {
+	m := &Manager{config: cfg, logger: log, metrics: metrics.New()}
+	for _, set := range cfg.Certificates {
+		client, err := newClient(cfg.ForCertificate(set), log.With("certificate", set.Name), m.metrics)
+		if err != nil {
+			return nil, fmt.Errorf("certificate %s: %w", set.Name, err)
+		}
+		m.certs = append(m.certs, &managedCertificate{name: set.Name, client: client})
+	}
+	return m, nil
+}
+
+// Start runs the clients until ctx is cancelled
+func (m *Manager) Start(ctx context.Context) error {
+	if m.config.MetricsAddr != "" {
+		go func() {
+			m.logger.Info("Serving metrics", "addr", m.config.MetricsAddr)
+			if err := m.metrics.Serve(ctx, m.config.MetricsAddr, map[string]http.Handler{
+				"/healthz": m.healthHandler(),
+				"/status":  m.statusHandler(),
+			}); err != nil {
+				m.logger.Error("Metrics server failed", "error", err)
+			}
+		}()
+	}
+
+	var wg sync.WaitGroup
+	for _, cert := range m.certs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.run(ctx, cert)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// run starts the client of a certificate and restarts it when it fails,
+// waiting IPSSL_RENEWAL_RETRY_DELAY at first and twice as long after every
+// further failure, up to the renewal interval
+func (m *Manager) run(ctx context.Context, cert *managedCertificate) {
+	delay := m.config.RenewalRetry
+	for {
+		err := cert.client.Start(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		retryAt := time.Now().Add(delay)
+		cert.mu.Lock()
+		cert.failure, cert.failedAt, cert.retryAt = err, time.Now(), retryAt
+		cert.mu.Unlock()
+		cert.client.logger.Error("Certificate client failed, other certificates are not affected", "error", err, "retry_at", retryAt.Round(time.Second))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, max(m.config.RenewalInterval, m.config.RenewalRetry))
+	}
+}
+
+// health returns the health of the certificate, which failed if its client
+// stopped and has not been healthy since
+func (cert *managedCertificate) health(started time.Time) healthStatus {
+	status := cert.client.health(started)
+	cert.mu.Lock()
+	defer cert.mu.Unlock()
+	if cert.failure != nil && !status.LastHealthy.After(cert.failedAt) {
+		status.Status = HealthFailed
+		status.Error = cert.failure.Error()
+		status.RetryAt = cert.retryAt
+	}
+	return status
+}
+
+// healthHandler serves /healthz for all certificates. A degraded manager,
+// with some certificates healthy, answers 200 so that orchestrators do not
+// restart it and interrupt the healthy ones; it answers 503 once all failed.
+func (m *Manager) healthHandler() http.Handler {
+	started := time.Now()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := combinedHealth{Certificates: make(map[string]healthStatus)}
+		healthy := 0
+		for _, cert := range m.certs {
+			status := cert.health(started)
+			if status.Status == HealthOK {
+				healthy++
+			}
+			body.Certificates[cert.name] = status
+		}
+		body.Status = combinedStatus(healthy, len(m.certs))
+
+		code := http.StatusOK
+		if body.Status == HealthFailed {
+			code = http.StatusServiceUnavailable
+		}
+		serveJSON(w, code, body)
+	})
+}
+
+// statusHandler serves /status with the renewal plans of all certificates.
+// A certificate whose client is waiting to be restarted is next checked then.
+func (m *Manager) statusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body statusResponse
+		for _, cert := range m.certs {
+			plan := cert.client.plan()
+			plan.Name = cert.name
+			cert.mu.Lock()
+			if cert.failure != nil && !cert.retryAt.Before(time.Now()) {
+				retryAt := cert.client.config.In(cert.retryAt)
+				plan.NextCheck, plan.NextRenewal = retryAt, retryAt
+				plan.Backoff = &backoffState{Reason: backoffRestart, RetryAt: retryAt, Error: cert.failure.Error()}
+			}
+			cert.mu.Unlock()
+			body.Certificates = append(body.Certificates, plan)
+		}
+		serveJSON(w, http.StatusOK, body)
+	})
+}
+
+// combinedStatus returns the health of a manager with healthy of total certificates healthy
+func combinedStatus(healthy, total int) string {
+	switch {
+	case healthy == total:
+		return HealthOK
+	case healthy > 0:
+		return HealthDegraded
+	default:
+		return HealthFailed
+	}
+}
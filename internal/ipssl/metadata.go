@@ -0,0 +1,80 @@
+package ipssl
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"ipssl-client/internal/chain"
+)
+
+// certificateIDer is implemented by providers that identify the certificates
+// they issue, such as ZeroSSL
+type certificateIDer interface {
+	CertificateID(cert *x509.Certificate) string
+}
+
+// certMetadata is the content of cert.json: the facts of the installed
+// certificate for automation that should not have to parse PEM
+type certMetadata struct {
+	Serial            string            `json:"serial"`
+	SHA256Fingerprint string            `json:"sha256_fingerprint"`
+	SHA1Fingerprint   string            `json:"sha1_fingerprint"`
+	Subject           string            `json:"subject"`
+	Issuer            string            `json:"issuer"`
+	IPAddresses       []string          `json:"ip_addresses,omitempty"`
+	DNSNames          []string          `json:"dns_names,omitempty"`
+	NotBefore         time.Time         `json:"not_before"`
+	NotAfter          time.Time         `json:"not_after"`
+	ChainLength       int               `json:"chain_length"`
+	Provider          string            `json:"provider"`
+	ProviderCertID    string            `json:"provider_cert_id,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty"`
+}
+
+// writeMetadata writes cert.json describing the leaf of cert. The provider's
+// certificate ID is included when the provider issued the certificate last.
+func (c *Client) writeMetadata(cert []byte) error {
+	certs, err := chain.Parse(cert)
+	if err != nil {
+		return err
+	}
+	leaf := certs[0]
+	sha256Sum, sha1Sum := sha256.Sum256(leaf.Raw), sha1.Sum(leaf.Raw)
+
+	meta := certMetadata{
+		Serial:            fmt.Sprintf("%x", leaf.SerialNumber),
+		SHA256Fingerprint: hex.EncodeToString(sha256Sum[:]),
+		SHA1Fingerprint:   hex.EncodeToString(sha1Sum[:]),
+		Subject:           leaf.Subject.String(),
+		Issuer:            leaf.Issuer.String(),
+		DNSNames:          leaf.DNSNames,
+		NotBefore:         c.config.In(leaf.NotBefore),
+		NotAfter:          c.config.In(leaf.NotAfter),
+		ChainLength:       len(certs),
+		Provider:          c.config.Provider,
+		Labels:            c.config.Labels,
+	}
+	for _, ip := range leaf.IPAddresses {
+		meta.IPAddresses = append(meta.IPAddresses, ip.String())
+	}
+	if ider, ok := c.provider.(certificateIDer); ok {
+		meta.ProviderCertID = ider.CertificateID(leaf)
+	}
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(c.config.SSLDir, "cert.json")
+	if err := c.writeArtifact(path, append(data, '\n'), false); err != nil {
+		return fmt.Errorf("failed to save certificate metadata: %w", err)
+	}
+	c.logger.Info("Certificate metadata saved", "path", path)
+	return nil
+}
@@ -0,0 +1,329 @@
+package ipssl
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"ipssl-client/internal/chain"
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/keys"
+)
+
+// splitLayout reports whether certificates are written in certbot's layout:
+// cert.pem with only the leaf, chain.pem and fullchain.pem
+func (c *Client) splitLayout() bool {
+	return slices.Contains(c.config.OutputFormats, config.OutputSplit)
+}
+
+// chainPath returns the file holding the whole chain, which is read back for
+// backups, standby nodes and overrides
+func (c *Client) chainPath() string {
+	if c.splitLayout() {
+		return filepath.Join(c.config.SSLDir, "fullchain.pem")
+	}
+	return filepath.Join(c.config.SSLDir, "cert.pem")
+}
+
+// certFile returns the contents of cert.pem for a chain: the chain itself, or
+// only the leaf with the split layout
+func (c *Client) certFile(cert []byte) ([]byte, error) {
+	if !c.splitLayout() {
+		return cert, nil
+	}
+	leaf, _, err := splitChain(cert)
+	return leaf, err
+}
+
+// writeOutputs writes the additional formats of IPSSL_OUTPUT_FORMATS next to
+// cert.pem and key.pem, which are always written since the client reads them
+func (c *Client) writeOutputs(cert, key []byte) error {
+	if c.splitLayout() {
+		if err := c.writeSplit(cert); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(c.config.OutputFormats, config.OutputDER) {
+		if err := c.writeDER(cert, key); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(c.config.OutputFormats, config.OutputP7B) {
+		if err := c.writeP7B(cert); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(c.config.OutputFormats, config.OutputHAProxy) {
+		if err := c.writeHAProxy(cert, key); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(c.config.OutputFormats, config.OutputLive) {
+		if err := c.writeLive(cert, key); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(c.config.OutputFormats, config.OutputPins) {
+		if err := c.writePins(cert); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(c.config.OutputFormats, config.OutputJSON) {
+		if err := c.writeMetadata(cert); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(c.config.OutputFormats, config.OutputCaddy) {
+		if err := c.writeCaddy(); err != nil {
+			return err
+		}
+	}
+	if c.config.CertTemplate != "" || c.config.KeyTemplate != "" {
+		if err := c.writeTemplated(cert, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDER writes cert.der and key.der. DER holds a single certificate, so
+// cert.der contains only the leaf.
+func (c *Client) writeDER(cert, key []byte) error {
+	certBlock, _ := pem.Decode(cert)
+	if certBlock == nil || certBlock.Type != "CERTIFICATE" {
+		return fmt.Errorf("failed to decode certificate PEM")
+	}
+	keyBlock, _ := pem.Decode(key)
+	if keyBlock == nil {
+		return fmt.Errorf("failed to decode private key PEM")
+	}
+
+	certPath := filepath.Join(c.config.SSLDir, "cert.der")
+	if err := c.writeArtifact(certPath, certBlock.Bytes, false); err != nil {
+		return fmt.Errorf("failed to save DER certificate: %w", err)
+	}
+	keyPath := filepath.Join(c.config.SSLDir, "key.der")
+	if err := c.writeArtifact(keyPath, keyBlock.Bytes, true); err != nil {
+		return fmt.Errorf("failed to save DER private key: %w", err)
+	}
+	c.logger.Info("DER files saved", "cert_path", certPath, "key_path", keyPath)
+	return nil
+}
+
+// writeP7B writes cert.p7b, the chain as a certs-only PKCS#7 bundle
+func (c *Client) writeP7B(cert []byte) error {
+	certs, err := chain.Parse(cert)
+	if err != nil {
+		return err
+	}
+	der, err := chain.EncodePKCS7(certs)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(c.config.SSLDir, "cert.p7b")
+	if err := c.writeArtifact(path, der, false); err != nil {
+		return fmt.Errorf("failed to save PKCS#7 bundle: %w", err)
+	}
+	c.logger.Info("PKCS#7 bundle saved", "path", path)
+	return nil
+}
+
+// writeHAProxy writes haproxy.pem: the leaf, the intermediates and the key in
+// one file, the order HAProxy's crt option expects
+func (c *Client) writeHAProxy(cert, key []byte) error {
+	var combined bytes.Buffer
+	combined.Write(bytes.TrimSpace(cert))
+	combined.WriteByte('\n')
+	combined.Write(bytes.TrimSpace(key))
+	combined.WriteByte('\n')
+
+	path := filepath.Join(c.config.SSLDir, "haproxy.pem")
+	if err := c.writeArtifact(path, combined.Bytes(), true); err != nil {
+		return fmt.Errorf("failed to save HAProxy bundle: %w", err)
+	}
+	c.logger.Info("HAProxy bundle saved", "path", path)
+	return nil
+}
+
+// writeCaddy writes caddy.snippet with the bind and tls directives for a
+// Caddyfile site block. The paths are those seen by the Caddy container when
+// IPSSL_CONTAINER_SSL_DIR is set, and the full chain is served with the split
+// layout.
+func (c *Client) writeCaddy() error {
+	dir := c.config.SSLDir
+	if c.config.ContainerSSLDir != "" {
+		dir = c.config.ContainerSSLDir
+	}
+	bind := c.config.CaddyBind
+	if len(bind) == 0 {
+		bind = c.config.ClientIPs
+	}
+
+	var snippet strings.Builder
+	snippet.WriteString("# Generated by ipssl-client; import it in the site block:\n")
+	fmt.Fprintf(&snippet, "#   import %s\n", path.Join(dir, "caddy.snippet"))
+	if len(bind) > 0 {
+		fmt.Fprintf(&snippet, "bind %s\n", strings.Join(bind, " "))
+	}
+	fmt.Fprintf(&snippet, "tls %s %s\n", path.Join(dir, filepath.Base(c.chainPath())), path.Join(dir, "key.pem"))
+
+	snippetPath := filepath.Join(c.config.SSLDir, "caddy.snippet")
+	if err := c.writeArtifact(snippetPath, []byte(snippet.String()), false); err != nil {
+		return fmt.Errorf("failed to save Caddyfile snippet: %w", err)
+	}
+	c.logger.Info("Caddyfile snippet saved", "path", snippetPath, "bind", bind)
+	return nil
+}
+
+// writePins writes pins.txt with the SPKI pin of the new key, followed by
+// that of the key it replaces, one per line. Pinning configurations updated
+// from the file accept both keys while the new certificate is rolled out.
+func (c *Client) writePins(cert []byte) error {
+	leaf, err := parseLeaf(cert)
+	if err != nil {
+		return err
+	}
+	pins := []string{keys.SPKIPin(leaf.RawSubjectPublicKeyInfo)}
+	if previous, err := c.files.ReadFile(c.chainPath() + backupSuffix); err == nil {
+		if prev, err := parseLeaf(previous); err == nil {
+			if pin := keys.SPKIPin(prev.RawSubjectPublicKeyInfo); pin != pins[0] {
+				pins = append(pins, pin)
+			}
+		}
+	}
+
+	path := filepath.Join(c.config.SSLDir, "pins.txt")
+	if err := c.writeArtifact(path, []byte(strings.Join(pins, "\n")+"\n"), false); err != nil {
+		return fmt.Errorf("failed to save SPKI pins: %w", err)
+	}
+	c.logger.Info("SPKI pins saved", "path", path, "pin", pins[0], "pins", len(pins))
+	return nil
+}
+
+// parseLeaf parses the first certificate of a PEM chain
+func parseLeaf(cert []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(cert)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("failed to decode certificate PEM")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// writeSplit writes chain.pem with the intermediates and fullchain.pem with
+// the leaf followed by the intermediates
+func (c *Client) writeSplit(cert []byte) error {
+	leaf, chain, err := splitChain(cert)
+	if err != nil {
+		return err
+	}
+
+	chainPath := filepath.Join(c.config.SSLDir, "chain.pem")
+	if err := c.writeArtifact(chainPath, chain, false); err != nil {
+		return fmt.Errorf("failed to save chain: %w", err)
+	}
+	fullchainPath := filepath.Join(c.config.SSLDir, "fullchain.pem")
+	if err := c.writeArtifact(fullchainPath, append(leaf, chain...), false); err != nil {
+		return fmt.Errorf("failed to save full chain: %w", err)
+	}
+	c.logger.Info("Chain files saved", "chain_path", chainPath, "fullchain_path", fullchainPath)
+	return nil
+}
+
+// splitChain separates the leaf certificate of a PEM chain from the
+// intermediates, re-encoding both
+func splitChain(cert []byte) (leaf, chain []byte, err error) {
+	for rest := cert; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if leaf == nil {
+			leaf = pem.EncodeToMemory(block)
+		} else {
+			chain = append(chain, pem.EncodeToMemory(block)...)
+		}
+	}
+	if leaf == nil {
+		return nil, nil, fmt.Errorf("failed to decode certificate PEM")
+	}
+	return leaf, chain, nil
+}
+
+// pathData is the data exposed to IPSSL_CERT_PATH_TEMPLATE and
+// IPSSL_KEY_PATH_TEMPLATE
+type pathData struct {
+	IP          string
+	Identifiers []string
+	Serial      string
+	CycleID     string
+}
+
+// writeTemplated writes copies of the certificate chain and the key to the
+// paths rendered from IPSSL_CERT_PATH_TEMPLATE and IPSSL_KEY_PATH_TEMPLATE.
+// Relative paths are resolved against IPSSL_SSL_DIR.
+func (c *Client) writeTemplated(cert, key []byte) error {
+	data := pathData{
+		IP:          c.config.Primary(),
+		Identifiers: c.config.Identifiers(),
+		CycleID:     c.logger.CycleID(),
+	}
+	if block, _ := pem.Decode(cert); block != nil {
+		if leaf, err := x509.ParseCertificate(block.Bytes); err == nil {
+			data.Serial = fmt.Sprintf("%x", leaf.SerialNumber)
+		}
+	}
+
+	for _, file := range []struct {
+		tmpl    string
+		content []byte
+		private bool
+	}{
+		{c.config.CertTemplate, cert, false},
+		{c.config.KeyTemplate, key, true},
+	} {
+		if file.tmpl == "" {
+			continue
+		}
+		path, err := renderPath(file.tmpl, data)
+		if err != nil {
+			return err
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(c.config.SSLDir, path)
+		}
+		if err := c.files.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", path, err)
+		}
+		if err := c.writeArtifact(path, file.content, file.private); err != nil {
+			return fmt.Errorf("failed to save %s: %w", path, err)
+		}
+		c.logger.Info("Templated output saved", "path", path)
+	}
+	return nil
+}
+
+// renderPath renders an output path template
+func renderPath(text string, data pathData) (string, error) {
+	tmpl, err := template.New("path").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse path template %q: %w", text, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("failed to render path template %q: %w", text, err)
+	}
+	if strings.TrimSpace(out.String()) == "" {
+		return "", fmt.Errorf("path template %q rendered an empty path", text)
+	}
+	return out.String(), nil
+}
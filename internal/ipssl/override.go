@@ -0,0 +1,83 @@
+package ipssl
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ipssl-client/internal/state"
+)
+
+// applyOverride installs a manually obtained certificate from the override
+// directory in place of automation. It reports whether the override is in
+// effect, in which case the certificate must not be renewed. Expired or
+// unusable override certificates are ignored so that automation resumes.
+func (c *Client) applyOverride(ctx context.Context) bool {
+	dir := c.config.OverrideDir
+	if dir == "" {
+		return false
+	}
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	cert, err := c.files.ReadFile(certPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return false
+	}
+	if err != nil {
+		c.logger.Error("Failed to read override certificate, ignoring override", "error", err, "path", certPath)
+		return false
+	}
+	key, err := c.files.ReadFile(keyPath)
+	if err != nil {
+		c.logger.Error("Failed to read override private key, ignoring override", "error", err, "path", keyPath)
+		return false
+	}
+	pair, err := tls.X509KeyPair(cert, key)
+	if err != nil {
+		c.logger.Error("Override certificate and key do not form a valid pair, ignoring override", "error", err, "dir", dir)
+		return false
+	}
+	leaf := pair.Leaf
+	if leaf == nil {
+		c.logger.Error("Override certificate has no leaf, ignoring override", "dir", dir)
+		return false
+	}
+
+	now := time.Now()
+	if now.After(leaf.NotAfter) {
+		c.logger.Error("Override certificate has expired, ignoring override and resuming automatic renewal", "dir", dir, "not_after", leaf.NotAfter)
+		c.alert("IPSSL override certificate expired",
+			fmt.Sprintf("The manual override certificate in %s expired on %s and is ignored. Automatic renewal has resumed; remove the override once the CA is available again.\n", dir, c.config.In(leaf.NotAfter).Format(time.RFC3339)))
+		return false
+	}
+
+	c.logger.Warn("MANUAL CERTIFICATE OVERRIDE ACTIVE - automatic renewal is disabled until the override is removed",
+		"dir", dir, "serial", fmt.Sprintf("%x", leaf.SerialNumber), "not_after", leaf.NotAfter)
+	if remaining := leaf.NotAfter.Sub(now); remaining < c.config.CertValidity {
+		c.logger.Warn("Override certificate expires soon", "dir", dir, "not_after", leaf.NotAfter, "remaining", remaining.Round(time.Hour))
+		c.alert("IPSSL override certificate expires soon",
+			fmt.Sprintf("The manual override certificate in %s expires on %s. Replace it or remove the override to resume automatic renewal.\n", dir, c.config.In(leaf.NotAfter).Format(time.RFC3339)))
+	}
+
+	// The override is installed once; later checks only monitor it
+	if installed, err := c.files.ReadFile(c.chainPath()); err == nil && bytes.Equal(installed, cert) {
+		return true
+	}
+
+	cycleID := c.logger.StartCycle()
+	defer c.logger.EndCycle()
+	renewal := &state.Renewal{CycleID: cycleID, Time: now, Override: true}
+	err = c.installCertificate(ctx, renewal, cert, key)
+	c.recordRenewal(renewal, err)
+	if err != nil {
+		c.logger.Error("Failed to install override certificate", "error", err)
+		return true
+	}
+	c.logger.Warn("Override certificate installed", "dir", dir, "serial", renewal.Serial)
+	return true
+}
@@ -0,0 +1,82 @@
+package ipssl
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ownershipProbeTimeout bounds each reverse DNS lookup and service probe of the ownership check
+const ownershipProbeTimeout = 3 * time.Second
+
+// checkOwnership warns when a configured IP address does not appear to belong
+// to this host: it is neither assigned to a local interface nor reachable on
+// any of IPSSL_OWNERSHIP_PORTS. The check never blocks issuance, since hosts
+// behind 1:1 NAT legitimately request certificates for addresses they do not hold.
+func (c *Client) checkOwnership(ctx context.Context) {
+	if !c.config.OwnershipCheck {
+		return
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		c.logger.Warn("Failed to list interface addresses for the ownership check", "error", err)
+		return
+	}
+
+	var unowned []string
+	for _, ip := range c.config.ClientIPs {
+		if hasAddress(addrs, net.ParseIP(ip)) {
+			continue
+		}
+		if port, ok := c.reachable(ctx, ip); ok {
+			c.logger.Info("IP address is not assigned locally but reachable", "ip", ip, "port", port)
+			continue
+		}
+
+		names := c.reverseNames(ctx, ip)
+		c.logger.Warn("IP address does not appear to belong to this host, check CLIENT_IP",
+			"ip", ip, "reverse_dns", names, "ports", c.config.OwnershipPorts)
+		entry := ip
+		if len(names) > 0 {
+			entry += " (" + strings.Join(names, ", ") + ")"
+		}
+		unowned = append(unowned, entry)
+	}
+
+	if len(unowned) > 0 {
+		c.alert("IPSSL issuing for an address this host does not appear to own",
+			fmt.Sprintf("The following addresses are not assigned to a local interface and were not reachable on ports %v:\n\n  %s\n\nCheck CLIENT_IP before the certificate is used.\n",
+				c.config.OwnershipPorts, strings.Join(unowned, "\n  ")))
+	}
+}
+
+// reachable returns the first of IPSSL_OWNERSHIP_PORTS on which ip accepts TCP connections
+func (c *Client) reachable(ctx context.Context, ip string) (int, bool) {
+	dialer := net.Dialer{Timeout: ownershipProbeTimeout}
+	for _, port := range c.config.OwnershipPorts {
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, strconv.Itoa(port)))
+		if err != nil {
+			continue
+		}
+		conn.Close()
+		return port, true
+	}
+	return 0, false
+}
+
+// reverseNames returns the PTR names of ip, or nil if the lookup fails
+func (c *Client) reverseNames(ctx context.Context, ip string) []string {
+	ctx, cancel := context.WithTimeout(ctx, ownershipProbeTimeout)
+	defer cancel()
+	names, err := net.DefaultResolver.LookupAddr(ctx, ip)
+	if err != nil {
+		return nil
+	}
+	for i, name := range names {
+		names[i] = strings.TrimSuffix(name, ".")
+	}
+	return names
+}
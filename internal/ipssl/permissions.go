@@ -0,0 +1,48 @@
+package ipssl
+
+import (
+	"fmt"
+	"os"
+
+	"ipssl-client/internal/fsys"
+)
+
+// writeArtifact writes a certificate or key file atomically and applies the
+// configured permissions and ownership. Without a configured mode, new files
+// are readable by everyone, or only by the owner if private, and existing
+// files keep their permissions.
+func (c *Client) writeArtifact(path string, data []byte, private bool) error {
+	perm, mode := os.FileMode(0644), c.config.Files.CertMode
+	if private {
+		perm, mode = 0600, c.config.Files.KeyMode
+	}
+	if err := fsys.WriteFileAtomic(c.files, path, data, perm); err != nil {
+		return err
+	}
+	return c.applyPermissions(path, mode)
+}
+
+// secureSSLDir applies the configured permissions and ownership to the SSL directory
+func (c *Client) secureSSLDir() error {
+	return c.applyPermissions(c.config.SSLDir, c.config.Files.DirMode)
+}
+
+// applyPermissions sets the mode, unless zero, and the configured owner and group of path
+func (c *Client) applyPermissions(path string, mode os.FileMode) error {
+	if mode != 0 {
+		if err := c.files.Chmod(path, mode); err != nil {
+			return fmt.Errorf("failed to set permissions of %s: %w", path, err)
+		}
+	}
+	if c.config.Files.Owner == "" && c.config.Files.Group == "" {
+		return nil
+	}
+	uid, gid, err := fsys.LookupOwner(c.config.Files.Owner, c.config.Files.Group)
+	if err != nil {
+		return err
+	}
+	if err := c.files.Chown(path, uid, gid); err != nil {
+		return fmt.Errorf("failed to set owner of %s: %w", path, err)
+	}
+	return nil
+}
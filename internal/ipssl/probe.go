@@ -0,0 +1,90 @@
+package ipssl
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+)
+
+// probeInterval is the pause between TLS probes while waiting for a reload
+const probeInterval = 2 * time.Second
+
+// servedSerial connects to addr and returns the serial number of the leaf
+// certificate presented for serverName. The chain is not verified since only
+// the identity of the served certificate matters.
+func servedSerial(ctx context.Context, addr, serverName string) (string, error) {
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: 10 * time.Second},
+		Config: &tls.Config{
+			ServerName:         serverName,
+			InsecureSkipVerify: true,
+		},
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return "", fmt.Errorf("no certificate presented by %s", addr)
+	}
+	return fmt.Sprintf("%x", certs[0].SerialNumber), nil
+}
+
+// waitForServed probes the TLS endpoint until it serves the certificate with
+// the given serial or the probe timeout expires
+func (c *Client) waitForServed(ctx context.Context, serial string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.config.TLSProbeTimeout)
+	defer cancel()
+
+	// Names are sent as SNI; IP addresses are not valid SNI values
+	serverName := ""
+	if len(c.config.Domains) > 0 {
+		serverName = c.config.Domains[0]
+	}
+
+	var last string
+	var lastErr error
+	for {
+		last, lastErr = servedSerial(ctx, c.config.TLSProbeAddr, serverName)
+		if lastErr == nil && last == serial {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("TLS probe of %s failed: %w", c.config.TLSProbeAddr, lastErr)
+			}
+			return fmt.Errorf("%s still serves certificate %s instead of %s", c.config.TLSProbeAddr, last, serial)
+		case <-time.After(probeInterval):
+		}
+	}
+}
+
+// ensureServed checks that a signal reload took effect and escalates to a
+// container restart when the old certificate is still being served
+func (c *Client) ensureServed(ctx context.Context, serial string) error {
+	err := c.waitForServed(ctx, serial)
+	if err == nil {
+		c.logger.Info("New certificate is being served", "addr", c.config.TLSProbeAddr)
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	c.logger.Warn("Reload did not take effect, restarting container", "error", err, "container", c.config.ContainerName)
+	if err := c.docker.RestartContainer(ctx, c.config.ContainerName); err != nil {
+		return fmt.Errorf("fallback restart failed: %w", err)
+	}
+	if err := c.waitForServed(ctx, serial); err != nil {
+		return err
+	}
+	c.logger.Info("New certificate is being served after restart", "addr", c.config.TLSProbeAddr)
+	return nil
+}
@@ -0,0 +1,60 @@
+package ipssl
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"path/filepath"
+)
+
+// writtenFile is a file in the SSL directory and the content written to it
+type writtenFile struct {
+	name string
+	data []byte
+}
+
+// verifyWrites reads the written files back and compares them with what was
+// written, catching filesystems that silently drop or alter writes
+func (c *Client) verifyWrites(files []writtenFile) error {
+	for _, f := range files {
+		filePath := filepath.Join(c.config.SSLDir, f.name)
+		data, err := c.files.ReadFile(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to read back %s: %w", filePath, err)
+		}
+		if err := compareWritten(filePath, f.data, data); err != nil {
+			return err
+		}
+	}
+	c.logger.Info("Written certificate files verified", "files", len(files))
+	return nil
+}
+
+// verifyContainerFiles reads the written files inside the container from
+// IPSSL_CONTAINER_SSL_DIR and compares them with what was written. A
+// mismatch usually means that the volume the server reads from is not the
+// directory the files are written to.
+func (c *Client) verifyContainerFiles(ctx context.Context, files []writtenFile) error {
+	for _, f := range files {
+		filePath := path.Join(c.config.ContainerSSLDir, f.name)
+		data, err := c.docker.ReadFile(ctx, c.config.ContainerName, filePath)
+		if err != nil {
+			return fmt.Errorf("failed to read %s in container %s: %w", filePath, c.config.ContainerName, err)
+		}
+		if err := compareWritten(filePath, f.data, data); err != nil {
+			return fmt.Errorf("%w in container %s; is %s mounted at %s?", err, c.config.ContainerName, c.config.SSLDir, c.config.ContainerSSLDir)
+		}
+	}
+	c.logger.Info("Certificate files verified in container", "container", c.config.ContainerName, "dir", c.config.ContainerSSLDir, "files", len(files))
+	return nil
+}
+
+// compareWritten returns an error naming both hashes if got differs from want
+func compareWritten(filePath string, want, got []byte) error {
+	wantSum, gotSum := sha256.Sum256(want), sha256.Sum256(got)
+	if wantSum != gotSum {
+		return fmt.Errorf("%s does not match the written file (sha256 %x, expected %x)", filePath, gotSum, wantSum)
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package ipssl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/notify"
+	"ipssl-client/internal/report"
+	"ipssl-client/internal/state"
+)
+
+// statePath returns the location of the renewal state file
+func (c *Client) statePath() string {
+	return filepath.Join(c.config.StateDir, state.FileName)
+}
+
+// updateState applies fn to the state file under its lock. Failures are only
+// logged since the state is informational.
+func (c *Client) updateState(fn func(*state.State)) {
+	err := state.Update(c.statePath(), fn)
+	if errors.Is(err, state.ErrCorrupt) {
+		c.logger.Warn("Failed to load state, starting fresh", "error", err)
+	} else if err != nil {
+		c.logger.Warn("Failed to save state", "error", err, "path", c.statePath())
+	}
+}
+
+// recordCheck stores the time of a renewal check and refreshes the expiry metric
+func (c *Client) recordCheck() {
+	c.metrics.Checked()
+	if cert, err := c.readCertificate(filepath.Join(c.config.SSLDir, "cert.pem")); err == nil {
+		c.metrics.SetCertificateExpiry(c.config.Primary(), cert.NotAfter)
+	}
+	c.updateState(func(st *state.State) {
+		st.LastCheck = time.Now()
+		st.Counters.Checks++
+	})
+}
+
+// pruneHistory applies IPSSL_HISTORY_KEEP and IPSSL_HISTORY_MAX_AGE to the
+// audit log and the state file, so long-running instances do not grow them unboundedly
+func (c *Client) pruneHistory() {
+	retention := state.Retention{Keep: c.config.HistoryKeep, MaxAge: c.config.HistoryMaxAge}
+	if !retention.Enabled() {
+		return
+	}
+	result, err := state.Prune(c.config.StateDir, retention, time.Now())
+	if err != nil {
+		c.logger.Warn("Failed to prune history", "error", err)
+		return
+	}
+	if result != (state.PruneResult{}) {
+		c.logger.Info("History pruned", "audit_entries", result.AuditEntries, "certificates", result.Certificates, "targets", result.Targets)
+	}
+}
+
+// recordRenewal stores the outcome of a certificate request
+func (c *Client) recordRenewal(renewal *state.Renewal, err error) {
+	renewal.Success = err == nil
+	if err != nil {
+		renewal.Error = err.Error()
+		c.metrics.RenewalFailed(renewal.CycleID)
+	} else {
+		c.metrics.RenewalSucceeded(renewal.CycleID)
+		c.metrics.SetCertificateExpiry(c.config.Primary(), renewal.NotAfter)
+	}
+	first := false
+	c.updateState(func(st *state.State) {
+		first = st.LastSuccess.IsZero()
+		st.LastRenewal = renewal
+		if renewal.Success {
+			st.LastSuccess = renewal.Time
+			st.Certificate(c.certificateKey()).LastSuccess = renewal.Time
+			st.Counters.RenewalSuccesses++
+		} else {
+			st.Counters.RenewalFailures++
+		}
+		for _, d := range renewal.Deployers {
+			st.RecordDeploy(d, renewal.Time)
+			c.metrics.SetTargetHealth(d.Name, st.Targets[d.Name])
+			if d.Success {
+				continue
+			}
+			if st.Counters.DeployerFailures == nil {
+				st.Counters.DeployerFailures = make(map[string]uint64)
+			}
+			st.Counters.DeployerFailures[d.Name]++
+		}
+	})
+
+	eventType := notify.EventRenewed
+	switch {
+	case !renewal.Success:
+		eventType = notify.EventRenewalFailed
+	case first:
+		eventType = notify.EventIssued
+	}
+	c.exportEvent(eventType, renewal)
+}
+
+// exportEvent sends a certificate lifecycle event to the SIEM, if configured
+func (c *Client) exportEvent(eventType string, renewal *state.Renewal) {
+	if c.siem == nil {
+		return
+	}
+	host, _ := os.Hostname()
+	event := notify.Event{
+		Time:           renewal.Time,
+		Type:           eventType,
+		Host:           host,
+		Identifiers:    c.config.Identifiers(),
+		Provider:       c.config.Provider,
+		CycleID:        renewal.CycleID,
+		Serial:         renewal.Serial,
+		PreviousSerial: renewal.PreviousSerial,
+		Changed:        renewal.Changed(),
+		NotAfter:       renewal.NotAfter,
+		Override:       renewal.Override,
+		Error:          renewal.Error,
+		Labels:         c.config.Labels,
+	}
+	if err := c.siem.Send(context.Background(), event); err != nil {
+		c.logger.Error("Failed to export event to SIEM", "error", err, "type", eventType)
+		return
+	}
+	c.logger.Info("Event exported to SIEM", "type", eventType)
+}
+
+// recordBudgetExceeded counts a renewal cycle that exceeded the latency budget
+func (c *Client) recordBudgetExceeded() {
+	c.metrics.RenewalBudgetExceeded()
+	c.updateState(func(st *state.State) {
+		st.Counters.BudgetExceeded++
+	})
+}
+
+// restoreMetrics seeds the metrics with the totals of previous runs
+func (c *Client) restoreMetrics() {
+	st, err := state.Load(c.statePath())
+	if err != nil {
+		c.logger.Warn("Failed to load state, metrics start from zero", "error", err)
+		return
+	}
+	c.metrics.Restore(st)
+}
+
+// sendReport emails the inventory report to the configured recipients
+func (c *Client) sendReport() error {
+	st, err := state.Load(c.statePath())
+	if err != nil {
+		return err
+	}
+	r := report.Build(c.config, st, time.Now())
+
+	var body bytes.Buffer
+	if err := report.Render(&body, r, c.config.Report.Format); err != nil {
+		return fmt.Errorf("failed to render report: %w", err)
+	}
+
+	if err := c.email.Send(c.config.Report.Recipients, r.Subject(), report.ContentType(c.config.Report.Format), body.Bytes()); err != nil {
+		return err
+	}
+	c.logger.Info("Certificate report sent", "recipients", c.config.Report.Recipients)
+	return nil
+}
+
+// writeCalendar refreshes the iCalendar feed of expiry dates, if configured
+func (c *Client) writeCalendar() {
+	path := c.config.Report.CalendarFile
+	if path == "" {
+		return
+	}
+
+	st, err := state.Load(c.statePath())
+	if err != nil {
+		c.logger.Warn("Failed to load state for calendar", "error", err)
+		st = &state.State{}
+	}
+
+	var buf bytes.Buffer
+	if err := report.Render(&buf, report.Build(c.config, st, time.Now()), report.FormatICS); err != nil {
+		c.logger.Error("Failed to render calendar", "error", err)
+		return
+	}
+	if err := c.files.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		c.logger.Error("Failed to write calendar", "error", err, "path", path)
+		return
+	}
+	c.logger.Info("Calendar updated", "path", path)
+}
+
+// alert emails a plain-text notification to the configured recipients, if any.
+// Alerts raised during a renewal cycle reference its ID.
+func (c *Client) alert(subject, body string) {
+	if c.email == nil {
+		return
+	}
+	if id := c.logger.CycleID(); id != "" {
+		body += "\nRenewal cycle: " + id + "\n"
+	}
+	if len(c.config.Labels) > 0 {
+		body += "Labels: " + config.FormatLabels(c.config.Labels) + "\n"
+	}
+	if err := c.email.Send(c.config.Report.Recipients, subject, "text/plain", []byte(body)); err != nil {
+		c.logger.Error("Failed to send alert", "error", err, "subject", subject)
+		return
+	}
+	c.logger.Info("Alert sent", "subject", subject, "recipients", c.config.Report.Recipients)
+}
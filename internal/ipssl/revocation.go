@@ -0,0 +1,78 @@
+package ipssl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ipssl-client/internal/chain"
+	"ipssl-client/internal/notify"
+	"ipssl-client/internal/ocsp"
+	"ipssl-client/internal/state"
+)
+
+// checkRevocation asks the CA for the revocation status of the installed
+// certificate, by OCSP or else its CRL, which is also used when the responder
+// fails. A revocation is alerted and exported once per certificate. It reports
+// whether the certificate must be reissued, which is only the case with
+// IPSSL_REVOKED_REISSUE.
+func (c *Client) checkRevocation(ctx context.Context) bool {
+	data, err := c.files.ReadFile(c.chainPath())
+	if err != nil {
+		return false
+	}
+	certs, err := chain.Parse(data)
+	if err != nil || isStopgap(certs[0]) {
+		return false
+	}
+	if len(certs) < 2 {
+		c.logger.Warn("Cannot check revocation, the certificate chain has no issuer")
+		return false
+	}
+
+	serial := fmt.Sprintf("%x", certs[0].SerialNumber)
+	resp, err := c.ocsp.Check(ctx, certs[0], certs[1])
+	if errors.Is(err, ocsp.ErrNoRevocationInfo) {
+		c.logger.Warn("Cannot check revocation of the installed certificate", "error", err, "serial", serial)
+		return false
+	}
+	if err != nil {
+		c.logger.Warn("Failed to check revocation of the installed certificate", "error", err, "serial", serial)
+		return false
+	}
+	switch resp.Status {
+	case ocsp.StatusGood:
+		return false
+	case ocsp.StatusUnknown:
+		c.logger.Warn("CA does not know the installed certificate", "serial", serial)
+		return false
+	}
+
+	if serial != c.revokedSerial {
+		c.revokedSerial = serial
+		c.logger.Error("Installed certificate has been revoked by the CA", "serial", serial, "revoked_at", resp.RevokedAt, "reissue", c.config.RevokedReissue)
+		action := "Clients checking revocation will reject it until it is replaced. Set IPSSL_REVOKED_REISSUE=true to reissue revoked certificates automatically."
+		if c.config.RevokedReissue {
+			action = "A new certificate is being requested."
+		}
+		c.alert("IPSSL certificate revoked",
+			fmt.Sprintf("The certificate for %s (serial %s) was revoked by the CA on %s.\n%s\n",
+				strings.Join(c.config.Identifiers(), ", "), serial, c.config.In(resp.RevokedAt).Format(time.RFC3339), action))
+		c.exportEvent(notify.EventRevoked, &state.Renewal{
+			Time:           time.Now(),
+			Serial:         serial,
+			PreviousSerial: serial,
+			NotAfter:       certs[0].NotAfter,
+			Error:          "certificate revoked at " + resp.RevokedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	return c.config.RevokedReissue
+}
+
+// isRevoked reports whether the leaf with the given serial was reported revoked
+// and is to be reissued
+func (c *Client) isRevoked(serial string) bool {
+	return c.config.RevokedReissue && c.revokedSerial != "" && serial == c.revokedSerial
+}
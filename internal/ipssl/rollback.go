@@ -0,0 +1,113 @@
+package ipssl
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/state"
+)
+
+// backupSuffix marks the copies of the chain (cert.pem, or fullchain.pem with the
+// split layout) and key.pem replaced by the last installation
+const backupSuffix = ".bak"
+
+// rollbackTimeout bounds restoring and reloading the previous certificate
+const rollbackTimeout = 5 * time.Minute
+
+// backupCertificate archives the installed pair before it is replaced so that
+// a failed installation can be rolled back. Without an installed pair, the
+// backup of an earlier installation is removed, as it is not what is served.
+func (c *Client) backupCertificate() error {
+	certPath := c.chainPath()
+	keyPath := filepath.Join(c.config.SSLDir, "key.pem")
+
+	cert, certErr := c.files.ReadFile(certPath)
+	key, keyErr := c.files.ReadFile(keyPath)
+	if os.IsNotExist(certErr) || os.IsNotExist(keyErr) {
+		for _, path := range []string{certPath + backupSuffix, keyPath + backupSuffix} {
+			if err := c.files.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	}
+	if certErr != nil {
+		return certErr
+	}
+	if keyErr != nil {
+		return keyErr
+	}
+
+	if err := c.writeArtifact(certPath+backupSuffix, cert, false); err != nil {
+		return err
+	}
+	return c.writeArtifact(keyPath+backupSuffix, key, true)
+}
+
+// rollback restores the pair archived by backupCertificate and reloads the
+// container again, so that a failed installation does not leave the site
+// serving a broken certificate
+func (c *Client) rollback(ctx context.Context) error {
+	certPath := filepath.Join(c.config.SSLDir, "cert.pem")
+	keyPath := filepath.Join(c.config.SSLDir, "key.pem")
+
+	cert, err := c.files.ReadFile(c.chainPath() + backupSuffix)
+	if err != nil {
+		return fmt.Errorf("no previous certificate to restore: %w", err)
+	}
+	key, err := c.files.ReadFile(keyPath + backupSuffix)
+	if err != nil {
+		return fmt.Errorf("no previous private key to restore: %w", err)
+	}
+
+	certFile, err := c.certFile(cert)
+	if err != nil {
+		return fmt.Errorf("failed to restore certificate: %w", err)
+	}
+	if err := c.writeArtifact(certPath, certFile, false); err != nil {
+		return fmt.Errorf("failed to restore certificate: %w", err)
+	}
+	if err := c.writeArtifact(keyPath, key, true); err != nil {
+		return fmt.Errorf("failed to restore private key: %w", err)
+	}
+	if err := c.writeOutputs(cert, key); err != nil {
+		return fmt.Errorf("failed to restore additional formats: %w", err)
+	}
+
+	restored := &state.Renewal{CycleID: c.logger.CycleID()}
+	if leaf, err := c.readCertificate(certPath); err == nil {
+		restored.Serial = fmt.Sprintf("%x", leaf.SerialNumber)
+	}
+	c.logger.Warn("Restored the previous certificate", "serial", restored.Serial)
+
+	if c.canReload() {
+		if err := c.reloadContainer(ctx, restored); err != nil {
+			return fmt.Errorf("failed to reload container with the previous certificate: %w", err)
+		}
+	}
+	return nil
+}
+
+// rollbackAfter restores the previous certificate after a failed installation
+// and reports whether it was restored. The rollback also runs when ctx was
+// cancelled by a shutdown or the renewal watchdog, bounded by rollbackTimeout,
+// since the failure may have left the site without a working certificate.
+func (c *Client) rollbackAfter(ctx context.Context, cause error) bool {
+	c.logger.Error("Installation failed, rolling back to the previous certificate", "error", cause)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+	defer cancel()
+	if err := c.rollback(ctx); err != nil {
+		c.logger.Error("Failed to roll back to the previous certificate", "error", err)
+		return false
+	}
+	return true
+}
+
+// canReload reports whether the container can be told to load new certificates
+func (c *Client) canReload() bool {
+	return c.config.ReloadStrategy == config.ReloadTrigger || (c.docker != nil && c.config.ContainerName != "")
+}
@@ -0,0 +1,40 @@
+package ipssl
+
+import (
+	"crypto/x509"
+	"path/filepath"
+	"time"
+)
+
+// renewalDue returns when cert is due for renewal: once RenewalFraction of its
+// lifetime has elapsed or only CertValidity remains, whichever comes first
+func (c *Client) renewalDue(cert *x509.Certificate) time.Time {
+	due := cert.NotAfter.Add(-c.config.CertValidity)
+	if fraction := c.config.RenewalFraction; fraction > 0 {
+		lifetime := cert.NotAfter.Sub(cert.NotBefore)
+		if byFraction := cert.NotBefore.Add(time.Duration(float64(lifetime) * fraction)); byFraction.Before(due) {
+			due = byFraction
+		}
+	}
+	return due
+}
+
+// nextCheck returns how long to sleep until the next renewal check: until the
+// installed certificate is due, but at most RenewalInterval so that overrides,
+// freezes and failover are still noticed. After a failed renewal the due time
+// has passed and the check is retried after RenewalInterval.
+func (c *Client) nextCheck() time.Duration {
+	wait := c.config.RenewalInterval
+	cert, err := c.readCertificate(filepath.Join(c.config.SSLDir, "cert.pem"))
+	if err != nil {
+		c.planCheck(wait, nil)
+		return wait
+	}
+	due := c.renewalDue(cert)
+	if until := time.Until(due); until > 0 && until < wait {
+		wait = until
+	}
+	c.logger.Info("Next renewal check scheduled", "at", c.config.In(time.Now().Add(wait).Round(time.Second)), "renewal_due", c.config.In(due))
+	c.planCheck(wait, nil)
+	return wait
+}
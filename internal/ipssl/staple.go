@@ -0,0 +1,99 @@
+package ipssl
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ipssl-client/internal/chain"
+	"ipssl-client/internal/ocsp"
+	"ipssl-client/internal/state"
+)
+
+// stapleFile is the OCSP response written next to cert.pem for servers
+// configured with a static staple file
+const stapleFile = "ocsp.der"
+
+// stapleInstalled fetches the OCSP response for a newly installed chain so
+// that it is in place when the server reloads. The response of the replaced
+// certificate is removed if none can be fetched, since servers refuse to
+// staple a response for another certificate.
+func (c *Client) stapleInstalled(ctx context.Context, cert []byte) {
+	certs, err := chain.Parse(cert)
+	if err == nil {
+		_, err = c.fetchStaple(ctx, certs)
+	}
+	if err == nil {
+		return
+	}
+	c.logger.Warn("Failed to fetch OCSP response for the new certificate", "error", err)
+	path := filepath.Join(c.config.SSLDir, stapleFile)
+	if err := c.files.Remove(path); err != nil && !os.IsNotExist(err) {
+		c.logger.Error("Failed to remove stale OCSP response", "error", err, "path", path)
+	}
+}
+
+// refreshStaple keeps the OCSP response of the installed certificate fresh
+// and returns when to check it again. A response that is past its refresh
+// time is replaced and the container reloaded so that it staples the new one;
+// failures are retried after IPSSL_RENEWAL_RETRY_DELAY.
+func (c *Client) refreshStaple(ctx context.Context) time.Duration {
+	data, err := c.files.ReadFile(c.chainPath())
+	if err != nil {
+		return c.config.RenewalRetry
+	}
+	certs, err := chain.Parse(data)
+	if err != nil || isStopgap(certs[0]) {
+		return c.config.RenewalRetry
+	}
+
+	path := filepath.Join(c.config.SSLDir, stapleFile)
+	if der, err := c.files.ReadFile(path); err == nil && len(certs) > 1 {
+		if current, err := ocsp.Parse(der, certs[0], certs[1]); err == nil && time.Now().Before(current.RefreshAt()) {
+			return time.Until(current.RefreshAt())
+		}
+	}
+
+	resp, err := c.fetchStaple(ctx, certs)
+	if errors.Is(err, ocsp.ErrNoResponder) {
+		c.logger.Warn("Installed certificate cannot be stapled", "error", err)
+		return c.config.RenewalInterval
+	}
+	if err != nil {
+		c.logger.Warn("Failed to refresh OCSP response", "error", err, "retry_in", c.config.RenewalRetry)
+		return c.config.RenewalRetry
+	}
+
+	if c.canReload() {
+		if err := c.reloadContainer(ctx, &state.Renewal{CycleID: c.logger.CycleID()}); err != nil {
+			c.logger.Error("Failed to reload container after refreshing OCSP response", "error", err)
+		}
+	}
+	return time.Until(resp.RefreshAt())
+}
+
+// fetchStaple fetches the OCSP response for the leaf of certs and writes it
+// to the staple file. Only good responses are stapled.
+func (c *Client) fetchStaple(ctx context.Context, certs []*x509.Certificate) (*ocsp.Response, error) {
+	if len(certs) < 2 {
+		return nil, fmt.Errorf("certificate chain has no issuer to verify the OCSP response with")
+	}
+	resp, err := c.ocsp.Fetch(ctx, certs[0], certs[1])
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != ocsp.StatusGood {
+		return nil, fmt.Errorf("OCSP responder reports the certificate as %s", resp.Status)
+	}
+
+	path := filepath.Join(c.config.SSLDir, stapleFile)
+	if err := c.writeArtifact(path, resp.Raw, false); err != nil {
+		return nil, fmt.Errorf("failed to save OCSP response: %w", err)
+	}
+	c.logger.Info("OCSP response saved", "path", path, "next_update", resp.NextUpdate, "refresh_at", resp.RefreshAt())
+	return resp, nil
+}
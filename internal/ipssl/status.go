@@ -0,0 +1,107 @@
+package ipssl
+
+import (
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"ipssl-client/internal/state"
+)
+
+// Reasons for retrying a renewal earlier or later than planned
+const (
+	backoffStartup        = "startup"
+	backoffRenewalFailed  = "renewal_failed"
+	backoffRenewalTimeout = "renewal_timeout"
+	backoffRestart        = "restart"
+)
+
+// renewalPlan is when a certificate is next checked and renewed, served on
+// /status so that external schedulers can plan maintenance around renewals
+type renewalPlan struct {
+	Name        string            `json:"name,omitempty"`
+	Identifiers []string          `json:"identifiers"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	NotAfter    time.Time         `json:"not_after,omitempty"`
+	RenewalDue  time.Time         `json:"renewal_due,omitempty"`
+	NextCheck   time.Time         `json:"next_check,omitempty"`
+	NextRenewal time.Time         `json:"next_renewal,omitempty"`
+	FrozenUntil time.Time         `json:"frozen_until,omitempty"`
+	Backoff     *backoffState     `json:"backoff,omitempty"`
+}
+
+// backoffState describes a retry after a failure
+type backoffState struct {
+	Reason  string    `json:"reason"`
+	Attempt int       `json:"attempt,omitempty"`
+	RetryAt time.Time `json:"retry_at"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// statusResponse is the body of the /status response
+type statusResponse struct {
+	Certificates []renewalPlan `json:"certificates"`
+}
+
+// planCheck records that the next check runs after wait, retrying after a
+// failure if backoff is set
+func (c *Client) planCheck(wait time.Duration, backoff *backoffState) {
+	at := time.Now().Add(wait)
+	if backoff != nil {
+		backoff.RetryAt = at
+	}
+	c.planMu.Lock()
+	defer c.planMu.Unlock()
+	c.nextCheckAt, c.backoff = at, backoff
+}
+
+// plan returns the renewal plan of the certificate. The renewal happens at the
+// first check once it is due, or at the next check if it is due already,
+// but not before a freeze ends.
+func (c *Client) plan() renewalPlan {
+	c.planMu.Lock()
+	p := renewalPlan{Identifiers: c.config.Identifiers(), Labels: c.config.Labels, NextCheck: c.nextCheckAt}
+	if c.backoff != nil {
+		backoff := *c.backoff
+		p.Backoff = &backoff
+	}
+	c.planMu.Unlock()
+
+	p.NextRenewal = p.NextCheck
+	if cert, err := c.readCertificate(filepath.Join(c.config.SSLDir, "cert.pem")); err == nil && !isStopgap(cert) {
+		p.NotAfter = cert.NotAfter
+		p.RenewalDue = c.renewalDue(cert)
+		if p.RenewalDue.After(p.NextRenewal) {
+			p.NextRenewal = p.RenewalDue
+		}
+	}
+	if st, err := state.Load(c.statePath()); err == nil {
+		if freeze := st.ActiveFreeze(time.Now()); freeze != nil {
+			p.FrozenUntil = freeze.Until
+			if freeze.Until.After(p.NextRenewal) {
+				p.NextRenewal = freeze.Until
+			}
+		}
+	}
+	return p.in(c)
+}
+
+// in converts the times of the plan to the configured time zone
+func (p renewalPlan) in(c *Client) renewalPlan {
+	p.NotAfter = c.config.In(p.NotAfter)
+	p.RenewalDue = c.config.In(p.RenewalDue)
+	p.NextCheck = c.config.In(p.NextCheck)
+	p.NextRenewal = c.config.In(p.NextRenewal)
+	p.FrozenUntil = c.config.In(p.FrozenUntil)
+	if p.Backoff != nil {
+		p.Backoff.RetryAt = c.config.In(p.Backoff.RetryAt)
+	}
+	return p
+}
+
+// statusHandler serves /status with the renewal plan of the certificate
+func (c *Client) statusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveJSON(w, http.StatusOK, statusResponse{Certificates: []renewalPlan{c.plan()}})
+	})
+}
@@ -0,0 +1,120 @@
+package ipssl
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"ipssl-client/internal/chain"
+	"ipssl-client/internal/ct"
+)
+
+// loadRoots returns the system roots extended with the PEM certificates in
+// path, or nil to use the system roots alone when path is empty
+func loadRoots(path string) (*x509.CertPool, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read IPSSL_CHAIN_ROOTS: %w", err)
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in IPSSL_CHAIN_ROOTS %s", path)
+	}
+	return roots, nil
+}
+
+// verifyChain checks an issued chain before it is installed: the leaf has to
+// cover every requested identifier and, unless disabled, lead through the
+// intermediates to a trusted root. Failures log every certificate of the chain.
+func (c *Client) verifyChain(certs []*x509.Certificate, identifiers []string) error {
+	if missing := chain.Missing(certs[0], identifiers); len(missing) > 0 {
+		c.logChain(certs)
+		return fmt.Errorf("issued certificate does not cover %s", strings.Join(missing, ", "))
+	}
+
+	switch {
+	case !c.config.VerifyChain:
+		return nil
+	case c.config.Sandbox && c.roots == nil:
+		c.logger.Info("Skipping chain verification, sandbox certificates are not publicly trusted")
+		return nil
+	}
+	if err := chain.Verify(certs, c.roots); err != nil {
+		c.logChain(certs)
+		return fmt.Errorf("issued certificate does not chain to a trusted root: %w", err)
+	}
+	c.logger.Info("Certificate chain verified", "certificates", len(certs))
+	return nil
+}
+
+// verifyCT checks that an issued certificate embeds SCTs of at least
+// IPSSL_CT_MIN_SCTS distinct CT logs, verified against IPSSL_CT_LOG_LIST when
+// set. The certificate is installed either way; missing CT evidence is
+// logged and alerted so that it can be followed up with the CA.
+func (c *Client) verifyCT(certs []*x509.Certificate) {
+	if c.config.Sandbox {
+		c.logger.Info("Skipping CT verification, sandbox certificates are not logged")
+		return
+	}
+
+	logged, err := c.ctLogged(certs)
+	if err == nil && logged < c.config.CTMinSCTs {
+		err = fmt.Errorf("certificate has SCTs of %d CT logs, %d required", logged, c.config.CTMinSCTs)
+	}
+	if err != nil {
+		c.logger.Error("Issued certificate is not CT-logged", "error", err, "serial", fmt.Sprintf("%x", certs[0].SerialNumber))
+		c.alert("IPSSL certificate is not CT-logged",
+			fmt.Sprintf("The certificate issued for %s (serial %x) failed Certificate Transparency verification:\n\n%v\n", strings.Join(c.config.Identifiers(), ", "), certs[0].SerialNumber, err))
+		return
+	}
+	c.logger.Info("Certificate Transparency verified", "logs", logged)
+}
+
+// ctLogged returns the number of distinct logs with an SCT embedded in the
+// leaf of certs, counting only valid SCTs of known logs if a log list is loaded
+func (c *Client) ctLogged(certs []*x509.Certificate) (int, error) {
+	if c.ctLogs == nil {
+		scts, err := ct.EmbeddedSCTs(certs[0])
+		if err != nil {
+			return 0, err
+		}
+		logs := make(map[[32]byte]bool)
+		for _, sct := range scts {
+			logs[sct.LogID] = true
+		}
+		return len(logs), nil
+	}
+
+	if len(certs) < 2 {
+		return 0, fmt.Errorf("certificate chain has no issuer to verify SCTs with")
+	}
+	result, err := ct.Check(certs[0], certs[1], c.ctLogs)
+	if err != nil {
+		return 0, err
+	}
+	for _, err := range result.Errors {
+		c.logger.Warn("SCT not verified", "error", err)
+	}
+	return len(result.Logs), nil
+}
+
+// logChain logs the certificates of a chain that failed verification
+func (c *Client) logChain(certs []*x509.Certificate) {
+	for i, cert := range certs {
+		c.logger.Error("Certificate chain entry",
+			"index", i,
+			"subject", cert.Subject.String(),
+			"issuer", cert.Issuer.String(),
+			"ip_sans", cert.IPAddresses,
+			"dns_sans", cert.DNSNames,
+			"not_before", cert.NotBefore,
+			"not_after", cert.NotAfter)
+	}
+}
@@ -0,0 +1,130 @@
+package ipssl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"ipssl-client/internal/deploy"
+	"ipssl-client/internal/state"
+)
+
+// vrrpCheckTimeout bounds the VRRP check command
+const vrrpCheckTimeout = 10 * time.Second
+
+// VRRP roles
+const (
+	roleActive  = "active"
+	roleStandby = "standby"
+)
+
+// standby reports whether this node is a VRRP standby that must not contact
+// the certificate authority. When the role cannot be determined the node
+// assumes standby, since two nodes issuing at once burns quota and rate limits.
+func (c *Client) standby(ctx context.Context) bool {
+	if !c.config.VRRP.Enabled() {
+		return false
+	}
+	active, err := c.holdsVIP(ctx)
+	if err != nil {
+		c.logger.Warn("Failed to determine VRRP role, assuming standby", "error", err)
+	}
+
+	role := roleStandby
+	if active {
+		role = roleActive
+	}
+	if role != c.vrrpRole {
+		c.logger.Info("VRRP role changed", "role", role, "previous", c.vrrpRole, "vip", c.config.VRRP.VIP)
+		c.vrrpRole = role
+	}
+	return !active
+}
+
+// holdsVIP reports whether this node is the active VRRP node, using the check
+// command if configured and otherwise the local interface addresses
+func (c *Client) holdsVIP(ctx context.Context) (bool, error) {
+	if command := c.config.VRRP.CheckCommand; command != "" {
+		ctx, cancel := context.WithTimeout(ctx, vrrpCheckTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Env = deploy.CommandEnv(os.Environ(), c.config.HookEnv)
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && ctx.Err() == nil {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("VRRP check command failed: %w", err)
+		}
+		return true, nil
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false, fmt.Errorf("failed to list interface addresses: %w", err)
+	}
+	return hasAddress(addrs, net.ParseIP(c.config.VRRP.VIP)), nil
+}
+
+// hasAddress reports whether ip is among the interface addresses
+func hasAddress(addrs []net.Addr, ip net.IP) bool {
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// followActive runs a standby check in download-only mode: the certificate is
+// never requested, but one placed in the SSL directory by the active node
+// (shared storage or an upload deployer) is reloaded and deployed locally
+func (c *Client) followActive(ctx context.Context) {
+	certPath := filepath.Join(c.config.SSLDir, "cert.pem")
+	leaf, err := c.readCertificate(certPath)
+	if err != nil {
+		c.logger.Info("Standby node, waiting for the active node to provide a certificate", "cert_path", certPath)
+		return
+	}
+	serial := fmt.Sprintf("%x", leaf.SerialNumber)
+	if serial == c.followedSerial {
+		c.logger.Info("Standby node, certificate unchanged", "serial", serial, "not_after", leaf.NotAfter)
+		return
+	}
+	if c.followedSerial == "" {
+		// Whatever was on disk at startup is already being served
+		c.followedSerial = serial
+		c.logger.Info("Standby node, using certificate provided by the active node", "serial", serial, "not_after", leaf.NotAfter)
+		return
+	}
+
+	cert, err := c.files.ReadFile(c.chainPath())
+	if err != nil {
+		c.logger.Error("Failed to read certificate provided by the active node", "error", err)
+		return
+	}
+	key, err := c.files.ReadFile(filepath.Join(c.config.SSLDir, "key.pem"))
+	if err != nil {
+		c.logger.Error("Failed to read private key provided by the active node", "error", err)
+		return
+	}
+
+	cycleID := c.logger.StartCycle()
+	defer c.logger.EndCycle()
+	c.logger.Info("Active node provided a new certificate, installing", "serial", serial, "previous", c.followedSerial)
+	// The active node already replaced cert.pem, so the previous serial is the one followed last
+	renewal := &state.Renewal{CycleID: cycleID, Time: time.Now(), PreviousSerial: c.followedSerial}
+	err = c.installCertificate(ctx, renewal, cert, key)
+	c.recordRenewal(renewal, err)
+	if err != nil {
+		c.logger.Error("Failed to install certificate provided by the active node", "error", err)
+		return
+	}
+	c.followedSerial = serial
+}
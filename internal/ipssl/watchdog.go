@@ -0,0 +1,35 @@
+package ipssl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"ipssl-client/internal/state"
+)
+
+// errRenewalTimeout is the cause of a renewal cycle cancelled by the watchdog
+var errRenewalTimeout = errors.New("renewal cycle exceeded IPSSL_RENEWAL_TIMEOUT")
+
+// watchdog bounds a renewal cycle to IPSSL_RENEWAL_TIMEOUT, so that a single
+// stuck API call cannot wedge the renewal loop: once the deadline passes the
+// cycle's context is cancelled with errRenewalTimeout as its cause
+func (c *Client) watchdog(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.config.RenewalTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, c.config.RenewalTimeout, errRenewalTimeout)
+}
+
+// timedOut marks err as caused by the watchdog if it cancelled the cycle, and counts the timeout
+func (c *Client) timedOut(cycleCtx context.Context, err error) error {
+	if context.Cause(cycleCtx) != errRenewalTimeout {
+		return err
+	}
+	c.logger.Error("Renewal cycle exceeded its maximum duration and was cancelled", "timeout", c.config.RenewalTimeout)
+	c.metrics.RenewalTimedOut()
+	c.updateState(func(st *state.State) {
+		st.Counters.RenewalTimeouts++
+	})
+	return fmt.Errorf("%w: %w", errRenewalTimeout, err)
+}
@@ -0,0 +1,151 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Supported certificate key types
+const (
+	RSA2048   = "rsa2048"
+	RSA4096   = "rsa4096"
+	ECDSAP256 = "ecdsa-p256"
+	ECDSAP384 = "ecdsa-p384"
+	Ed25519   = "ed25519"
+)
+
+// Private key PEM encodings
+const (
+	// FormatTraditional is PKCS#1 for RSA, SEC 1 for ECDSA and PKCS#8 for Ed25519
+	FormatTraditional = "traditional"
+	FormatPKCS8       = "pkcs8"
+)
+
+// All lists every key type the client can generate, in order of preference for fallbacks
+var All = []string{RSA2048, RSA4096, ECDSAP256, ECDSAP384, Ed25519}
+
+// Valid reports whether keyType is a known key type
+func Valid(keyType string) bool {
+	return slices.Contains(All, keyType)
+}
+
+// Generate creates a private key of the given type
+func Generate(keyType string) (crypto.Signer, error) {
+	switch keyType {
+	case RSA2048:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case RSA4096:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	case ECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case ECDSAP384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case Ed25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", keyType)
+	}
+}
+
+// TypeOf returns the key type of a public key, such as rsa2048 or ecdsa-p256.
+// RSA keys and curves the client does not generate are named the same way
+// (e.g. rsa3072, ecdsa-p521); other keys return "".
+func TypeOf(pub crypto.PublicKey) string {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("rsa%d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ecdsa-p" + strings.TrimPrefix(k.Curve.Params().Name, "P-")
+	case ed25519.PublicKey:
+		return Ed25519
+	default:
+		return ""
+	}
+}
+
+// EncodePEM encodes a private key in the traditional PEM form for its algorithm:
+// PKCS#1 for RSA, SEC 1 for ECDSA and PKCS#8 for Ed25519
+func EncodePEM(key crypto.Signer) ([]byte, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}), nil
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+	default:
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+	}
+}
+
+// Negotiate picks the key type to use with a provider. The requested type is
+// used when supported; otherwise the first supported type is returned if
+// fallback is allowed.
+func Negotiate(requested string, supported []string, fallback bool) (string, error) {
+	if slices.Contains(supported, requested) {
+		return requested, nil
+	}
+	if !fallback || len(supported) == 0 {
+		return "", fmt.Errorf("key type %s is not supported by the provider (supported: %v)", requested, supported)
+	}
+	return supported[0], nil
+}
+
+// ConvertPEM re-encodes a PEM private key in the given format
+func ConvertPEM(keyPEM []byte, format string) ([]byte, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode private key PEM")
+	}
+
+	var (
+		key any
+		err error
+	)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported private key type %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key %T", key)
+	}
+
+	switch format {
+	case FormatTraditional:
+		return EncodePEM(signer)
+	case FormatPKCS8:
+		der, err := x509.MarshalPKCS8PrivateKey(signer)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+	default:
+		return nil, fmt.Errorf("unknown private key format %q", format)
+	}
+}
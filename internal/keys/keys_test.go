@@ -0,0 +1,124 @@
+package keys
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+)
+
+func TestGenerateAndEncode(t *testing.T) {
+	for _, keyType := range []string{RSA2048, ECDSAP256, ECDSAP384, Ed25519} {
+		key, err := Generate(keyType)
+		if err != nil {
+			t.Fatalf("Generate(%s) failed: %v", keyType, err)
+		}
+		if got := TypeOf(key.Public()); got != keyType {
+			t.Errorf("Expected TypeOf to return %s, got %q", keyType, got)
+		}
+		data, err := EncodePEM(key)
+		if err != nil {
+			t.Fatalf("EncodePEM(%s) failed: %v", keyType, err)
+		}
+		block, _ := pem.Decode(data)
+		if block == nil {
+			t.Fatalf("EncodePEM(%s) produced no PEM block", keyType)
+		}
+
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			_, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			_, err = x509.ParseECPrivateKey(block.Bytes)
+		default:
+			_, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		}
+		if err != nil {
+			t.Errorf("%s key does not round-trip: %v", keyType, err)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	supported := []string{RSA2048, ECDSAP256}
+
+	if got, err := Negotiate(ECDSAP256, supported, false); err != nil || got != ECDSAP256 {
+		t.Errorf("Expected supported type to be kept, got %q, %v", got, err)
+	}
+	if got, err := Negotiate(Ed25519, supported, true); err != nil || got != RSA2048 {
+		t.Errorf("Expected fallback to %s, got %q, %v", RSA2048, got, err)
+	}
+	if _, err := Negotiate(Ed25519, supported, false); err == nil {
+		t.Error("Expected error without fallback, got nil")
+	}
+}
+
+func TestMustStaple(t *testing.T) {
+	key, err := Generate(ECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		ExtraExtensions: []pkix.Extension{MustStaple()},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !HasMustStaple(cert) {
+		t.Error("Expected the certificate to require stapling")
+	}
+
+	template.ExtraExtensions = nil
+	der, err = x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert, _ = x509.ParseCertificate(der); HasMustStaple(cert) {
+		t.Error("Expected a certificate without the extension not to require stapling")
+	}
+}
+
+func TestConvertPEM(t *testing.T) {
+	for _, keyType := range []string{RSA2048, ECDSAP256, Ed25519} {
+		key, err := Generate(keyType)
+		if err != nil {
+			t.Fatalf("Generate(%s) failed: %v", keyType, err)
+		}
+		traditional, err := EncodePEM(key)
+		if err != nil {
+			t.Fatalf("EncodePEM(%s) failed: %v", keyType, err)
+		}
+
+		pkcs8, err := ConvertPEM(traditional, FormatPKCS8)
+		if err != nil {
+			t.Fatalf("ConvertPEM(%s, pkcs8) failed: %v", keyType, err)
+		}
+		block, _ := pem.Decode(pkcs8)
+		if block == nil || block.Type != "PRIVATE KEY" {
+			t.Fatalf("Expected a PKCS#8 block for %s, got %v", keyType, block)
+		}
+		if _, err := x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+			t.Errorf("%s PKCS#8 key does not parse: %v", keyType, err)
+		}
+
+		back, err := ConvertPEM(pkcs8, FormatTraditional)
+		if err != nil {
+			t.Fatalf("ConvertPEM(%s, traditional) failed: %v", keyType, err)
+		}
+		if string(back) != string(traditional) {
+			t.Errorf("%s key does not round-trip to the traditional encoding", keyType)
+		}
+	}
+
+	if _, err := ConvertPEM([]byte("not a key"), FormatPKCS8); err == nil {
+		t.Error("Expected error for invalid PEM, got nil")
+	}
+}
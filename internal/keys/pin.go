@@ -0,0 +1,13 @@
+package keys
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// SPKIPin returns the base64 SHA-256 hash of a DER SubjectPublicKeyInfo, the
+// pin-sha256 value of HPKP (RFC 7469) that mobile pinning libraries also use
+func SPKIPin(spki []byte) string {
+	sum := sha256.Sum256(spki)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
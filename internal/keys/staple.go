@@ -0,0 +1,31 @@
+package keys
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"slices"
+)
+
+// oidTLSFeature identifies the TLS Feature extension (RFC 7633)
+var oidTLSFeature = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}
+
+// mustStapleValue is the DER encoding of the feature list [status_request]
+var mustStapleValue = []byte{0x30, 0x03, 0x02, 0x01, 0x05}
+
+// MustStaple returns the TLS Feature extension requesting OCSP Must-Staple.
+// In a CSR it asks the CA for a certificate that clients reject unless the
+// server staples a valid OCSP response.
+func MustStaple() pkix.Extension {
+	return pkix.Extension{Id: oidTLSFeature, Value: slices.Clone(mustStapleValue)}
+}
+
+// HasMustStaple reports whether cert carries the OCSP Must-Staple extension
+func HasMustStaple(cert *x509.Certificate) bool {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidTLSFeature) && slices.Equal(ext.Value, mustStapleValue) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,13 +1,18 @@
 package logger
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"log/slog"
 	"os"
+	"sync/atomic"
 )
 
 // Logger wraps slog.Logger with additional methods
 type Logger struct {
 	*slog.Logger
+	cycle *atomic.Pointer[string]
 }
 
 // New creates a new logger instance
@@ -16,10 +21,23 @@ func New() *Logger {
 		Level: slog.LevelInfo,
 	}
 
-	var handler slog.Handler = slog.NewJSONHandler(os.Stdout, opts)
+	cycle := &atomic.Pointer[string]{}
+	var handler slog.Handler = &cycleHandler{Handler: slog.NewJSONHandler(os.Stdout, opts), cycle: cycle}
 	logger := slog.New(handler)
 
-	return &Logger{Logger: logger}
+	return &Logger{Logger: logger, cycle: cycle}
+}
+
+// With returns a logger adding args to every entry. It tracks its own renewal
+// cycle, so that clients running side by side only tag their own entries.
+func (l *Logger) With(args ...any) *Logger {
+	handler := l.Handler()
+	if h, ok := handler.(*cycleHandler); ok {
+		handler = h.Handler
+	}
+	cycle := &atomic.Pointer[string]{}
+	logger := slog.New(&cycleHandler{Handler: handler, cycle: cycle}).With(args...)
+	return &Logger{Logger: logger, cycle: cycle}
 }
 
 // Fatal logs a fatal error and exits the program
@@ -27,3 +45,50 @@ func (l *Logger) Fatal(msg string, args ...any) {
 	l.Error(msg, args...)
 	os.Exit(1)
 }
+
+// StartCycle generates a renewal cycle ID and tags every entry logged until
+// EndCycle with it, including entries from components sharing this logger
+func (l *Logger) StartCycle() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	id := hex.EncodeToString(buf)
+	l.cycle.Store(&id)
+	return id
+}
+
+// EndCycle stops tagging entries with the renewal cycle ID
+func (l *Logger) EndCycle() {
+	l.cycle.Store(nil)
+}
+
+// CycleID returns the ID of the running renewal cycle, or "" outside a cycle
+func (l *Logger) CycleID() string {
+	if id := l.cycle.Load(); id != nil {
+		return *id
+	}
+	return ""
+}
+
+// cycleHandler adds the current renewal cycle ID to every record
+type cycleHandler struct {
+	slog.Handler
+	cycle *atomic.Pointer[string]
+}
+
+// Handle implements slog.Handler
+func (h *cycleHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := h.cycle.Load(); id != nil {
+		r.AddAttrs(slog.String("cycle_id", *id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler
+func (h *cycleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &cycleHandler{Handler: h.Handler.WithAttrs(attrs), cycle: h.cycle}
+}
+
+// WithGroup implements slog.Handler
+func (h *cycleHandler) WithGroup(name string) slog.Handler {
+	return &cycleHandler{Handler: h.Handler.WithGroup(name), cycle: h.cycle}
+}
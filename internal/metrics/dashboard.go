@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DashboardOptions parameterizes the generated Grafana dashboard
+type DashboardOptions struct {
+	Title string
+
+	// Labels become dashboard variables that filter every query, e.g. job and instance
+	Labels []string
+}
+
+// Dashboard returns a Grafana dashboard JSON model for the exported metrics
+func Dashboard(opts DashboardOptions) ([]byte, error) {
+	if opts.Title == "" {
+		opts.Title = "IPSSL certificates"
+	}
+	if len(opts.Labels) == 0 {
+		opts.Labels = []string{"job", "instance"}
+	}
+
+	matchers := make([]string, 0, len(opts.Labels))
+	variables := []map[string]any{{
+		"name":  "datasource",
+		"label": "Data source",
+		"type":  "datasource",
+		"query": "prometheus",
+	}}
+	for _, label := range opts.Labels {
+		matchers = append(matchers, fmt.Sprintf(`%s=~"$%s"`, label, label))
+		variables = append(variables, map[string]any{
+			"name":       label,
+			"label":      label,
+			"type":       "query",
+			"datasource": datasourceRef,
+			"query":      fmt.Sprintf("label_values(%s, %s)", CertificateExpiry, label),
+			"refresh":    2,
+			"includeAll": true,
+			"multi":      true,
+			"current":    map[string]any{"text": "All", "value": "$__all"},
+		})
+	}
+	selector := "{" + strings.Join(matchers, ",") + "}"
+
+	panels := []map[string]any{
+		statPanel(1, "Days until expiry", 0, 0,
+			fmt.Sprintf("(%s%s - time()) / 86400", CertificateExpiry, selector), "d",
+			[]threshold{{"red", nil}, {"orange", 7}, {"green", 30}}),
+		statPanel(2, "Time since last successful renewal", 8, 0,
+			fmt.Sprintf("time() - %s%s", LastRenewalSuccess, selector), "s", nil),
+		statPanel(3, "Failed renewals (24h)", 16, 0,
+			fmt.Sprintf(`sum(increase(%s{result="failure",%s}[24h]))`, RenewalsTotal, strings.Join(matchers, ",")), "short",
+			[]threshold{{"green", nil}, {"red", 1}}),
+		timeseriesPanel(4, "Certificate expiry", 0, 8,
+			fmt.Sprintf("(%s%s - time()) / 86400", CertificateExpiry, selector), "{{instance}} {{identifier}}", "d"),
+		timeseriesPanel(5, "Renewals", 12, 8,
+			fmt.Sprintf("sum by (result) (increase(%s%s[1h]))", RenewalsTotal, selector), "{{result}}", "short"),
+		timeseriesPanel(6, "Deployer failures", 0, 16,
+			fmt.Sprintf("sum by (deployer) (increase(%s%s[1h]))", DeployerFailures, selector), "{{deployer}}", "short"),
+		timeseriesPanel(7, "Renewal checks", 12, 16,
+			fmt.Sprintf("sum(increase(%s%s[1h]))", ChecksTotal, selector), "checks", "short"),
+		timeseriesPanel(8, "Renewal cycle duration", 0, 24,
+			fmt.Sprintf("%s%s", LastRenewalLatency, selector), "{{instance}}", "s"),
+		timeseriesPanel(9, "Latency budget exceeded", 12, 24,
+			fmt.Sprintf("sum(increase(%s%s[1d]))", BudgetExceeded, selector), "exceeded", "short"),
+		timeseriesPanel(10, "CA API latency (p95)", 0, 32,
+			fmt.Sprintf("histogram_quantile(0.95, sum by (le, api, endpoint) (rate(%s_bucket%s[1h])))", APIRequestDuration, selector), "{{api}} {{endpoint}}", "s"),
+		timeseriesPanel(11, "CA API errors", 12, 32,
+			fmt.Sprintf("sum by (api, class) (increase(%s%s[1h]))", APIErrors, selector), "{{api}} {{class}}", "short"),
+		timeseriesPanel(12, "Time since last successful deployment", 0, 40,
+			fmt.Sprintf("time() - %s%s", TargetLastSuccess, selector), "{{instance}} {{deployer}}", "s"),
+		timeseriesPanel(13, "Failing deployment targets", 12, 40,
+			fmt.Sprintf("sum by (deployer) (%s%s)", TargetFailing, selector), "{{deployer}}", "short"),
+	}
+
+	dashboard := map[string]any{
+		"title":         opts.Title,
+		"uid":           "ipssl-certificates",
+		"tags":          []string{"ipssl", "certificates"},
+		"schemaVersion": 39,
+		"editable":      true,
+		"time":          map[string]any{"from": "now-30d", "to": "now"},
+		"refresh":       "5m",
+		"templating":    map[string]any{"list": variables},
+		"panels":        panels,
+	}
+	return json.MarshalIndent(dashboard, "", "  ")
+}
+
+// datasourceRef points panels at the dashboard's data source variable
+var datasourceRef = map[string]any{"type": "prometheus", "uid": "${datasource}"}
+
+// threshold is a color step; a nil value is the base step
+type threshold struct {
+	color string
+	value any
+}
+
+// statPanel builds a single-value panel
+func statPanel(id int, title string, x, y int, expr, unit string, steps []threshold) map[string]any {
+	if steps == nil {
+		steps = []threshold{{"green", nil}}
+	}
+	thresholdSteps := make([]map[string]any, 0, len(steps))
+	for _, step := range steps {
+		thresholdSteps = append(thresholdSteps, map[string]any{"color": step.color, "value": step.value})
+	}
+
+	return map[string]any{
+		"id":         id,
+		"type":       "stat",
+		"title":      title,
+		"datasource": datasourceRef,
+		"gridPos":    map[string]any{"x": x, "y": y, "w": 8, "h": 8},
+		"targets":    []map[string]any{{"refId": "A", "expr": expr, "datasource": datasourceRef}},
+		"fieldConfig": map[string]any{
+			"defaults": map[string]any{
+				"unit":       unit,
+				"thresholds": map[string]any{"mode": "absolute", "steps": thresholdSteps},
+			},
+		},
+		"options": map[string]any{
+			"reduceOptions": map[string]any{"calcs": []string{"lastNotNull"}},
+			"colorMode":     "background",
+		},
+	}
+}
+
+// timeseriesPanel builds a graph panel
+func timeseriesPanel(id int, title string, x, y int, expr, legend, unit string) map[string]any {
+	return map[string]any{
+		"id":         id,
+		"type":       "timeseries",
+		"title":      title,
+		"datasource": datasourceRef,
+		"gridPos":    map[string]any{"x": x, "y": y, "w": 12, "h": 8},
+		"targets": []map[string]any{{
+			"refId":        "A",
+			"expr":         expr,
+			"legendFormat": legend,
+			"datasource":   datasourceRef,
+		}},
+		"fieldConfig": map[string]any{
+			"defaults": map[string]any{"unit": unit},
+		},
+	}
+}
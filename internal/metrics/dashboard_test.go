@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDashboard(t *testing.T) {
+	data, err := Dashboard(DashboardOptions{Labels: []string{"cluster", "instance"}})
+	if err != nil {
+		t.Fatalf("Dashboard failed: %v", err)
+	}
+
+	var dashboard struct {
+		Templating struct {
+			List []struct {
+				Name string `json:"name"`
+			} `json:"list"`
+		} `json:"templating"`
+		Panels []struct {
+			Targets []struct {
+				Expr string `json:"expr"`
+			} `json:"targets"`
+		} `json:"panels"`
+	}
+	if err := json.Unmarshal(data, &dashboard); err != nil {
+		t.Fatalf("Dashboard is not valid JSON: %v", err)
+	}
+
+	var names []string
+	for _, v := range dashboard.Templating.List {
+		names = append(names, v.Name)
+	}
+	if strings.Join(names, ",") != "datasource,cluster,instance" {
+		t.Errorf("Unexpected dashboard variables: %v", names)
+	}
+
+	for _, panel := range dashboard.Panels {
+		for _, target := range panel.Targets {
+			if !strings.Contains(target.Expr, `cluster=~"$cluster"`) || !strings.Contains(target.Expr, "ipssl_") {
+				t.Errorf("Query does not filter by dashboard labels: %s", target.Expr)
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// infoCollector exports ipssl_certificate_info with the labels of every
+// certificate. Label names depend on the configuration, so the collector
+// describes no metrics up front and gives every certificate the union of
+// all label names, as a metric family needs the same labels throughout.
+type infoCollector struct {
+	mu     sync.Mutex
+	labels map[string]map[string]string
+}
+
+// set replaces the labels of the certificate identified by identifier
+func (c *infoCollector) set(identifier string, labels map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.labels[identifier] = labels
+}
+
+// Describe implements prometheus.Collector
+func (c *infoCollector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector
+func (c *infoCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var names []string
+	identifiers := make([]string, 0, len(c.labels))
+	for identifier, labels := range c.labels {
+		identifiers = append(identifiers, identifier)
+		for name := range labels {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	sort.Strings(identifiers)
+
+	labelNames := []string{"identifier"}
+	for _, name := range names {
+		labelNames = append(labelNames, "label_"+name)
+	}
+	desc := prometheus.NewDesc(CertificateInfo, "Labels of the managed certificate, always 1.", labelNames, nil)
+	for _, identifier := range identifiers {
+		values := []string{identifier}
+		for _, name := range names {
+			values = append(values, c.labels[identifier][name])
+		}
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, values...)
+	}
+}
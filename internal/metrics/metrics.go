@@ -0,0 +1,279 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"ipssl-client/internal/state"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Exported metric names
+const (
+	CertificateExpiry  = "ipssl_certificate_expiry_timestamp_seconds"
+	RenewalsTotal      = "ipssl_renewals_total"
+	LastRenewalSuccess = "ipssl_renewal_last_success_timestamp_seconds"
+	DeployerFailures   = "ipssl_deployer_failures_total"
+	ChecksTotal        = "ipssl_checks_total"
+	RenewalDuration    = "ipssl_renewal_duration_seconds"
+	LastRenewalLatency = "ipssl_renewal_last_duration_seconds"
+	BudgetExceeded     = "ipssl_renewal_budget_exceeded_total"
+	RenewalTimeouts    = "ipssl_renewal_timeouts_total"
+	ProcessStartTime   = "ipssl_process_start_time_seconds"
+	TargetLastSuccess  = "ipssl_deploy_target_last_success_timestamp_seconds"
+	TargetLastFailure  = "ipssl_deploy_target_last_failure_timestamp_seconds"
+	TargetFailing      = "ipssl_deploy_target_failing"
+	CertificateInfo    = "ipssl_certificate_info"
+)
+
+// Metrics holds the Prometheus collectors exported by the client
+type Metrics struct {
+	registry *prometheus.Registry
+
+	certificateExpiry  *prometheus.GaugeVec
+	renewals           *prometheus.CounterVec
+	lastRenewalSuccess prometheus.Gauge
+	deployerFailures   *prometheus.CounterVec
+	checks             prometheus.Counter
+	renewalDuration    prometheus.Histogram
+	lastRenewalLatency prometheus.Gauge
+	budgetExceeded     prometheus.Counter
+	renewalTimeouts    prometheus.Counter
+	processStartTime   prometheus.Gauge
+	targetLastSuccess  *prometheus.GaugeVec
+	targetLastFailure  *prometheus.GaugeVec
+	targetFailing      *prometheus.GaugeVec
+	apiDuration        *prometheus.HistogramVec
+	apiErrors          *prometheus.CounterVec
+	certificateInfo    *infoCollector
+
+	mu     sync.Mutex
+	recent []APICall
+}
+
+// New creates and registers the client metrics
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		certificateExpiry: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: CertificateExpiry,
+			Help: "Expiry time of the installed certificate as a Unix timestamp.",
+		}, []string{"identifier"}),
+		renewals: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: RenewalsTotal,
+			Help: "Certificate renewal attempts by result.",
+		}, []string{"result"}),
+		lastRenewalSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: LastRenewalSuccess,
+			Help: "Time of the last successful renewal as a Unix timestamp.",
+		}),
+		deployerFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: DeployerFailures,
+			Help: "Failed deployer runs by deployer.",
+		}, []string{"deployer"}),
+		checks: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: ChecksTotal,
+			Help: "Renewal checks performed.",
+		}),
+		renewalDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    RenewalDuration,
+			Help:    "Time from detecting that renewal is needed until the new certificate is deployed and verified.",
+			Buckets: []float64{30, 60, 120, 300, 600, 1200, 1800, 3600, 7200},
+		}),
+		lastRenewalLatency: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: LastRenewalLatency,
+			Help: "Duration of the last completed renewal cycle.",
+		}),
+		budgetExceeded: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: BudgetExceeded,
+			Help: "Renewal cycles that took longer than the configured latency budget.",
+		}),
+		renewalTimeouts: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: RenewalTimeouts,
+			Help: "Renewal cycles cancelled by the watchdog for exceeding the maximum duration.",
+		}),
+		processStartTime: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: ProcessStartTime,
+			Help: "Start time of the process as a Unix timestamp, to tell restarts from counter resets.",
+		}),
+		targetLastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: TargetLastSuccess,
+			Help: "Time of the last successful deployment to a target as a Unix timestamp.",
+		}, []string{"deployer"}),
+		targetLastFailure: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: TargetLastFailure,
+			Help: "Time of the last failed deployment to a target as a Unix timestamp.",
+		}, []string{"deployer"}),
+		targetFailing: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: TargetFailing,
+			Help: "Whether the most recent deployment to a target failed (1) or succeeded (0).",
+		}, []string{"deployer"}),
+		apiDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    APIRequestDuration,
+			Help:    "Latency of certificate authority API calls.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"api", "method", "endpoint", "status"}),
+		apiErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: APIErrors,
+			Help: "Failed certificate authority API calls by error class (network, rate-limit, 4xx, 5xx).",
+		}, []string{"api", "endpoint", "class"}),
+	}
+	m.certificateInfo = &infoCollector{labels: make(map[string]map[string]string)}
+	m.processStartTime.SetToCurrentTime()
+
+	m.registry.MustRegister(
+		m.certificateExpiry,
+		m.renewals,
+		m.lastRenewalSuccess,
+		m.deployerFailures,
+		m.checks,
+		m.renewalDuration,
+		m.lastRenewalLatency,
+		m.budgetExceeded,
+		m.renewalTimeouts,
+		m.processStartTime,
+		m.targetLastSuccess,
+		m.targetLastFailure,
+		m.targetFailing,
+		m.apiDuration,
+		m.apiErrors,
+		m.certificateInfo,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Restore seeds the counters with totals persisted by a previous process
+func (m *Metrics) Restore(st *state.State) {
+	c := st.Counters
+	m.checks.Add(float64(c.Checks))
+	m.renewals.WithLabelValues("success").Add(float64(c.RenewalSuccesses))
+	m.renewals.WithLabelValues("failure").Add(float64(c.RenewalFailures))
+	m.budgetExceeded.Add(float64(c.BudgetExceeded))
+	m.renewalTimeouts.Add(float64(c.RenewalTimeouts))
+	for deployer, failures := range c.DeployerFailures {
+		m.deployerFailures.WithLabelValues(deployer).Add(float64(failures))
+	}
+	if !st.LastSuccess.IsZero() {
+		m.lastRenewalSuccess.Set(float64(st.LastSuccess.Unix()))
+	}
+	for deployer, target := range st.Targets {
+		m.SetTargetHealth(deployer, target)
+	}
+}
+
+// SetTargetHealth exports the deployment health of a target
+func (m *Metrics) SetTargetHealth(deployer string, target *state.TargetHealth) {
+	if !target.LastSuccess.IsZero() {
+		m.targetLastSuccess.WithLabelValues(deployer).Set(float64(target.LastSuccess.Unix()))
+	}
+	if !target.LastFailure.IsZero() {
+		m.targetLastFailure.WithLabelValues(deployer).Set(float64(target.LastFailure.Unix()))
+	}
+	failing := 0.0
+	if target.Failing() {
+		failing = 1
+	}
+	m.targetFailing.WithLabelValues(deployer).Set(failing)
+}
+
+// SetCertificateExpiry records the expiry time of the installed certificate
+func (m *Metrics) SetCertificateExpiry(identifier string, notAfter time.Time) {
+	m.certificateExpiry.WithLabelValues(identifier).Set(float64(notAfter.Unix()))
+}
+
+// SetCertificateLabels exports the labels of a certificate as
+// label_<name> labels of ipssl_certificate_info, for joining with other metrics
+func (m *Metrics) SetCertificateLabels(identifier string, labels map[string]string) {
+	m.certificateInfo.set(identifier, labels)
+}
+
+// RenewalSucceeded counts a successful renewal, attaching the cycle ID as exemplar
+func (m *Metrics) RenewalSucceeded(cycleID string) {
+	addWithExemplar(m.renewals.WithLabelValues("success"), cycleID)
+	m.lastRenewalSuccess.SetToCurrentTime()
+}
+
+// RenewalFailed counts a failed renewal, attaching the cycle ID as exemplar
+func (m *Metrics) RenewalFailed(cycleID string) {
+	addWithExemplar(m.renewals.WithLabelValues("failure"), cycleID)
+}
+
+// DeployerFailed counts a failed deployer run
+func (m *Metrics) DeployerFailed(deployer string) {
+	m.deployerFailures.WithLabelValues(deployer).Inc()
+}
+
+// Checked counts a renewal check
+func (m *Metrics) Checked() {
+	m.checks.Inc()
+}
+
+// ObserveRenewalDuration records the end-to-end duration of a renewal cycle
+func (m *Metrics) ObserveRenewalDuration(d time.Duration, cycleID string) {
+	if observer, ok := m.renewalDuration.(prometheus.ExemplarObserver); ok && cycleID != "" {
+		observer.ObserveWithExemplar(d.Seconds(), prometheus.Labels{"cycle_id": cycleID})
+	} else {
+		m.renewalDuration.Observe(d.Seconds())
+	}
+	m.lastRenewalLatency.Set(d.Seconds())
+}
+
+// addWithExemplar increments a counter, attaching the cycle ID as exemplar when known
+func addWithExemplar(counter prometheus.Counter, cycleID string) {
+	if adder, ok := counter.(prometheus.ExemplarAdder); ok && cycleID != "" {
+		adder.AddWithExemplar(1, prometheus.Labels{"cycle_id": cycleID})
+		return
+	}
+	counter.Inc()
+}
+
+// RenewalBudgetExceeded counts a renewal cycle that exceeded its latency budget
+func (m *Metrics) RenewalBudgetExceeded() {
+	m.budgetExceeded.Inc()
+}
+
+// RenewalTimedOut counts a renewal cycle cancelled by the watchdog
+func (m *Metrics) RenewalTimedOut() {
+	m.renewalTimeouts.Inc()
+}
+
+// Handler returns the HTTP handler serving the metrics
+func (m *Metrics) Handler() http.Handler {
+	// Exemplars are only exposed in the OpenMetrics format
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// Serve exposes the metrics on addr under /metrics, and the given handlers
+// such as /healthz under their paths, until ctx is cancelled
+func (m *Metrics) Serve(ctx context.Context, addr string, handlers map[string]http.Handler) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	for path, handler := range handlers {
+		mux.Handle(path, handler)
+	}
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"ipssl-client/internal/state"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRestore(t *testing.T) {
+	m := New()
+	lastSuccess := time.Unix(1700000000, 0)
+	m.Restore(&state.State{
+		LastSuccess: lastSuccess,
+		Counters: state.Counters{
+			Checks:           10,
+			RenewalSuccesses: 3,
+			RenewalFailures:  2,
+			DeployerFailures: map[string]uint64{"compose": 1},
+		},
+		Targets: map[string]*state.TargetHealth{
+			"compose": {LastSuccess: lastSuccess.Add(-time.Hour), LastFailure: lastSuccess},
+		},
+	})
+	m.RenewalFailed("0123456789abcdef")
+
+	if got := testutil.ToFloat64(m.renewals.WithLabelValues("success")); got != 3 {
+		t.Errorf("Expected 3 restored successes, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.renewals.WithLabelValues("failure")); got != 3 {
+		t.Errorf("Expected 2 restored failures plus 1 new, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.deployerFailures.WithLabelValues("compose")); got != 1 {
+		t.Errorf("Expected 1 restored deployer failure, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.lastRenewalSuccess); got != float64(lastSuccess.Unix()) {
+		t.Errorf("Expected last success %d, got %v", lastSuccess.Unix(), got)
+	}
+	if got := testutil.ToFloat64(m.targetFailing.WithLabelValues("compose")); got != 1 {
+		t.Errorf("Expected compose target to be failing, got %v", got)
+	}
+	if testutil.ToFloat64(m.processStartTime) == 0 {
+		t.Error("Expected process start time to be set")
+	}
+}
+
+func TestCertificateLabels(t *testing.T) {
+	m := New()
+	m.SetCertificateLabels("192.0.2.1", map[string]string{"team": "web"})
+	m.SetCertificateLabels("192.0.2.2", map[string]string{"env": "prod"})
+
+	expected := `
+# HELP ipssl_certificate_info Labels of the managed certificate, always 1.
+# TYPE ipssl_certificate_info gauge
+ipssl_certificate_info{identifier="192.0.2.1",label_env="",label_team="web"} 1
+ipssl_certificate_info{identifier="192.0.2.2",label_env="prod",label_team=""} 1
+`
+	if err := testutil.GatherAndCompare(m.registry, strings.NewReader(expected), CertificateInfo); err != nil {
+		t.Error(err)
+	}
+}
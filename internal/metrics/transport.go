@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// API call metric names
+const (
+	APIRequestDuration = "ipssl_api_request_duration_seconds"
+	APIErrors          = "ipssl_api_errors_total"
+)
+
+// Error classes of failed API calls
+const (
+	ErrorNetwork   = "network"
+	ErrorRateLimit = "rate-limit"
+	ErrorClient    = "4xx"
+	ErrorServer    = "5xx"
+)
+
+// recentCallLimit is the number of recent API calls kept for failure reports
+const recentCallLimit = 20
+
+// APICall describes a recent request to a certificate authority API. Only
+// metadata is kept; request and response bodies may contain secrets.
+type APICall struct {
+	Time     time.Time `json:"time"`
+	API      string    `json:"api"`
+	Method   string    `json:"method"`
+	Endpoint string    `json:"endpoint"`
+	Status   int       `json:"status,omitempty"`
+	Duration float64   `json:"duration_seconds"`
+	Class    string    `json:"error_class,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// RecentCalls returns the recorded API calls made at or after since, oldest first
+func (m *Metrics) RecentCalls(since time.Time) []APICall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var calls []APICall
+	for _, call := range m.recent {
+		if !call.Time.Before(since) {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// recordCall appends to the bounded list of recent API calls
+func (m *Metrics) recordCall(call APICall) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.recent = append(m.recent, call)
+	if len(m.recent) > recentCallLimit {
+		m.recent = m.recent[len(m.recent)-recentCallLimit:]
+	}
+}
+
+// instrumentedTransport records latency and errors of every request to a CA API
+type instrumentedTransport struct {
+	metrics *Metrics
+	api     string
+	next    http.RoundTripper
+}
+
+// InstrumentClient returns an HTTP client whose requests are recorded under the
+// given API name, e.g. zerossl or acme
+func (m *Metrics) InstrumentClient(api string, client *http.Client) *http.Client {
+	if client == nil {
+		client = &http.Client{}
+	}
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	instrumented := *client
+	instrumented.Transport = &instrumentedTransport{metrics: m, api: api, next: next}
+	return &instrumented
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := normalizeEndpoint(req.URL.Path)
+	started := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	elapsed := time.Since(started)
+
+	call := APICall{Time: started, API: t.api, Method: req.Method, Endpoint: endpoint, Duration: elapsed.Seconds()}
+	status := "error"
+	if resp != nil {
+		status = strconv.Itoa(resp.StatusCode)
+		call.Status = resp.StatusCode
+	}
+	if err != nil {
+		call.Error = err.Error()
+	}
+	t.metrics.apiDuration.WithLabelValues(t.api, req.Method, endpoint, status).Observe(elapsed.Seconds())
+
+	if class := classify(resp, err); class != "" {
+		call.Class = class
+		t.metrics.apiErrors.WithLabelValues(t.api, endpoint, class).Inc()
+	}
+	t.metrics.recordCall(call)
+	return resp, err
+}
+
+// classify returns the error class of a response, or "" for successful calls
+func classify(resp *http.Response, err error) string {
+	switch {
+	case err != nil:
+		return ErrorNetwork
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return ErrorRateLimit
+	case resp.StatusCode >= 500:
+		return ErrorServer
+	case resp.StatusCode >= 400:
+		return ErrorClient
+	default:
+		return ""
+	}
+}
+
+// normalizeEndpoint replaces identifiers in a URL path with {id} to keep label cardinality bounded
+func normalizeEndpoint(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if isIdentifier(segment) {
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// isIdentifier reports whether a path segment looks like a generated ID rather
+// than a resource name: all digits, very long, or a mix of letters and digits
+// without the dashes used in names such as authz-v3
+func isIdentifier(segment string) bool {
+	if segment == "" {
+		return false
+	}
+	digits := 0
+	for _, r := range segment {
+		if unicode.IsDigit(r) {
+			digits++
+		}
+	}
+	switch {
+	case digits == len(segment):
+		return true
+	case len(segment) >= 16:
+		return true
+	default:
+		return len(segment) >= 6 && digits > 0 && !strings.Contains(segment, "-")
+	}
+}
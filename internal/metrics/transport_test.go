@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstrumentClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/certificates/0123456789abcdef0123456789abcdef":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/certificates":
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	m := New()
+	client := m.InstrumentClient("zerossl", nil)
+	for _, path := range []string{"/certificates/0123456789abcdef0123456789abcdef", "/certificates", "/validation/csr"} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if got := testutil.ToFloat64(m.apiErrors.WithLabelValues("zerossl", "/certificates/{id}", ErrorRateLimit)); got != 1 {
+		t.Errorf("Expected 1 rate-limit error, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.apiErrors.WithLabelValues("zerossl", "/certificates", ErrorServer)); got != 1 {
+		t.Errorf("Expected 1 server error, got %v", got)
+	}
+	if got := testutil.CollectAndCount(m.apiErrors); got != 2 {
+		t.Errorf("Expected errors for 2 endpoints only, got %d", got)
+	}
+}
+
+func TestNormalizeEndpoint(t *testing.T) {
+	tests := map[string]string{
+		"/certificates/0123456789abcdef0123456789abcdef/challenges": "/certificates/{id}/challenges",
+		"/acme/authz-v3/123456789":                                  "/acme/authz-v3/{id}",
+		"/acme/chall-v3/123456789/Dd8rYq":                           "/acme/chall-v3/{id}/{id}",
+		"/acme/new-order":                                           "/acme/new-order",
+	}
+	for path, want := range tests {
+		if got := normalizeEndpoint(path); got != want {
+			t.Errorf("normalizeEndpoint(%s) = %s, want %s", path, got, want)
+		}
+	}
+}
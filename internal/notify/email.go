@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"ipssl-client/internal/config"
+)
+
+// Email sends messages through an SMTP server
+type Email struct {
+	config config.SMTPConfig
+}
+
+// NewEmail creates an SMTP sender
+func NewEmail(cfg config.SMTPConfig) (*Email, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("SMTP host is required")
+	}
+	if cfg.From == "" {
+		return nil, fmt.Errorf("SMTP sender address is required")
+	}
+	return &Email{config: cfg}, nil
+}
+
+// Send delivers a message with the given MIME content type to all recipients.
+// STARTTLS is used automatically when the server offers it.
+func (e *Email) Send(to []string, subject, contentType string, body []byte) error {
+	if len(to) == 0 {
+		return fmt.Errorf("no recipients configured")
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: %s; charset=utf-8\r\n\r\n", contentType)
+	msg.Write(body)
+
+	var auth smtp.Auth
+	if e.config.Username != "" {
+		auth = smtp.PlainAuth("", e.config.Username, e.config.Password, e.config.Host)
+	}
+
+	addr := net.JoinHostPort(e.config.Host, strconv.Itoa(e.config.Port))
+	if err := smtp.SendMail(addr, auth, e.config.From, to, msg.Bytes()); err != nil {
+		return fmt.Errorf("failed to send email via %s: %w", addr, err)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"ipssl-client/internal/config"
+)
+
+// Certificate lifecycle event types
+const (
+	EventIssued        = "certificate.issued"
+	EventRenewed       = "certificate.renewed"
+	EventRenewalFailed = "certificate.renewal_failed"
+	EventRevoked       = "certificate.revoked"
+)
+
+// Event is a certificate lifecycle event exported to a SIEM
+type Event struct {
+	Time           time.Time         `json:"time"`
+	Type           string            `json:"type"`
+	Host           string            `json:"host"`
+	Identifiers    []string          `json:"identifiers"`
+	Provider       string            `json:"provider"`
+	CycleID        string            `json:"cycle_id,omitempty"`
+	Serial         string            `json:"serial,omitempty"`
+	PreviousSerial string            `json:"previous_serial,omitempty"`
+	Changed        bool              `json:"changed"`
+	NotAfter       time.Time         `json:"not_after,omitzero"`
+	Override       bool              `json:"override,omitempty"`
+	Error          string            `json:"error,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	Signature      string            `json:"signature,omitempty"`
+}
+
+// severity returns the CEF severity (0-10) of the event
+func (e Event) severity() int {
+	switch e.Type {
+	case EventRevoked:
+		return 9
+	case EventRenewalFailed:
+		return 7
+	}
+	return 3
+}
+
+// SIEM exports events as JSON lines or CEF records over TLS
+type SIEM struct {
+	config    config.SIEMConfig
+	tlsConfig *tls.Config
+}
+
+// NewSIEM creates a SIEM exporter for the listener at cfg.Addr
+func NewSIEM(cfg config.SIEMConfig) (*SIEM, error) {
+	if cfg.Addr == "" {
+		return nil, fmt.Errorf("SIEM address is required")
+	}
+	host, _, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SIEM address %q: %w", cfg.Addr, err)
+	}
+
+	tlsConfig := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SIEM CA file: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in SIEM CA file %s", cfg.CAFile)
+		}
+	}
+	return &SIEM{config: cfg, tlsConfig: tlsConfig}, nil
+}
+
+// Send signs the event if a signing key is configured and delivers it as one
+// line over a new TLS connection. Events are rare, so connections are not kept.
+func (s *SIEM) Send(ctx context.Context, event Event) error {
+	line, err := s.format(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+	defer cancel()
+	dialer := &tls.Dialer{Config: s.tlsConfig}
+	conn, err := dialer.DialContext(ctx, "tcp", s.config.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SIEM at %s: %w", s.config.Addr, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to send event to SIEM at %s: %w", s.config.Addr, err)
+	}
+	return nil
+}
+
+// format renders the event in the configured format
+func (s *SIEM) format(event Event) ([]byte, error) {
+	event.Signature = ""
+	if s.config.Format == config.SIEMFormatCEF {
+		record := formatCEF(event)
+		if s.config.SigningKey != "" {
+			record += " cs2Label=signature cs2=" + s.sign([]byte(record))
+		}
+		return []byte(record), nil
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode event: %w", err)
+	}
+	if s.config.SigningKey == "" {
+		return data, nil
+	}
+	// The signature covers the encoding of the event without it
+	event.Signature = s.sign(data)
+	return json.Marshal(event)
+}
+
+// sign returns the hex HMAC-SHA256 of data under the signing key
+func (s *SIEM) sign(data []byte) string {
+	mac := hmac.New(sha256.New, []byte(s.config.SigningKey))
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// formatCEF renders the event as an ArcSight Common Event Format record
+func formatCEF(event Event) string {
+	name := strings.ReplaceAll(strings.TrimPrefix(event.Type, "certificate."), "_", " ")
+	ext := []string{
+		"rt=" + strconv.FormatInt(event.Time.UnixMilli(), 10),
+		"dvchost=" + cefValue(event.Host),
+		"dhost=" + cefValue(strings.Join(event.Identifiers, ",")),
+		"cs1Label=provider cs1=" + cefValue(event.Provider),
+	}
+	if event.CycleID != "" {
+		ext = append(ext, "externalId="+cefValue(event.CycleID))
+	}
+	if event.Serial != "" {
+		ext = append(ext, "cs3Label=serial cs3="+cefValue(event.Serial))
+	}
+	if event.PreviousSerial != "" {
+		ext = append(ext, "cs6Label=previous_serial cs6="+cefValue(event.PreviousSerial))
+	}
+	if event.Changed {
+		ext = append(ext, "cn1Label=changed cn1=1")
+	} else {
+		ext = append(ext, "cn1Label=changed cn1=0")
+	}
+	if !event.NotAfter.IsZero() {
+		ext = append(ext, "end="+strconv.FormatInt(event.NotAfter.UnixMilli(), 10))
+	}
+	if event.Override {
+		ext = append(ext, "cs4Label=override cs4=true")
+	}
+	if len(event.Labels) > 0 {
+		ext = append(ext, "cs5Label=labels cs5="+cefValue(config.FormatLabels(event.Labels)))
+	}
+	if event.Error != "" {
+		ext = append(ext, "reason="+cefValue(event.Error))
+	}
+	return fmt.Sprintf("CEF:0|IPSSL|ipssl-client|1.0|%s|%s|%d|%s",
+		cefHeader(event.Type), cefHeader("Certificate "+name), event.severity(), strings.Join(ext, " "))
+}
+
+// cefHeader escapes a CEF header field
+func cefHeader(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "|", `\|`, "\n", " ", "\r", " ").Replace(s)
+}
+
+// cefValue escapes a CEF extension value
+func cefValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "=", `\=`, "\n", `\n`, "\r", `\r`).Replace(s)
+}
@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"ipssl-client/internal/config"
+)
+
+func TestSIEMFormatJSONSigned(t *testing.T) {
+	s := &SIEM{config: config.SIEMConfig{Format: config.SIEMFormatJSON, SigningKey: "secret"}}
+	event := Event{Time: time.Unix(1700000000, 0).UTC(), Type: EventRenewed, Identifiers: []string{"192.0.2.1"}, Serial: "ab"}
+
+	line, err := s.format(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Event
+	if err := json.Unmarshal(line, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Signature == "" {
+		t.Fatal("Expected a signature")
+	}
+	if strings.Contains(string(line), "not_after") {
+		t.Errorf("Expected no not_after without a certificate, got %s", line)
+	}
+
+	// Verify the way a SIEM would: remove the signature and recompute it
+	signature := got.Signature
+	got.Signature = ""
+	unsigned, _ := json.Marshal(got)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(unsigned)
+	if hex.EncodeToString(mac.Sum(nil)) != signature {
+		t.Error("Signature does not match the unsigned event")
+	}
+}
+
+func TestSIEMFormatCEF(t *testing.T) {
+	s := &SIEM{config: config.SIEMConfig{Format: config.SIEMFormatCEF}}
+	event := Event{Time: time.Now(), Type: EventRenewalFailed, Identifiers: []string{"192.0.2.1"}, Provider: "zerossl", Error: "status=500\nretry"}
+
+	line, err := s.format(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := string(line)
+	if !strings.HasPrefix(record, "CEF:0|IPSSL|ipssl-client|1.0|certificate.renewal_failed|Certificate renewal failed|7|") {
+		t.Errorf("Unexpected CEF header in %q", record)
+	}
+	if !strings.Contains(record, `reason=status\=500\nretry`) {
+		t.Errorf("Expected escaped reason in %q", record)
+	}
+	if strings.Contains(record, "\n") {
+		t.Errorf("Expected a single line, got %q", record)
+	}
+}
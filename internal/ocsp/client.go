@@ -0,0 +1,137 @@
+package ocsp
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	xocsp "golang.org/x/crypto/ocsp"
+
+	"ipssl-client/internal/logger"
+)
+
+// Certificate statuses reported by a responder
+const (
+	StatusGood    = "good"
+	StatusRevoked = "revoked"
+	StatusUnknown = "unknown"
+)
+
+// ErrNoResponder is returned for certificates that name no OCSP responder,
+// which CAs that dropped OCSP no longer include
+var ErrNoResponder = errors.New("certificate names no OCSP responder")
+
+// Response is an OCSP response whose signature was verified
+type Response struct {
+	// Raw is the DER-encoded response as returned by the responder, the
+	// form servers staple
+	Raw        []byte
+	Status     string
+	ThisUpdate time.Time
+	NextUpdate time.Time
+	RevokedAt  time.Time
+}
+
+// RefreshAt returns when the response should be replaced: halfway through
+// its validity, or an hour after it was produced if it names no next update
+func (r *Response) RefreshAt() time.Time {
+	if r.NextUpdate.IsZero() {
+		return r.ThisUpdate.Add(time.Hour)
+	}
+	return r.ThisUpdate.Add(r.NextUpdate.Sub(r.ThisUpdate) / 2)
+}
+
+// Client fetches OCSP responses from the responders named in certificates
+type Client struct {
+	httpClient *http.Client
+	logger     *logger.Logger
+}
+
+// NewClient creates a new OCSP client
+func NewClient(logger *logger.Logger) *Client {
+	return &Client{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		logger:     logger,
+	}
+}
+
+// Fetch requests the status of leaf from the first responder it names. The
+// response must be signed by issuer or a responder it delegated to.
+func (c *Client) Fetch(ctx context.Context, leaf, issuer *x509.Certificate) (*Response, error) {
+	if len(leaf.OCSPServer) == 0 {
+		return nil, ErrNoResponder
+	}
+	url := leaf.OCSPServer[0]
+
+	reqDER, err := xocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode OCSP request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(reqDER))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("OCSP request to %s failed: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OCSP response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OCSP responder %s returned HTTP %d", url, resp.StatusCode)
+	}
+
+	response, err := Parse(body, leaf, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response from OCSP responder %s: %w", url, err)
+	}
+	c.logger.Info("OCSP response obtained", "responder", url, "status", response.Status, "this_update", response.ThisUpdate, "next_update", response.NextUpdate)
+	return response, nil
+}
+
+// Parse parses a DER OCSP response for leaf and verifies its signature
+// against issuer. Responses past their next update are rejected.
+func Parse(der []byte, leaf, issuer *x509.Certificate) (*Response, error) {
+	parsed, err := xocsp.ParseResponseForCert(der, leaf, issuer)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFresh(parsed.NextUpdate); err != nil {
+		return nil, fmt.Errorf("OCSP response %w", err)
+	}
+
+	response := &Response{
+		Raw:        der,
+		Status:     StatusUnknown,
+		ThisUpdate: parsed.ThisUpdate,
+		NextUpdate: parsed.NextUpdate,
+	}
+	switch parsed.Status {
+	case xocsp.Good:
+		response.Status = StatusGood
+	case xocsp.Revoked:
+		response.Status = StatusRevoked
+		response.RevokedAt = parsed.RevokedAt
+	}
+	return response, nil
+}
+
+// checkFresh rejects revocation information whose next update has passed,
+// which a CA no longer vouches for and an attacker may replay
+func checkFresh(nextUpdate time.Time) error {
+	if !nextUpdate.IsZero() && time.Now().After(nextUpdate) {
+		return fmt.Errorf("is stale, its next update was due at %s", nextUpdate.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package ocsp
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	xocsp "golang.org/x/crypto/ocsp"
+
+	"ipssl-client/internal/logger"
+)
+
+// testResponder is a CA that answers OCSP requests for the leaf it issued
+type testResponder struct {
+	ca, leaf *x509.Certificate
+	key      crypto.Signer
+	status   int
+
+	// stale makes the responder answer with a response past its next update,
+	// and fail makes it answer with an HTTP error
+	stale, fail bool
+}
+
+// newTestResponder issues a leaf that names an OCSP responder served by the test
+func newTestResponder(t *testing.T, status int) *testResponder {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, key.Public(), key)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	r := &testResponder{ca: ca, key: key, status: status}
+	server := httptest.NewServer(http.HandlerFunc(r.serveHTTP))
+	t.Cleanup(server.Close)
+
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		OCSPServer:   []string{server.URL},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, ca, key.Public(), key)
+	if err != nil {
+		t.Fatalf("Failed to create leaf: %v", err)
+	}
+	r.leaf, _ = x509.ParseCertificate(leafDER)
+	return r
+}
+
+func (r *testResponder) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	if _, err := xocsp.ParseRequest(body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.fail {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	now := time.Now().Truncate(time.Minute)
+	if r.stale {
+		now = now.Add(-5 * 24 * time.Hour)
+	}
+	der, err := xocsp.CreateResponse(r.ca, r.ca, xocsp.Response{
+		Status:       r.status,
+		SerialNumber: r.leaf.SerialNumber,
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(4 * 24 * time.Hour),
+		RevokedAt:    now.Add(-time.Hour),
+	}, r.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(der)
+}
+
+func TestFetch(t *testing.T) {
+	r := newTestResponder(t, xocsp.Good)
+	resp, err := NewClient(logger.New()).Fetch(context.Background(), r.leaf, r.ca)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if resp.Status != StatusGood {
+		t.Errorf("Expected status good, got %s", resp.Status)
+	}
+	if want := resp.ThisUpdate.Add(2 * 24 * time.Hour); !resp.RefreshAt().Equal(want) {
+		t.Errorf("Expected refresh at %s, got %s", want, resp.RefreshAt())
+	}
+	if _, err := Parse(resp.Raw, r.leaf, r.ca); err != nil {
+		t.Errorf("Expected the raw response to parse again: %v", err)
+	}
+}
+
+func TestFetchRevoked(t *testing.T) {
+	r := newTestResponder(t, xocsp.Revoked)
+	resp, err := NewClient(logger.New()).Fetch(context.Background(), r.leaf, r.ca)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if resp.Status != StatusRevoked || resp.RevokedAt.IsZero() {
+		t.Errorf("Expected a revoked status with its time, got %s at %s", resp.Status, resp.RevokedAt)
+	}
+}
+
+func TestFetchNoResponder(t *testing.T) {
+	r := newTestResponder(t, xocsp.Good)
+	r.leaf.OCSPServer = nil
+	if _, err := NewClient(logger.New()).Fetch(context.Background(), r.leaf, r.ca); err != ErrNoResponder {
+		t.Errorf("Expected ErrNoResponder, got %v", err)
+	}
+}
+
+func TestFetchStale(t *testing.T) {
+	r := newTestResponder(t, xocsp.Good)
+	r.stale = true
+	if _, err := NewClient(logger.New()).Fetch(context.Background(), r.leaf, r.ca); err == nil || !strings.Contains(err.Error(), "stale") {
+		t.Errorf("Expected a response past its next update to be rejected, got %v", err)
+	}
+}
+
+// serveCRL serves a CRL of r.ca revoking the entries of revoked and valid
+// until nextUpdate
+func serveCRL(t *testing.T, r *testResponder, revoked *[]x509.RevocationListEntry, nextUpdate *time.Time) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+			Number:                    big.NewInt(1),
+			ThisUpdate:                nextUpdate.Add(-25 * time.Hour),
+			NextUpdate:                *nextUpdate,
+			RevokedCertificateEntries: *revoked,
+		}, r.ca, r.key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(der)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCheckFallsBackToCRLWhenOCSPFails(t *testing.T) {
+	r := newTestResponder(t, xocsp.Good)
+	r.fail = true
+	var revoked []x509.RevocationListEntry
+	nextUpdate := time.Now().Add(24 * time.Hour)
+	r.leaf.CRLDistributionPoints = []string{serveCRL(t, r, &revoked, &nextUpdate).URL}
+
+	client := NewClient(logger.New())
+	revoked = []x509.RevocationListEntry{{SerialNumber: r.leaf.SerialNumber, RevocationTime: time.Now().Add(-time.Minute)}}
+	resp, err := client.Check(context.Background(), r.leaf, r.ca)
+	if err != nil || resp.Status != StatusRevoked {
+		t.Fatalf("Expected the revocation from the CRL while the responder is down, got %v (%v)", resp, err)
+	}
+
+	// A stale OCSP response is not trusted either
+	r.fail, r.stale = false, true
+	if resp, err := client.Check(context.Background(), r.leaf, r.ca); err != nil || resp.Status != StatusRevoked {
+		t.Errorf("Expected the CRL instead of a stale OCSP response, got %v (%v)", resp, err)
+	}
+
+	// Without a CRL the OCSP failure is reported rather than missing revocation info
+	r.leaf.CRLDistributionPoints = nil
+	if _, err := client.Check(context.Background(), r.leaf, r.ca); err == nil || errors.Is(err, ErrNoRevocationInfo) {
+		t.Errorf("Expected the OCSP failure, got %v", err)
+	}
+}
+
+func TestCheckFallsBackToCRL(t *testing.T) {
+	r := newTestResponder(t, xocsp.Good)
+	var revoked []x509.RevocationListEntry
+	nextUpdate := time.Now().Add(24 * time.Hour)
+	r.leaf.OCSPServer = nil
+	r.leaf.CRLDistributionPoints = []string{serveCRL(t, r, &revoked, &nextUpdate).URL}
+
+	client := NewClient(logger.New())
+	resp, err := client.Check(context.Background(), r.leaf, r.ca)
+	if err != nil || resp.Status != StatusGood {
+		t.Fatalf("Expected status good from the CRL, got %v (%v)", resp, err)
+	}
+
+	revoked = []x509.RevocationListEntry{{SerialNumber: r.leaf.SerialNumber, RevocationTime: time.Now().Add(-time.Minute)}}
+	resp, err = client.Check(context.Background(), r.leaf, r.ca)
+	if err != nil || resp.Status != StatusRevoked || resp.RevokedAt.IsZero() {
+		t.Errorf("Expected a revoked status from the CRL, got %v (%v)", resp, err)
+	}
+
+	nextUpdate = time.Now().Add(-time.Minute)
+	if _, err := client.Check(context.Background(), r.leaf, r.ca); err == nil || !strings.Contains(err.Error(), "stale") {
+		t.Errorf("Expected a CRL past its next update to be rejected, got %v", err)
+	}
+
+	r.leaf.CRLDistributionPoints = nil
+	if _, err := client.Check(context.Background(), r.leaf, r.ca); err != ErrNoRevocationInfo {
+		t.Errorf("Expected ErrNoRevocationInfo, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package ocsp
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrNoRevocationInfo is returned for certificates that name neither an OCSP
+// responder nor a CRL distribution point
+var ErrNoRevocationInfo = errors.New("certificate names no OCSP responder or CRL distribution point")
+
+// Check returns the revocation status of leaf from its OCSP responder, or from
+// its CRL if it names no responder, as is the case with CAs that dropped OCSP,
+// or the responder fails or is unreachable
+func (c *Client) Check(ctx context.Context, leaf, issuer *x509.Certificate) (*Response, error) {
+	resp, err := c.Fetch(ctx, leaf, issuer)
+	if err == nil {
+		return resp, nil
+	}
+	noResponder := errors.Is(err, ErrNoResponder)
+	if len(leaf.CRLDistributionPoints) == 0 && !noResponder {
+		return nil, err
+	}
+	if !noResponder {
+		c.logger.Warn("OCSP check failed, falling back to the CRL", "error", err)
+	}
+
+	resp, crlErr := c.FetchCRL(ctx, leaf, issuer)
+	if crlErr != nil && !noResponder {
+		return nil, errors.Join(err, crlErr)
+	}
+	return resp, crlErr
+}
+
+// FetchCRL downloads the CRL from the first distribution point leaf names and
+// looks up its serial. The CRL must be signed by issuer.
+func (c *Client) FetchCRL(ctx context.Context, leaf, issuer *x509.Certificate) (*Response, error) {
+	if len(leaf.CRLDistributionPoints) == 0 {
+		return nil, ErrNoRevocationInfo
+	}
+	url := leaf.CRLDistributionPoints[0]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("CRL request to %s failed: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 32*1024*1024))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CRL: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CRL distribution point %s returned HTTP %d", url, resp.StatusCode)
+	}
+
+	crl, err := x509.ParseRevocationList(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CRL from %s: %w", url, err)
+	}
+	if err := crl.CheckSignatureFrom(issuer); err != nil {
+		return nil, fmt.Errorf("CRL from %s is not signed by the issuer: %w", url, err)
+	}
+	if err := checkFresh(crl.NextUpdate); err != nil {
+		return nil, fmt.Errorf("CRL from %s %w", url, err)
+	}
+
+	response := &Response{
+		Raw:        body,
+		Status:     StatusGood,
+		ThisUpdate: crl.ThisUpdate,
+		NextUpdate: crl.NextUpdate,
+	}
+	for _, entry := range crl.RevokedCertificateEntries {
+		if entry.SerialNumber.Cmp(leaf.SerialNumber) == 0 {
+			response.Status = StatusRevoked
+			response.RevokedAt = entry.RevocationTime
+			break
+		}
+	}
+	c.logger.Info("CRL checked", "distribution_point", url, "status", response.Status, "this_update", response.ThisUpdate, "next_update", response.NextUpdate)
+	return response, nil
+}
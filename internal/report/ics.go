@@ -0,0 +1,91 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// icsTimeFormat is the UTC date-time form used by iCalendar
+const icsTimeFormat = "20060102T150405Z"
+
+// renderICS writes an iCalendar feed with one event for each certificate expiry
+// and one spanning its renewal window
+func renderICS(w io.Writer, r *Report) error {
+	var b strings.Builder
+	line := func(format string, args ...any) {
+		b.WriteString(foldICS(fmt.Sprintf(format, args...)))
+		b.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//ipssl-client//certificate expiry//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:IPSSL certificates")
+
+	stamp := r.GeneratedAt.UTC().Format(icsTimeFormat)
+	for _, cert := range r.Certificates {
+		if cert.NotAfter.IsZero() {
+			continue
+		}
+		names := escapeICS(strings.Join(cert.Identifiers, ", "))
+		uid := cert.Serial + "@ipssl-client"
+
+		line("BEGIN:VEVENT")
+		line("UID:expiry-%s", uid)
+		line("DTSTAMP:%s", stamp)
+		line("DTSTART:%s", cert.NotAfter.UTC().Format(icsTimeFormat))
+		line("DURATION:PT1H")
+		line("SUMMARY:Certificate expires: %s", names)
+		line("DESCRIPTION:%s", escapeICS(fmt.Sprintf("Certificate %s (serial %s) expires. Status: %s.", cert.Path, cert.Serial, cert.Status)))
+		line("BEGIN:VALARM")
+		line("ACTION:DISPLAY")
+		line("TRIGGER:-P7D")
+		line("DESCRIPTION:Certificate expires in 7 days: %s", names)
+		line("END:VALARM")
+		line("END:VEVENT")
+
+		if !cert.RenewAfter.IsZero() && cert.RenewAfter.Before(cert.NotAfter) {
+			line("BEGIN:VEVENT")
+			line("UID:renewal-%s", uid)
+			line("DTSTAMP:%s", stamp)
+			line("DTSTART:%s", cert.RenewAfter.UTC().Format(icsTimeFormat))
+			line("DTEND:%s", cert.NotAfter.UTC().Format(icsTimeFormat))
+			line("SUMMARY:Certificate renewal window: %s", names)
+			line("DESCRIPTION:%s", escapeICS("The client renews the certificate at the first check inside this window. Next action: "+cert.NextAction+"."))
+			line("TRANSP:TRANSPARENT")
+			line("END:VEVENT")
+		}
+	}
+
+	line("END:VCALENDAR")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeICS escapes text values as required by RFC 5545
+func escapeICS(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+// foldICS splits content lines longer than 75 octets into continuation lines
+func foldICS(s string) string {
+	const limit = 75
+	if len(s) <= limit {
+		return s
+	}
+
+	var b strings.Builder
+	width := 0
+	for _, r := range s {
+		size := len(string(r))
+		if width+size > limit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
@@ -0,0 +1,179 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"ipssl-client/internal/config"
+)
+
+// Output formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+	FormatHTML = "html"
+	FormatICS  = "ics"
+)
+
+// Render writes the report in the given format
+func Render(w io.Writer, r *Report, format string) error {
+	switch format {
+	case FormatText, "":
+		return renderText(w, r)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case FormatHTML:
+		return htmlTemplate.Execute(w, r)
+	case FormatICS:
+		return renderICS(w, r)
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+}
+
+// writeLabels writes the labels of a certificate, if any
+func writeLabels(w io.Writer, cert Certificate) {
+	if len(cert.Labels) > 0 {
+		fmt.Fprintf(w, "  labels: %s\n", config.FormatLabels(cert.Labels))
+	}
+}
+
+// renderText writes a plain-text table followed by the last renewal details
+func renderText(w io.Writer, r *Report) error {
+	fmt.Fprintf(w, "IPSSL certificate report (%s)\n\n", r.GeneratedAt.Format(time.RFC3339))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CERTIFICATE\tIDENTIFIERS\tSTATUS\tEXPIRES\tDAYS LEFT\tNEXT ACTION")
+	for _, cert := range r.Certificates {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n",
+			cert.Path, strings.Join(cert.Identifiers, ","), cert.Status,
+			formatTime(cert.NotAfter), cert.DaysLeft, cert.NextAction)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, cert := range r.Certificates {
+		renewal := cert.LastRenewal
+		if renewal == nil {
+			fmt.Fprintf(w, "\n%s: no renewal recorded\n", cert.Path)
+			writeLabels(w, cert)
+			continue
+		}
+		fmt.Fprintf(w, "\n%s: last renewal %s, %s", cert.Path, renewal.Time.Format(time.RFC3339), result(renewal.Success, renewal.Error))
+		if renewal.Override {
+			fmt.Fprint(w, " (manual override)")
+		}
+		if renewal.CycleID != "" {
+			fmt.Fprintf(w, " (cycle %s)", renewal.CycleID)
+		}
+		fmt.Fprintln(w)
+		writeLabels(w, cert)
+		if !renewal.Success && !cert.LastSuccess.IsZero() {
+			fmt.Fprintf(w, "  last successful renewal: %s\n", cert.LastSuccess.Format(time.RFC3339))
+		}
+		for _, d := range renewal.Deployers {
+			fmt.Fprintf(w, "  %s: %s\n", d.Name, result(d.Success, d.Error))
+		}
+	}
+
+	if len(r.Targets) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tSTATUS\tLAST SUCCESS\tLAST FAILURE\tERROR")
+	for _, target := range r.Targets {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			target.Name, target.Status, formatTime(target.LastSuccess), formatTime(target.LastFailure), target.LastError)
+	}
+	return tw.Flush()
+}
+
+// result formats a success flag and an error message
+func result(success bool, errMsg string) string {
+	if success {
+		return "succeeded"
+	}
+	return "failed: " + errMsg
+}
+
+// formatTime formats a time, leaving unknown times empty
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"time":   formatTime,
+	"join":   strings.Join,
+	"result": result,
+}).Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif">
+<h2>IPSSL certificate report</h2>
+<p>Generated {{time .GeneratedAt}}</p>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Certificate</th><th>Identifiers</th><th>Status</th><th>Expires</th><th>Days left</th><th>Last renewal</th><th>Next action</th></tr>
+{{range .Certificates}}<tr>
+<td>{{.Path}}</td>
+<td>{{join .Identifiers ", "}}</td>
+<td>{{.Status}}{{with .Error}} ({{.}}){{end}}</td>
+<td>{{time .NotAfter}}</td>
+<td>{{.DaysLeft}}</td>
+<td>{{with .LastRenewal}}{{time .Time}}: {{result .Success .Error}}{{if .Override}} (manual override){{end}}{{with .CycleID}} (cycle {{.}}){{end}}{{range .Deployers}}<br>{{.Name}}: {{result .Success .Error}}{{end}}{{else}}-{{end}}</td>
+<td>{{.NextAction}}</td>
+</tr>
+{{end}}</table>
+{{if .Targets}}<h3>Deployment targets</h3>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Target</th><th>Status</th><th>Last success</th><th>Last failure</th><th>Error</th></tr>
+{{range .Targets}}<tr>
+<td>{{.Name}}</td>
+<td>{{.Status}}{{if .ConsecutiveFailures}} ({{.ConsecutiveFailures}} consecutive failures){{end}}</td>
+<td>{{time .LastSuccess}}</td>
+<td>{{time .LastFailure}}</td>
+<td>{{.LastError}}</td>
+</tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
+
+// ContentType returns the MIME type of a rendered report
+func ContentType(format string) string {
+	switch format {
+	case FormatHTML:
+		return "text/html"
+	case FormatJSON:
+		return "application/json"
+	case FormatICS:
+		return "text/calendar"
+	default:
+		return "text/plain"
+	}
+}
+
+// Subject returns a one-line summary suitable for an email subject
+func (r *Report) Subject() string {
+	var problems []string
+	if n := r.NeedsAttention(); n > 0 {
+		problems = append(problems, fmt.Sprintf("%d certificate(s) need attention", n))
+	}
+	if n := r.FailingTargets(); n > 0 {
+		problems = append(problems, fmt.Sprintf("%d deployment target(s) failing", n))
+	}
+	if len(problems) > 0 {
+		return "IPSSL certificate report: " + strings.Join(problems, ", ")
+	}
+	return "IPSSL certificate report: all certificates ok"
+}
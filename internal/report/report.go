@@ -0,0 +1,193 @@
+package report
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/state"
+)
+
+// Certificate statuses
+const (
+	StatusOK       = "ok"
+	StatusExpiring = "expiring"
+	StatusExpired  = "expired"
+	StatusMissing  = "missing"
+	StatusInvalid  = "invalid"
+)
+
+// Deployment target statuses
+const (
+	TargetOK      = "ok"
+	TargetFailing = "failing"
+)
+
+// Report summarizes every managed certificate and deployment target
+type Report struct {
+	GeneratedAt  time.Time     `json:"generated_at"`
+	Certificates []Certificate `json:"certificates"`
+	Targets      []Target      `json:"targets,omitempty"`
+}
+
+// Target describes the deployment health of one deployer
+type Target struct {
+	Name                string    `json:"name"`
+	Status              string    `json:"status"`
+	LastSuccess         time.Time `json:"last_success,omitempty"`
+	LastFailure         time.Time `json:"last_failure,omitempty"`
+	LastError           string    `json:"last_error,omitempty"`
+	ConsecutiveFailures int       `json:"consecutive_failures,omitempty"`
+}
+
+// Certificate describes one managed certificate and the actions scheduled for it
+type Certificate struct {
+	Path        string            `json:"path"`
+	Identifiers []string          `json:"identifiers"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Status      string            `json:"status"`
+	Error       string            `json:"error,omitempty"`
+	Serial      string            `json:"serial,omitempty"`
+	NotAfter    time.Time         `json:"not_after,omitempty"`
+	DaysLeft    int               `json:"days_left"`
+	RenewAfter  time.Time         `json:"renew_after,omitempty"`
+	NextCheck   time.Time         `json:"next_check,omitempty"`
+	NextAction  string            `json:"next_action"`
+	LastRenewal *state.Renewal    `json:"last_renewal,omitempty"`
+	LastSuccess time.Time         `json:"last_success,omitempty"`
+	Freeze      *state.Freeze     `json:"freeze,omitempty"`
+}
+
+// Build assembles a report from the installed certificate files and the recorded
+// state, with every timestamp in the configured time zone
+func Build(cfg *config.Config, st *state.State, now time.Time) *Report {
+	cert := Certificate{
+		Path:        filepath.Join(cfg.SSLDir, "cert.pem"),
+		Identifiers: cfg.Identifiers(),
+		Labels:      cfg.Labels,
+		LastSuccess: cfg.In(st.LastSuccess),
+	}
+	if renewal := st.LastRenewal; renewal != nil {
+		localized := *renewal
+		localized.Time = cfg.In(renewal.Time)
+		localized.NotAfter = cfg.In(renewal.NotAfter)
+		cert.LastRenewal = &localized
+	}
+	if freeze := st.ActiveFreeze(now); freeze != nil {
+		localized := *freeze
+		localized.Since = cfg.In(freeze.Since)
+		localized.Until = cfg.In(freeze.Until)
+		cert.Freeze = &localized
+	}
+	if health := st.Certificates[strings.Join(cert.Identifiers, ",")]; health != nil {
+		cert.LastSuccess = cfg.In(health.LastSuccess)
+	}
+	if !st.LastCheck.IsZero() {
+		cert.NextCheck = cfg.In(st.LastCheck.Add(cfg.RenewalInterval))
+	}
+
+	leaf, err := readLeaf(cert.Path)
+	switch {
+	case os.IsNotExist(err):
+		cert.Status = StatusMissing
+	case err != nil:
+		cert.Status = StatusInvalid
+		cert.Error = err.Error()
+	default:
+		cert.Serial = fmt.Sprintf("%x", leaf.SerialNumber)
+		cert.NotAfter = cfg.In(leaf.NotAfter)
+		cert.DaysLeft = int(leaf.NotAfter.Sub(now).Hours() / 24)
+		cert.RenewAfter = cfg.In(leaf.NotAfter.Add(-cfg.CertValidity))
+		switch {
+		case now.After(leaf.NotAfter):
+			cert.Status = StatusExpired
+		case now.After(cert.RenewAfter):
+			cert.Status = StatusExpiring
+		default:
+			cert.Status = StatusOK
+		}
+	}
+	cert.NextAction = nextAction(cert)
+
+	return &Report{
+		GeneratedAt:  cfg.In(now),
+		Certificates: []Certificate{cert},
+		Targets:      buildTargets(cfg, st),
+	}
+}
+
+// buildTargets lists the recorded deployment targets by name
+func buildTargets(cfg *config.Config, st *state.State) []Target {
+	targets := make([]Target, 0, len(st.Targets))
+	for name, health := range st.Targets {
+		target := Target{
+			Name:                name,
+			Status:              TargetOK,
+			LastSuccess:         cfg.In(health.LastSuccess),
+			LastFailure:         cfg.In(health.LastFailure),
+			ConsecutiveFailures: health.ConsecutiveFailures,
+		}
+		if health.Failing() {
+			target.Status = TargetFailing
+			target.LastError = health.LastError
+		}
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Name < targets[j].Name })
+	return targets
+}
+
+// NeedsAttention counts certificates that are not in the ok state
+func (r *Report) NeedsAttention() int {
+	count := 0
+	for _, cert := range r.Certificates {
+		if cert.Status != StatusOK {
+			count++
+		}
+	}
+	return count
+}
+
+// FailingTargets counts deployment targets whose last deployment failed
+func (r *Report) FailingTargets() int {
+	count := 0
+	for _, target := range r.Targets {
+		if target.Status == TargetFailing {
+			count++
+		}
+	}
+	return count
+}
+
+// nextAction describes what the client will do next for a certificate
+func nextAction(cert Certificate) string {
+	if cert.Freeze != nil {
+		return "frozen until " + cert.Freeze.Until.Format(time.RFC3339) + " (" + cert.Freeze.Reason + ")"
+	}
+	if cert.Status == StatusOK {
+		return "renew after " + cert.RenewAfter.Format(time.RFC3339)
+	}
+	if cert.NextCheck.IsZero() {
+		return "renew at next check"
+	}
+	return "renew at next check (" + cert.NextCheck.Format(time.RFC3339) + ")"
+}
+
+// readLeaf parses the first certificate of a PEM file
+func readLeaf(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode PEM block")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
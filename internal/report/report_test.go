@@ -0,0 +1,207 @@
+package report
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/state"
+)
+
+func writeCert(t *testing.T, dir string, notAfter time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, "cert.pem"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuildStatus(t *testing.T) {
+	now := time.Now()
+	cfg := &config.Config{
+		ClientIPs:       []string{"192.0.2.1"},
+		CertValidity:    30 * 24 * time.Hour,
+		RenewalInterval: 24 * time.Hour,
+	}
+
+	tests := []struct {
+		name     string
+		notAfter time.Time
+		want     string
+	}{
+		{"ok", now.Add(60 * 24 * time.Hour), StatusOK},
+		{"expiring", now.Add(10 * 24 * time.Hour), StatusExpiring},
+		{"expired", now.Add(-time.Hour), StatusExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.SSLDir = t.TempDir()
+			writeCert(t, cfg.SSLDir, tt.notAfter)
+
+			r := Build(cfg, &state.State{LastCheck: now}, now)
+			cert := r.Certificates[0]
+			if cert.Status != tt.want {
+				t.Errorf("Expected status %s, got %s", tt.want, cert.Status)
+			}
+			if cert.Serial != "2a" {
+				t.Errorf("Expected serial 2a, got %s", cert.Serial)
+			}
+		})
+	}
+}
+
+func TestBuildMissing(t *testing.T) {
+	cfg := &config.Config{SSLDir: t.TempDir()}
+	r := Build(cfg, &state.State{}, time.Now())
+	if r.Certificates[0].Status != StatusMissing {
+		t.Errorf("Expected status %s, got %s", StatusMissing, r.Certificates[0].Status)
+	}
+	if r.NeedsAttention() != 1 {
+		t.Errorf("Expected 1 certificate needing attention, got %d", r.NeedsAttention())
+	}
+}
+
+func TestBuildTimezone(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip("time zone database unavailable:", err)
+	}
+	cfg := &config.Config{
+		SSLDir:          t.TempDir(),
+		Location:        loc,
+		CertValidity:    30 * 24 * time.Hour,
+		RenewalInterval: 24 * time.Hour,
+	}
+	now := time.Now().UTC()
+	writeCert(t, cfg.SSLDir, now.Add(60*24*time.Hour))
+
+	st := &state.State{LastCheck: now, LastRenewal: &state.Renewal{Time: now, Success: true}}
+	r := Build(cfg, st, now)
+	cert := r.Certificates[0]
+	for name, ts := range map[string]time.Time{
+		"generated_at": r.GeneratedAt,
+		"not_after":    cert.NotAfter,
+		"renew_after":  cert.RenewAfter,
+		"next_check":   cert.NextCheck,
+		"last_renewal": cert.LastRenewal.Time,
+	} {
+		if ts.Location() != loc {
+			t.Errorf("Expected %s in %s, got %s", name, loc, ts.Location())
+		}
+	}
+	if !strings.Contains(cert.NextAction, "+08:00") {
+		t.Errorf("Expected next action in +08:00, got %q", cert.NextAction)
+	}
+	if st.LastRenewal.Time.Location() != time.UTC {
+		t.Error("Build modified the recorded state")
+	}
+}
+
+func TestRenderFormats(t *testing.T) {
+	r := &Report{
+		GeneratedAt: time.Now(),
+		Certificates: []Certificate{{
+			Path:        "/ipssl/cert.pem",
+			Identifiers: []string{"192.0.2.1"},
+			Status:      StatusOK,
+			LastRenewal: &state.Renewal{Time: time.Now(), Success: true, Deployers: []state.DeployResult{{Name: "compose", Error: "exit status 1"}}},
+		}},
+	}
+	for _, format := range []string{FormatText, FormatJSON, FormatHTML} {
+		var buf bytes.Buffer
+		if err := Render(&buf, r, format); err != nil {
+			t.Fatalf("Render(%s) failed: %v", format, err)
+		}
+		if !strings.Contains(buf.String(), "192.0.2.1") {
+			t.Errorf("Render(%s) output is missing the identifier", format)
+		}
+	}
+	if err := Render(&bytes.Buffer{}, r, "xml"); err == nil {
+		t.Error("Expected error for unknown format, got nil")
+	}
+}
+
+func TestRenderICS(t *testing.T) {
+	notAfter := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	r := &Report{
+		GeneratedAt: time.Now(),
+		Certificates: []Certificate{{
+			Path:        "/ipssl/cert.pem",
+			Identifiers: []string{"192.0.2.1", "example.com"},
+			Status:      StatusOK,
+			Serial:      "2a",
+			NotAfter:    notAfter,
+			RenewAfter:  notAfter.Add(-30 * 24 * time.Hour),
+		}},
+	}
+
+	var buf bytes.Buffer
+	if err := Render(&buf, r, FormatICS); err != nil {
+		t.Fatalf("Render(ics) failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"BEGIN:VCALENDAR\r\n", "UID:expiry-2a@ipssl-client", "DTSTART:20260301T120000Z", "DTSTART:20260130T120000Z", "192.0.2.1\\, example.com"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Calendar is missing %q", want)
+		}
+	}
+	for _, line := range strings.Split(out, "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("Calendar line exceeds 75 octets: %q", line)
+		}
+	}
+}
+
+func TestBuildTargets(t *testing.T) {
+	now := time.Now()
+	st := &state.State{}
+	st.RecordDeploy(state.DeployResult{Name: "ssh", Success: true}, now.Add(-48*time.Hour))
+	st.RecordDeploy(state.DeployResult{Name: "ssh", Error: "connection refused"}, now)
+	st.RecordDeploy(state.DeployResult{Name: "compose", Success: true}, now)
+
+	r := Build(&config.Config{SSLDir: t.TempDir()}, st, now)
+	if len(r.Targets) != 2 || r.Targets[0].Name != "compose" || r.Targets[1].Name != "ssh" {
+		t.Fatalf("Expected targets compose and ssh, got %+v", r.Targets)
+	}
+	if r.Targets[0].Status != TargetOK {
+		t.Errorf("Expected compose to be %s, got %s", TargetOK, r.Targets[0].Status)
+	}
+	ssh := r.Targets[1]
+	if ssh.Status != TargetFailing || ssh.LastError != "connection refused" || ssh.ConsecutiveFailures != 1 {
+		t.Errorf("Expected ssh to be failing with its last error, got %+v", ssh)
+	}
+	if r.FailingTargets() != 1 || !strings.Contains(r.Subject(), "1 deployment target(s) failing") {
+		t.Errorf("Expected the subject to mention the failing target, got %q", r.Subject())
+	}
+
+	var buf bytes.Buffer
+	if err := Render(&buf, r, FormatText); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "connection refused") {
+		t.Errorf("Expected text report to list the target error, got:\n%s", buf.String())
+	}
+}
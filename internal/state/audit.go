@@ -0,0 +1,64 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditFileName is the name of the audit log inside the SSL directory
+const AuditFileName = "audit.log"
+
+// auditMu serialises appending to and pruning the audit log within the process
+var auditMu sync.Mutex
+
+// Audit actions
+const (
+	AuditFreeze       = "freeze"
+	AuditUnfreeze     = "unfreeze"
+	AuditFreezeExpire = "freeze-expired"
+	AuditSSHDeploy    = "ssh-deploy"
+)
+
+// AuditEvent is one entry of the audit log
+type AuditEvent struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	Reason string    `json:"reason,omitempty"`
+	Until  time.Time `json:"until,omitempty"`
+
+	// Target, HostKey and CycleID describe a deployment over SSH: the target
+	// name, the fingerprint of the host key it presented and the renewal cycle
+	Target  string `json:"target,omitempty"`
+	HostKey string `json:"host_key,omitempty"`
+	CycleID string `json:"cycle_id,omitempty"`
+}
+
+// AppendAudit appends an event as one JSON line to the audit log at path
+func AppendAudit(path string, event AuditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit event: %w", err)
+	}
+
+	// Pruning rewrites the log, so appends take the same locks
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	unlock, err := lock(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,56 @@
+//go:build !windows
+
+package state
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// lock takes an exclusive POSIX record lock on path, waiting up to lockTimeout.
+// Record locks are honoured over NFS (through the lock manager) and released
+// by the kernel if the holder dies, so a crashed node never leaves it stale.
+func lock(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state lock: %w", err)
+	}
+
+	flock := syscall.Flock_t{Type: syscall.F_WRLCK, Whence: 0}
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		err = syscall.FcntlFlock(f.Fd(), syscall.F_SETLK, &flock)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EAGAIN) && !errors.Is(err, syscall.EACCES) {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock state: %w", err)
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("timed out after %s waiting for state lock %s", lockTimeout, path)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+
+	return func() {
+		flock.Type = syscall.F_UNLCK
+		syscall.FcntlFlock(f.Fd(), syscall.F_SETLK, &flock)
+		f.Close()
+	}, nil
+}
+
+// tryLock takes an exclusive POSIX record lock on f without waiting and
+// reports whether it was taken
+func tryLock(f *os.File) (bool, error) {
+	flock := syscall.Flock_t{Type: syscall.F_WRLCK, Whence: 0}
+	err := syscall.FcntlFlock(f.Fd(), syscall.F_SETLK, &flock)
+	if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EACCES) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,150 @@
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Retention limits the history kept in the audit log and the state file
+type Retention struct {
+	// Keep is the number of newest audit log entries kept, 0 for no limit
+	Keep int
+
+	// MaxAge drops audit log entries, and certificates and deployment targets
+	// not seen since, older than this; 0 for no limit
+	MaxAge time.Duration
+}
+
+// Enabled reports whether the retention limits anything
+func (r Retention) Enabled() bool {
+	return r.Keep > 0 || r.MaxAge > 0
+}
+
+// PruneResult counts the entries removed by Prune
+type PruneResult struct {
+	AuditEntries int
+	Certificates int
+	Targets      int
+}
+
+// Prune applies the retention to the audit log and the state file in dir
+func Prune(dir string, r Retention, now time.Time) (PruneResult, error) {
+	var result PruneResult
+	if !r.Enabled() {
+		return result, nil
+	}
+
+	removed, err := pruneAudit(filepath.Join(dir, AuditFileName), r, now)
+	if err != nil {
+		return result, err
+	}
+	result.AuditEntries = removed
+
+	if r.MaxAge <= 0 {
+		return result, nil
+	}
+	cutoff := now.Add(-r.MaxAge)
+	path := filepath.Join(dir, FileName)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	}
+	err = Update(path, func(st *State) {
+		for key, cert := range st.Certificates {
+			if cert.LastHealthy().Before(cutoff) {
+				delete(st.Certificates, key)
+				result.Certificates++
+			}
+		}
+		for name, target := range st.Targets {
+			if target.LastSuccess.Before(cutoff) && target.LastFailure.Before(cutoff) {
+				delete(st.Targets, name)
+				result.Targets++
+			}
+		}
+	})
+	return result, err
+}
+
+// pruneAudit rewrites the audit log without the entries outside the
+// retention and returns how many were removed. Lines that cannot be parsed
+// are kept unless they fall outside Keep.
+func pruneAudit(path string, r Retention, now time.Time) (int, error) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	unlock, err := lock(path + ".lock")
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	var (
+		lines [][]byte
+		total int
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		total++
+		if r.MaxAge > 0 {
+			var event AuditEvent
+			if err := json.Unmarshal(line, &event); err == nil && event.Time.Before(now.Add(-r.MaxAge)) {
+				continue
+			}
+		}
+		lines = append(lines, append([]byte(nil), line...))
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	if r.Keep > 0 && len(lines) > r.Keep {
+		lines = lines[len(lines)-r.Keep:]
+	}
+
+	removed := total - len(lines)
+	if removed <= 0 {
+		return 0, nil
+	}
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to write audit log: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return 0, fmt.Errorf("failed to write audit log: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write audit log: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return 0, fmt.Errorf("failed to write audit log: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return removed, nil
+}
@@ -0,0 +1,261 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// queueStaleAfter is how long the ticket of a process that stopped keeps its
+// place in the queue for when the process asks again
+const queueStaleAfter = 24 * time.Hour
+
+// ticketSuffix marks the ticket files in a queue directory
+const ticketSuffix = ".ticket"
+
+// queueMu serialises queue scans within the process, which record locks do not
+var queueMu sync.Mutex
+
+// heldTickets are the ticket files held by this process with their last
+// written content. Record locks do not exclude the process's own goroutines,
+// and closing any descriptor of a file drops the process's locks on it, so
+// these are never opened again while held.
+var (
+	heldMu      sync.Mutex
+	heldTickets = make(map[string]ticket)
+)
+
+// ticket is the content of a ticket file
+type ticket struct {
+	Enqueued time.Time `json:"enqueued"`
+	Running  bool      `json:"running"`
+}
+
+// Queue hands out a limited number of slots to the processes sharing a
+// directory, in the order they asked. Every process waiting for or holding a
+// slot keeps a ticket file with a record lock on it: the kernel releases the
+// lock if the process dies, so its slot frees up, while the ticket keeps its
+// place in the queue should it ask again under the same id after a restart.
+type Queue struct {
+	dir   string
+	limit int
+	poll  time.Duration
+}
+
+// NewQueue returns a queue of at most limit concurrent slots kept in dir
+func NewQueue(dir string, limit int) *Queue {
+	return &Queue{dir: dir, limit: limit, poll: time.Second}
+}
+
+// Acquire waits until a slot is free for id and returns the function that
+// releases it. If ctx ends first, the ticket is kept so that id retains its
+// place in the queue.
+func (q *Queue) Acquire(ctx context.Context, id string) (func(), error) {
+	if err := os.MkdirAll(q.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create queue directory: %w", err)
+	}
+	path := filepath.Join(q.dir, ticketName(id))
+
+	f, err := q.hold(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	release := func() {
+		os.Remove(path)
+		f.Close()
+		heldMu.Lock()
+		delete(heldTickets, path)
+		heldMu.Unlock()
+	}
+
+	for {
+		granted, err := q.try(f, path)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		if granted {
+			return release, nil
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			heldMu.Lock()
+			delete(heldTickets, path)
+			heldMu.Unlock()
+			return nil, ctx.Err()
+		case <-time.After(q.poll):
+		}
+	}
+}
+
+// hold opens and locks the ticket file at path, waiting while another
+// goroutine or process holds the same id
+func (q *Queue) hold(ctx context.Context, path string) (*os.File, error) {
+	for {
+		heldMu.Lock()
+		if _, held := heldTickets[path]; !held {
+			f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+			if err != nil {
+				heldMu.Unlock()
+				return nil, fmt.Errorf("failed to open queue ticket: %w", err)
+			}
+			locked, err := tryLock(f)
+			if err != nil {
+				f.Close()
+				heldMu.Unlock()
+				return nil, fmt.Errorf("failed to lock queue ticket: %w", err)
+			}
+			// The ticket may have been removed as stale before it was locked
+			if locked && !sameFile(f, path) {
+				locked = false
+			}
+			if locked {
+				// A ticket kept from an earlier attempt retains its place
+				t, _ := readTicket(f)
+				heldTickets[path] = t
+				heldMu.Unlock()
+				return f, nil
+			}
+			f.Close()
+		}
+		heldMu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(q.poll):
+		}
+	}
+}
+
+// try grants the held ticket a slot if fewer than limit tickets are running
+// and every live ticket enqueued before it is already running
+func (q *Queue) try(f *os.File, path string) (bool, error) {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	unlock, err := lock(filepath.Join(q.dir, "queue.lock"))
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	own, err := readTicket(f)
+	if err != nil || own.Enqueued.IsZero() {
+		own = ticket{Enqueued: time.Now()}
+	}
+
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return false, fmt.Errorf("failed to read queue directory: %w", err)
+	}
+	name := filepath.Base(path)
+	running, ahead := 0, 0
+	for _, entry := range entries {
+		if entry.Name() == name || !strings.HasSuffix(entry.Name(), ticketSuffix) {
+			continue
+		}
+		other, live := q.probe(filepath.Join(q.dir, entry.Name()))
+		switch {
+		case !live:
+		case other.Running:
+			running++
+		case other.Enqueued.Before(own.Enqueued) || (other.Enqueued.Equal(own.Enqueued) && entry.Name() < name):
+			ahead++
+		}
+	}
+
+	own.Running = running+ahead < q.limit
+	if err := writeTicket(f, own); err != nil {
+		return false, err
+	}
+	heldMu.Lock()
+	heldTickets[path] = own
+	heldMu.Unlock()
+	return own.Running, nil
+}
+
+// probe reads the ticket at path and reports whether a live process holds it.
+// Tickets held by this process are answered from memory. Tickets not written
+// for queueStaleAfter are removed.
+func (q *Queue) probe(path string) (ticket, bool) {
+	// Held while the file is open, so that hold cannot lock it in between
+	heldMu.Lock()
+	defer heldMu.Unlock()
+	t, held := heldTickets[path]
+	if held {
+		return t, true
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return t, false
+	}
+	defer f.Close()
+	if data, err := io.ReadAll(f); err == nil {
+		json.Unmarshal(data, &t)
+	}
+	if locked, err := tryLock(f); err != nil || !locked {
+		return t, err == nil
+	}
+	// Live tickets are rewritten on every poll
+	if info, err := f.Stat(); err == nil && time.Since(info.ModTime()) > queueStaleAfter {
+		os.Remove(path)
+	}
+	return t, false
+}
+
+// sameFile reports whether f is still the file at path
+func sameFile(f *os.File, path string) bool {
+	opened, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(path)
+	return err == nil && os.SameFile(opened, current)
+}
+
+// readTicket reads a held ticket file
+func readTicket(f *os.File) (ticket, error) {
+	var t ticket
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return t, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return t, err
+	}
+	err = json.Unmarshal(data, &t)
+	return t, err
+}
+
+// writeTicket replaces the content of a held ticket file
+func writeTicket(f *os.File, t ticket) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		return fmt.Errorf("failed to write queue ticket: %w", err)
+	}
+	if _, err := f.WriteAt(data, 0); err != nil {
+		return fmt.Errorf("failed to write queue ticket: %w", err)
+	}
+	return nil
+}
+
+// ticketName returns the file name of the ticket for id
+func ticketName(id string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, id) + ticketSuffix
+}
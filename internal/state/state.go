@@ -0,0 +1,234 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileName is the name of the state file inside the state directory
+const FileName = "state.json"
+
+// How long Update waits for the state lock held by another process or host
+const (
+	lockTimeout       = 30 * time.Second
+	lockRetryInterval = 100 * time.Millisecond
+)
+
+// updateMu serialises updates within the process, which record locks do not
+var updateMu sync.Mutex
+
+// ErrCorrupt is returned when the state file exists but cannot be parsed
+var ErrCorrupt = errors.New("failed to parse state file")
+
+// State records the outcome of renewal checks so that reports can be produced
+// without contacting the certificate authority
+type State struct {
+	LastCheck   time.Time `json:"last_check"`
+	LastRenewal *Renewal  `json:"last_renewal,omitempty"`
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	Counters    Counters  `json:"counters"`
+
+	// Targets tracks the deployment health of each deployer, keyed by name
+	Targets map[string]*TargetHealth `json:"targets,omitempty"`
+
+	// Certificates tracks each certificate, keyed by its comma-separated identifiers
+	Certificates map[string]*CertificateHealth `json:"certificates,omitempty"`
+
+	// Freeze suspends renewal and deployment of the certificate until it expires
+	Freeze *Freeze `json:"freeze,omitempty"`
+}
+
+// Freeze is a change-freeze window during which the certificate is neither
+// renewed nor deployed
+type Freeze struct {
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason"`
+}
+
+// ActiveFreeze returns the freeze in effect at now, or nil
+func (s *State) ActiveFreeze(now time.Time) *Freeze {
+	if s.Freeze == nil || !now.Before(s.Freeze.Until) {
+		return nil
+	}
+	return s.Freeze
+}
+
+// TargetHealth records when a deployment target last received a certificate
+// and when it last failed to, so that targets that silently stopped being
+// updated stand out
+type TargetHealth struct {
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	LastFailure time.Time `json:"last_failure,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+
+	// ConsecutiveFailures counts failed deployments since the last success
+	ConsecutiveFailures int `json:"consecutive_failures,omitempty"`
+}
+
+// Failing reports whether the most recent deployment to the target failed
+func (t *TargetHealth) Failing() bool {
+	return t.LastFailure.After(t.LastSuccess)
+}
+
+// CertificateHealth records when a certificate was last renewed and when a
+// check last found it installed and not yet due for renewal
+type CertificateHealth struct {
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	LastCurrent time.Time `json:"last_current,omitempty"`
+}
+
+// LastHealthy returns the later of the last successful renewal and the last current check
+func (c *CertificateHealth) LastHealthy() time.Time {
+	if c.LastCurrent.After(c.LastSuccess) {
+		return c.LastCurrent
+	}
+	return c.LastSuccess
+}
+
+// Certificate returns the health record of the certificate with the given key, creating it if needed
+func (s *State) Certificate(key string) *CertificateHealth {
+	if s.Certificates == nil {
+		s.Certificates = make(map[string]*CertificateHealth)
+	}
+	cert := s.Certificates[key]
+	if cert == nil {
+		cert = &CertificateHealth{}
+		s.Certificates[key] = cert
+	}
+	return cert
+}
+
+// Counters are cumulative totals kept across restarts so that exported
+// Prometheus counters do not reset when the process is restarted
+type Counters struct {
+	Checks           uint64            `json:"checks"`
+	RenewalSuccesses uint64            `json:"renewal_successes"`
+	RenewalFailures  uint64            `json:"renewal_failures"`
+	BudgetExceeded   uint64            `json:"budget_exceeded"`
+	RenewalTimeouts  uint64            `json:"renewal_timeouts"`
+	DeployerFailures map[string]uint64 `json:"deployer_failures,omitempty"`
+}
+
+// Renewal is the result of a single certificate request and deployment
+type Renewal struct {
+	CycleID        string         `json:"cycle_id,omitempty"`
+	Time           time.Time      `json:"time"`
+	Override       bool           `json:"override,omitempty"`
+	Success        bool           `json:"success"`
+	RolledBack     bool           `json:"rolled_back,omitempty"`
+	Error          string         `json:"error,omitempty"`
+	Serial         string         `json:"serial,omitempty"`
+	PreviousSerial string         `json:"previous_serial,omitempty"`
+	NotAfter       time.Time      `json:"not_after,omitempty"`
+	Deployers      []DeployResult `json:"deployers,omitempty"`
+}
+
+// Changed reports whether the renewal installed a certificate other than the
+// one it replaced, rather than the same certificate again
+func (r *Renewal) Changed() bool {
+	return r.Serial != "" && r.Serial != r.PreviousSerial
+}
+
+// DeployResult is the outcome of one deployer during a renewal
+type DeployResult struct {
+	Name     string `json:"name"`
+	Success  bool   `json:"success"`
+	Error    string `json:"error,omitempty"`
+	Attempts int    `json:"attempts,omitempty"`
+}
+
+// RecordDeploy updates the health of the target of a deployer result
+func (s *State) RecordDeploy(result DeployResult, at time.Time) {
+	if s.Targets == nil {
+		s.Targets = make(map[string]*TargetHealth)
+	}
+	target := s.Targets[result.Name]
+	if target == nil {
+		target = &TargetHealth{}
+		s.Targets[result.Name] = target
+	}
+	if result.Success {
+		target.LastSuccess = at
+		target.ConsecutiveFailures = 0
+		return
+	}
+	target.LastFailure = at
+	target.LastError = result.Error
+	target.ConsecutiveFailures++
+}
+
+// Load reads the state file at path. A missing file yields an empty state.
+func Load(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &State{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%w %s: %w", ErrCorrupt, path, err)
+	}
+	return &s, nil
+}
+
+// Update applies fn to the state file at path while holding a lock on it, so
+// that processes and hosts sharing the file (e.g. an active/standby pair on
+// NFS) never overwrite each other's changes. A state file that cannot be
+// parsed is replaced by a fresh state and reported with an ErrCorrupt error.
+func Update(path string, fn func(*State)) error {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+	unlock, err := lock(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	st, corrupt := Load(path)
+	if corrupt != nil && !errors.Is(corrupt, ErrCorrupt) {
+		return corrupt
+	}
+	if corrupt != nil {
+		st = &State{}
+	}
+	fn(st)
+	if err := st.Save(path); err != nil {
+		return err
+	}
+	return corrupt
+}
+
+// Save writes the state to path, replacing the previous file atomically
+func (s *State) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUpdateConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := Update(path, func(st *State) { st.Counters.Checks++ }); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	st, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Counters.Checks != 20 {
+		t.Errorf("Expected 20 checks to be recorded, got %d", st.Counters.Checks)
+	}
+}
+
+func TestUpdateCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := Update(path, func(st *State) { st.Counters.Checks++ })
+	if !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("Expected ErrCorrupt, got %v", err)
+	}
+	st, err := Load(path)
+	if err != nil || st.Counters.Checks != 1 {
+		t.Errorf("Expected the corrupt state to be replaced, got %+v (%v)", st, err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	auditPath := filepath.Join(dir, AuditFileName)
+	for i := range 5 {
+		event := AuditEvent{Time: now.Add(-time.Duration(5-i) * 24 * time.Hour), Action: AuditFreeze, Reason: strconv.Itoa(i)}
+		if err := AppendAudit(auditPath, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Update(filepath.Join(dir, FileName), func(st *State) {
+		st.Certificate("192.0.2.1").LastSuccess = now
+		st.Certificate("192.0.2.2").LastSuccess = now.Add(-10 * 24 * time.Hour)
+		st.RecordDeploy(DeployResult{Name: "old", Success: true}, now.Add(-10*24*time.Hour))
+		st.RecordDeploy(DeployResult{Name: "new", Success: true}, now)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Entries older than 3.5 days are dropped first, then all but the newest two
+	result, err := Prune(dir, Retention{Keep: 2, MaxAge: 84 * time.Hour}, now)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if result != (PruneResult{AuditEntries: 3, Certificates: 1, Targets: 1}) {
+		t.Errorf("Unexpected prune result %+v", result)
+	}
+
+	data, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"reason":"3"`) || !strings.Contains(lines[1], `"reason":"4"`) {
+		t.Errorf("Expected the two newest audit entries to remain, got %q", lines)
+	}
+
+	st, err := Load(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Certificates["192.0.2.1"] == nil || st.Certificates["192.0.2.2"] != nil {
+		t.Errorf("Expected only the stale certificate to be pruned, got %+v", st.Certificates)
+	}
+	if st.Targets["new"] == nil || st.Targets["old"] != nil {
+		t.Errorf("Expected only the stale target to be pruned, got %+v", st.Targets)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	dir := t.TempDir()
+	q := NewQueue(dir, 1)
+	q.poll = 10 * time.Millisecond
+
+	releaseA, err := q.Acquire(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("Acquire(a) failed: %v", err)
+	}
+
+	// The only slot is taken; b keeps its ticket when it stops waiting
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := q.Acquire(ctx, "b"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected Acquire(b) to wait for the slot, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b"+ticketSuffix)); err != nil {
+		t.Errorf("Expected b to keep its place in the queue: %v", err)
+	}
+
+	releaseA()
+	releaseB, err := q.Acquire(context.Background(), "b")
+	if err != nil {
+		t.Fatalf("Acquire(b) failed: %v", err)
+	}
+	releaseB()
+	if _, err := os.Stat(filepath.Join(dir, "b"+ticketSuffix)); !os.IsNotExist(err) {
+		t.Errorf("Expected the released ticket to be removed, got %v", err)
+	}
+}
+
+func TestQueueProbeKeepsLocks(t *testing.T) {
+	if path := os.Getenv("IPSSL_TEST_TICKET"); path != "" {
+		// Run in a child process, where the parent's record locks apply
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if locked, err := tryLock(f); err != nil || locked {
+			t.Fatalf("Expected the ticket to be locked by the parent, got %v (%v)", locked, err)
+		}
+		return
+	}
+
+	dir := t.TempDir()
+	q := NewQueue(dir, 2)
+	q.poll = 10 * time.Millisecond
+
+	// Each acquisition probes the ticket of the other
+	releaseA, err := q.Acquire(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("Acquire(a) failed: %v", err)
+	}
+	defer releaseA()
+	releaseB, err := q.Acquire(context.Background(), "b")
+	if err != nil {
+		t.Fatalf("Acquire(b) failed: %v", err)
+	}
+	defer releaseB()
+	pathA := filepath.Join(dir, "a"+ticketSuffix)
+	if _, live := q.probe(pathA); !live {
+		t.Fatal("Expected the held ticket to be live")
+	}
+
+	for _, path := range []string{pathA, filepath.Join(dir, "b"+ticketSuffix)} {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestQueueProbeKeepsLocks$")
+		cmd.Env = append(os.Environ(), "IPSSL_TEST_TICKET="+path)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("Expected %s to stay locked: %v\n%s", filepath.Base(path), err, out)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package support
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"ipssl-client/internal/config"
+	"ipssl-client/internal/ipssl"
+	"ipssl-client/internal/report"
+	"ipssl-client/internal/state"
+)
+
+// Redacted replaces secrets in the bundle
+const Redacted = "REDACTED"
+
+// DefaultLogLines is the number of trailing log lines kept in the bundle
+const DefaultLogLines = 1000
+
+// Options selects what goes into a support bundle
+type Options struct {
+	// Config is the loaded configuration, nil when it failed to load
+	Config *config.Config
+
+	// Problems are the configuration errors reported by config.Validate
+	Problems []error
+
+	// Secrets are the credentials found in the environment, scrubbed from
+	// every file even when the configuration failed to load
+	Secrets []string
+
+	// Logs, when set, is read to the end and its last LogLines lines are kept
+	Logs     io.Reader
+	LogLines int
+
+	Now time.Time
+}
+
+// file is one entry of the bundle
+type file struct {
+	name string
+	data []byte
+}
+
+// Write gathers the redacted configuration, version information, state,
+// certificate report, last failure report, audit log and logs into a gzipped
+// tar archive written to w. The files of each certificate set are placed in
+// a directory named after the set. Files that are missing are skipped; known
+// secrets are replaced in every file.
+func Write(w io.Writer, opts Options) error {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	if opts.LogLines <= 0 {
+		opts.LogLines = DefaultLogLines
+	}
+
+	var files []file
+	secrets := opts.Secrets
+	add := func(name string, data []byte) {
+		files = append(files, file{name: name, data: data})
+	}
+
+	add("version.txt", versions())
+
+	var problems bytes.Buffer
+	for _, err := range opts.Problems {
+		fmt.Fprintln(&problems, err)
+	}
+	if problems.Len() == 0 {
+		problems.WriteString("no problems found\n")
+	}
+	add("config-problems.txt", problems.Bytes())
+
+	if cfg := opts.Config; cfg != nil {
+		redacted, found, err := RedactConfig(cfg)
+		if err != nil {
+			return err
+		}
+		secrets = append(secrets, found...)
+		add("config.json", redacted)
+
+		// Each certificate set keeps its state, audit log and failure report
+		// in directories of its own
+		if len(cfg.Certificates) == 0 {
+			if err := addCertificate(add, "", cfg, opts); err != nil {
+				return err
+			}
+		}
+		for _, set := range cfg.Certificates {
+			if err := addCertificate(add, set.Name+"/", cfg.ForCertificate(set), opts); err != nil {
+				return err
+			}
+		}
+	}
+
+	if opts.Logs != nil {
+		logs, err := io.ReadAll(opts.Logs)
+		if err != nil {
+			return fmt.Errorf("failed to read logs: %w", err)
+		}
+		add("logs.txt", tail(logs, opts.LogLines))
+	}
+
+	scrub := scrubber(secrets)
+	prefix := "ipssl-support-" + opts.Now.UTC().Format("20060102T150405Z") + "/"
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, f := range files {
+		data := []byte(scrub.Replace(string(f.data)))
+		header := &tar.Header{
+			Name:    prefix + f.name,
+			Mode:    0600,
+			Size:    int64(len(data)),
+			ModTime: opts.Now,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write support bundle: %w", err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return fmt.Errorf("failed to write support bundle: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write support bundle: %w", err)
+	}
+	return gz.Close()
+}
+
+// addCertificate adds the state, last failure report, audit log and
+// certificate report of the client configured by cfg under prefix
+func addCertificate(add func(name string, data []byte), prefix string, cfg *config.Config, opts Options) error {
+	stateFile := filepath.Join(cfg.StateDir, state.FileName)
+	for _, source := range []struct{ name, path string }{
+		{"state.json", stateFile},
+		{ipssl.FailureReportFile, filepath.Join(cfg.SSLDir, ipssl.FailureReportFile)},
+		{state.AuditFileName, filepath.Join(cfg.StateDir, state.AuditFileName)},
+	} {
+		data, err := os.ReadFile(source.path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", source.path, err)
+		}
+		if source.name == state.AuditFileName {
+			data = tail(data, opts.LogLines)
+		}
+		add(prefix+source.name, data)
+	}
+
+	if st, err := state.Load(stateFile); err == nil {
+		var status bytes.Buffer
+		if err := report.Render(&status, report.Build(cfg, st, opts.Now), report.FormatText); err != nil {
+			return err
+		}
+		add(prefix+"status.txt", status.Bytes())
+	}
+	return nil
+}
+
+// RedactConfig renders the configuration as indented JSON with header values
+// and URL credentials replaced, and returns the secret values it removed
+// together with the credentials of the configuration, which are never encoded,
+// so that they can be scrubbed from logs as well
+func RedactConfig(cfg *config.Config) ([]byte, []string, error) {
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	var tree any
+	if err := json.Unmarshal(raw, &tree); err != nil {
+		return nil, nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+
+	secrets := cfg.Secrets()
+	tree = redact(tree, "", &secrets)
+
+	data, err := json.MarshalIndent(tree, "", "  ")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	return append(data, '\n'), secrets, nil
+}
+
+// redact replaces the secret values in a decoded JSON tree
+func redact(value any, key string, secrets *[]string) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, child := range v {
+			v[k] = redact(child, k, secrets)
+		}
+		return v
+	case []any:
+		for i, child := range v {
+			v[i] = redact(child, key, secrets)
+		}
+		return v
+	case string:
+		if v == "" {
+			return v
+		}
+		// Request headers such as Authorization keep only their name
+		if key == "headers" {
+			if name, value, ok := strings.Cut(v, ":"); ok {
+				*secrets = append(*secrets, strings.TrimSpace(value))
+				return name + ": " + Redacted
+			}
+		}
+		return redactURL(v, secrets)
+	default:
+		return v
+	}
+}
+
+// redactURL replaces the password and query of a URL, which may carry tokens
+func redactURL(value string, secrets *[]string) string {
+	if !strings.Contains(value, "://") {
+		return value
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return value
+	}
+	if password, ok := u.User.Password(); ok {
+		*secrets = append(*secrets, password)
+		u.User = url.UserPassword(u.User.Username(), Redacted)
+	}
+	if u.RawQuery != "" {
+		*secrets = append(*secrets, u.RawQuery)
+		u.RawQuery = Redacted
+	}
+	return u.String()
+}
+
+// scrubber replaces every occurrence of the secrets, longest first so that a
+// secret containing another is not partially revealed
+func scrubber(secrets []string) *strings.Replacer {
+	var unique []string
+	seen := make(map[string]bool)
+	for _, secret := range secrets {
+		if secret != "" && !seen[secret] {
+			seen[secret] = true
+			unique = append(unique, secret)
+		}
+	}
+	sort.Slice(unique, func(i, j int) bool { return len(unique[i]) > len(unique[j]) })
+
+	var pairs []string
+	for _, secret := range unique {
+		pairs = append(pairs, secret, Redacted)
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// versions describes the build and the platform it runs on
+func versions() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "go: %s\nplatform: %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return b.Bytes()
+	}
+	fmt.Fprintf(&b, "module: %s %s\n", info.Main.Path, info.Main.Version)
+	for _, setting := range info.Settings {
+		if strings.HasPrefix(setting.Key, "vcs") {
+			fmt.Fprintf(&b, "%s: %s\n", setting.Key, setting.Value)
+		}
+	}
+	for _, dep := range info.Deps {
+		fmt.Fprintf(&b, "dependency: %s %s\n", dep.Path, dep.Version)
+	}
+	return b.Bytes()
+}
+
+// tail returns the last n lines of data
+func tail(data []byte, n int) []byte {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
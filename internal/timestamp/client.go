@@ -0,0 +1,393 @@
+package timestamp
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha1"
+	"crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"time"
+
+	"ipssl-client/internal/logger"
+)
+
+// oidSHA256 identifies the SHA-256 hash algorithm
+var oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+
+// Object identifiers of the CMS attributes and algorithms checked in tokens
+var (
+	oidContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidRSAPSS        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 10}
+)
+
+// hashes maps the digest algorithms TSAs sign with to their hash functions
+var hashes = map[string]crypto.Hash{
+	"1.3.14.3.2.26":          crypto.SHA1,
+	"2.16.840.1.101.3.4.2.1": crypto.SHA256,
+	"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+	"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+}
+
+// PKI status values that indicate a granted timestamp
+const (
+	statusGranted         = 0
+	statusGrantedWithMods = 1
+)
+
+// messageImprint is the hash of the timestamped data
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+// timeStampReq is an RFC 3161 TimeStampReq
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	Nonce          *big.Int `asn1:"optional"`
+	CertReq        bool     `asn1:"optional"`
+}
+
+// pkiStatusInfo is the status part of a TimeStampResp
+type pkiStatusInfo struct {
+	Status       int
+	StatusString asn1.RawValue `asn1:"optional"`
+}
+
+// timeStampResp is an RFC 3161 TimeStampResp
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+// contentInfo is a CMS ContentInfo
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue // [0] EXPLICIT, unwrapped manually
+}
+
+// signedData is a CMS SignedData
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+// signerInfo is a CMS SignerInfo
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+// issuerAndSerial identifies a signer certificate by issuer and serial number
+type issuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+// attribute is a CMS attribute
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+// encapsulatedContentInfo carries the DER-encoded TSTInfo
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     asn1.RawValue `asn1:"optional"` // [0] EXPLICIT OCTET STRING
+}
+
+// accuracy is the accuracy of the time in a TSTInfo
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+// tstInfo is the leading part of an RFC 3161 TSTInfo, up to the nonce
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+	Accuracy       accuracy  `asn1:"optional"`
+	Ordering       bool      `asn1:"optional,default:false"`
+	Nonce          *big.Int  `asn1:"optional"`
+}
+
+// Token is a granted timestamp
+type Token struct {
+	// Response is the DER-encoded TimeStampResp as returned by the TSA
+	Response     []byte
+	GenTime      time.Time
+	SerialNumber *big.Int
+	Policy       asn1.ObjectIdentifier
+}
+
+// Client requests RFC 3161 timestamps from a time-stamping authority
+type Client struct {
+	url        string
+	httpClient *http.Client
+	logger     *logger.Logger
+}
+
+// NewClient creates a new timestamp client for the given TSA URL
+func NewClient(url string, logger *logger.Logger) *Client {
+	return &Client{
+		url:        url,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		logger:     logger,
+	}
+}
+
+// Timestamp obtains a timestamp token over the SHA-256 hash of data. The response is
+// checked to be granted, to cover the requested hash and nonce and to be signed by
+// the certificate it contains; whether to trust that certificate is left to the
+// consumer (e.g. `openssl ts -verify -CAfile`).
+func (c *Client) Timestamp(ctx context.Context, data []byte) (*Token, error) {
+	digest := sha256.Sum256(data)
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	reqDER, err := asn1.Marshal(timeStampReq{
+		Version: 1,
+		MessageImprint: messageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+			HashedMessage: digest[:],
+		},
+		Nonce:   nonce,
+		CertReq: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode timestamp request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(reqDER))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/timestamp-query")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("timestamp request to %s failed: %w", c.url, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read timestamp response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("TSA %s returned HTTP %d", c.url, resp.StatusCode)
+	}
+
+	token, err := ParseResponse(body, digest[:], nonce)
+	if err != nil {
+		return nil, err
+	}
+	c.logger.Info("Timestamp obtained", "tsa", c.url, "gen_time", token.GenTime, "serial", token.SerialNumber)
+	return token, nil
+}
+
+// ParseResponse parses a DER TimeStampResp and checks that it covers digest and,
+// unless it is nil, nonce, and that its signed attributes are signed by the
+// time-stamping certificate among the certificates of the token
+func ParseResponse(der, digest []byte, nonce *big.Int) (*Token, error) {
+	var resp timeStampResp
+	if _, err := asn1.Unmarshal(der, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse timestamp response: %w", err)
+	}
+	if resp.Status.Status != statusGranted && resp.Status.Status != statusGrantedWithMods {
+		return nil, fmt.Errorf("timestamp request rejected with status %d", resp.Status.Status)
+	}
+	if len(resp.TimeStampToken.FullBytes) == 0 {
+		return nil, fmt.Errorf("timestamp response contains no token")
+	}
+
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(resp.TimeStampToken.FullBytes, &ci); err != nil {
+		return nil, fmt.Errorf("failed to parse timestamp token: %w", err)
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("failed to parse timestamp signed data: %w", err)
+	}
+	var eContent []byte
+	if _, err := asn1.Unmarshal(sd.EncapContentInfo.EContent.Bytes, &eContent); err != nil {
+		return nil, fmt.Errorf("failed to parse timestamp content: %w", err)
+	}
+	var info tstInfo
+	if _, err := asn1.Unmarshal(eContent, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse TSTInfo: %w", err)
+	}
+
+	if !info.MessageImprint.HashAlgorithm.Algorithm.Equal(oidSHA256) || !bytes.Equal(info.MessageImprint.HashedMessage, digest) {
+		return nil, fmt.Errorf("timestamp token does not cover the requested data")
+	}
+	if nonce != nil && (info.Nonce == nil || info.Nonce.Cmp(nonce) != 0) {
+		return nil, fmt.Errorf("timestamp token does not carry the nonce of the request")
+	}
+	if err := verifySignedData(&sd, eContent); err != nil {
+		return nil, fmt.Errorf("invalid timestamp token signature: %w", err)
+	}
+
+	return &Token{
+		Response:     der,
+		GenTime:      info.GenTime,
+		SerialNumber: info.SerialNumber,
+		Policy:       info.Policy,
+	}, nil
+}
+
+// verifySignedData checks that the single signer of sd signed attributes that
+// bind eContent, with a certificate included in sd that is valid for time stamping
+func verifySignedData(sd *signedData, eContent []byte) error {
+	if len(sd.SignerInfos) != 1 {
+		return fmt.Errorf("expected one signer, got %d", len(sd.SignerInfos))
+	}
+	si := sd.SignerInfos[0]
+
+	hash, ok := hashes[si.DigestAlgorithm.Algorithm.String()]
+	if !ok {
+		return fmt.Errorf("unsupported digest algorithm %s", si.DigestAlgorithm.Algorithm)
+	}
+	if len(si.SignedAttrs.FullBytes) == 0 {
+		return errors.New("signer has no signed attributes")
+	}
+	if err := checkSignedAttrs(si.SignedAttrs.Bytes, sd.EncapContentInfo.EContentType, hash, eContent); err != nil {
+		return err
+	}
+
+	cert, err := signerCertificate(sd.Certificates.Bytes, si.SID)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(cert.ExtKeyUsage, x509.ExtKeyUsageTimeStamping) {
+		return fmt.Errorf("signer certificate %q is not valid for time stamping", cert.Subject)
+	}
+
+	// The signature covers the DER SET OF the attributes, not their [0] IMPLICIT form
+	signed := bytes.Clone(si.SignedAttrs.FullBytes)
+	signed[0] = 0x31
+	return verifySignature(cert.PublicKey, hash, si.SignatureAlgorithm.Algorithm, signed, si.Signature)
+}
+
+// checkSignedAttrs checks that the content type and message digest attributes
+// match the encapsulated content
+func checkSignedAttrs(der []byte, contentType asn1.ObjectIdentifier, hash crypto.Hash, eContent []byte) error {
+	h := hash.New()
+	h.Write(eContent)
+	want := h.Sum(nil)
+
+	var typeOK, digestOK bool
+	for rest := der; len(rest) > 0; {
+		var attr attribute
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &attr); err != nil {
+			return fmt.Errorf("failed to parse signed attribute: %w", err)
+		}
+		switch {
+		case attr.Type.Equal(oidContentType):
+			var value asn1.ObjectIdentifier
+			if _, err := asn1.Unmarshal(attr.Values.Bytes, &value); err != nil || !value.Equal(contentType) {
+				return errors.New("content type attribute does not match the token content")
+			}
+			typeOK = true
+		case attr.Type.Equal(oidMessageDigest):
+			var value []byte
+			if _, err := asn1.Unmarshal(attr.Values.Bytes, &value); err != nil || !bytes.Equal(value, want) {
+				return errors.New("message digest attribute does not match the token content")
+			}
+			digestOK = true
+		}
+	}
+	if !typeOK || !digestOK {
+		return errors.New("signed attributes lack the content type or message digest")
+	}
+	return nil
+}
+
+// signerCertificate returns the certificate among certs that sid identifies,
+// by issuer and serial number or by subject key identifier
+func signerCertificate(certs []byte, sid asn1.RawValue) (*x509.Certificate, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("token contains no certificates")
+	}
+	parsed, err := x509.ParseCertificates(certs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse token certificates: %w", err)
+	}
+
+	var ias issuerAndSerial
+	bySerial := sid.Class == asn1.ClassUniversal && sid.Tag == asn1.TagSequence
+	if bySerial {
+		if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err != nil {
+			return nil, fmt.Errorf("failed to parse signer identifier: %w", err)
+		}
+	}
+	for _, cert := range parsed {
+		if bySerial && bytes.Equal(cert.RawIssuer, ias.Issuer.FullBytes) && cert.SerialNumber.Cmp(ias.SerialNumber) == 0 {
+			return cert, nil
+		}
+		if !bySerial && sid.Class == asn1.ClassContextSpecific && sid.Tag == 0 && bytes.Equal(cert.SubjectKeyId, sid.Bytes) {
+			return cert, nil
+		}
+	}
+	return nil, errors.New("token does not contain the signer certificate")
+}
+
+// verifySignature checks sig over signed with pub
+func verifySignature(pub crypto.PublicKey, hash crypto.Hash, algorithm asn1.ObjectIdentifier, signed, sig []byte) error {
+	h := hash.New()
+	h.Write(signed)
+	sum := h.Sum(nil)
+
+	var ok bool
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		var err error
+		if algorithm.Equal(oidRSAPSS) {
+			err = rsa.VerifyPSS(pub, hash, sum, sig, nil)
+		} else {
+			err = rsa.VerifyPKCS1v15(pub, hash, sum, sig)
+		}
+		ok = err == nil
+	case *ecdsa.PublicKey:
+		ok = ecdsa.VerifyASN1(pub, sum, sig)
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(pub, signed, sig)
+	default:
+		return fmt.Errorf("unsupported signer key type %T", pub)
+	}
+	if !ok {
+		return errors.New("signature does not verify with the signer certificate")
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package timestamp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// testTSA signs timestamp tokens with a self-signed time-stamping certificate
+type testTSA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestTSA(t *testing.T) *testTSA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(7),
+		Subject:      pkix.Name{CommonName: "Test TSA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testTSA{cert: cert, key: key}
+}
+
+// respond assembles a TimeStampResp covering digest and nonce, signed by the TSA
+func (s *testTSA) respond(t *testing.T, status int, digest []byte, nonce *big.Int) []byte {
+	t.Helper()
+
+	info, err := asn1.Marshal(tstInfo{
+		Version: 1,
+		Policy:  asn1.ObjectIdentifier{1, 2, 3},
+		MessageImprint: messageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+			HashedMessage: digest,
+		},
+		SerialNumber: big.NewInt(42),
+		GenTime:      time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Accuracy:     accuracy{Seconds: 1},
+		Nonce:        nonce,
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal TSTInfo: %v", err)
+	}
+	eContent, _ := asn1.Marshal(info)
+	contentType := asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+
+	// Sign the content type and message digest attributes
+	infoDigest := sha256.Sum256(info)
+	attrs, err := asn1.MarshalWithParams([]attribute{
+		{Type: oidContentType, Values: set(t, contentType)},
+		{Type: oidMessageDigest, Values: set(t, infoDigest[:])},
+	}, "set")
+	if err != nil {
+		t.Fatalf("Failed to marshal signed attributes: %v", err)
+	}
+	attrsDigest := sha256.Sum256(attrs)
+	signature, err := ecdsa.SignASN1(rand.Reader, s.key, attrsDigest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedAttrs := append([]byte{0xa0}, attrs[1:]...)
+
+	issuer, _ := asn1.Marshal(issuerAndSerial{Issuer: asn1.RawValue{FullBytes: s.cert.RawIssuer}, SerialNumber: s.cert.SerialNumber})
+	certs, _ := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: s.cert.Raw})
+	sd, err := asn1.Marshal(signedData{
+		Version:          3,
+		DigestAlgorithms: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true},
+		EncapContentInfo: encapsulatedContentInfo{
+			EContentType: contentType,
+			EContent:     asn1.RawValue{FullBytes: explicit(t, eContent)},
+		},
+		Certificates: asn1.RawValue{FullBytes: certs},
+		SignerInfos: []signerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: issuer},
+			DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+			SignedAttrs:        asn1.RawValue{FullBytes: signedAttrs},
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+			Signature:          signature,
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal SignedData: %v", err)
+	}
+	token, err := asn1.Marshal(contentInfo{
+		ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2},
+		Content:     asn1.RawValue{FullBytes: explicit(t, sd)},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal ContentInfo: %v", err)
+	}
+
+	resp, err := asn1.Marshal(timeStampResp{
+		Status:         pkiStatusInfo{Status: status},
+		TimeStampToken: asn1.RawValue{FullBytes: token},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal response: %v", err)
+	}
+	return resp
+}
+
+// set wraps the DER encoding of value in a SET, as attribute values are
+func set(t *testing.T, value any) asn1.RawValue {
+	t.Helper()
+	der, err := asn1.Marshal(value)
+	if err != nil {
+		t.Fatalf("Failed to marshal attribute value: %v", err)
+	}
+	wrapped, _ := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: der})
+	return asn1.RawValue{FullBytes: wrapped}
+}
+
+// explicit wraps DER in a context-specific [0] tag
+func explicit(t *testing.T, der []byte) []byte {
+	t.Helper()
+	wrapped, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der})
+	if err != nil {
+		t.Fatalf("Failed to wrap: %v", err)
+	}
+	return wrapped
+}
+
+func TestParseResponse(t *testing.T) {
+	digest := sha256.Sum256([]byte("certificate"))
+	nonce := big.NewInt(1234)
+	token, err := ParseResponse(newTestTSA(t).respond(t, statusGranted, digest[:], nonce), digest[:], nonce)
+	if err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if token.SerialNumber.Int64() != 42 {
+		t.Errorf("Expected serial 42, got %v", token.SerialNumber)
+	}
+	if !token.GenTime.Equal(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("Unexpected gen time %v", token.GenTime)
+	}
+}
+
+func TestParseResponseMismatch(t *testing.T) {
+	digest := sha256.Sum256([]byte("certificate"))
+	other := sha256.Sum256([]byte("other"))
+	if _, err := ParseResponse(newTestTSA(t).respond(t, statusGranted, other[:], nil), digest[:], nil); err == nil {
+		t.Error("Expected error for mismatched imprint, got nil")
+	}
+}
+
+func TestParseResponseRejected(t *testing.T) {
+	digest := sha256.Sum256([]byte("certificate"))
+	if _, err := ParseResponse(newTestTSA(t).respond(t, 2, digest[:], nil), digest[:], nil); err == nil {
+		t.Error("Expected error for rejected status, got nil")
+	}
+}
+
+func TestParseResponseNonce(t *testing.T) {
+	digest := sha256.Sum256([]byte("certificate"))
+	tsa := newTestTSA(t)
+	if _, err := ParseResponse(tsa.respond(t, statusGranted, digest[:], big.NewInt(1)), digest[:], big.NewInt(2)); err == nil {
+		t.Error("Expected error for a replayed response with another nonce, got nil")
+	}
+	if _, err := ParseResponse(tsa.respond(t, statusGranted, digest[:], nil), digest[:], big.NewInt(2)); err == nil {
+		t.Error("Expected error for a response without the nonce, got nil")
+	}
+}
+
+func TestParseResponseSignature(t *testing.T) {
+	digest := sha256.Sum256([]byte("certificate"))
+	tsa := newTestTSA(t)
+	// Sign with a key other than the one of the included certificate
+	tsa.key = newTestTSA(t).key
+	if _, err := ParseResponse(tsa.respond(t, statusGranted, digest[:], nil), digest[:], nil); err == nil {
+		t.Error("Expected error for a token not signed by its certificate, got nil")
+	}
+}
@@ -0,0 +1,55 @@
+package webroot
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"ipssl-client/internal/fsys"
+)
+
+// Write writes content to the file served at urlPath below each document root
+// in parallel and returns the paths written. Any root may be the one answering
+// validation requests, so a failure in one removes the files written to the
+// others and fails the whole write.
+func Write(files fsys.FS, dirs []string, urlPath string, content []byte) ([]string, error) {
+	paths := make([]string, len(dirs))
+	errs := make([]error, len(dirs))
+
+	var wg sync.WaitGroup
+	for i, dir := range dirs {
+		paths[i] = filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(urlPath, "/")))
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = writeFile(files, paths[i], content)
+		}(i)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		Remove(files, paths)
+		return nil, err
+	}
+	return paths, nil
+}
+
+// Remove removes files created by Write, ignoring files that no longer exist
+func Remove(files fsys.FS, paths []string) {
+	for _, path := range paths {
+		files.Remove(path)
+	}
+}
+
+// writeFile writes content to path, creating its directory
+func writeFile(files fsys.FS, path string, content []byte) error {
+	if err := files.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create validation directory %s: %w", filepath.Dir(path), err)
+	}
+	if err := files.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("failed to write validation file %s: %w", path, err)
+	}
+	return nil
+}
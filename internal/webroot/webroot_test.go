@@ -0,0 +1,49 @@
+package webroot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ipssl-client/internal/fsys"
+)
+
+func TestWrite(t *testing.T) {
+	dirs := []string{t.TempDir(), t.TempDir()}
+
+	paths, err := Write(fsys.OS, dirs, "/.well-known/pki-validation/ABC.txt", []byte("token"))
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	for i, dir := range dirs {
+		want := filepath.Join(dir, ".well-known", "pki-validation", "ABC.txt")
+		if paths[i] != want {
+			t.Errorf("Expected path %s, got %s", want, paths[i])
+		}
+		if content, err := os.ReadFile(want); err != nil || string(content) != "token" {
+			t.Errorf("Expected token in %s, got %q (%v)", want, content, err)
+		}
+	}
+
+	Remove(fsys.OS, paths)
+	for _, path := range paths {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", path)
+		}
+	}
+}
+
+func TestWriteFailureRemovesOthers(t *testing.T) {
+	good := t.TempDir()
+	bad := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(bad, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Write(fsys.OS, []string{good, bad}, "/.well-known/pki-validation/ABC.txt", []byte("token")); err == nil {
+		t.Fatal("Expected an error when a document root is not a directory")
+	}
+	if _, err := os.Stat(filepath.Join(good, ".well-known", "pki-validation", "ABC.txt")); !os.IsNotExist(err) {
+		t.Error("Expected the file in the good document root to be removed")
+	}
+}
@@ -0,0 +1,112 @@
+package zerossl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/caddyserver/zerossl"
+)
+
+// Certificate statuses that are cancelled before deletion or deleted outright
+var (
+	unfinishedStatuses = []string{"draft", "pending_validation"}
+	finishedStatuses   = []string{"cancelled", "expired"}
+)
+
+// SetCleanupStale enables removing stale certificate objects for the managed
+// identifiers from the account after each successful issuance
+func (c *Client) SetCleanupStale(enabled bool) {
+	c.cleanupStale = enabled
+}
+
+// CleanupStaleCertificates cancels abandoned drafts and deletes cancelled and
+// expired certificate objects whose CommonName is the primary identifier, so
+// that they do not count against the account quota. The certificate keepID is
+// never touched. Failures are logged and the cleanup continues.
+func (c *Client) CleanupStaleCertificates(ctx context.Context, identifiers []string, keepID string) (int, error) {
+	if len(identifiers) == 0 {
+		return 0, nil
+	}
+	params := zerossl.ListAllCertificates()
+	params.Search = identifiers[0]
+	certificates, err := c.listCertificates(ctx, params)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, cert := range certificates {
+		if cert.ID == keepID || cert.CommonName != identifiers[0] {
+			continue
+		}
+
+		switch {
+		case slices.Contains(unfinishedStatuses, cert.Status):
+			if err := apiError("cancel certificate", c.client.CancelCertificate(ctx, cert.ID)); err != nil {
+				c.logger.Warn("Failed to cancel stale certificate", "cert_id", cert.ID, "status", cert.Status, "error", err)
+				continue
+			}
+		case slices.Contains(finishedStatuses, cert.Status):
+		default:
+			continue
+		}
+
+		if err := c.deleteCertificate(ctx, cert.ID); err != nil {
+			c.logger.Warn("Failed to delete stale certificate", "cert_id", cert.ID, "status", cert.Status, "error", err)
+			continue
+		}
+		c.removeKey(cert.ID)
+		c.logger.Info("Removed stale certificate", "cert_id", cert.ID, "status", cert.Status)
+		removed++
+	}
+	return removed, nil
+}
+
+// deleteCertificate removes a certificate object from the account. The
+// upstream library has no binding for this endpoint.
+func (c *Client) deleteCertificate(ctx context.Context, certID string) error {
+	baseURL := c.client.BaseURL
+	if baseURL == "" {
+		baseURL = zerossl.BaseURL
+	}
+	u := fmt.Sprintf("%s/certificates/%s?%s", strings.TrimSuffix(baseURL, "/"), url.PathEscape(certID), url.Values{"access_key": {c.apiKey}}.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u, nil)
+	if err != nil {
+		return err
+	}
+	httpClient := c.client.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// The error includes the URL; do not leak the access key into logs
+		return fmt.Errorf("DELETE /certificates/%s: %w", certID, unwrapURLError(err))
+	}
+	defer resp.Body.Close()
+
+	var apiErr zerossl.APIError
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if json.Unmarshal(body, &apiErr) == nil && (apiErr.ErrorInfo.Code != 0 || apiErr.ErrorInfo.Type != "") {
+		return newAPIError("delete certificate", resp.StatusCode, apiErr, fmt.Errorf("DELETE /certificates/%s: %w", certID, apiErr))
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("DELETE /certificates/%s: HTTP %d", certID, resp.StatusCode)
+	}
+	return nil
+}
+
+// unwrapURLError strips the request URL from transport errors
+func unwrapURLError(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		return urlErr.Err
+	}
+	return err
+}
@@ -0,0 +1,57 @@
+package zerossl
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"ipssl-client/internal/logger"
+)
+
+func TestCleanupStaleCertificates(t *testing.T) {
+	var cancelled, deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/certificates":
+			json.NewEncoder(w).Encode(map[string]any{"results": []map[string]string{
+				{"id": "current", "common_name": "192.0.2.1", "status": "issued"},
+				{"id": "draft", "common_name": "192.0.2.1", "status": "draft"},
+				{"id": "expired", "common_name": "192.0.2.1", "status": "expired"},
+				{"id": "issued", "common_name": "192.0.2.1", "status": "issued"},
+				{"id": "other", "common_name": "192.0.2.2", "status": "cancelled"},
+			}})
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/cancel"):
+			cancelled = append(cancelled, strings.Split(r.URL.Path, "/")[2])
+			w.Write([]byte(`{"success": 1}`))
+		case r.Method == http.MethodDelete:
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/certificates/"))
+			w.Write([]byte(`{"success": true}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient("test-key", server.URL, server.Client(), logger.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := client.CleanupStaleCertificates(context.Background(), []string{"192.0.2.1"}, "current")
+	if err != nil {
+		t.Fatalf("CleanupStaleCertificates failed: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("Expected 2 removed certificates, got %d", removed)
+	}
+	if !slices.Equal(cancelled, []string{"draft"}) {
+		t.Errorf("Expected only the draft to be cancelled, got %v", cancelled)
+	}
+	if !slices.Equal(deleted, []string{"draft", "expired"}) {
+		t.Errorf("Expected draft and expired certificates to be deleted, got %v", deleted)
+	}
+}
@@ -3,75 +3,189 @@ package zerossl
 import (
 	"context"
 	"crypto/rand"
-	"crypto/rsa"
+	"crypto/sha1"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"net"
-	"os"
-	"path/filepath"
+	"net/http"
+	"path"
+	"strings"
 	"time"
 
+	"ipssl-client/internal/chain"
+	"ipssl-client/internal/fsys"
+	"ipssl-client/internal/keys"
 	"ipssl-client/internal/logger"
+	"ipssl-client/internal/webroot"
 
 	"github.com/caddyserver/zerossl"
 )
 
+// Pagination of certificate listings; 100 is the largest page the API returns
+const (
+	listPageSize = 100
+	listMaxPages = 100
+)
+
+// defaultValidationDir is the webroot validation files are written to unless configured
+const defaultValidationDir = "/usr/share/caddy/"
+
+// defaultPollInterval is the interval between status checks while waiting for issuance
+const defaultPollInterval = 10 * time.Second
+
 // Client represents a ZeroSSL API client
 type Client struct {
-	apiKey      string
-	logger      *logger.Logger
-	client      *zerossl.Client
-	privateKeys map[string]*rsa.PrivateKey
+	apiKey string
+	logger *logger.Logger
+	client *zerossl.Client
+	files  fsys.FS
+
+	cleanupStale bool
+	mustStaple   bool
+	quota        int
+	retryPolicy  RetryPolicy
+	verifyQueue  VerifyQueue
+
+	keyDir          string
+	validationDirs  []string
+	validationEOL   string
+	canonicalOrder  bool
+	pollInterval    time.Duration
+	issuanceTimeout time.Duration
+	selfCheck       bool
+
+	// issuedSerial and issuedID identify the certificate issued last
+	issuedSerial string
+	issuedID     string
 }
 
-// NewClient creates a new ZeroSSL client
-func NewClient(apiKey string, logger *logger.Logger) (*Client, error) {
+// NewClient creates a new ZeroSSL client. baseURL overrides the API endpoint,
+// e.g. for a sandbox or mock server, and may be empty to use api.zerossl.com.
+// httpClient may be nil to use the default client.
+func NewClient(apiKey, baseURL string, httpClient *http.Client, logger *logger.Logger) (*Client, error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("API key is required")
 	}
 
 	client := zerossl.Client{
-		AccessKey: apiKey,
+		AccessKey:  apiKey,
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: httpClient,
 	}
 
 	return &Client{
-		apiKey:      apiKey,
-		logger:      logger,
-		client:      &client,
-		privateKeys: make(map[string]*rsa.PrivateKey),
+		apiKey: apiKey,
+		logger: logger,
+		client: &client,
+		files:  fsys.OS,
+
+		keyDir:         defaultKeyDir,
+		validationDirs: []string{defaultValidationDir},
+		validationEOL:  "\n",
 	}, nil
 }
 
-// RequestCertificate requests a new certificate for the given IP address
-func (c *Client) RequestCertificate(ctx context.Context, ip string) ([]byte, []byte, error) {
-	c.logger.Info("Requesting certificate from ZeroSSL", "ip", ip)
+// SetValidationDirs sets the webroots validation files are written to. Several
+// directories serve web nodes sharing the certificate's address.
+func (c *Client) SetValidationDirs(dirs []string) {
+	if len(dirs) > 0 {
+		c.validationDirs = dirs
+	}
+}
+
+// SetFS replaces the filesystem certificates, keys and validation files are
+// read from and written to
+func (c *Client) SetFS(files fsys.FS) {
+	c.files = files
+}
+
+// SetMustStaple requests the OCSP Must-Staple extension in certificates
+func (c *Client) SetMustStaple(enabled bool) {
+	c.mustStaple = enabled
+}
+
+// SetIssuancePolling sets how often the certificate status is polled while
+// waiting for issuance and how long to wait in total. A zero interval keeps the
+// default; a zero timeout waits until the context is cancelled.
+func (c *Client) SetIssuancePolling(interval, timeout time.Duration) {
+	c.pollInterval = interval
+	c.issuanceTimeout = timeout
+}
+
+// GenerateEABCredentials creates External Account Binding credentials for using
+// the account behind the API key through the ZeroSSL ACME endpoint
+func (c *Client) GenerateEABCredentials(ctx context.Context) (kid, hmacKey string, err error) {
+	c.logger.Info("Generating ZeroSSL EAB credentials")
+	return c.client.GenerateEABCredentials(ctx)
+}
+
+// SupportedKeyTypes lists the key types ZeroSSL accepts in CSRs
+func (c *Client) SupportedKeyTypes() []string {
+	return []string{keys.RSA2048, keys.RSA4096, keys.ECDSAP256, keys.ECDSAP384}
+}
+
+// RequestCertificate requests a single certificate covering all given identifiers
+// (IP addresses and hostnames). The first identifier becomes the CommonName.
+func (c *Client) RequestCertificate(ctx context.Context, identifiers []string, keyType string) ([]byte, []byte, error) {
+	if len(identifiers) == 0 {
+		return nil, nil, fmt.Errorf("at least one identifier is required")
+	}
+	c.logger.Info("Requesting certificate from ZeroSSL", "identifiers", identifiers)
 	c.logger.Info("=== ENTERING RequestCertificate METHOD ===")
 
+	cert, key, err := c.requestCertificate(ctx, identifiers, keyType, true)
+	if errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrKeyMismatch) {
+		// Never hand out a certificate with a key that does not belong to it
+		c.logger.Error("No matching private key for the issued certificate, reissuing with a fresh key pair", "error", err)
+		return c.requestCertificate(ctx, identifiers, keyType, false)
+	}
+	return cert, key, err
+}
+
+// requestCertificate runs one issuance. An existing certificate request for the
+// identifiers is completed if reuse is set; otherwise a new one is created.
+func (c *Client) requestCertificate(ctx context.Context, identifiers []string, keyType string, reuse bool) ([]byte, []byte, error) {
 	// First, check if there's already an existing certificate request
-	c.logger.Info("Checking for existing certificate", "ip", ip)
-	existingCertID, err := c.findExistingCertificate(ctx, ip)
-	if err != nil {
-		c.logger.Warn("Failed to check for existing certificate", "error", err)
-	} else if existingCertID != "" {
-		c.logger.Info("Found existing certificate", "cert_id", existingCertID)
-	} else {
-		c.logger.Info("No existing certificate found, will create new one")
+	var existingCertID string
+	if reuse {
+		c.logger.Info("Checking for existing certificate", "identifiers", identifiers)
+		var err error
+		existingCertID, err = c.findExistingCertificate(ctx, identifiers)
+		if err != nil {
+			c.logger.Warn("Failed to check for existing certificate", "error", err)
+		} else if existingCertID != "" {
+			c.logger.Info("Found existing certificate", "cert_id", existingCertID)
+		} else {
+			c.logger.Info("No existing certificate found, will create new one")
+		}
+	}
+
+	// A request whose key was lost can never be completed
+	if existingCertID != "" && !c.hasKey(existingCertID, identifiers[0]) {
+		c.logger.Warn("No private key stored for existing certificate request, creating a new one", "cert_id", existingCertID, "key_dir", c.keyDir)
+		existingCertID = ""
 	}
 
 	var certObj *zerossl.CertificateObject
 	if existingCertID != "" {
 		c.logger.Info("Found existing certificate request", "cert_id", existingCertID)
 		// Get existing certificate details
-		certDetails, err := c.client.GetCertificate(ctx, existingCertID)
+		certDetails, err := c.getCertificate(ctx, existingCertID)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to get existing certificate details: %w", err)
 		}
 		certObj = &certDetails
 	} else {
+		// A new certificate counts against the account quota
+		if err := c.checkQuota(ctx); err != nil {
+			return nil, nil, err
+		}
+
 		// Create new certificate request
-		newCertObj, err := c.createIPCertificate(ctx, ip)
+		newCertObj, err := c.createIPCertificate(ctx, identifiers, keyType)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to create IP certificate: %w", err)
 		}
@@ -79,54 +193,72 @@ func (c *Client) RequestCertificate(ctx context.Context, ip string) ([]byte, []b
 		c.logger.Info("Certificate request created", "cert_id", certObj.ID)
 	}
 
-	// First, we need to validate the certificate
-	validationDir := os.Getenv("IPSSL_VALIDATION_DIR")
-	if validationDir == "" {
-		validationDir = "/usr/share/caddy/"
+	// Concurrent validations on the account are limited by the CA
+	release, err := c.acquireValidationSlot(ctx, certObj.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to wait for a validation slot: %w", err)
 	}
 
-	err = c.ValidateCertificate(ctx, certObj.ID, validationDir)
+	// First, we need to validate the certificate
+	err = c.ValidateCertificate(ctx, certObj.ID, c.validationDirs)
 	if err != nil {
+		release()
 		return nil, nil, fmt.Errorf("failed to validate certificate: %w", err)
 	}
 
 	// Wait for certificate to be issued
 	certDetails, err := c.waitForCertificateIssuance(ctx, certObj.ID)
+	release()
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to wait for certificate issuance: %w", err)
 	}
 	// Download certificate with cross-signed certificates (intermediate certificates)
-	certBundle, err := c.client.DownloadCertificate(ctx, certDetails.ID, true)
+	certBundle, err := c.downloadCertificate(ctx, certDetails.ID)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to download certificate: %w", err)
 	}
 
-	// For auto-generated certificates, we need to get the private key from ZeroSSL
-	// This might require a different API call or the private key might be included in the certificate bundle
-	keyPEM, err := c.getPrivateKey(ctx, certDetails.ID)
+	// Combine the main certificate with the intermediate certificate chain
+	fullCertChain, err := chain.Assemble([]byte(certBundle.CertificateCrt), []byte(certBundle.CABundleCrt))
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get private key: %w", err)
+		return nil, nil, fmt.Errorf("failed to assemble certificate chain: %w", err)
 	}
 
-	// Combine the main certificate with the intermediate certificate chain
-	var fullCertChain string
-	if certBundle.CertificateCrt != "" {
-		fullCertChain = certBundle.CertificateCrt
+	// The key never leaves this host, so it has to be the one saved with the CSR
+	keyPEM, err := c.loadKey(certDetails.ID, identifiers[0], fullCertChain)
+	if err != nil {
+		c.removeKey(certDetails.ID)
+		return nil, nil, err
 	}
-	if certBundle.CABundleCrt != "" {
-		if fullCertChain != "" {
-			fullCertChain += "\n"
+
+	c.logger.Info("Certificate downloaded successfully", "cert_id", certDetails.ID, "has_intermediate", certBundle.CABundleCrt != "")
+	if block, _ := pem.Decode(fullCertChain); block != nil {
+		if leaf, err := x509.ParseCertificate(block.Bytes); err == nil {
+			c.issuedSerial, c.issuedID = fmt.Sprintf("%x", leaf.SerialNumber), certDetails.ID
 		}
-		fullCertChain += certBundle.CABundleCrt
 	}
 
-	c.logger.Info("Certificate downloaded successfully", "cert_id", certDetails.ID, "has_intermediate", certBundle.CABundleCrt != "")
-	return []byte(fullCertChain), keyPEM, nil
+	if c.cleanupStale {
+		if removed, err := c.CleanupStaleCertificates(ctx, identifiers, certDetails.ID); err != nil {
+			c.logger.Warn("Failed to clean up stale certificates", "error", err)
+		} else if removed > 0 {
+			c.logger.Info("Cleaned up stale certificates", "removed", removed)
+		}
+	}
+	return fullCertChain, keyPEM, nil
+}
+
+// CertificateID returns the ZeroSSL ID of cert if this client issued it last
+func (c *Client) CertificateID(cert *x509.Certificate) string {
+	if fmt.Sprintf("%x", cert.SerialNumber) != c.issuedSerial {
+		return ""
+	}
+	return c.issuedID
 }
 
 // IsCertificateValid checks if a certificate is valid and not expired
 func (c *Client) IsCertificateValid(certPath string, validityDuration time.Duration) (bool, error) {
-	certPEM, err := os.ReadFile(certPath)
+	certPEM, err := c.files.ReadFile(certPath)
 	if err != nil {
 		return false, fmt.Errorf("failed to read certificate file: %w", err)
 	}
@@ -155,32 +287,80 @@ func (c *Client) IsCertificateValid(certPath string, validityDuration time.Durat
 	return true, nil
 }
 
-// createIPCertificate creates a certificate request for IP address using ZeroSSL library
-func (c *Client) createIPCertificate(ctx context.Context, ip string) (*zerossl.CertificateObject, error) {
-	c.logger.Info("Creating IP certificate using ZeroSSL library", "ip", ip)
+// RevokeCertificate revokes an issued certificate, locating it on the account by its SHA-1 fingerprint
+func (c *Client) RevokeCertificate(ctx context.Context, certPEM []byte) error {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return fmt.Errorf("failed to decode PEM block")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	fingerprint := fmt.Sprintf("%X", sha1.Sum(cert.Raw))
+
+	search := cert.Subject.CommonName
+	if search == "" && len(cert.IPAddresses) > 0 {
+		search = cert.IPAddresses[0].String()
+	}
+	certificates, err := c.listCertificates(ctx, zerossl.ListCertificatesParameters{
+		Status: "issued",
+		Search: search,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, candidate := range certificates {
+		if candidate.FingerprintSHA1 == nil || !strings.EqualFold(strings.ReplaceAll(*candidate.FingerprintSHA1, ":", ""), fingerprint) {
+			continue
+		}
+		if err := apiError("revoke certificate", c.client.RevokeCertificate(ctx, candidate.ID, zerossl.UnspecifiedReason)); err != nil {
+			return fmt.Errorf("failed to revoke certificate %s: %w", candidate.ID, err)
+		}
+		c.logger.Info("Certificate revoked", "cert_id", candidate.ID)
+		return nil
+	}
+
+	return fmt.Errorf("certificate with fingerprint %s not found on the ZeroSSL account", fingerprint)
+}
+
+// createIPCertificate creates a certificate request for the given identifiers using ZeroSSL library
+func (c *Client) createIPCertificate(ctx context.Context, identifiers []string, keyType string) (*zerossl.CertificateObject, error) {
+	c.logger.Info("Creating IP certificate using ZeroSSL library", "identifiers", identifiers, "key_type", keyType)
+	ip := identifiers[0]
 
 	// Generate private key
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	privateKey, err := keys.Generate(keyType)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate private key: %w", err)
 	}
-
-	// Parse IP address
-	ipAddr := net.ParseIP(ip)
-	if ipAddr == nil {
-		return nil, fmt.Errorf("invalid IP address: %s", ip)
+	keyPEM, err := keys.EncodePEM(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %w", err)
 	}
 
 	// Create CSR with minimal fields to avoid duplication
-	// Use IP address as CommonName
+	// Use the primary identifier as CommonName
 	csrTemplate := &x509.CertificateRequest{
 		Subject: pkix.Name{
 			Country:      []string{"US"},
 			Organization: []string{"IPSSL Client"},
-			CommonName:   ip, // Use IP address as CommonName
+			CommonName:   ip, // Use primary identifier as CommonName
 		},
-		// Don't include IPAddresses to avoid duplication
-		// ZeroSSL will handle IP validation separately
+	}
+	if c.mustStaple {
+		csrTemplate.ExtraExtensions = []pkix.Extension{keys.MustStaple()}
+	}
+
+	// The CommonName is not repeated in the SANs to avoid duplication, since
+	// ZeroSSL derives the identifier list from CommonName plus SANs
+	for _, identifier := range identifiers[1:] {
+		if ipAddr := net.ParseIP(identifier); ipAddr != nil {
+			csrTemplate.IPAddresses = append(csrTemplate.IPAddresses, ipAddr)
+		} else {
+			csrTemplate.DNSNames = append(csrTemplate.DNSNames, identifier)
+		}
 	}
 
 	// Create CSR
@@ -196,120 +376,144 @@ func (c *Client) createIPCertificate(ctx context.Context, ip string) (*zerossl.C
 	}
 
 	// Verify CSR was created successfully
-	c.logger.Info("CSR created successfully", "ip", ip, "common_name", csr.Subject.CommonName)
+	c.logger.Info("CSR created successfully", "ip", ip, "common_name", csr.Subject.CommonName,
+		"ip_sans", len(csr.IPAddresses), "dns_sans", len(csr.DNSNames))
 
-	// Store the private key for later retrieval
-	c.privateKeys[ip] = privateKey
+	// Persist the private key before the request exists, so that a restart
+	// while waiting for issuance does not lose it
+	if err := c.saveKey(ip, keyPEM); err != nil {
+		return nil, err
+	}
 
 	// Create certificate request with ZeroSSL library
 	// The library should handle the API call properly
-	certObj, err := c.client.CreateCertificate(ctx, csr, 90)
+	certObj, err := c.createCertificate(ctx, csr, 90)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create certificate request: %w", err)
 	}
 
+	if err := c.saveKey(certObj.ID, keyPEM); err != nil {
+		c.logger.Warn("Failed to store private key under the certificate ID", "cert_id", certObj.ID, "error", err)
+	} else {
+		c.files.Remove(c.keyPath(ip))
+	}
 	return &certObj, nil
 }
 
-// findExistingCertificate looks for an existing certificate request for the given IP
-func (c *Client) findExistingCertificate(ctx context.Context, ip string) (string, error) {
+// findExistingCertificate looks for an existing certificate request covering exactly the given identifiers
+func (c *Client) findExistingCertificate(ctx context.Context, identifiers []string) (string, error) {
+	ip := identifiers[0]
+
 	// List all certificates to find one for this IP
 	params := zerossl.ListAllCertificates()
-	certificateList, err := c.client.ListCertificates(ctx, params)
+	params.Search = ip
+	certificates, err := c.listCertificates(ctx, params)
 	if err != nil {
-		return "", fmt.Errorf("failed to list certificates: %w", err)
+		return "", err
 	}
 
-	// Look for a certificate with matching CommonName (IP address)
-	for _, cert := range certificateList.Results {
-		if cert.CommonName == ip {
+	// Look for a certificate with matching CommonName (IP address) and additional identifiers
+	var found string
+	for _, cert := range certificates {
+		if cert.CommonName == ip && sameIdentifiers(cert.AdditionalDomains, ip, identifiers[1:]) {
 			c.logger.Info("Found existing certificate", "cert_id", cert.ID, "status", cert.Status)
 
 			// Only return valid certificates (issued or pending validation)
 			// Skip cancelled, expired, or failed certificates
-			if cert.Status == "issued" || cert.Status == "pending_validation" || cert.Status == "draft" {
-				return cert.ID, nil
-			} else {
+			if cert.Status != "issued" && cert.Status != "pending_validation" && cert.Status != "draft" {
 				c.logger.Info("Skipping certificate with invalid status", "cert_id", cert.ID, "status", cert.Status)
+				continue
+			}
+			// Prefer a certificate whose key is still stored over one
+			// abandoned after its key was lost
+			if c.hasKey(cert.ID, ip) {
+				return cert.ID, nil
+			}
+			if found == "" {
+				found = cert.ID
 			}
 		}
 	}
 
-	return "", nil // No existing certificate found
+	return found, nil // Empty if no existing certificate was found
 }
 
-// getPrivateKey retrieves the private key for the given certificate
-func (c *Client) getPrivateKey(ctx context.Context, certID string) ([]byte, error) {
-	// Get certificate details to find the IP address
-	certDetails, err := c.client.GetCertificate(ctx, certID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get certificate details: %w", err)
-	}
-
-	// Find the private key for this IP address
-	// We'll use the CommonName to match the IP
-	ip := certDetails.CommonName
+// listCertificates returns every certificate matching params, following the
+// pagination of the API
+func (c *Client) listCertificates(ctx context.Context, params zerossl.ListCertificatesParameters) ([]zerossl.CertificateObject, error) {
+	params.Limit = listPageSize
+	var certificates []zerossl.CertificateObject
+	for page := 1; page <= listMaxPages; page++ {
+		params.Page = page
+		certificateList, err := c.listCertificatesPage(ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list certificates (page %d): %w", page, err)
+		}
+		certificates = append(certificates, certificateList.Results...)
 
-	// First, try to get from in-memory storage
-	if privateKey, exists := c.privateKeys[ip]; exists {
-		// Convert private key to PEM
-		keyPEM := pem.EncodeToMemory(&pem.Block{
-			Type:  "RSA PRIVATE KEY",
-			Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
-		})
-		return keyPEM, nil
+		if len(certificateList.Results) < listPageSize || len(certificates) >= certificateList.TotalCount {
+			return certificates, nil
+		}
 	}
+	c.logger.Warn("Stopped listing certificates after page limit", "pages", listMaxPages, "certificates", len(certificates))
+	return certificates, nil
+}
 
-	// If not in memory, try to load from file
-	keyPath := filepath.Join("/ipssl", "key.pem")
-	if keyPEM, err := os.ReadFile(keyPath); err == nil {
-		c.logger.Info("Loaded private key from file", "path", keyPath)
-		return keyPEM, nil
+// sameIdentifiers reports whether a comma-separated identifier list (ignoring the
+// CommonName) contains exactly the expected set
+func sameIdentifiers(list, commonName string, expected []string) bool {
+	found := make(map[string]bool)
+	for _, identifier := range strings.Split(list, ",") {
+		if identifier = strings.TrimSpace(identifier); identifier != "" && identifier != commonName {
+			found[identifier] = true
+		}
 	}
-
-	// If still not found, generate a new private key and store it
-	c.logger.Info("Generating new private key for IP", "ip", ip)
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate new private key: %w", err)
+	if len(found) != len(expected) {
+		return false
 	}
-
-	// Store the private key in memory for future use
-	c.privateKeys[ip] = privateKey
-
-	// Convert private key to PEM
-	keyPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
-	})
-
-	// Save the private key to file for persistence
-	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
-		c.logger.Warn("Failed to save private key to file", "error", err)
+	for _, identifier := range expected {
+		if !found[identifier] {
+			return false
+		}
 	}
-
-	c.logger.Info("Generated and stored new private key", "ip", ip)
-	return keyPEM, nil
+	return true
 }
 
 // waitForCertificateIssuance waits for the certificate to be issued
 func (c *Client) waitForCertificateIssuance(ctx context.Context, certID string) (*zerossl.CertificateObject, error) {
-	c.logger.Info("Waiting for certificate issuance", "cert_id", certID)
+	interval := c.pollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	c.logger.Info("Waiting for certificate issuance", "cert_id", certID, "poll_interval", interval, "timeout", c.issuanceTimeout)
 
-	ticker := time.NewTicker(10 * time.Second)
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	var deadline <-chan time.Time
+	if c.issuanceTimeout > 0 {
+		timer := time.NewTimer(c.issuanceTimeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	lastStatus := "unknown"
 	for {
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
+		case <-deadline:
+			// Wrapping DeadlineExceeded classifies the failure as a timeout
+			return nil, fmt.Errorf("certificate %s was not issued within %s (last status: %s); check that the validation file is reachable over HTTP on port 80: %w",
+				certID, c.issuanceTimeout, lastStatus, context.DeadlineExceeded)
 		case <-ticker.C:
-			certDetails, err := c.client.GetCertificate(ctx, certID)
+			certDetails, err := c.getCertificate(ctx, certID)
 			if err != nil {
 				c.logger.Error("Failed to get certificate details", "error", err)
 				continue
 			}
 
+			lastStatus = certDetails.Status
 			c.logger.Info("Certificate status", "status", certDetails.Status, "cert_id", certID)
 
 			switch certDetails.Status {
@@ -328,14 +532,21 @@ func (c *Client) waitForCertificateIssuance(ctx context.Context, certID string)
 	}
 }
 
-// ValidateCertificate performs domain validation for IP addresses
-func (c *Client) ValidateCertificate(ctx context.Context, certID string, validationDir string) error {
+// validationURLPath returns the path below the webroot at which the validation
+// file for url must be served
+func validationURLPath(url string) string {
+	return "/.well-known/pki-validation/" + path.Base(url)
+}
+
+// ValidateCertificate performs domain validation for IP addresses, writing the
+// validation files to every directory in validationDirs
+func (c *Client) ValidateCertificate(ctx context.Context, certID string, validationDirs []string) error {
 	c.logger.Info("Starting certificate validation", "cert_id", certID)
 	c.logger.Info("=== ENTERING ValidateCertificate METHOD ===")
 
 	// Get certificate details to check validation method
 	c.logger.Info("Getting certificate details", "cert_id", certID)
-	certDetails, err := c.client.GetCertificate(ctx, certID)
+	certDetails, err := c.getCertificate(ctx, certID)
 	if err != nil {
 		c.logger.Error("Failed to get certificate details", "error", err)
 		return fmt.Errorf("failed to get certificate details: %w", err)
@@ -346,6 +557,7 @@ func (c *Client) ValidateCertificate(ctx context.Context, certID string, validat
 	// Place validation files in the webroot directory
 	c.logger.Info("Certificate validation details", "validation", certDetails.Validation)
 
+	expected := make(map[string]string)
 	if certDetails.Validation != nil && certDetails.Validation.OtherMethods != nil {
 		c.logger.Info("Found validation methods", "methods", certDetails.Validation.OtherMethods)
 
@@ -354,29 +566,16 @@ func (c *Client) ValidateCertificate(ctx context.Context, certID string, validat
 
 			if len(validation.FileValidationContent) > 0 {
 				// Combine all validation content parts (token, comodoca.com, hash)
-				var validationContent string
-				for i, content := range validation.FileValidationContent {
-					if i > 0 {
-						validationContent += "\n"
-					}
-					validationContent += content
-				}
-
-				// Extract filename from the validation URL
-				filename := filepath.Base(validation.FileValidationURLHTTP)
-				validationPath := filepath.Join(validationDir, ".well-known", "pki-validation", filename)
-
-				// Ensure directory exists
-				if err := os.MkdirAll(filepath.Dir(validationPath), 0755); err != nil {
-					return fmt.Errorf("failed to create validation directory: %w", err)
-				}
+				validationContent := c.validationContent(validation.FileValidationContent)
 
-				// Write validation file
-				if err := os.WriteFile(validationPath, []byte(validationContent), 0644); err != nil {
+				// Write the validation file to every webroot
+				validationPaths, err := webroot.Write(c.files, validationDirs, validationURLPath(validation.FileValidationURLHTTP), []byte(validationContent))
+				if err != nil {
 					return fmt.Errorf("failed to write validation file: %w", err)
 				}
 
-				c.logger.Info("Validation file created", "path", validationPath, "content", validationContent)
+				c.logger.Info("Validation file created", "paths", validationPaths, "content", validationContent)
+				expected[validation.FileValidationURLHTTP] = validationContent
 			} else {
 				c.logger.Warn("Skipping validation method", "method", method, "has_content", len(validation.FileValidationContent) > 0)
 			}
@@ -385,17 +584,26 @@ func (c *Client) ValidateCertificate(ctx context.Context, certID string, validat
 		c.logger.Warn("No validation methods found", "validation_nil", certDetails.Validation == nil, "other_methods_nil", certDetails.Validation != nil && certDetails.Validation.OtherMethods == nil)
 	}
 
+	// Confirm that the validation files are served before the CA looks for them
+	if c.selfCheck && len(expected) > 0 {
+		if err := c.checkValidationFiles(ctx, expected); err != nil {
+			return err
+		}
+	}
+
 	// First, let's try to trigger validation to get the validation details
 	c.logger.Info("Attempting to trigger domain validation", "cert_id", certID)
-	_, err = c.client.VerifyIdentifiers(ctx, certID, zerossl.HTTPVerification, []string{})
-	if err != nil {
+	_, err = c.verifyIdentifiers(ctx, certID, zerossl.HTTPVerification)
+	if errors.Is(err, ErrValidationFailed) || errors.Is(err, ErrRateLimited) {
+		return fmt.Errorf("failed to trigger domain validation: %w", err)
+	} else if err != nil {
 		c.logger.Error("Failed to trigger domain validation", "error", err)
 		// Don't return error immediately, let's check if we can get validation details
 	}
 
 	// Get updated certificate details after triggering validation
 	c.logger.Info("Getting updated certificate details", "cert_id", certID)
-	updatedCertDetails, err := c.client.GetCertificate(ctx, certID)
+	updatedCertDetails, err := c.getCertificate(ctx, certID)
 	if err != nil {
 		return fmt.Errorf("failed to get updated certificate details: %w", err)
 	}
@@ -412,31 +620,15 @@ func (c *Client) ValidateCertificate(ctx context.Context, certID string, validat
 			if len(validation.FileValidationContent) > 0 {
 				// For IP certificates, method is the IP address, not "http"
 				// Combine all validation content parts (token, comodoca.com, hash)
-				var validationContent string
-				for i, content := range validation.FileValidationContent {
-					if i > 0 {
-						validationContent += "\n"
-					}
-					validationContent += content
-				}
-
-				// Extract filename from the validation URL
-				filename := filepath.Base(validation.FileValidationURLHTTP)
-				validationPath := filepath.Join(validationDir, ".well-known", "pki-validation", filename)
-
-				c.logger.Info("Creating validation file", "path", validationPath, "content", validationContent)
-
-				// Ensure directory exists
-				if err := os.MkdirAll(filepath.Dir(validationPath), 0755); err != nil {
-					return fmt.Errorf("failed to create validation directory: %w", err)
-				}
+				validationContent := c.validationContent(validation.FileValidationContent)
 
-				// Write validation file
-				if err := os.WriteFile(validationPath, []byte(validationContent), 0644); err != nil {
+				// Write the validation file to every webroot
+				validationPaths, err := webroot.Write(c.files, validationDirs, validationURLPath(validation.FileValidationURLHTTP), []byte(validationContent))
+				if err != nil {
 					return fmt.Errorf("failed to write validation file: %w", err)
 				}
 
-				c.logger.Info("Validation file created successfully", "path", validationPath, "content", validationContent)
+				c.logger.Info("Validation file created successfully", "paths", validationPaths, "content", validationContent)
 			} else {
 				c.logger.Warn("Skipping updated validation method - no content", "method", method, "has_content", len(validation.FileValidationContent) > 0)
 			}
@@ -0,0 +1,104 @@
+package zerossl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"ipssl-client/internal/fsys"
+	"ipssl-client/internal/logger"
+)
+
+func TestFindExistingCertificatePaginates(t *testing.T) {
+	const total = 150
+	pages := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages++
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+		var results []map[string]string
+		for i := (page - 1) * limit; i < total && i < page*limit; i++ {
+			commonName := "192.0.2.2"
+			if i == total-1 {
+				commonName = "192.0.2.1"
+			}
+			results = append(results, map[string]string{"id": fmt.Sprintf("cert-%d", i), "common_name": commonName, "status": "issued"})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"total_count": total, "results": results})
+	}))
+	defer server.Close()
+
+	client, err := NewClient("test-key", server.URL, server.Client(), logger.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := client.findExistingCertificate(context.Background(), []string{"192.0.2.1"})
+	if err != nil {
+		t.Fatalf("findExistingCertificate failed: %v", err)
+	}
+	if id != fmt.Sprintf("cert-%d", total-1) {
+		t.Errorf("Expected certificate from the last page, got %q", id)
+	}
+	if pages != 2 {
+		t.Errorf("Expected 2 pages to be requested, got %d", pages)
+	}
+}
+
+func TestWaitForCertificateIssuanceTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"id": "abc", "status": "pending_validation"})
+	}))
+	defer server.Close()
+
+	client, err := NewClient("test-key", server.URL, server.Client(), logger.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetIssuancePolling(5*time.Millisecond, 50*time.Millisecond)
+
+	_, err = client.waitForCertificateIssuance(context.Background(), "abc")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected issuance to time out, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "pending_validation") {
+		t.Errorf("Expected the last status in %q", err)
+	}
+}
+
+func TestFindExistingCertificatePrefersStoredKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		results := []map[string]string{
+			{"id": "lost", "common_name": "192.0.2.1", "status": "issued"},
+			{"id": "kept", "common_name": "192.0.2.1", "status": "issued"},
+		}
+		json.NewEncoder(w).Encode(map[string]any{"total_count": len(results), "results": results})
+	}))
+	defer server.Close()
+
+	client, err := NewClient("test-key", server.URL, server.Client(), logger.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetFS(fsys.NewMem())
+	client.SetKeyDir("/ssl/keys")
+	if err := client.saveKey("kept", []byte("key")); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := client.findExistingCertificate(context.Background(), []string{"192.0.2.1"})
+	if err != nil {
+		t.Fatalf("findExistingCertificate failed: %v", err)
+	}
+	if id != "kept" {
+		t.Errorf("Expected the certificate with a stored key, got %q", id)
+	}
+}
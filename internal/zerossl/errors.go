@@ -0,0 +1,121 @@
+package zerossl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/caddyserver/zerossl"
+)
+
+// Kinds of API failures that callers handle differently: rate limits call for
+// backing off, an exhausted quota and failed validation need the operator
+var (
+	ErrRateLimited      = errors.New("ZeroSSL rate limit reached")
+	ErrQuotaExceeded    = errors.New("ZeroSSL certificate quota exceeded")
+	ErrValidationFailed = errors.New("ZeroSSL domain validation failed")
+)
+
+// APIError is a failed ZeroSSL API call. It matches ErrRateLimited,
+// ErrQuotaExceeded or ErrValidationFailed with errors.Is when the failure is of
+// that kind.
+type APIError struct {
+	Op     string
+	Status int
+	Code   int
+	Type   string
+
+	// Reason is the CA's explanation of a failed validation
+	Reason string
+
+	kind error
+	err  error
+}
+
+func (e *APIError) Error() string {
+	switch {
+	case e.kind == nil:
+		return e.err.Error()
+	case e.Reason != "":
+		return fmt.Sprintf("%v: %s", e.kind, e.Reason)
+	default:
+		return fmt.Sprintf("%v (%s: HTTP %d, API error %d: %s)", e.kind, e.Op, e.Status, e.Code, e.Type)
+	}
+}
+
+func (e *APIError) Unwrap() error { return e.err }
+
+// Is reports whether the error is of the given kind
+func (e *APIError) Is(target error) bool {
+	return e.kind != nil && target == e.kind
+}
+
+// libraryError matches the errors of the upstream library, which flattens the
+// API error into a string but keeps the raw response body
+var libraryError = regexp.MustCompile(`(?s)HTTP (\d{3}): .*\(raw=(.*) decode_error=`)
+
+// apiError converts an error returned by the upstream library for operation op
+// into an *APIError. Errors without an API response, such as network failures,
+// are returned unchanged.
+func apiError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	match := libraryError.FindStringSubmatch(err.Error())
+	if match == nil {
+		return err
+	}
+	status, _ := strconv.Atoi(match[1])
+
+	var body zerossl.APIError
+	if json.Unmarshal([]byte(match[2]), &body) != nil {
+		body = zerossl.APIError{}
+	}
+	return newAPIError(op, status, body, err)
+}
+
+// newAPIError classifies a decoded API error response
+func newAPIError(op string, status int, body zerossl.APIError, err error) *APIError {
+	e := &APIError{
+		Op:     op,
+		Status: status,
+		Code:   body.ErrorInfo.Code,
+		Type:   body.ErrorInfo.Type,
+		err:    err,
+	}
+
+	errorType := strings.ToLower(e.Type)
+	switch {
+	case status == 429 || strings.Contains(errorType, "rate_limit") || strings.Contains(errorType, "too_many_requests"):
+		e.kind = ErrRateLimited
+	case strings.Contains(errorType, "limit_reached") || strings.Contains(errorType, "quota"):
+		e.kind = ErrQuotaExceeded
+	case len(body.ErrorInfo.Details) > 0 || errorType == "domain_control_validation_failed":
+		e.kind = ErrValidationFailed
+		e.Reason = validationReason(body)
+	}
+	return e
+}
+
+// validationReason summarises the per-identifier details of a failed validation
+func validationReason(body zerossl.APIError) string {
+	var reasons []string
+	for identifier, methods := range body.ErrorInfo.Details {
+		for url, detail := range methods {
+			switch {
+			case detail.ErrorInfo != "":
+				reasons = append(reasons, fmt.Sprintf("%s: %s", identifier, detail.ErrorInfo))
+			case detail.ErrorSlug != "":
+				reasons = append(reasons, fmt.Sprintf("%s: %s", identifier, detail.ErrorSlug))
+			case detail.FileFound == 0 && strings.HasPrefix(url, "http"):
+				reasons = append(reasons, fmt.Sprintf("%s: validation file not found at %s", identifier, url))
+			}
+		}
+	}
+	sort.Strings(reasons)
+	return strings.Join(reasons, "; ")
+}
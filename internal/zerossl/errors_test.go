@@ -0,0 +1,59 @@
+package zerossl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ipssl-client/internal/logger"
+)
+
+func TestAPIErrorKinds(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"rate limited", http.StatusTooManyRequests, `{"success": false, "error": {"code": 0, "type": "too_many_requests"}}`, ErrRateLimited},
+		{"quota", http.StatusOK, `{"success": false, "error": {"code": 2817, "type": "certificate_limit_reached"}}`, ErrQuotaExceeded},
+		{"validation", http.StatusOK, `{"success": false, "error": {"code": 0, "type": "domain_control_validation_failed", "details": {"192.0.2.1": {"http://192.0.2.1/.well-known/pki-validation/ABC.txt": {"file_found": 0, "error": true, "error_slug": "invalid_file_content", "error_info": "File content does not match"}}}}}`, ErrValidationFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client, err := NewClient("test-key", server.URL, server.Client(), logger.New())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = client.client.GetCertificate(context.Background(), "abc")
+			err = apiError("get certificate", err)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, err)
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.Status != tt.status {
+				t.Errorf("Expected *APIError with status %d, got %#v", tt.status, err)
+			}
+			if tt.want == ErrValidationFailed && !strings.Contains(err.Error(), "File content does not match") {
+				t.Errorf("Expected the CA's failure reason in %q", err)
+			}
+		})
+	}
+}
+
+func TestAPIErrorUnclassified(t *testing.T) {
+	err := errors.New("dial tcp: connection refused")
+	if got := apiError("get certificate", err); got != err {
+		t.Errorf("Expected errors without an API response to be returned unchanged, got %v", got)
+	}
+}
@@ -0,0 +1,99 @@
+package zerossl
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ipssl-client/internal/fsys"
+)
+
+// defaultKeyDir is where private keys are kept unless configured
+const defaultKeyDir = "/ipssl/keys"
+
+// Errors returned when no persisted private key matches an issued certificate.
+// Such a certificate cannot be served and has to be reissued.
+var (
+	ErrKeyNotFound = errors.New("private key not found")
+	ErrKeyMismatch = errors.New("private key does not match the certificate")
+)
+
+// SetKeyDir sets the directory private keys are persisted in between creating
+// a certificate request and downloading the issued certificate
+func (c *Client) SetKeyDir(dir string) {
+	if dir != "" {
+		c.keyDir = dir
+	}
+}
+
+// keyPath returns the file holding a private key. Keys are saved under the
+// primary identifier when the CSR is created, before ZeroSSL assigns an ID,
+// and under the certificate ID once the request exists.
+func (c *Client) keyPath(name string) string {
+	return filepath.Join(c.keyDir, strings.ReplaceAll(name, ":", "_")+".key")
+}
+
+// saveKey persists a PEM-encoded private key under name
+func (c *Client) saveKey(name string, keyPEM []byte) error {
+	if err := c.files.MkdirAll(c.keyDir, 0700); err != nil {
+		return fmt.Errorf("failed to create key directory: %w", err)
+	}
+	if err := fsys.WriteFileAtomic(c.files, c.keyPath(name), keyPEM, 0600); err != nil {
+		return fmt.Errorf("failed to save private key: %w", err)
+	}
+	return nil
+}
+
+// hasKey reports whether a private key may exist for the certificate request
+func (c *Client) hasKey(certID, primary string) bool {
+	for _, name := range []string{certID, primary} {
+		if _, err := c.files.Stat(c.keyPath(name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// loadKey returns the persisted private key matching the leaf of chainPEM,
+// looking under the certificate ID and then under the primary identifier for
+// a key saved before the request was created
+func (c *Client) loadKey(certID, primary string, chainPEM []byte) ([]byte, error) {
+	mismatched := false
+	for _, name := range []string{certID, primary} {
+		path := c.keyPath(name)
+		keyPEM, err := c.files.ReadFile(path)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				c.logger.Warn("Failed to read private key", "path", path, "error", err)
+			}
+			continue
+		}
+		if _, err := tls.X509KeyPair(chainPEM, keyPEM); err != nil {
+			c.logger.Warn("Persisted private key does not match the certificate", "path", path, "cert_id", certID, "error", err)
+			mismatched = true
+			continue
+		}
+
+		if name != certID {
+			if err := c.saveKey(certID, keyPEM); err == nil {
+				c.files.Remove(path)
+			}
+		}
+		c.logger.Info("Loaded private key", "path", path, "cert_id", certID)
+		return keyPEM, nil
+	}
+	if mismatched {
+		return nil, fmt.Errorf("%w %s; the certificate has to be reissued", ErrKeyMismatch, certID)
+	}
+	return nil, fmt.Errorf("%w for certificate %s in %s; the certificate has to be reissued", ErrKeyNotFound, certID, c.keyDir)
+}
+
+// removeKey deletes the private key of a certificate that is no longer used
+func (c *Client) removeKey(certID string) {
+	if err := c.files.Remove(c.keyPath(certID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		c.logger.Warn("Failed to remove private key", "cert_id", certID, "error", err)
+	}
+}
@@ -0,0 +1,89 @@
+package zerossl
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"ipssl-client/internal/fsys"
+	"ipssl-client/internal/keys"
+	"ipssl-client/internal/logger"
+)
+
+// selfSigned returns a PEM certificate and key
+func selfSigned(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	signer, err := keys.Generate(keys.ECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, signer.Public(), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM, err := keys.EncodePEM(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), keyPEM
+}
+
+func TestLoadKey(t *testing.T) {
+	client, err := NewClient("test-key", "", nil, logger.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem := fsys.NewMem()
+	client.SetFS(mem)
+	client.SetKeyDir("/ssl/keys")
+
+	cert, key := selfSigned(t)
+	if err := client.saveKey("2001:db8::1", key); err != nil {
+		t.Fatal(err)
+	}
+	if !client.hasKey("abc", "2001:db8::1") {
+		t.Fatal("Expected the key saved under the identifier to be found")
+	}
+
+	got, err := client.loadKey("abc", "2001:db8::1", cert)
+	if err != nil {
+		t.Fatalf("loadKey failed: %v", err)
+	}
+	if string(got) != string(key) {
+		t.Error("Expected the saved key")
+	}
+	if _, err := mem.Stat("/ssl/keys/abc.key"); err != nil {
+		t.Errorf("Expected the key to be stored under the certificate ID: %v", err)
+	}
+	if _, err := mem.Stat("/ssl/keys/2001_db8__1.key"); err == nil {
+		t.Error("Expected the key saved under the identifier to be removed")
+	}
+}
+
+func TestLoadKeyMissing(t *testing.T) {
+	client, err := NewClient("test-key", "", nil, logger.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetFS(fsys.NewMem())
+	client.SetKeyDir("/ssl/keys")
+
+	cert, _ := selfSigned(t)
+	if _, err := client.loadKey("abc", "192.0.2.1", cert); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound without a saved key, got %v", err)
+	}
+
+	// A key from another request must not be paired with the certificate
+	_, other := selfSigned(t)
+	if err := client.saveKey("abc", other); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.loadKey("abc", "192.0.2.1", cert); !errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("Expected ErrKeyMismatch for a mismatched key, got %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package zerossl
+
+import (
+	"context"
+	"time"
+)
+
+// VerifyQueue limits how many certificates of the account are validated at
+// once, across every client sharing it
+type VerifyQueue interface {
+	// Acquire waits for a validation slot for the certificate and returns the
+	// function that releases it
+	Acquire(ctx context.Context, certID string) (func(), error)
+}
+
+// SetVerifyQueue makes validation wait for a slot of queue, held from
+// triggering validation until the certificate is issued. nil validates at once.
+func (c *Client) SetVerifyQueue(queue VerifyQueue) {
+	c.verifyQueue = queue
+}
+
+// acquireValidationSlot waits for a slot of the verify queue, if configured
+func (c *Client) acquireValidationSlot(ctx context.Context, certID string) (func(), error) {
+	if c.verifyQueue == nil {
+		return func() {}, nil
+	}
+	c.logger.Info("Waiting for a validation slot on the account", "cert_id", certID)
+	start := time.Now()
+	release, err := c.verifyQueue.Acquire(ctx, certID)
+	if err != nil {
+		return nil, err
+	}
+	c.logger.Info("Validation slot acquired", "cert_id", certID, "waited", time.Since(start).Round(time.Second))
+	return release, nil
+}
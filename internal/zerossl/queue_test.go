@@ -0,0 +1,60 @@
+package zerossl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ipssl-client/internal/keys"
+	"ipssl-client/internal/logger"
+)
+
+type blockedQueue struct {
+	requested []string
+}
+
+func (q *blockedQueue) Acquire(ctx context.Context, certID string) (func(), error) {
+	q.requested = append(q.requested, certID)
+	return nil, context.DeadlineExceeded
+}
+
+func TestRequestCertificateWaitsForValidationSlot(t *testing.T) {
+	verified := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/certificates":
+			json.NewEncoder(w).Encode(map[string]any{"total_count": 0, "results": []any{}})
+		case r.Method == http.MethodPost && r.URL.Path == "/certificates":
+			json.NewEncoder(w).Encode(map[string]string{"id": "abc", "status": "draft"})
+		case strings.HasSuffix(r.URL.Path, "/challenges"):
+			verified = true
+			http.Error(w, "unexpected", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient("test-key", server.URL, server.Client(), logger.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetKeyDir(t.TempDir())
+	queue := &blockedQueue{}
+	client.SetVerifyQueue(queue)
+
+	_, _, err = client.RequestCertificate(context.Background(), []string{"192.0.2.1"}, keys.ECDSAP256)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the queue error, got %v", err)
+	}
+	if len(queue.requested) != 1 || queue.requested[0] != "abc" {
+		t.Errorf("Expected a slot to be requested for abc, got %v", queue.requested)
+	}
+	if verified {
+		t.Error("Expected validation not to be triggered without a slot")
+	}
+}
@@ -0,0 +1,71 @@
+package zerossl
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/caddyserver/zerossl"
+)
+
+// Usage summarises the certificates on the account that count against the plan quota
+type Usage struct {
+	Issued  int `json:"issued"`
+	Pending int `json:"pending"`
+
+	// Limit is the configured plan quota; 0 means unknown
+	Limit int `json:"limit"`
+}
+
+// Used returns the number of certificates counting against the quota
+func (u Usage) Used() int {
+	return u.Issued + u.Pending
+}
+
+// Exhausted reports whether no further certificate can be created
+func (u Usage) Exhausted() bool {
+	return u.Limit > 0 && u.Used() >= u.Limit
+}
+
+// SetQuota sets the number of certificates the account plan allows. The API
+// does not expose the plan, so the limit must be configured; 0 disables the
+// check before issuance.
+func (c *Client) SetQuota(limit int) {
+	c.quota = limit
+}
+
+// Usage counts the issued and unfinished certificates on the account
+func (c *Client) Usage(ctx context.Context) (Usage, error) {
+	certificates, err := c.listCertificates(ctx, zerossl.ListCertificatesParameters{
+		Status: "draft,pending_validation,issued",
+	})
+	if err != nil {
+		return Usage{}, err
+	}
+
+	usage := Usage{Limit: c.quota}
+	for _, cert := range certificates {
+		if cert.Status == "issued" {
+			usage.Issued++
+		} else {
+			usage.Pending++
+		}
+	}
+	return usage, nil
+}
+
+// checkQuota logs the account usage and fails with ErrQuotaExceeded when a new
+// certificate cannot be created. Failing to query the usage is not fatal.
+func (c *Client) checkQuota(ctx context.Context) error {
+	usage, err := c.Usage(ctx)
+	if err != nil {
+		c.logger.Warn("Failed to query ZeroSSL account usage", "error", err)
+		return nil
+	}
+	c.logger.Info("ZeroSSL account usage", "issued", usage.Issued, "pending", usage.Pending, "limit", usage.Limit)
+
+	if usage.Exhausted() {
+		return fmt.Errorf("%w: %d of %d certificates in use (%d issued, %d pending); cancel or delete unused certificates or raise IPSSL_CERT_QUOTA",
+			ErrQuotaExceeded, usage.Used(), usage.Limit, usage.Issued, usage.Pending)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package zerossl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ipssl-client/internal/keys"
+	"ipssl-client/internal/logger"
+)
+
+func TestRequestCertificateRefusedWhenQuotaExhausted(t *testing.T) {
+	created := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/certificates":
+			json.NewEncoder(w).Encode(map[string]any{"total_count": 3, "results": []map[string]string{
+				{"id": "a", "common_name": "192.0.2.2", "status": "issued"},
+				{"id": "b", "common_name": "192.0.2.3", "status": "issued"},
+				{"id": "c", "common_name": "192.0.2.4", "status": "draft"},
+			}})
+		case r.Method == http.MethodPost && r.URL.Path == "/certificates":
+			created = true
+			http.Error(w, "unexpected", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient("test-key", server.URL, server.Client(), logger.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := client.Usage(context.Background())
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	if usage.Issued != 2 || usage.Pending != 1 || usage.Exhausted() {
+		t.Errorf("Unexpected usage without a quota: %+v", usage)
+	}
+
+	client.SetQuota(3)
+	_, _, err = client.RequestCertificate(context.Background(), []string{"192.0.2.1"}, keys.RSA2048)
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Expected ErrQuotaExceeded, got %v", err)
+	}
+	if created {
+		t.Error("Expected no certificate to be created once the quota is exhausted")
+	}
+}
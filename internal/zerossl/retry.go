@@ -0,0 +1,121 @@
+package zerossl
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"github.com/caddyserver/zerossl"
+)
+
+// RetryPolicy controls how failed API calls are retried
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts per call; values below 2 disable retries
+	MaxAttempts int
+
+	// BaseDelay is the upper bound of the first backoff, doubled for every further attempt
+	BaseDelay time.Duration
+
+	// MaxDelay caps the backoff between attempts
+	MaxDelay time.Duration
+}
+
+// SetRetryPolicy enables retrying API calls that fail with a server error or a
+// network error, with jittered exponential backoff between attempts
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
+	c.retryPolicy = policy
+}
+
+// call runs an API call for operation op, converting its error with apiError
+// and retrying transient failures according to the retry policy
+func (c *Client) call(ctx context.Context, op string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := apiError(op, fn())
+		if err == nil || attempt >= c.retryPolicy.MaxAttempts || !transient(err) || ctx.Err() != nil {
+			return err
+		}
+
+		delay := c.retryPolicy.backoff(attempt)
+		c.logger.Warn("ZeroSSL API call failed, retrying", "op", op, "attempt", attempt, "max_attempts", c.retryPolicy.MaxAttempts, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// backoff returns the pause after the given failed attempt: a random duration
+// up to BaseDelay doubled per attempt, capped at MaxDelay ("full jitter")
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.BaseDelay << (attempt - 1)
+	if ceiling <= 0 || (p.MaxDelay > 0 && ceiling > p.MaxDelay) {
+		ceiling = p.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling) + 1
+}
+
+// transient reports whether a failed call may succeed when retried: server
+// errors and network failures are, rejected requests and rate limits are not
+func transient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.kind == nil && apiErr.Status >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// getCertificate fetches a certificate object
+func (c *Client) getCertificate(ctx context.Context, certID string) (certificate zerossl.CertificateObject, err error) {
+	err = c.call(ctx, "get certificate", func() (err error) {
+		certificate, err = c.client.GetCertificate(ctx, certID)
+		return err
+	})
+	return certificate, err
+}
+
+// createCertificate submits a certificate request for the CSR
+func (c *Client) createCertificate(ctx context.Context, csr *x509.CertificateRequest, validityDays int) (certificate zerossl.CertificateObject, err error) {
+	err = c.call(ctx, "create certificate", func() (err error) {
+		certificate, err = c.client.CreateCertificate(ctx, csr, validityDays)
+		return err
+	})
+	return certificate, err
+}
+
+// verifyIdentifiers triggers validation of a certificate's identifiers
+func (c *Client) verifyIdentifiers(ctx context.Context, certID string, method zerossl.VerificationMethod) (certificate zerossl.CertificateObject, err error) {
+	err = c.call(ctx, "verify identifiers", func() (err error) {
+		certificate, err = c.client.VerifyIdentifiers(ctx, certID, method, []string{})
+		return err
+	})
+	return certificate, err
+}
+
+// downloadCertificate downloads an issued certificate and its chain
+func (c *Client) downloadCertificate(ctx context.Context, certID string) (bundle zerossl.CertificateBundle, err error) {
+	err = c.call(ctx, "download certificate", func() (err error) {
+		bundle, err = c.client.DownloadCertificate(ctx, certID, true)
+		return err
+	})
+	return bundle, err
+}
+
+// listCertificatesPage fetches one page of a certificate listing
+func (c *Client) listCertificatesPage(ctx context.Context, params zerossl.ListCertificatesParameters) (list zerossl.CertificateList, err error) {
+	err = c.call(ctx, "list certificates", func() (err error) {
+		list, err = c.client.ListCertificates(ctx, params)
+		return err
+	})
+	return list, err
+}
@@ -0,0 +1,69 @@
+package zerossl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ipssl-client/internal/logger"
+)
+
+func TestGetCertificateRetriesServerErrors(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`{"success": false, "error": {"code": 0, "type": "internal_error"}}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id": "abc", "status": "issued"})
+	}))
+	defer server.Close()
+
+	client, err := NewClient("test-key", server.URL, server.Client(), logger.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond})
+
+	cert, err := client.getCertificate(context.Background(), "abc")
+	if err != nil {
+		t.Fatalf("Expected the third attempt to succeed, got %v", err)
+	}
+	if cert.ID != "abc" || requests != 3 {
+		t.Errorf("Expected certificate abc after 3 requests, got %q after %d", cert.ID, requests)
+	}
+}
+
+func TestCallDoesNotRetryRejectedRequests(t *testing.T) {
+	client, err := NewClient("test-key", "", nil, logger.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetRetryPolicy(RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond})
+
+	attempts := 0
+	rejected := &APIError{Op: "create certificate", Status: http.StatusOK, kind: ErrQuotaExceeded, err: errors.New("limit reached")}
+	err = client.call(context.Background(), "create certificate", func() error {
+		attempts++
+		return rejected
+	})
+	if !errors.Is(err, ErrQuotaExceeded) || attempts != 1 {
+		t.Errorf("Expected a single attempt returning ErrQuotaExceeded, got %d attempts and %v", attempts, err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 3 * time.Second}
+	for attempt := 1; attempt <= 5; attempt++ {
+		ceiling := min(time.Second<<(attempt-1), 3*time.Second)
+		if d := policy.backoff(attempt); d <= 0 || d > ceiling {
+			t.Errorf("backoff(%d) = %s, want within (0, %s]", attempt, d, ceiling)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package zerossl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// selfCheckTimeout bounds each validation URL fetch of the self-check
+const selfCheckTimeout = 10 * time.Second
+
+// SetSelfCheck enables fetching every validation URL before asking the CA to
+// validate, so that an unreachable or misplaced validation file is reported
+// directly instead of as an opaque validation failure
+func (c *Client) SetSelfCheck(enabled bool) {
+	c.selfCheck = enabled
+}
+
+// checkValidationFiles fetches each validation URL the way the CA will and
+// confirms that the expected content is served. expected maps URLs to content.
+func (c *Client) checkValidationFiles(ctx context.Context, expected map[string]string) error {
+	client := &http.Client{Timeout: selfCheckTimeout}
+
+	urls := make([]string, 0, len(expected))
+	for url := range expected {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+
+	for _, url := range urls {
+		if err := fetchValidationFile(ctx, client, url, expected[url]); err != nil {
+			return fmt.Errorf("validation self-check of %s failed: %s (disable with IPSSL_VALIDATION_SELF_CHECK=false if this host cannot reach its own address): %w",
+				url, err, ErrValidationFailed)
+		}
+		c.logger.Info("Validation file served correctly", "url", url)
+	}
+	return nil
+}
+
+// fetchValidationFile fetches url and compares the body with content
+func fetchValidationFile(ctx context.Context, client *http.Client, url, content string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("not reachable, is port 80 open and forwarded to the web server? (%v)", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d, does IPSSL_VALIDATION_DIR match the web server's document root?", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %v", err)
+	}
+	if normalizeContent(string(body)) != normalizeContent(content) {
+		return fmt.Errorf("unexpected content, is another web server or a stale file answering?")
+	}
+	return nil
+}
+
+// normalizeContent ignores line ending and trailing whitespace differences
+func normalizeContent(content string) string {
+	return strings.TrimSpace(strings.ReplaceAll(content, "\r\n", "\n"))
+}
@@ -0,0 +1,58 @@
+package zerossl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ipssl-client/internal/logger"
+)
+
+func TestCheckValidationFiles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/pki-validation/ABC.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "hash\r\ncomodoca.com\r\ntoken\n")
+	}))
+	defer server.Close()
+
+	client, err := NewClient("test-key", server.URL, server.Client(), logger.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := server.URL + "/.well-known/pki-validation/ABC.txt"
+	tests := []struct {
+		name     string
+		expected map[string]string
+		wantErr  string
+	}{
+		{"served", map[string]string{valid: "hash\ncomodoca.com\ntoken"}, ""},
+		{"wrong content", map[string]string{valid: "other"}, "unexpected content"},
+		{"missing file", map[string]string{server.URL + "/.well-known/pki-validation/XYZ.txt": "x"}, "HTTP 404"},
+		{"unreachable", map[string]string{"http://127.0.0.1:1/.well-known/pki-validation/ABC.txt": "x"}, "not reachable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := client.checkValidationFiles(context.Background(), tt.expected)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Expected self-check to pass, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+			if !errors.Is(err, ErrValidationFailed) {
+				t.Errorf("Expected ErrValidationFailed, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package zerossl
+
+import (
+	"slices"
+	"strings"
+)
+
+// SetValidationFormat sets how the parts of a validation file are joined: eol
+// separates them, and canonical puts them in the order hash, CA domain, unique
+// value regardless of the order the API lists them in
+func (c *Client) SetValidationFormat(eol string, canonical bool) {
+	if eol != "" {
+		c.validationEOL = eol
+	}
+	c.canonicalOrder = canonical
+}
+
+// validationContent builds the content of a validation file from the parts
+// returned by the API. Surrounding whitespace and line endings within parts
+// are dropped, since a stray character is enough for the CA to reject the file.
+func (c *Client) validationContent(parts []string) string {
+	cleaned := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			cleaned = append(cleaned, part)
+		}
+	}
+	if c.canonicalOrder {
+		slices.SortStableFunc(cleaned, func(a, b string) int { return partRank(a) - partRank(b) })
+	}
+	return strings.Join(cleaned, c.validationEOL)
+}
+
+// partRank orders validation file parts: the hex digest of the CSR, the CA
+// domain and then anything else, such as the unique value
+func partRank(part string) int {
+	switch {
+	case isHexDigest(part):
+		return 0
+	case strings.Contains(part, "."):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// isHexDigest reports whether s looks like a SHA-256 or longer hex digest
+func isHexDigest(s string) bool {
+	if len(s) < 64 {
+		return false
+	}
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package zerossl
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ipssl-client/internal/logger"
+
+	"github.com/caddyserver/zerossl"
+)
+
+func TestValidationContent(t *testing.T) {
+	const (
+		hashIP    = "5E2B0C0F8A3D6E9B1C4F7A2D5E8B1C4F7A2D5E8B1C4F7A2D5E8B1C4F7A2D5E8B"
+		hashMulti = "0A1B2C3D4E5F60718293A4B5C6D7E8F90A1B2C3D4E5F60718293A4B5C6D7E8F9"
+	)
+
+	tests := []struct {
+		file      string
+		eol       string
+		canonical bool
+		want      map[string]string
+	}{
+		{"validation-ip.json", "\n", false, map[string]string{
+			"192.0.2.1": hashIP + "\ncomodoca.com\nf1e2d3c4b5a6978",
+		}},
+		{"validation-ip.json", "\r\n", false, map[string]string{
+			"192.0.2.1": hashIP + "\r\ncomodoca.com\r\nf1e2d3c4b5a6978",
+		}},
+		{"validation-multi.json", "\n", false, map[string]string{
+			"198.51.100.7":    "9a8b7c6d5e4f321\nsectigo.com\n" + hashMulti,
+			"www.example.com": hashMulti + "\nsectigo.com\n9a8b7c6d5e4f321",
+		}},
+		{"validation-multi.json", "\n", true, map[string]string{
+			"198.51.100.7":    hashMulti + "\nsectigo.com\n9a8b7c6d5e4f321",
+			"www.example.com": hashMulti + "\nsectigo.com\n9a8b7c6d5e4f321",
+		}},
+	}
+	for _, tt := range tests {
+		data, err := os.ReadFile(filepath.Join("testdata", tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cert zerossl.CertificateObject
+		if err := json.Unmarshal(data, &cert); err != nil {
+			t.Fatalf("Failed to decode %s: %v", tt.file, err)
+		}
+
+		client, err := NewClient("test-key", "", nil, logger.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		client.SetValidationFormat(tt.eol, tt.canonical)
+
+		for method, want := range tt.want {
+			got := client.validationContent(cert.Validation.OtherMethods[method].FileValidationContent)
+			if got != want {
+				t.Errorf("%s %s (eol %q, canonical %v): expected %q, got %q", tt.file, method, tt.eol, tt.canonical, want, got)
+			}
+		}
+	}
+}
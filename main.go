@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"sort"
 	"syscall"
 
 	"ipssl-client/internal/config"
@@ -23,12 +24,90 @@ func main() {
 	// Initialize logger
 	logger := logger.New()
 
+	// Secrets may be kept in a sops-encrypted dotenv file committed to Git
+	if path := os.Getenv("IPSSL_SOPS_FILE"); path != "" {
+		loaded, err := config.LoadEncryptedEnv(path)
+		if err != nil {
+			logger.Fatal("Failed to load encrypted configuration", "error", err)
+		}
+		sort.Strings(loaded)
+		logger.Info("Encrypted configuration loaded", "file", path, "variables", loaded)
+	}
+
+	// The dashboard command needs no configuration
+	if len(os.Args) > 1 && os.Args[1] == "dashboard" {
+		if err := runDashboard(os.Args[2:]); err != nil {
+			logger.Fatal("Failed to generate dashboard", "error", err)
+		}
+		return
+	}
+
+	// The config command checks configurations that may not load
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err := runConfig(os.Args[2:]); err != nil {
+			logger.Fatal("Configuration check failed", "error", err)
+		}
+		return
+	}
+
+	// The support-bundle command also reports configurations that do not load
+	if len(os.Args) > 1 && os.Args[1] == "support-bundle" {
+		if err := runSupportBundle(os.Args[2:]); err != nil {
+			logger.Fatal("Failed to create support bundle", "error", err)
+		}
+		return
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
 		logger.Fatal("Failed to load configuration", "error", err)
 	}
 
+	// Secrets are kept in the configuration only, out of reach of child processes
+	if cfg.ScrubEnv {
+		scrubbed, err := config.ScrubEnv()
+		if err != nil {
+			logger.Fatal("Failed to scrub secrets from the environment", "error", err)
+		}
+		logger.Info("Secrets removed from the environment", "variables", scrubbed)
+	}
+
+	// Subcommands run once and exit
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		if err := runReport(cfg, os.Args[2:]); err != nil {
+			logger.Fatal("Failed to produce report", "error", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && (os.Args[1] == "freeze" || os.Args[1] == "unfreeze") {
+		run := runFreeze
+		if os.Args[1] == "unfreeze" {
+			run = runUnfreeze
+		}
+		if err := run(cfg, os.Args[2:]); err != nil {
+			logger.Fatal("Failed to update renewal freeze", "error", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "history" {
+		if err := runHistory(cfg, os.Args[2:]); err != nil {
+			logger.Fatal("Failed to prune history", "error", err)
+		}
+		return
+	}
+
+	// Several certificate sets are managed side by side, each by its own client
+	if len(cfg.Certificates) > 0 {
+		if len(os.Args) > 1 && os.Args[1] == "revoke" {
+			logger.Fatal("Failed to revoke certificate", "error", "revoke is not supported with IPSSL_CERTIFICATES")
+		}
+		runManager(cfg, logger)
+		return
+	}
+
 	// Create IPSSL client
 	client, err := ipssl.NewClient(cfg, logger)
 	if err != nil {
@@ -39,6 +118,13 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	if len(os.Args) > 1 && os.Args[1] == "revoke" {
+		if err := client.RevokeCertificate(ctx); err != nil {
+			logger.Fatal("Failed to revoke certificate", "error", err)
+		}
+		return
+	}
+
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -50,8 +136,27 @@ func main() {
 	}()
 
 	// Start the IPSSL client
-	logger.Info("Starting IPSSL client", "client_ip", cfg.ClientIP)
+	logger.Info("Starting IPSSL client", "primary", cfg.Primary(), "identifiers", cfg.Identifiers())
 	if err := client.Start(ctx); err != nil {
 		logger.Fatal("IPSSL client failed", "error", err)
 	}
 }
+
+// runManager runs the clients of all certificate sets until a shutdown signal
+func runManager(cfg *config.Config, logger *logger.Logger) {
+	manager, err := ipssl.NewManager(cfg, logger)
+	if err != nil {
+		logger.Fatal("Failed to create IPSSL clients", "error", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	names := make([]string, 0, len(cfg.Certificates))
+	for _, set := range cfg.Certificates {
+		names = append(names, set.Name)
+	}
+	logger.Info("Starting IPSSL clients", "certificates", names)
+	manager.Start(ctx)
+	logger.Info("Received shutdown signal, stopped")
+}